@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/rand"
+	"os"
+	"time"
+
+	"notes/internal/app"
+	"notes/internal/share"
+	"notes/internal/user"
+)
+
+// tokenTtl is how long the tokens of the users are valid
+const tokenTtl = 24 * time.Hour
+
+// sessionTtl is how long a browser stays logged in
+const sessionTtl = 7 * 24 * time.Hour
+
+// newAccounts keeps the users, the shares of their notes, the sessions
+// of their browsers and their API tokens next to the json storage, a random secret
+// signs their tokens when none is configured
+func newAccounts(config Config) ([]app.Option, error) {
+	usersPath, sharesPath, sessionsPath, apiTokensPath := "", "", "", ""
+	if config.storage == "json" {
+		usersPath = config.storagePath + ".users"
+		sharesPath = config.storagePath + ".shares"
+		sessionsPath = config.storagePath + ".sessions"
+		apiTokensPath = config.storagePath + ".tokens"
+	}
+	users, err := user.NewStore(usersPath)
+	if err != nil {
+		return nil, err
+	}
+	shares, err := share.NewStore(sharesPath)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := user.NewSessions(sessionsPath, sessionTtl)
+	if err != nil {
+		return nil, err
+	}
+	apiTokens, err := user.NewApiTokens(apiTokensPath)
+	if err != nil {
+		return nil, err
+	}
+	secret := []byte(config.tokenSecret)
+	if env, ok := os.LookupEnv("NOTES_TOKEN_SECRET"); ok {
+		secret = []byte(env)
+	}
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		_, err = rand.Read(secret)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return []app.Option{
+		app.WithAccounts(users, user.NewTokens(secret, tokenTtl), shares),
+		app.WithSessions(sessions),
+		app.WithApiTokens(apiTokens),
+	}, nil
+}
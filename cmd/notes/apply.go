@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"notes/internal/remote"
+)
+
+func init() {
+	subcommands["apply"] = runApply
+}
+
+// runApply makes the notes of a notes server match a manifest, a yaml
+// or json file of notes kept with the code they document, see
+// remote.Manifest
+// The changes are printed as a diff before they are made, --plan or
+// -dry-run only prints them. The url of the server defaults to the
+// remote of the configuration.
+func runApply(config Config, args []string) {
+	file, plan, url := "", config.dryRun, config.remote
+	urls := []string{}
+	usage := false
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "-f" && i+1 < len(args):
+			i++
+			file = args[i]
+		case args[i] == "--plan":
+			plan = true
+		case strings.HasPrefix(args[i], "-"):
+			usage = true
+		default:
+			urls = append(urls, args[i])
+		}
+	}
+	if len(urls) == 1 {
+		url = urls[0]
+	}
+	if usage || file == "" || url == "" || len(urls) > 1 {
+		fmt.Fprintln(os.Stderr, "usage: apply -f FILE [--plan] [URL]")
+		os.Exit(2)
+	}
+	data, err := os.ReadFile(file)
+	exitOnError(err)
+	m, err := remote.ParseManifest(data)
+	exitOnError(err)
+	c, err := newRemote(config, url)
+	exitOnError(err)
+	changes, err := remote.Plan(c, m)
+	exitOnError(err)
+	if len(changes) == 0 {
+		fmt.Println("No changes, the notes match the manifest")
+		return
+	}
+	counts := map[remote.ChangeKind]int{}
+	for _, change := range changes {
+		counts[change.Kind]++
+		printChange(change)
+	}
+	fmt.Printf("Plan: %d to create, %d to update, %d to delete\n", counts[remote.Create], counts[remote.Update], counts[remote.Delete])
+	if plan {
+		return
+	}
+	exitOnError(remote.Apply(c, changes))
+	fmt.Printf("Applied %d changes\n", len(changes))
+}
+
+// printChange prints a change and the lines of the content it adds or
+// removes
+func printChange(change remote.Change) {
+	switch change.Kind {
+	case remote.Create:
+		fmt.Printf("+ create %s/%s\n", change.Want.Notebook, change.Want.Name)
+	case remote.Update:
+		fmt.Printf("~ update %s/%s (note %d)\n", change.Note.Notebook, change.Note.Name, change.Note.Id)
+		if change.Moved() {
+			fmt.Printf("    moved to %s\n", change.Want.Notebook)
+		}
+	case remote.Delete:
+		fmt.Printf("- delete %s/%s (note %d)\n", change.Note.Notebook, change.Note.Name, change.Note.Id)
+	}
+	for _, l := range change.Lines {
+		if l.Kind != " " {
+			fmt.Printf("    %s%s\n", l.Kind, l.Text)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"notes/internal/retention"
+	"notes/internal/usecase"
+)
+
+func init() {
+	subcommands["archive"] = runArchive
+}
+
+// archivePolicies reads the retention policies of the config, they
+// need the audit log file to tell when the notes last changed
+func archivePolicies(config Config) ([]usecase.Policy, error) {
+	policies := []usecase.Policy{}
+	for _, p := range config.archive {
+		policy, err := retention.ParsePolicy(p)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	if len(policies) > 0 && config.auditPath == "" {
+		return nil, fmt.Errorf("archiving needs the audit log file, which tells when the notes last changed")
+	}
+	return policies, nil
+}
+
+// runArchive reports the notes the retention policies archive, or
+// archives them right away
+func runArchive(config Config, args []string) {
+	if len(args) != 1 || (args[0] != "report" && args[0] != "run") {
+		fmt.Fprintln(os.Stderr, "usage: archive report|run")
+		os.Exit(2)
+	}
+	policies, err := archivePolicies(config)
+	exitOnError(err)
+	if len(policies) == 0 {
+		exitOnError(fmt.Errorf("no retention policy, see -archive"))
+	}
+	u, err := newUsecase(config)
+	exitOnError(err)
+	result, err := u.Archive.Execute(usecase.ArchiveMessage{
+		Context:  usecase.Context{DryRun: args[0] == "report" || config.dryRun, Actor: "archiver"},
+		Policies: policies,
+	})
+	exitOnError(err)
+	_, err = u.Save.Execute(usecase.SaveMessage{})
+	exitOnError(err)
+	verb := "Archived"
+	if result.DryRun {
+		verb = "Would archive"
+	}
+	for _, a := range result.Archived {
+		fmt.Printf("%s %d %s from %s to %s, untouched since %s\n", verb, a.Note.Id, a.Note.Name, a.From, a.Note.Notebook, a.Touched.Format("2006-01-02"))
+	}
+	fmt.Printf("%s %d notes\n", verb, len(result.Archived))
+}
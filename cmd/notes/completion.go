@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"notes/internal/audit"
+	"notes/internal/command"
+	"notes/internal/usecase"
+)
+
+// Subcommands
+// They run in place of an application, names starting with "__" are
+// hidden from completion
+var subcommands = map[string]func(Config, []string){}
+
+func init() {
+	subcommands["completion"] = runCompletion
+	subcommands["__complete"] = runComplete
+}
+
+var completionScripts = map[string]string{
+	"bash": `_%[1]s_complete() {
+    local IFS=$'\n'
+    COMPREPLY=( $(%[1]s __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null | cut -f1) )
+}
+complete -o default -F _%[1]s_complete %[1]s
+`,
+	"zsh": `#compdef %[1]s
+_%[1]s() {
+    local -a candidates
+    candidates=("${(@f)$(%[1]s __complete "${(@)words[2,CURRENT]}" 2>/dev/null | tr '\t' ':')}")
+    _describe '%[1]s' candidates
+}
+compdef _%[1]s %[1]s
+`,
+	"fish": `complete -c %[1]s -f -a '(%[1]s __complete (commandline -opc)[2..-1] (commandline -ct))'
+`,
+}
+
+// runCompletion prints the completion script of a shell
+func runCompletion(config Config, args []string) {
+	if len(args) != 1 || completionScripts[args[0]] == "" {
+		fmt.Fprintln(os.Stderr, "usage: completion bash|zsh|fish")
+		os.Exit(2)
+	}
+	fmt.Printf(completionScripts[args[0]], filepath.Base(os.Args[0]))
+}
+
+// runComplete is the hidden lookup used by the completion scripts
+// It receives the words typed so far, the last one being completed,
+// and prints one candidate per line with an optional tab separated
+// description
+func runComplete(config Config, args []string) {
+	current := ""
+	if len(args) > 0 {
+		current = args[len(args)-1]
+		args = args[:len(args)-1]
+	}
+	for _, candidate := range completeArgs(config, args, current) {
+		if strings.HasPrefix(candidate, current) {
+			fmt.Println(candidate)
+		}
+	}
+}
+
+func completeArgs(config Config, args []string, current string) []string {
+	candidates := []string{}
+	if strings.HasPrefix(current, "-") {
+		flag.VisitAll(func(f *flag.Flag) {
+			candidates = append(candidates, "-"+f.Name+"\t"+f.Usage)
+		})
+		return candidates
+	}
+	words := positionalArgs(args)
+	if len(words) == 0 {
+		for name := range subcommands {
+			if !strings.HasPrefix(name, "__") {
+				candidates = append(candidates, name)
+			}
+		}
+		candidates = append(candidates, command.CliCommands()...)
+		sort.Strings(candidates)
+		// export is a subcommand and a command of the REPL
+		return slices.Compact(candidates)
+	}
+	switch {
+	case words[0] == "completion" && len(words) == 1:
+		for shell := range completionScripts {
+			candidates = append(candidates, shell)
+		}
+		sort.Strings(candidates)
+	case slices.Contains(command.CliIdCommands(), words[0]) && len(words) == 1:
+		s, err := newStorage(config)
+		if err != nil {
+			return candidates
+		}
+		result, _ := usecase.New(usecase.Deps{Storage: s, Inbox: config.inbox, Log: audit.NewMemory()}).ReadAll.Execute(usecase.ReadAllMessage{})
+		for _, n := range result.Notes {
+			candidates = append(candidates, strconv.Itoa(n.Id)+"\t"+n.Name)
+		}
+	}
+	return candidates
+}
+
+// positionalArgs drops the flags, and the values of non boolean flags,
+// from the words typed so far
+func positionalArgs(args []string) []string {
+	words := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || len(words) > 0 {
+			words = append(words, arg)
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		f := flag.Lookup(name)
+		if f == nil {
+			continue
+		}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !b.IsBoolFlag() {
+			i++
+		}
+	}
+	return words
+}
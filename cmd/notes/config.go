@@ -0,0 +1,761 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"notes/internal/datadir"
+	"notes/internal/note"
+	"notes/internal/repl"
+)
+
+// Configuration
+type Config struct {
+	confirmDelete bool
+	// transcriptDir enables REPL session recording when not empty
+	transcriptDir string
+	// batchFile runs the REPL commands of a file instead of stdin
+	batchFile string
+	// args are the command line arguments run in CLI mode
+	args []string
+	// profile is the profile of the config file the settings come from,
+	// see loadConfig
+	profile string
+	// prompt is a text/template of the REPL prompt, it can use
+	// {{.count}}, {{.backend}}, {{.unsaved}} and {{.notebook}}, such as
+	// notes{{with .notebook}}[{{.}}]{{end}} {{.count}}{{.unsaved}} >
+	prompt string
+	// locale is the language of the REPL, such as fr or fr_FR.UTF-8, the
+	// LC_ALL, LC_MESSAGES and LANG environment variables give it when
+	// empty
+	locale string
+	// format of the results of the REPL and CLI, plain by default
+	format string
+	// storage is the backend, memory or json
+	storage string
+	// dataDir is the directory of the files of the notes which are not
+	// configured otherwise, see datadir.Data, the working directory when
+	// empty
+	dataDir string
+	// storagePath is the file of the json storage, notes.json in the
+	// data directory when empty, the files of the accounts, of the sync
+	// and of the saved searches are kept next to it
+	storagePath string
+	// compressAbove is the size of the contents the json storage
+	// compresses in its file, 0 compresses none
+	compressAbove int
+	// inbox is the notebook of quick captures
+	inbox note.Notebook
+	// journalNotebook is the notebook of the daily notes of TODAY, and
+	// journalTemplate the file of the text/template of their content
+	journalNotebook note.Notebook
+	journalTemplate string
+	// logCommands logs every command to stderr
+	logCommands bool
+	// dryRun runs every REPL and CLI command as a dry run
+	dryRun bool
+	// mode lists the applications to run, such as "http,repl", the REPL
+	// or CLI is chosen from the arguments when empty
+	mode string
+	// readOnly lists the actors who may only read notes, the user name
+	// in the REPL and the remote address over HTTP
+	readOnly []string
+	// hooksDir holds the on-create, on-update and on-delete executables
+	// run when notes change, no hook runs when empty
+	hooksDir string
+	// hookTimeout kills the hooks running for longer
+	hookTimeout time.Duration
+	// jobWorkers run the background jobs, the hooks, and jobRetries is
+	// the number of times a failing job runs again
+	jobWorkers int
+	jobRetries int
+	// writeQueue is the number of changes of the json storage and its
+	// saves waiting their turn, the next ones are refused as busy, none
+	// are queued when 0
+	writeQueue int
+	// remote is the url of the notes server to sync with, the sync state
+	// is kept next to the json storage
+	remote string
+	// remoteToken authenticates sync and apply to the remote when it has
+	// accounts, it may be given by the NOTES_REMOTE_TOKEN environment
+	// variable instead
+	remoteToken string
+	// conflicts is how sync resolves the notes changed on both sides,
+	// skip, last-writer-wins, keep-both or ask
+	conflicts string
+	// autoSync syncs with the remote after each CLI command, the commands
+	// work on the json storage whether the server is reachable or not
+	autoSync bool
+	// mailListen is the address of the smtp mode
+	mailListen string
+	// mailTo lists the addresses whose emails become notes in the smtp
+	// mode, any address when empty
+	mailTo []string
+	// mailAttachments is the directory of the attachments of the emails,
+	// they are dropped when empty
+	mailAttachments string
+	// smtpServer is the host:port notes are emailed through, emailing
+	// fails when empty
+	smtpServer   string
+	smtpUsername string
+	// smtpPassword may be left out of the file and given by the
+	// NOTES_SMTP_PASSWORD environment variable
+	smtpPassword string
+	// mailFrom is the sender of the notes emailed
+	mailFrom string
+	// mailSubject and mailBody are text/templates of the note emailed
+	mailSubject string
+	mailBody    string
+	// gistToken is the GitHub token notes are published with, it may
+	// be given by the NOTES_GIST_TOKEN environment variable instead
+	gistToken string
+	// gistApi is the GitHub API, such as the one of GitHub Enterprise
+	gistApi string
+	// joplinDir keeps the files of the Joplin sync target served in the
+	// http mode, there is no target when empty, it is refused with accounts
+	joplinDir string
+	// telegramToken is the token of the bot of the telegram mode, it may
+	// be given by the NOTES_TELEGRAM_TOKEN environment variable instead
+	telegramToken string
+	// telegramUsers may use the bot, by user name or id, the telegram
+	// mode doesn't start without them
+	telegramUsers []string
+	// vault is the folder of Markdown files of the watch mode, scanned
+	// every vaultEvery, vaultWrite writes the changes of the notes back
+	// to it
+	vault      string
+	vaultEvery time.Duration
+	vaultWrite bool
+	// variables are the custom placeholders of the notes created, by
+	// name, such as {{author}}
+	variables map[string]string
+	// checkLinks follows the web links of the notes when they are linted,
+	// the wiki links are always checked
+	checkLinks bool
+	// slackSecret is the signing secret of the Slack app whose /note
+	// command is answered in http mode, it may be given by the
+	// NOTES_SLACK_SECRET environment variable instead
+	slackSecret string
+	// mqttBroker is the host:port of the MQTT broker the note events are
+	// published to, none are published when empty
+	mqttBroker string
+	// mqttPrefix of the topics, events go to PREFIX/ID/KIND
+	mqttPrefix string
+	// mqttQos is 0 or 1
+	mqttQos int
+	// mqttRetain keeps the last event of each topic on the broker
+	mqttRetain   bool
+	mqttUsername string
+	// mqttPassword may be given by the NOTES_MQTT_PASSWORD environment
+	// variable instead
+	mqttPassword string
+	// searchIndex is memory, an index built on every start, or bleve, an
+	// index kept in indexPath
+	searchIndex string
+	// indexPath is the directory of the bleve index of the json storage,
+	// next to the storage when empty
+	indexPath string
+	// searchFuzziness is the number of typos tolerated in each word
+	// searched by the memory index, 0 for exact words
+	searchFuzziness int
+	// embeddingsModel enables the semantic search, the notes are embedded
+	// by this model of the OpenAI compatible API at embeddingsUrl
+	embeddingsModel string
+	embeddingsUrl   string
+	// embeddingsKey may be given by the NOTES_EMBEDDINGS_KEY environment
+	// variable instead
+	embeddingsKey string
+	// llmModel summarizes the notes and suggests their titles, through
+	// the OpenAI compatible API at llmUrl, they are off without a model
+	// llmKey may be given by the NOTES_LLM_KEY environment variable
+	// instead.
+	llmModel string
+	llmUrl   string
+	llmKey   string
+	// accounts lets several people share the HTTP server, the users are
+	// kept next to the json storage
+	accounts bool
+	// tokenSecret signs the tokens of the users, it may be given by the
+	// NOTES_TOKEN_SECRET environment variable instead, without it the
+	// tokens don't survive a restart
+	tokenSecret string
+	// auditPath is the file of the audit log, the log is only kept in
+	// memory when empty
+	auditPath string
+	// keyPath is the file of the key sync encrypts the notes with before
+	// sending them, the key may be given by the NOTES_KEY environment
+	// variable instead, the notes are sent in the clear without either
+	keyPath string
+	// e2e makes the server keep only the notes encrypted by the clients
+	e2e bool
+	// quotaNotes and quotaBytes limit the number of notes of each user
+	// and the total size of their contents, 0 is no limit
+	quotaNotes int
+	quotaBytes int
+	// debugListen is the address the profiles of the http mode are
+	// served on, they aren't served when empty
+	debugListen string
+	// cacheTtl keeps the listings and searches of the http mode cached
+	// for that long at most, they aren't cached when 0
+	cacheTtl time.Duration
+	// adminToken lets its bearer read the runtime information of the
+	// http mode on /admin/, it may be given by the NOTES_ADMIN_TOKEN
+	// environment variable instead, there is no /admin/ without it
+	adminToken string
+	// recoveryDir is where the notes are written when the programme
+	// panics or gets SIGQUIT, recovery in the data directory when empty
+	// or the temporary directory without a data directory
+	recoveryDir string
+	// remindPrompt prints how many notes are due today before the REPL
+	// prompt
+	remindPrompt bool
+	// dashboard prints the pinned notes, those due today and the recent
+	// ones when the REPL starts
+	dashboard bool
+	// remindDesktop, remindWebhook and remindEmail dispatch the notes
+	// falling due as desktop notifications, as json posted to a URL and
+	// by email to an address, through the smtp server, none is
+	// dispatched when they are all unset
+	remindDesktop bool
+	remindWebhook string
+	remindEmail   string
+	// remindEvery is how often the due dates are checked
+	remindEvery time.Duration
+	// archive are the retention policies, as inbox:180d, see package
+	// retention, they are applied every archiveEvery
+	archive      []string
+	archiveEvery time.Duration
+}
+
+// secrets are the fields of the configuration redacted by redacted
+var secrets = []string{"smtpPassword", "gistToken", "telegramToken", "slackSecret", "mqttPassword", "embeddingsKey", "llmKey", "tokenSecret", "adminToken", "remoteToken"}
+
+// redacted is the configuration by field, the secrets which are set
+// are replaced by "redacted"
+func (c Config) redacted() map[string]any {
+	fields := map[string]any{}
+	v := reflect.ValueOf(c)
+	for i := range v.NumField() {
+		name, field := v.Type().Field(i).Name, v.Field(i)
+		switch {
+		case slices.Contains(secrets, name):
+			if !field.IsZero() {
+				fields[name] = "redacted"
+			}
+		case field.Type() == reflect.TypeOf(time.Duration(0)):
+			fields[name] = time.Duration(field.Int()).String()
+		case field.Kind() == reflect.String:
+			fields[name] = field.String()
+		case field.Kind() == reflect.Bool:
+			fields[name] = field.Bool()
+		case field.Kind() == reflect.Int:
+			fields[name] = field.Int()
+		default:
+			fields[name] = fmt.Sprint(field)
+		}
+	}
+	return fields
+}
+
+func defaultConfig() Config {
+	return Config{
+		confirmDelete:   true,
+		prompt:          repl.DefaultPrompt,
+		storage:         "memory",
+		dataDir:         defaultDataDir(),
+		inbox:           "inbox",
+		journalNotebook: "journal",
+		remindPrompt:    true,
+		dashboard:       true,
+		remindEvery:     time.Minute,
+		archiveEvery:    time.Hour,
+		vaultEvery:      2 * time.Second,
+		hookTimeout:     10 * time.Second,
+		jobWorkers:      4,
+		writeQueue:      256,
+		conflicts:       "skip",
+		mailListen:      "127.0.0.1:2525",
+		mqttPrefix:      "notes",
+		searchIndex:     "memory",
+		searchFuzziness: 1,
+	}
+}
+
+// defaultConfigPath is config.json in the configuration directory of
+// the user, see datadir.Config
+func defaultConfigPath() string {
+	dir, err := datadir.Config()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "config.json")
+}
+
+// defaultDataDir is the data directory of the user, see datadir.Data,
+// the working directory keeps the files without one
+func defaultDataDir() string {
+	dir, err := datadir.Data()
+	if err != nil {
+		return ""
+	}
+	return dir
+}
+
+// legacyStoragePath is where the json storage was kept before the data
+// directory, the working directory
+const legacyStoragePath = "notes.json"
+
+// withPaths places the files which are not configured in the data
+// directory, which is created for the json storage
+// A json storage left in the working directory by a previous version is
+// still used, with a warning, rather than starting an empty one.
+func withPaths(config Config) (Config, error) {
+	if _, err := os.Stat(legacyStoragePath); config.storagePath == "" && config.storage == "json" && config.dataDir != "" && err == nil {
+		config.storagePath = legacyStoragePath
+		fmt.Fprintf(os.Stderr, "notes: using %s of the working directory, move it and the files next to it to %s or set storagePath to keep it there\n", legacyStoragePath, config.dataDir)
+	}
+	if config.storagePath == "" {
+		config.storagePath = filepath.Join(config.dataDir, "notes.json")
+		if config.storage == "json" && config.dataDir != "" {
+			err := datadir.Create(config.dataDir)
+			if err != nil {
+				return config, err
+			}
+		}
+	}
+	if config.indexPath == "" {
+		config.indexPath = config.storagePath + ".bleve"
+	}
+	if config.recoveryDir == "" && config.dataDir != "" {
+		config.recoveryDir = filepath.Join(config.dataDir, "recovery")
+	}
+	return config, nil
+}
+
+// configPath finds the -config flag before the flags are parsed, the
+// config file gives their defaults
+func configPath(args []string) string {
+	if path, ok := flagValue(args, "config"); ok {
+		return path
+	}
+	return defaultConfigPath()
+}
+
+// configProfile finds the -profile flag before the flags are parsed, or
+// else the NOTES_PROFILE environment variable
+func configProfile(args []string) string {
+	if profile, ok := flagValue(args, "profile"); ok {
+		return profile
+	}
+	return os.Getenv("NOTES_PROFILE")
+}
+
+// flagValue is the value of a flag given as -name value or -name=value
+func flagValue(args []string, name string) (string, bool) {
+	for i, arg := range args {
+		n, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || n != name {
+			continue
+		}
+		if hasValue {
+			return value, true
+		}
+		if i+1 < len(args) {
+			return args[i+1], true
+		}
+	}
+	return "", false
+}
+
+// loadConfig overrides the config with the settings of a json file,
+// a missing file is not an error
+// The settings of a profile of the file then override those of the file,
+// the profile is the one given or else the profile setting of the file,
+// so one file keeps several collections of notes apart:
+//
+//	{"storage": "json", "profile": "personal", "profiles": {
+//		"personal": {"storagePath": "personal.json"},
+//		"work": {"storagePath": "work.json", "remote": "https://notes.example.com"}}}
+func loadConfig(path, profile string, config Config) (Config, error) {
+	data, err := os.ReadFile(path)
+	if path == "" || os.IsNotExist(err) {
+		if profile != "" {
+			return config, fmt.Errorf("config %s: no profile %q", path, profile)
+		}
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	file := struct {
+		configFile
+		Profile  *string               `json:"profile"`
+		Profiles map[string]configFile `json:"profiles"`
+	}{}
+	err = json.Unmarshal(data, &file)
+	if err != nil {
+		return config, fmt.Errorf("config %s: %w", path, err)
+	}
+	config, err = file.apply(path, config)
+	if err != nil {
+		return config, err
+	}
+	if profile == "" && file.Profile != nil {
+		profile = *file.Profile
+	}
+	if profile == "" {
+		return config, nil
+	}
+	settings, ok := file.Profiles[profile]
+	if !ok {
+		return config, fmt.Errorf("config %s: no profile %q, the profiles are %s", path, profile, strings.Join(slices.Sorted(maps.Keys(file.Profiles)), ", "))
+	}
+	config.profile = profile
+	return settings.apply(path+" profile "+profile, config)
+}
+
+// configFile are the settings of a config file or of one of its
+// profiles, those missing leave the config as it is
+type configFile struct {
+	ConfirmDelete   *bool             `json:"confirmDelete"`
+	TranscriptDir   *string           `json:"transcriptDir"`
+	Prompt          *string           `json:"prompt"`
+	Locale          *string           `json:"locale"`
+	Format          *string           `json:"format"`
+	Storage         *string           `json:"storage"`
+	DataDir         *string           `json:"dataDir"`
+	StoragePath     *string           `json:"storagePath"`
+	CompressAbove   *int              `json:"compressAbove"`
+	Inbox           *string           `json:"inbox"`
+	JournalNotebook *string           `json:"journalNotebook"`
+	JournalTemplate *string           `json:"journalTemplate"`
+	LogCommands     *bool             `json:"logCommands"`
+	AuditPath       *string           `json:"auditPath"`
+	ReadOnly        []string          `json:"readOnly"`
+	HooksDir        *string           `json:"hooksDir"`
+	Remote          *string           `json:"remote"`
+	RemoteToken     *string           `json:"remoteToken"`
+	Conflicts       *string           `json:"conflicts"`
+	AutoSync        *bool             `json:"autoSync"`
+	HookTimeout     *string           `json:"hookTimeout"`
+	JobWorkers      *int              `json:"jobWorkers"`
+	JobRetries      *int              `json:"jobRetries"`
+	WriteQueue      *int              `json:"writeQueue"`
+	MailListen      *string           `json:"mailListen"`
+	MailTo          []string          `json:"mailTo"`
+	MailAttachments *string           `json:"mailAttachments"`
+	SmtpServer      *string           `json:"smtpServer"`
+	SmtpUsername    *string           `json:"smtpUsername"`
+	SmtpPassword    *string           `json:"smtpPassword"`
+	MailFrom        *string           `json:"mailFrom"`
+	MailSubject     *string           `json:"mailSubject"`
+	MailBody        *string           `json:"mailBody"`
+	GistToken       *string           `json:"gistToken"`
+	GistApi         *string           `json:"gistApi"`
+	JoplinDir       *string           `json:"joplinDir"`
+	TelegramToken   *string           `json:"telegramToken"`
+	TelegramUsers   []string          `json:"telegramUsers"`
+	Vault           *string           `json:"vault"`
+	VaultEvery      *string           `json:"vaultEvery"`
+	VaultWrite      *bool             `json:"vaultWrite"`
+	CheckLinks      *bool             `json:"checkLinks"`
+	Variables       map[string]string `json:"variables"`
+	SlackSecret     *string           `json:"slackSecret"`
+	MqttBroker      *string           `json:"mqttBroker"`
+	MqttPrefix      *string           `json:"mqttPrefix"`
+	MqttQos         *int              `json:"mqttQos"`
+	MqttRetain      *bool             `json:"mqttRetain"`
+	MqttUsername    *string           `json:"mqttUsername"`
+	MqttPassword    *string           `json:"mqttPassword"`
+	SearchIndex     *string           `json:"searchIndex"`
+	IndexPath       *string           `json:"indexPath"`
+	SearchFuzziness *int              `json:"searchFuzziness"`
+	EmbeddingsModel *string           `json:"embeddingsModel"`
+	EmbeddingsUrl   *string           `json:"embeddingsUrl"`
+	EmbeddingsKey   *string           `json:"embeddingsKey"`
+	LlmModel        *string           `json:"llmModel"`
+	LlmUrl          *string           `json:"llmUrl"`
+	LlmKey          *string           `json:"llmKey"`
+	Accounts        *bool             `json:"accounts"`
+	TokenSecret     *string           `json:"tokenSecret"`
+	KeyPath         *string           `json:"keyPath"`
+	E2e             *bool             `json:"e2e"`
+	QuotaNotes      *int              `json:"quotaNotes"`
+	QuotaBytes      *int              `json:"quotaBytes"`
+	DebugListen     *string           `json:"debugListen"`
+	CacheTtl        *string           `json:"cacheTtl"`
+	AdminToken      *string           `json:"adminToken"`
+	RecoveryDir     *string           `json:"recoveryDir"`
+	RemindPrompt    *bool             `json:"remindPrompt"`
+	Dashboard       *bool             `json:"dashboard"`
+	RemindDesktop   *bool             `json:"remindDesktop"`
+	RemindWebhook   *string           `json:"remindWebhook"`
+	RemindEmail     *string           `json:"remindEmail"`
+	RemindEvery     *string           `json:"remindEvery"`
+	Archive         []string          `json:"archive"`
+	ArchiveEvery    *string           `json:"archiveEvery"`
+}
+
+// apply overrides the config with the settings, path tells where they
+// come from in errors
+func (f configFile) apply(path string, config Config) (Config, error) {
+	if f.ConfirmDelete != nil {
+		config.confirmDelete = *f.ConfirmDelete
+	}
+	if f.TranscriptDir != nil {
+		config.transcriptDir = *f.TranscriptDir
+	}
+	if f.Prompt != nil {
+		config.prompt = *f.Prompt
+	}
+	if f.Locale != nil {
+		config.locale = *f.Locale
+	}
+	if f.Format != nil {
+		config.format = *f.Format
+	}
+	if f.Storage != nil {
+		config.storage = *f.Storage
+	}
+	if f.DataDir != nil {
+		config.dataDir = *f.DataDir
+	}
+	if f.StoragePath != nil {
+		config.storagePath = *f.StoragePath
+	}
+	if f.Inbox != nil {
+		config.inbox = *f.Inbox
+	}
+	if f.AuditPath != nil {
+		config.auditPath = *f.AuditPath
+	}
+	if f.ReadOnly != nil {
+		config.readOnly = f.ReadOnly
+	}
+	if f.Remote != nil {
+		config.remote = *f.Remote
+	}
+	if f.RemoteToken != nil {
+		config.remoteToken = *f.RemoteToken
+	}
+	if f.Conflicts != nil {
+		config.conflicts = *f.Conflicts
+	}
+	if f.AutoSync != nil {
+		config.autoSync = *f.AutoSync
+	}
+	if f.JournalNotebook != nil {
+		config.journalNotebook = *f.JournalNotebook
+	}
+	if f.JournalTemplate != nil {
+		config.journalTemplate = *f.JournalTemplate
+	}
+	if f.LogCommands != nil {
+		config.logCommands = *f.LogCommands
+	}
+	if f.HooksDir != nil {
+		config.hooksDir = *f.HooksDir
+	}
+	if f.MailListen != nil {
+		config.mailListen = *f.MailListen
+	}
+	if f.MailTo != nil {
+		config.mailTo = f.MailTo
+	}
+	if f.MailAttachments != nil {
+		config.mailAttachments = *f.MailAttachments
+	}
+	if f.SmtpServer != nil {
+		config.smtpServer = *f.SmtpServer
+	}
+	if f.SmtpUsername != nil {
+		config.smtpUsername = *f.SmtpUsername
+	}
+	if f.SmtpPassword != nil {
+		config.smtpPassword = *f.SmtpPassword
+	}
+	if f.MailFrom != nil {
+		config.mailFrom = *f.MailFrom
+	}
+	if f.MailSubject != nil {
+		config.mailSubject = *f.MailSubject
+	}
+	if f.MailBody != nil {
+		config.mailBody = *f.MailBody
+	}
+	if f.GistToken != nil {
+		config.gistToken = *f.GistToken
+	}
+	if f.GistApi != nil {
+		config.gistApi = *f.GistApi
+	}
+	if f.JoplinDir != nil {
+		config.joplinDir = *f.JoplinDir
+	}
+	if f.TelegramToken != nil {
+		config.telegramToken = *f.TelegramToken
+	}
+	if f.TelegramUsers != nil {
+		config.telegramUsers = f.TelegramUsers
+	}
+	if f.Vault != nil {
+		config.vault = *f.Vault
+	}
+	if f.VaultEvery != nil {
+		every, err := time.ParseDuration(*f.VaultEvery)
+		if err != nil {
+			return config, fmt.Errorf("config %s: vaultEvery: %w", path, err)
+		}
+		config.vaultEvery = every
+	}
+	if f.VaultWrite != nil {
+		config.vaultWrite = *f.VaultWrite
+	}
+	if f.Variables != nil {
+		config.variables = f.Variables
+	}
+	if f.CheckLinks != nil {
+		config.checkLinks = *f.CheckLinks
+	}
+	if f.SlackSecret != nil {
+		config.slackSecret = *f.SlackSecret
+	}
+	if f.MqttBroker != nil {
+		config.mqttBroker = *f.MqttBroker
+	}
+	if f.MqttPrefix != nil {
+		config.mqttPrefix = *f.MqttPrefix
+	}
+	if f.MqttQos != nil {
+		config.mqttQos = *f.MqttQos
+	}
+	if f.MqttRetain != nil {
+		config.mqttRetain = *f.MqttRetain
+	}
+	if f.MqttUsername != nil {
+		config.mqttUsername = *f.MqttUsername
+	}
+	if f.MqttPassword != nil {
+		config.mqttPassword = *f.MqttPassword
+	}
+	if f.SearchIndex != nil {
+		config.searchIndex = *f.SearchIndex
+	}
+	if f.IndexPath != nil {
+		config.indexPath = *f.IndexPath
+	}
+	if f.SearchFuzziness != nil {
+		config.searchFuzziness = *f.SearchFuzziness
+	}
+	if f.EmbeddingsModel != nil {
+		config.embeddingsModel = *f.EmbeddingsModel
+	}
+	if f.EmbeddingsUrl != nil {
+		config.embeddingsUrl = *f.EmbeddingsUrl
+	}
+	if f.EmbeddingsKey != nil {
+		config.embeddingsKey = *f.EmbeddingsKey
+	}
+	if f.LlmModel != nil {
+		config.llmModel = *f.LlmModel
+	}
+	if f.LlmUrl != nil {
+		config.llmUrl = *f.LlmUrl
+	}
+	if f.LlmKey != nil {
+		config.llmKey = *f.LlmKey
+	}
+	if f.Accounts != nil {
+		config.accounts = *f.Accounts
+	}
+	if f.TokenSecret != nil {
+		config.tokenSecret = *f.TokenSecret
+	}
+	if f.KeyPath != nil {
+		config.keyPath = *f.KeyPath
+	}
+	if f.E2e != nil {
+		config.e2e = *f.E2e
+	}
+	if f.QuotaNotes != nil {
+		config.quotaNotes = *f.QuotaNotes
+	}
+	if f.QuotaBytes != nil {
+		config.quotaBytes = *f.QuotaBytes
+	}
+	if f.CompressAbove != nil {
+		config.compressAbove = *f.CompressAbove
+	}
+	if f.DebugListen != nil {
+		config.debugListen = *f.DebugListen
+	}
+	if f.JobWorkers != nil {
+		config.jobWorkers = *f.JobWorkers
+	}
+	if f.JobRetries != nil {
+		config.jobRetries = *f.JobRetries
+	}
+	if f.WriteQueue != nil {
+		config.writeQueue = *f.WriteQueue
+	}
+	if f.HookTimeout != nil {
+		timeout, err := time.ParseDuration(*f.HookTimeout)
+		if err != nil {
+			return config, fmt.Errorf("config %s: hookTimeout: %w", path, err)
+		}
+		config.hookTimeout = timeout
+	}
+	if f.RecoveryDir != nil {
+		config.recoveryDir = *f.RecoveryDir
+	}
+	if f.AdminToken != nil {
+		config.adminToken = *f.AdminToken
+	}
+	if f.CacheTtl != nil {
+		ttl, err := time.ParseDuration(*f.CacheTtl)
+		if err != nil {
+			return config, fmt.Errorf("config %s: cacheTtl: %w", path, err)
+		}
+		config.cacheTtl = ttl
+	}
+	if f.RemindPrompt != nil {
+		config.remindPrompt = *f.RemindPrompt
+	}
+	if f.Dashboard != nil {
+		config.dashboard = *f.Dashboard
+	}
+	if f.RemindDesktop != nil {
+		config.remindDesktop = *f.RemindDesktop
+	}
+	if f.RemindWebhook != nil {
+		config.remindWebhook = *f.RemindWebhook
+	}
+	if f.RemindEmail != nil {
+		config.remindEmail = *f.RemindEmail
+	}
+	if f.RemindEvery != nil {
+		every, err := time.ParseDuration(*f.RemindEvery)
+		if err != nil {
+			return config, fmt.Errorf("config %s: remindEvery: %w", path, err)
+		}
+		config.remindEvery = every
+	}
+	if f.Archive != nil {
+		config.archive = f.Archive
+	}
+	if f.ArchiveEvery != nil {
+		every, err := time.ParseDuration(*f.ArchiveEvery)
+		if err != nil {
+			return config, fmt.Errorf("config %s: archiveEvery: %w", path, err)
+		}
+		config.archiveEvery = every
+	}
+	return config, nil
+}
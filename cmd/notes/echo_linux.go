@@ -0,0 +1,22 @@
+//go:build linux
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// hideInput stops the terminal of fd from showing what is typed, until
+// restore is called, it fails when fd isn't a terminal
+func hideInput(fd int) (restore func(), err error) {
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return nil, err
+	}
+	hidden := *termios
+	hidden.Lflag &^= unix.ECHO
+	hidden.Lflag |= unix.ICANON | unix.ECHONL
+	err = unix.IoctlSetTermios(fd, unix.TCSETS, &hidden)
+	if err != nil {
+		return nil, err
+	}
+	return func() { unix.IoctlSetTermios(fd, unix.TCSETS, termios) }, nil
+}
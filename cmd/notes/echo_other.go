@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// hideInput isn't supported here, what is typed stays shown
+func hideInput(fd int) (restore func(), err error) {
+	return nil, errors.New("hiding the input isn't supported")
+}
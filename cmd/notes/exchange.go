@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"os"
+	"slices"
+	"strings"
+
+	"notes/internal/audit"
+	"notes/internal/exchange"
+	"notes/internal/note"
+	"notes/internal/search"
+	"notes/internal/usecase"
+)
+
+func init() {
+	subcommands["export"] = runExport
+	subcommands["import"] = runImport
+}
+
+// newUsecase builds the usecases of the configured storage for the
+// subcommands, the changes are audited
+func newUsecase(config Config) (usecase.Usecase, error) {
+	s, err := newStorage(config)
+	if err != nil {
+		return usecase.Usecase{}, err
+	}
+	clock := usecase.SystemClock{}
+	events := usecase.NewEventBus(clock)
+	auditLog := newAuditStore(config)
+	events.Subscribe(audit.NewRecorder(auditLog, func(err error) {
+		fmt.Fprintln(os.Stderr, "notes: audit:", err)
+	}))
+	// the index is only searched by the exports of a query
+	index := search.NewIndex(s.ReadAll(), config.searchFuzziness)
+	return usecase.New(usecase.Deps{Storage: s, Clock: clock, Inbox: config.inbox, Events: events, Log: auditLog, Searcher: index}), nil
+}
+
+// readNotes reads every note of the configured storage
+func readNotes(config Config) (note.List, error) {
+	u, err := newUsecase(config)
+	if err != nil {
+		return nil, err
+	}
+	result, err := u.ReadAll.Execute(usecase.ReadAllMessage{})
+	return result.Notes, err
+}
+
+// exportNotes goes through every note of the configured storage, or
+// those matching a search query
+func exportNotes(config Config, query string) (iter.Seq[note.Note], error) {
+	u, err := newUsecase(config)
+	if err != nil {
+		return nil, err
+	}
+	result, err := u.Export.Execute(usecase.ExportMessage{Query: query})
+	return result.Notes, err
+}
+
+const exportUsage = "usage: export [--query QUERY] markdown DIR | csv | json | pdf FILE [NOTEBOOK] | bundle FILE"
+
+// runExport writes the notes in the format of another tool, csv and
+// json lines are written to stdout
+// --query exports the notes a search finds, as in
+// `export --query tag:recipes markdown recipes`. A bundle is a backup
+// encrypted with a password, see bundlePassword.
+func runExport(config Config, args []string) {
+	query := ""
+	if len(args) >= 2 && args[0] == "--query" {
+		query, args = args[1], args[2:]
+	} else if len(args) >= 1 && strings.HasPrefix(args[0], "--query=") {
+		query, args = strings.TrimPrefix(args[0], "--query="), args[1:]
+	}
+	switch {
+	case len(args) == 2 && args[0] == "markdown":
+		notes, err := exportNotes(config, query)
+		exitOnError(err)
+		exitOnError(exchange.WriteMarkdown(args[1], notes))
+	case len(args) == 1 && args[0] == "csv":
+		notes, err := exportNotes(config, query)
+		exitOnError(err)
+		exitOnError(exchange.WriteCSV(os.Stdout, notes))
+	case len(args) == 1 && args[0] == "json":
+		notes, err := exportNotes(config, query)
+		exitOnError(err)
+		encoder := json.NewEncoder(os.Stdout)
+		for n := range notes {
+			exitOnError(encoder.Encode(n))
+		}
+	case (len(args) == 2 || len(args) == 3) && args[0] == "pdf":
+		exportPdf(config, query, args[1], args[2:])
+	case len(args) == 2 && args[0] == "bundle":
+		exportBundle(config, query, args[1])
+	default:
+		fmt.Fprintln(os.Stderr, exportUsage)
+		os.Exit(2)
+	}
+}
+
+// exportPdf renders the notes to a PDF file, those of a notebook when
+// one is given
+func exportPdf(config Config, query string, path string, notebook []string) {
+	u, err := newUsecase(config)
+	exitOnError(err)
+	title, message := "Notes", usecase.ExportMessage{Query: query, Transclude: true}
+	if len(notebook) == 1 {
+		title, message.Notebook = notebook[0], notebook[0]
+	}
+	result, err := u.Export.Execute(message)
+	exitOnError(err)
+	notes := slices.Collect(result.Notes)
+	if len(notes) == 0 {
+		exitOnError(fmt.Errorf("no notes to export"))
+	}
+	file, err := os.Create(path)
+	exitOnError(err)
+	err = exchange.WritePDF(file, title, notes)
+	if closed := file.Close(); err == nil {
+		err = closed
+	}
+	exitOnError(err)
+	fmt.Printf("Exported %d notes to %s\n", len(notes), path)
+}
+
+// exportBundle writes the notes to a bundle only its owner may read,
+// an existing file is replaced
+func exportBundle(config Config, query string, path string) {
+	notes, err := exportNotes(config, query)
+	exitOnError(err)
+	password, err := bundlePassword(true)
+	exitOnError(err)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	exitOnError(err)
+	count, err := exchange.WriteBundle(file, password, notes)
+	if closed := file.Close(); err == nil {
+		err = closed
+	}
+	exitOnError(err)
+	fmt.Printf("Exported %d notes to %s\n", count, path)
+}
+
+// bundlePassword is the password of the NOTES_BUNDLE_PASSWORD
+// environment variable, or else the one typed on stdin, twice when it
+// seals a new bundle
+// The password typed isn't shown on a terminal.
+func bundlePassword(confirm bool) (string, error) {
+	if env, ok := os.LookupEnv("NOTES_BUNDLE_PASSWORD"); ok {
+		return env, nil
+	}
+	in := bufio.NewReader(os.Stdin)
+	read := func(prompt string) (string, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		restore, err := hideInput(int(os.Stdin.Fd()))
+		if err == nil {
+			defer restore()
+		}
+		line, err := in.ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("no password, set NOTES_BUNDLE_PASSWORD or type it")
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	password, err := read("Password: ")
+	if err != nil || !confirm {
+		return password, err
+	}
+	again, err := read("Password again: ")
+	if err != nil {
+		return "", err
+	}
+	if again != password {
+		return "", fmt.Errorf("the passwords differ")
+	}
+	return password, nil
+}
+
+const importUsage = "usage: import csv FILE [FIELD=COLUMN...] | import enex FILE [ATTACHMENTS_DIR] | import notion FILE | import bundle FILE"
+
+// runImport creates the notes of a file written by another tool
+// The columns of a csv file can be mapped to the fields of the notes as
+// in `import csv notes.csv name=Title content=Body`, the attachments of
+// an Evernote export are saved to a directory when one is given
+func runImport(config Config, args []string) {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, importUsage)
+		os.Exit(2)
+	}
+	file, err := os.Open(args[1])
+	exitOnError(err)
+	defer file.Close()
+	notes := note.List{}
+	report := []string{}
+	switch {
+	case args[0] == "csv":
+		mapping := map[string]string{}
+		for _, arg := range args[2:] {
+			field, column, ok := strings.Cut(arg, "=")
+			if !ok {
+				fmt.Fprintln(os.Stderr, importUsage)
+				os.Exit(2)
+			}
+			mapping[field] = column
+		}
+		notes, err = exchange.ReadCSV(file, mapping)
+	case args[0] == "enex" && len(args) <= 3:
+		attachments := ""
+		if len(args) == 3 {
+			attachments = args[2]
+		}
+		notes, report, err = exchange.ReadEnex(file, attachments)
+	case args[0] == "notion" && len(args) == 2:
+		var info os.FileInfo
+		info, err = file.Stat()
+		exitOnError(err)
+		notes, report, err = exchange.ReadNotion(file, info.Size())
+	case args[0] == "bundle" && len(args) == 2:
+		var password string
+		password, err = bundlePassword(false)
+		exitOnError(err)
+		notes, err = exchange.ReadBundle(file, password)
+	default:
+		fmt.Fprintln(os.Stderr, importUsage)
+		os.Exit(2)
+	}
+	exitOnError(err)
+	u, err := newUsecase(config)
+	exitOnError(err)
+	actor := currentUser()
+	for i, n := range notes {
+		_, err := u.Create.Execute(usecase.CreateMessage{
+			Context:  usecase.Context{DryRun: config.dryRun, Actor: actor},
+			Name:     n.Name,
+			Content:  n.Content,
+			Notebook: n.Notebook,
+		})
+		if err != nil {
+			exitOnError(fmt.Errorf("%s: note %d: %w", args[1], i+1, err))
+		}
+	}
+	_, err = u.Save.Execute(usecase.SaveMessage{})
+	exitOnError(err)
+	for _, line := range report {
+		fmt.Println("Skipped", line)
+	}
+	fmt.Printf("Imported %d notes\n", len(notes))
+}
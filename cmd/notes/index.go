@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"notes/internal/query"
+	"notes/internal/search"
+)
+
+func init() {
+	subcommands["index"] = runIndex
+}
+
+// newBleve opens the bleve index of the config, nil with the default
+// in-memory index
+// The index of the json storage is kept next to it, the one of the
+// memory storage in memory.
+func newBleve(config Config) (*search.Bleve, error) {
+	switch config.searchIndex {
+	case "memory":
+		return nil, nil
+	case "bleve":
+	default:
+		return nil, fmt.Errorf("unknown search index %s", config.searchIndex)
+	}
+	notes, err := readNotes(config)
+	if err != nil {
+		return nil, err
+	}
+	path := ""
+	if config.storage == "json" {
+		path = config.indexPath
+	}
+	return search.OpenBleve(path, notes, func(err error) {
+		fmt.Fprintln(os.Stderr, "notes:", err)
+	})
+}
+
+// runIndex rebuilds the bleve index from the storage, after the notes
+// were changed without it, or verifies that the index and the saved
+// searches still agree with the storage
+func runIndex(config Config, args []string) {
+	if len(args) != 1 || (args[0] != "rebuild" && args[0] != "verify") {
+		fmt.Fprintln(os.Stderr, "usage: index rebuild|verify")
+		os.Exit(2)
+	}
+	if args[0] == "verify" {
+		verifyIndex(config)
+		return
+	}
+	if config.searchIndex != "bleve" {
+		exitOnError(fmt.Errorf("only the bleve index is kept, the %s one is built on every start", config.searchIndex))
+	}
+	b, err := newBleve(config)
+	exitOnError(err)
+	notes, err := readNotes(config)
+	exitOnError(err)
+	err = b.Rebuild(notes)
+	exitOnError(err)
+	exitOnError(b.Close())
+	fmt.Printf("Indexed %d notes\n", len(notes))
+}
+
+// verifyIndex lists the inconsistencies between the storage and what
+// is kept beside it, and exits with 1 when there are some
+// The bleve index of the memory storage and the memory index are built
+// on every start, they can't disagree with the storage.
+func verifyIndex(config Config) {
+	notes, err := readNotes(config)
+	exitOnError(err)
+	problems := []string{}
+	if config.searchIndex == "bleve" && config.storage == "json" {
+		path := config.indexPath
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			problems = append(problems, fmt.Sprintf("no bleve index in %s, it is built on the next start", path))
+		} else {
+			p, err := search.VerifyBleve(path, notes)
+			exitOnError(err)
+			problems = append(problems, p...)
+		}
+	} else if config.searchIndex != "memory" && config.searchIndex != "bleve" {
+		exitOnError(fmt.Errorf("unknown search index %s", config.searchIndex))
+	}
+	if config.storage == "json" {
+		saved, err := search.NewSaved(config.storagePath + ".searches")
+		exitOnError(err)
+		queries, err := saved.All()
+		exitOnError(err)
+		for name, q := range queries {
+			if _, err := query.Parse(q); err != nil {
+				problems = append(problems, fmt.Sprintf("smart notebook %s: %v", name, err))
+			}
+		}
+	}
+	sort.Strings(problems)
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "notes: problems found: %d, index rebuild fixes those of the index\n", len(problems))
+		os.Exit(1)
+	}
+	fmt.Printf("Verified %d notes\n", len(notes))
+}
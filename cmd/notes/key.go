@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"notes/internal/crypt"
+)
+
+func init() {
+	subcommands["key"] = runKey
+}
+
+// loadKey reads the key of the NOTES_KEY environment variable or of the
+// key file, nil without either
+func loadKey(config Config) (*crypt.Key, error) {
+	if env, ok := os.LookupEnv("NOTES_KEY"); ok {
+		key, err := crypt.ParseKey(env)
+		if err != nil {
+			return nil, fmt.Errorf("NOTES_KEY: %w", err)
+		}
+		return &key, nil
+	}
+	if config.keyPath == "" {
+		return nil, nil
+	}
+	key, err := crypt.LoadKey(config.keyPath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no key in %s, key generate makes one", config.keyPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// runKey generates the key of the -key file, to copy to the other
+// clients, or prints the fingerprint of the key to check they have the
+// same
+func runKey(config Config, args []string) {
+	if len(args) != 1 || (args[0] != "generate" && args[0] != "fingerprint") {
+		fmt.Fprintln(os.Stderr, "usage: key generate|fingerprint")
+		os.Exit(2)
+	}
+	if args[0] == "fingerprint" {
+		key, err := loadKey(config)
+		exitOnError(err)
+		if key == nil {
+			exitOnError(fmt.Errorf("no key, set -key or NOTES_KEY"))
+		}
+		fmt.Println(key.Fingerprint())
+		return
+	}
+	if config.keyPath == "" {
+		exitOnError(fmt.Errorf("key generate needs the -key file to write"))
+	}
+	key, err := crypt.NewKey()
+	exitOnError(err)
+	exitOnError(key.Save(config.keyPath))
+	fmt.Printf("Key %s written to %s, copy it to the other clients, the notes can't be read without it\n", key.Fingerprint(), config.keyPath)
+}
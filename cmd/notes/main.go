@@ -0,0 +1,574 @@
+// Command notes keeps notes from a REPL, the command line or an HTTP
+// API.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"notes/internal/app"
+	"notes/internal/audit"
+	"notes/internal/color"
+	"notes/internal/embedding"
+	"notes/internal/expand"
+	"notes/internal/gist"
+	"notes/internal/hooks"
+	"notes/internal/i18n"
+	"notes/internal/jobs"
+	"notes/internal/links"
+	"notes/internal/llm"
+	"notes/internal/mail"
+	"notes/internal/mqtt"
+	"notes/internal/reminder"
+	"notes/internal/repl"
+	"notes/internal/search"
+	"notes/internal/storage"
+	"notes/internal/systemd"
+	"notes/internal/telegram"
+	"notes/internal/usecase"
+	"notes/internal/vault"
+)
+
+func newStorage(config Config) (storage.Storage, error) {
+	switch config.storage {
+	case "memory":
+		return storage.NewInMemory(storage.NewSequence(0)), nil
+	case "json":
+		s, err := storage.NewJson(config.storagePath, storage.NewSequence(0))
+		return s.Compressing(config.compressAbove), err
+	default:
+		return nil, fmt.Errorf("unknown storage %s", config.storage)
+	}
+}
+
+func newAuditStore(config Config) audit.Store {
+	if config.auditPath == "" {
+		return audit.NewMemory()
+	}
+	return audit.NewFile(config.auditPath)
+}
+
+// currentUser is the actor of the REPL and CLI changes
+func currentUser() string {
+	u, err := user.Current()
+	if err != nil {
+		return os.Getenv("USER")
+	}
+	return u.Username
+}
+
+// replConfig opens the batch file and the transcript of the REPL
+func replConfig(config Config) (repl.Config, error) {
+	replConfig := repl.Config{
+		ConfirmDelete: config.confirmDelete,
+		Prompt:        config.prompt,
+		Backend:       config.storage,
+		DryRun:        config.dryRun,
+		Actor:         currentUser(),
+		Reminders:     config.remindPrompt,
+		Dashboard:     config.dashboard,
+		Format:        config.format,
+		Messages:      i18n.New(i18n.Detect(config.locale, os.Getenv("LC_ALL"), os.Getenv("LC_MESSAGES"), os.Getenv("LANG"))),
+	}
+	if config.batchFile != "" {
+		file, err := os.Open(config.batchFile)
+		if err != nil {
+			return replConfig, err
+		}
+		replConfig.Input = file
+		replConfig.Batch = true
+	}
+	if config.transcriptDir != "" {
+		transcript, err := repl.NewTranscript(config.transcriptDir)
+		if err != nil {
+			return replConfig, err
+		}
+		replConfig.Transcript = transcript
+	}
+	return replConfig, nil
+}
+
+// mailConfig remembers the emails turned into notes next to the json
+// storage
+func mailConfig(config Config) mail.Config {
+	m := mail.Config{
+		Addr:        config.mailListen,
+		Recipients:  config.mailTo,
+		Attachments: config.mailAttachments,
+	}
+	if config.storage == "json" {
+		m.Seen = config.storagePath + ".mail"
+	}
+	return m
+}
+
+// newSender takes the smtp password from the environment when it is
+// set there
+func newSender(config Config) (mail.Sender, error) {
+	password := config.smtpPassword
+	if env, ok := os.LookupEnv("NOTES_SMTP_PASSWORD"); ok {
+		password = env
+	}
+	return mail.NewSender(mail.SenderConfig{
+		Server:   config.smtpServer,
+		Username: config.smtpUsername,
+		Password: password,
+		From:     config.mailFrom,
+		Subject:  config.mailSubject,
+		Body:     config.mailBody,
+	})
+}
+
+// newPublisher publishes the notes as gists when there is a token, the
+// gists are remembered next to the json storage
+func newPublisher(config Config) (usecase.Publisher, error) {
+	token := config.gistToken
+	if env, ok := os.LookupEnv("NOTES_GIST_TOKEN"); ok {
+		token = env
+	}
+	if token == "" {
+		return nil, nil
+	}
+	g := gist.Config{Token: token, API: config.gistApi}
+	if config.storage == "json" {
+		g.State = config.storagePath + ".gists"
+	}
+	return gist.New(g)
+}
+
+// newMqtt publishes the note events to the broker of the config, nil
+// without a broker
+func newMqtt(config Config) (*mqtt.Publisher, error) {
+	if config.mqttBroker == "" {
+		return nil, nil
+	}
+	if config.mqttQos != 0 && config.mqttQos != 1 {
+		return nil, fmt.Errorf("mqtt qos %d: only 0 and 1 are supported", config.mqttQos)
+	}
+	password := config.mqttPassword
+	if env, ok := os.LookupEnv("NOTES_MQTT_PASSWORD"); ok {
+		password = env
+	}
+	return mqtt.New(mqtt.Config{
+		Broker:   config.mqttBroker,
+		Prefix:   config.mqttPrefix,
+		QoS:      byte(config.mqttQos),
+		Retain:   config.mqttRetain,
+		Username: config.mqttUsername,
+		Password: password,
+	}, func(err error) {
+		fmt.Fprintln(os.Stderr, "notes:", err)
+	}), nil
+}
+
+// newEmbeddings embeds the notes with the model of the config for the
+// semantic search, nil without a model
+// The vectors of the json storage are kept next to it.
+func newEmbeddings(config Config) (*embedding.Index, error) {
+	if config.embeddingsModel == "" {
+		return nil, nil
+	}
+	key := config.embeddingsKey
+	if env, ok := os.LookupEnv("NOTES_EMBEDDINGS_KEY"); ok {
+		key = env
+	}
+	notes, err := readNotes(config)
+	if err != nil {
+		return nil, err
+	}
+	path := ""
+	if config.storage == "json" {
+		path = config.storagePath + ".vectors"
+	}
+	provider := embedding.NewOpenAI(embedding.Config{URL: config.embeddingsUrl, Model: config.embeddingsModel, Key: key})
+	return embedding.NewIndex(provider, path, notes, func(err error) {
+		fmt.Fprintln(os.Stderr, "notes:", err)
+	})
+}
+
+// parseModes reads the -mode flag, without it the REPL runs unless a
+// command line is given
+func parseModes(mode string, args []string) ([]app.AppMode, error) {
+	if mode == "" && len(args) == 0 {
+		return []app.AppMode{app.REPL}, nil
+	}
+	if mode == "" {
+		return []app.AppMode{app.CLI}, nil
+	}
+	modes := []app.AppMode{}
+	for _, name := range strings.Split(mode, ",") {
+		m := app.AppMode(strings.ToUpper(strings.TrimSpace(name)))
+		switch m {
+		case app.HTTP, app.REPL, app.CLI, app.SMTP, app.TELEGRAM, app.WATCH:
+		default:
+			return nil, fmt.Errorf("unknown mode %s", name)
+		}
+		if slices.Contains(modes, m) {
+			continue
+		}
+		modes = append(modes, m)
+	}
+	if slices.Contains(modes, app.REPL) && slices.Contains(modes, app.CLI) {
+		return nil, fmt.Errorf("the repl and cli modes both read the command line")
+	}
+	if slices.Contains(modes, app.CLI) && len(args) == 0 {
+		return nil, fmt.Errorf("the cli mode needs a command")
+	}
+	return modes, nil
+}
+
+// newApplication builds the applications of the modes from the
+// configuration, they share the storage
+func newApplication(modes []app.AppMode, config Config, more ...app.Option) (app.Application, error) {
+	s, err := newStorage(config)
+	if err != nil {
+		return nil, err
+	}
+	opts := []app.Option{
+		app.WithMode(modes...),
+		app.WithStorage(s),
+		app.WithInbox(config.inbox),
+		app.WithAudit(newAuditStore(config)),
+		app.WithArgs(config.args),
+		app.WithFuzziness(config.searchFuzziness),
+		app.WithRecovery(config.recoveryDir),
+	}
+	variables := newVariables(modes, config)
+	journal, err := newJournal(config, variables)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, app.WithJournal(journal), app.WithVariables(variables))
+	if config.storage == "json" {
+		saved, err := search.NewSaved(config.storagePath + ".searches")
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, app.WithSavedSearches(saved))
+		colors, err := color.NewStore(config.storagePath + ".colors")
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, app.WithNotebookColors(colors))
+	}
+	if slices.Contains(modes, app.REPL) || slices.Contains(modes, app.CLI) {
+		r, err := replConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, app.WithRepl(r))
+	}
+	if slices.Contains(modes, app.SMTP) {
+		opts = append(opts, app.WithMail(mailConfig(config)))
+	}
+	notifiers := []reminder.Notifier{}
+	if config.smtpServer != "" {
+		sender, err := newSender(config)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, app.WithMailer(sender))
+		if config.remindEmail != "" {
+			notifiers = append(notifiers, reminder.Email{Mailer: sender, To: config.remindEmail})
+		}
+	} else if config.remindEmail != "" {
+		return nil, fmt.Errorf("emailing the reminders needs an smtp server")
+	}
+	if config.remindDesktop {
+		notifiers = append(notifiers, reminder.Desktop{})
+	}
+	if config.remindWebhook != "" {
+		notifiers = append(notifiers, reminder.Webhook{URL: config.remindWebhook})
+	}
+	if len(notifiers) > 0 {
+		opts = append(opts, app.WithReminders(config.remindEvery, notifiers...))
+	}
+	policies, err := archivePolicies(config)
+	if err != nil {
+		return nil, err
+	}
+	if len(policies) > 0 {
+		opts = append(opts, app.WithRetention(config.archiveEvery, policies...))
+	}
+	publisher, err := newPublisher(config)
+	if err != nil {
+		return nil, err
+	}
+	if publisher != nil {
+		opts = append(opts, app.WithPublisher(publisher))
+	}
+	if slices.Contains(modes, app.TELEGRAM) {
+		token := config.telegramToken
+		if env, ok := os.LookupEnv("NOTES_TELEGRAM_TOKEN"); ok {
+			token = env
+		}
+		opts = append(opts, app.WithTelegram(telegram.Config{Token: token, Users: config.telegramUsers}))
+	}
+	if slices.Contains(modes, app.WATCH) {
+		opts = append(opts, app.WithVault(vault.Config{Dir: config.vault, Interval: config.vaultEvery, Write: config.vaultWrite}))
+	}
+	if config.llmModel != "" {
+		key := config.llmKey
+		if env, ok := os.LookupEnv("NOTES_LLM_KEY"); ok {
+			key = env
+		}
+		opts = append(opts, app.WithLanguageModel(llm.NewOpenAI(llm.Config{URL: config.llmUrl, Model: config.llmModel, Key: key})))
+	}
+	if config.checkLinks {
+		opts = append(opts, app.WithLinkChecker(links.NewChecker(0)))
+	}
+	if env, ok := os.LookupEnv("NOTES_SLACK_SECRET"); ok {
+		config.slackSecret = env
+	}
+	if config.slackSecret != "" {
+		opts = append(opts, app.WithSlack(config.slackSecret))
+	}
+	if config.joplinDir != "" {
+		opts = append(opts, app.WithJoplin(config.joplinDir))
+	}
+	if len(config.readOnly) > 0 {
+		opts = append(opts, app.WithAuthorizer(usecase.ReadOnly(config.readOnly)))
+	}
+	if config.accounts {
+		accounts, err := newAccounts(config)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, accounts...)
+	}
+	if config.e2e {
+		opts = append(opts, app.WithSealedContent())
+	}
+	if config.writeQueue > 0 {
+		opts = append(opts, app.WithWriteQueue(config.writeQueue))
+	}
+	if config.quotaNotes != 0 || config.quotaBytes != 0 {
+		opts = append(opts, app.WithQuota(usecase.Quota{Notes: config.quotaNotes, Bytes: config.quotaBytes}))
+	}
+	if config.debugListen != "" {
+		opts = append(opts, app.WithDebug(config.debugListen))
+	}
+	if config.cacheTtl > 0 {
+		opts = append(opts, app.WithResponseCache(config.cacheTtl))
+	}
+	if env, ok := os.LookupEnv("NOTES_ADMIN_TOKEN"); ok {
+		config.adminToken = env
+	}
+	if config.adminToken != "" {
+		opts = append(opts, app.WithAdmin(config.adminToken))
+	}
+	return app.NewApplication(append(opts, more...)...)
+}
+
+// newVariables are the placeholders of the configuration, and the
+// clipboard when the notes are created on this machine
+func newVariables(modes []app.AppMode, config Config) map[string]expand.Variable {
+	variables := map[string]expand.Variable{}
+	for name, value := range config.variables {
+		variables[name] = expand.Value(value)
+	}
+	if slices.Contains(modes, app.REPL) || slices.Contains(modes, app.CLI) {
+		variables["clipboard"] = func(string) (string, error) {
+			text, err := repl.SystemClipboard{}.Read()
+			return strings.TrimRight(text, "\r\n"), err
+		}
+	}
+	return variables
+}
+
+// newJournal reads the template of the daily notes, the usecase has a
+// default one
+// The template may use the placeholders, the builtin ones and the
+// variables, as functions.
+func newJournal(config Config, variables map[string]expand.Variable) (usecase.Journal, error) {
+	journal := usecase.Journal{Notebook: config.journalNotebook}
+	if config.journalTemplate == "" {
+		return journal, nil
+	}
+	funcs := expand.New(expand.Builtins(time.Now)).With(variables).Funcs()
+	t, err := template.New(filepath.Base(config.journalTemplate)).Funcs(funcs).ParseFiles(config.journalTemplate)
+	if err != nil {
+		return journal, err
+	}
+	journal.Template = t
+	return journal, nil
+}
+
+// notify tells systemd the state of the service, when run by systemd
+func notify(state string, report func(error)) {
+	if err := systemd.Notify(state); err != nil {
+		report(err)
+	}
+}
+
+// exitOnError stops the programme when it can't even start
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "notes:", err)
+		os.Exit(1)
+	}
+}
+
+func main() {
+	path, profile := configPath(os.Args[1:]), configProfile(os.Args[1:])
+	config, err := loadConfig(path, profile, defaultConfig())
+	exitOnError(err)
+	flag.String("config", path, "json configuration file")
+	flag.String("profile", profile, "profile of the configuration file overriding its settings, such as work or personal, NOTES_PROFILE by default")
+	flag.BoolVar(&config.confirmDelete, "confirm-delete", config.confirmDelete, "ask for confirmation before deleting a note in the REPL")
+	flag.StringVar(&config.transcriptDir, "transcript", config.transcriptDir, "record the REPL session to a timestamped file in this directory")
+	flag.StringVar(&config.storage, "storage", config.storage, "storage backend, memory or json")
+	flag.StringVar(&config.dataDir, "data-dir", config.dataDir, "directory of the files of the notes which are not configured otherwise")
+	flag.StringVar(&config.storagePath, "storage-path", config.storagePath, "file of the json storage, notes.json in the data directory by default")
+	flag.StringVar(&config.inbox, "inbox", config.inbox, "notebook of quick captures")
+	flag.StringVar(&config.journalNotebook, "journal", config.journalNotebook, "notebook of the daily notes")
+	flag.StringVar(&config.journalTemplate, "journal-template", config.journalTemplate, "file of the text/template of the content of a new daily note, given its .Date")
+	flag.BoolVar(&config.logCommands, "log-commands", config.logCommands, "log every command to stderr")
+	flag.BoolVar(&config.dryRun, "dry-run", config.dryRun, "report what commands would change without changing anything")
+	flag.StringVar(&config.mode, "mode", config.mode, "applications to run together on the same storage, http, smtp, telegram, watch, repl or cli, such as http,repl")
+	flag.StringVar(&config.hooksDir, "hooks", config.hooksDir, "directory of the on-create, on-update and on-delete hooks")
+	flag.IntVar(&config.jobWorkers, "job-workers", config.jobWorkers, "number of hooks run at the same time")
+	flag.IntVar(&config.jobRetries, "job-retries", config.jobRetries, "times a failing hook runs again")
+	flag.IntVar(&config.writeQueue, "write-queue", config.writeQueue, "changes and saves of the json storage waiting their turn, the next ones are refused with 503 over HTTP, 0 doesn't queue them")
+	flag.DurationVar(&config.hookTimeout, "hook-timeout", config.hookTimeout, "kill the hooks running for longer")
+	flag.StringVar(&config.remote, "remote", config.remote, "url of the notes server to sync with")
+	flag.BoolVar(&config.autoSync, "auto-sync", config.autoSync, "sync the json storage with the remote after each CLI command, the changes made offline are pushed on the next sync")
+	flag.StringVar(&config.conflicts, "conflicts", config.conflicts, "how sync resolves notes changed on both sides, skip, last-writer-wins, keep-both or ask")
+	flag.StringVar(&config.mailListen, "mail-listen", config.mailListen, "address of the smtp mode receiving emails as notes")
+	flag.Func("mail-to", "comma separated addresses whose emails become notes, any address by default", func(value string) error {
+		config.mailTo = strings.Split(value, ",")
+		return nil
+	})
+	flag.StringVar(&config.mailAttachments, "mail-attachments", config.mailAttachments, "directory of the attachments of the emails received")
+	flag.StringVar(&config.smtpServer, "smtp-server", config.smtpServer, "host:port of the smtp server notes are emailed through")
+	flag.StringVar(&config.mailFrom, "mail-from", config.mailFrom, "sender address of the notes emailed")
+	flag.StringVar(&config.joplinDir, "joplin", config.joplinDir, "directory of the Joplin WebDAV sync target served on /joplin/ in http mode, not with -accounts")
+	flag.StringVar(&config.mqttBroker, "mqtt", config.mqttBroker, "host:port of the MQTT broker the note events are published to")
+	flag.StringVar(&config.mqttPrefix, "mqtt-prefix", config.mqttPrefix, "prefix of the MQTT topics, events go to PREFIX/ID/KIND")
+	flag.IntVar(&config.mqttQos, "mqtt-qos", config.mqttQos, "QoS of the MQTT events, 0 or 1")
+	flag.StringVar(&config.searchIndex, "search-index", config.searchIndex, "index the notes are searched with, memory or bleve")
+	flag.StringVar(&config.indexPath, "index-path", config.indexPath, "directory of the bleve index of the json storage, next to the storage by default")
+	flag.BoolVar(&config.accounts, "accounts", config.accounts, "let several people share the HTTP server with their own accounts")
+	flag.StringVar(&config.embeddingsModel, "embeddings-model", config.embeddingsModel, "model embedding the notes for the semantic search, which is off without one")
+	flag.StringVar(&config.embeddingsUrl, "embeddings-url", config.embeddingsUrl, "OpenAI compatible API of the embeddings model, such as http://localhost:11434/v1 for Ollama")
+	flag.StringVar(&config.llmModel, "llm-model", config.llmModel, "language model summarizing the notes and suggesting their titles, which are off without one")
+	flag.StringVar(&config.llmUrl, "llm-url", config.llmUrl, "OpenAI compatible API of the language model, such as http://localhost:11434/v1 for Ollama")
+	flag.IntVar(&config.searchFuzziness, "fuzziness", config.searchFuzziness, "typos tolerated in each word searched with the memory index, 0 for exact words")
+	flag.StringVar(&config.auditPath, "audit", config.auditPath, "append the audit log of note changes to this file")
+	flag.StringVar(&config.keyPath, "key", config.keyPath, "file of the key sync encrypts the notes with, see the key command")
+	flag.BoolVar(&config.e2e, "e2e", config.e2e, "only keep the notes encrypted by the clients, the server can't read them")
+	flag.IntVar(&config.quotaNotes, "quota-notes", config.quotaNotes, "number of notes each user may keep, 0 for no limit")
+	flag.IntVar(&config.quotaBytes, "quota-bytes", config.quotaBytes, "total size in bytes of the contents of the notes of each user, 0 for no limit")
+	flag.IntVar(&config.compressAbove, "compress-above", config.compressAbove, "size in bytes of the contents the json storage compresses, 0 for none")
+	flag.StringVar(&config.debugListen, "debug-listen", config.debugListen, "address serving the pprof profiles of the http mode on /debug/pprof/, such as 127.0.0.1:6060")
+	flag.DurationVar(&config.cacheTtl, "cache-ttl", config.cacheTtl, "cache the listings and searches of the http mode for that long at most, until the notes change")
+	flag.StringVar(&config.recoveryDir, "recovery-dir", config.recoveryDir, "directory the notes are written to when the programme panics or gets SIGQUIT, recovery in the data directory by default")
+	flag.StringVar(&config.locale, "locale", config.locale, "language of the REPL, "+strings.Join(i18n.Languages(), " or ")+", from LC_ALL, LC_MESSAGES or LANG by default")
+	flag.StringVar(&config.format, "format", config.format, "format of the results of the REPL and the CLI, plain, json, yaml, xml or table, a command chooses another with --format=json")
+	flag.BoolVar(&config.remindPrompt, "remind-prompt", config.remindPrompt, "print how many notes are due today before the REPL prompt, a note is due at the date of a due:2006-01-02 or due:2006-01-02T15:04 word")
+	flag.BoolVar(&config.dashboard, "dashboard", config.dashboard, "print the pinned notes, those due today and the recent ones when the REPL starts")
+	flag.BoolVar(&config.remindDesktop, "remind-desktop", config.remindDesktop, "show a desktop notification when a note falls due")
+	flag.StringVar(&config.remindWebhook, "remind-webhook", config.remindWebhook, "post the notes falling due as json to this URL")
+	flag.StringVar(&config.remindEmail, "remind-email", config.remindEmail, "email the notes falling due to this address through the smtp server")
+	flag.DurationVar(&config.remindEvery, "remind-every", config.remindEvery, "how often the due dates are checked")
+	flag.Func("archive", "comma separated retention policies NOTEBOOK:AGE[:ARCHIVE], such as inbox:180d to archive the notes of the inbox untouched for 180 days, see the archive command", func(value string) error {
+		config.archive = strings.Split(value, ",")
+		return nil
+	})
+	flag.DurationVar(&config.archiveEvery, "archive-every", config.archiveEvery, "how often the retention policies are applied")
+	flag.StringVar(&config.vault, "vault", config.vault, "folder of Markdown files the watch mode mirrors into the notes, such as an Obsidian vault")
+	flag.DurationVar(&config.vaultEvery, "vault-every", config.vaultEvery, "how often the watch mode scans the vault")
+	flag.BoolVar(&config.vaultWrite, "vault-write", config.vaultWrite, "write the changes of the notes back to the vault in watch mode")
+	flag.Func("var", "custom placeholder NAME=VALUE of the notes created, used as {{NAME}}, may be repeated", func(value string) error {
+		name, v, ok := strings.Cut(value, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("%q is not NAME=VALUE", value)
+		}
+		if config.variables == nil {
+			config.variables = map[string]string{}
+		}
+		config.variables[name] = v
+		return nil
+	})
+	flag.BoolVar(&config.checkLinks, "check-links", config.checkLinks, "follow the web links of the notes when they are linted, the server fetches them but not from its own network")
+	flag.StringVar(&config.batchFile, "batch", config.batchFile, "run the REPL commands of a file, such as a transcript, and exit")
+	flag.Parse()
+	config, err = withPaths(config)
+	exitOnError(err)
+	args := flag.Args()
+	if len(args) > 0 {
+		if subcommand, ok := subcommands[args[0]]; ok {
+			subcommand(config, args[1:])
+			return
+		}
+	}
+	config.args = args
+	modes, err := parseModes(config.mode, args)
+	exitOnError(err)
+	report := func(err error) {
+		fmt.Fprintln(os.Stderr, "notes:", err)
+	}
+	queue := jobs.New(jobs.Config{Workers: config.jobWorkers, Retries: config.jobRetries}, report)
+	h := hooks.New(config.hooksDir, config.hookTimeout, queue, report)
+	r := newReloader(path, config.profile, &config, h)
+	r.watch(report)
+	more := []app.Option{
+		app.WithSubscriber(h),
+		app.WithLogger(r.logger),
+		app.WithAdminInfo("jobs", func() any { return queue.Stats() }),
+		app.WithAdminInfo("config", r.redacted),
+		app.WithAdminReload(r.reload),
+	}
+	m, err := newMqtt(config)
+	exitOnError(err)
+	if m != nil {
+		more = append(more, app.WithSubscriber(m))
+	}
+	b, err := newBleve(config)
+	exitOnError(err)
+	if b != nil {
+		more = append(more, app.WithSearcher(b))
+	}
+	e, err := newEmbeddings(config)
+	exitOnError(err)
+	if e != nil {
+		more = append(more, app.WithSemanticSearcher(e))
+	}
+	if slices.Contains(modes, app.HTTP) {
+		listeners, err := systemd.Listeners()
+		exitOnError(err)
+		if len(listeners) > 0 {
+			more = append(more, app.WithListener(listeners[0]))
+		}
+	}
+	a, err := newApplication(modes, config, more...)
+	exitOnError(err)
+	// the storage is loaded, systemd may start the services after this
+	// one while it runs
+	notify("READY=1", report)
+	stop := make(chan struct{})
+	go systemd.Watchdog(stop, report)
+	a.Run()
+	if config.autoSync && config.remote != "" && config.storage == "json" && slices.Equal(modes, []app.AppMode{app.CLI}) {
+		autoSync(config)
+	}
+	close(stop)
+	notify("STOPPING=1", report)
+	queue.Close()
+	if m != nil {
+		m.Close()
+	}
+	if b != nil {
+		exitOnError(b.Close())
+	}
+	if e != nil {
+		exitOnError(e.Close())
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"notes/internal/hooks"
+)
+
+// reloader reloads the settings which may change while the applications
+// run from the configuration file, on SIGHUP or POST /admin/reload
+// These are the logging of the commands and the directory and timeout
+// of the hooks, the flags given on the command line keep their value.
+type reloader struct {
+	path    string
+	profile string
+	mutex   *sync.Mutex
+	config  *Config
+	logger  *log.Logger
+	hooks   hooks.Hooks
+}
+
+func newReloader(path, profile string, config *Config, h hooks.Hooks) reloader {
+	r := reloader{path: path, profile: profile, mutex: &sync.Mutex{}, config: config, logger: log.New(io.Discard, "", log.LstdFlags), hooks: h}
+	r.apply()
+	return r
+}
+
+// watch reloads on every SIGHUP, failures are given to report
+func (r reloader) watch(report func(error)) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			err := r.reload()
+			if err != nil {
+				report(err)
+			}
+		}
+	}()
+}
+
+func (r reloader) reload() error {
+	file, err := loadConfig(r.path, r.profile, defaultConfig())
+	if err != nil {
+		return err
+	}
+	given := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if !given["log-commands"] {
+		r.config.logCommands = file.logCommands
+	}
+	if !given["hooks"] {
+		r.config.hooksDir = file.hooksDir
+	}
+	if !given["hook-timeout"] {
+		r.config.hookTimeout = file.hookTimeout
+	}
+	r.apply()
+	return nil
+}
+
+// apply gives the settings to the components, the commands are logged
+// to nowhere rather than not logged so the logger can be turned on
+func (r reloader) apply() {
+	if r.config.logCommands {
+		r.logger.SetOutput(os.Stderr)
+	} else {
+		r.logger.SetOutput(io.Discard)
+	}
+	r.hooks.Reload(r.config.hooksDir, r.config.hookTimeout)
+}
+
+// redacted is the configuration of /admin/config
+func (r reloader) redacted() any {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.config.redacted()
+}
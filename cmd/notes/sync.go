@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"notes/internal/note"
+	"notes/internal/remote"
+	"notes/internal/usecase"
+	"notes/pkg/client"
+)
+
+func init() {
+	subcommands["sync"] = runSync
+}
+
+// runSync synchronizes the json storage with a notes server, the url of
+// the server defaults to the remote of the configuration
+// The json storage is the offline copy of the notes of the server, they
+// are read and changed without it between two syncs. --pending lists the
+// local changes the next sync pushes.
+func runSync(config Config, args []string) {
+	pending := len(args) > 0 && args[0] == "--pending"
+	if pending {
+		args = args[1:]
+	}
+	if len(args) > 1 || (len(args) == 0 && config.remote == "" && !pending) {
+		fmt.Fprintln(os.Stderr, "usage: sync [--pending] [URL]")
+		os.Exit(2)
+	}
+	if config.storage != "json" {
+		exitOnError(fmt.Errorf("sync needs the json storage"))
+	}
+	if pending {
+		printPending(config)
+		return
+	}
+	url := config.remote
+	if len(args) == 1 {
+		url = args[0]
+	}
+	report, err := syncWith(config, url)
+	exitOnError(err)
+	for _, line := range report.Pushed {
+		fmt.Println("Pushed", line)
+	}
+	for _, line := range report.Pulled {
+		fmt.Println("Pulled", line)
+	}
+	for _, line := range report.Conflicts {
+		fmt.Println("Conflict", line)
+	}
+	for _, line := range report.Failed {
+		fmt.Println("Failed", line)
+	}
+}
+
+// syncWith syncs the json storage with the server at url, nothing is
+// saved when the server can't be reached and the local changes wait for
+// the next sync
+func syncWith(config Config, url string) (remote.Report, error) {
+	u, err := newUsecase(config)
+	if err != nil {
+		return remote.Report{}, err
+	}
+	state, err := remote.LoadState(config.storagePath + ".sync")
+	if err != nil {
+		return remote.Report{}, err
+	}
+	resolver, err := newResolver(config.conflicts)
+	if err != nil {
+		return remote.Report{}, err
+	}
+	client, err := newRemote(config, url)
+	if err != nil {
+		return remote.Report{}, err
+	}
+	state, report, err := remote.Sync(u, client, state, currentUser(), resolver)
+	if err != nil {
+		return report, err
+	}
+	_, err = u.Save.Execute(usecase.SaveMessage{})
+	if err != nil {
+		return report, err
+	}
+	return report, state.Save()
+}
+
+// newRemote calls the server at url with the token and the key of the
+// configuration
+func newRemote(config Config, url string) (remote.Client, error) {
+	token := config.remoteToken
+	if env, ok := os.LookupEnv("NOTES_REMOTE_TOKEN"); ok {
+		token = env
+	}
+	options := []client.Option{}
+	if token != "" {
+		options = append(options, client.WithToken(token))
+	}
+	c := remote.NewClient(url, options...)
+	key, err := loadKey(config)
+	if err != nil {
+		return c, err
+	}
+	if key != nil {
+		c = c.WithKey(*key)
+	}
+	return c, nil
+}
+
+// printPending lists the local changes since the last sync
+func printPending(config Config) {
+	u, err := newUsecase(config)
+	exitOnError(err)
+	state, err := remote.LoadState(config.storagePath + ".sync")
+	exitOnError(err)
+	pending, err := remote.Pending(u, state, currentUser())
+	exitOnError(err)
+	if len(pending) == 0 {
+		fmt.Println("Nothing to push")
+	}
+	for _, line := range pending {
+		fmt.Println("Pending", line)
+	}
+}
+
+// autoSync syncs the changes of a CLI command with the remote, a server
+// out of reach only delays them to the next sync
+func autoSync(config Config) {
+	report, err := syncWith(config, config.remote)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "notes: offline, the changes are pushed on the next sync:", err)
+		return
+	}
+	for _, line := range report.Conflicts {
+		fmt.Fprintln(os.Stderr, "notes: conflict", line)
+	}
+	for _, line := range report.Failed {
+		fmt.Fprintln(os.Stderr, "notes: failed", line)
+	}
+}
+
+func newResolver(conflicts string) (remote.Resolver, error) {
+	switch conflicts {
+	case "skip":
+		return nil, nil
+	case "last-writer-wins":
+		return remote.LastWriterWins{}, nil
+	case "keep-both":
+		return remote.KeepBothVersions{}, nil
+	case "ask":
+		return askResolver{in: bufio.NewReader(os.Stdin), out: os.Stdout}, nil
+	default:
+		return nil, fmt.Errorf("unknown conflict resolution %s", conflicts)
+	}
+}
+
+// askResolver shows both versions of a conflicting note and lets the
+// user pick one or type the merged content
+type askResolver struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (r askResolver) Resolve(local remote.Version, other remote.Version) (remote.Resolution, note.Note, error) {
+	r.show("Local", local)
+	r.show("Remote", other)
+	for {
+		fmt.Fprint(r.out, "Keep [l]ocal, [r]emote, [b]oth, [m]erge or [s]kip? ")
+		line, err := r.in.ReadString('\n')
+		if err != nil && line == "" {
+			return remote.Skip, note.Note{}, err
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "l":
+			return remote.KeepLocal, note.Note{}, nil
+		case "r":
+			return remote.KeepRemote, note.Note{}, nil
+		case "b":
+			return remote.KeepBoth, note.Note{}, nil
+		case "m":
+			merged, err := r.merge(local, other)
+			return remote.Merge, merged, err
+		case "s":
+			return remote.Skip, note.Note{}, nil
+		}
+	}
+}
+
+func (r askResolver) show(side string, v remote.Version) {
+	if v.Note.Id == 0 {
+		fmt.Fprintf(r.out, "%s: deleted\n", side)
+		return
+	}
+	changed := "unknown"
+	if !v.ChangedAt.IsZero() {
+		changed = v.ChangedAt.Local().Format("2006-01-02 15:04:05")
+	}
+	fmt.Fprintf(r.out, "%s: %q, changed %s\n%s\n", side, v.Note.Name, changed, v.Note.Content)
+}
+
+// merge reads the merged content up to a line holding a single dot, the
+// name is kept unless another one is typed
+func (r askResolver) merge(local remote.Version, other remote.Version) (note.Note, error) {
+	merged := local.Note
+	if merged.Id == 0 {
+		merged = other.Note
+	}
+	fmt.Fprintf(r.out, "Name [%s]: ", merged.Name)
+	name, err := r.in.ReadString('\n')
+	if err != nil {
+		return merged, err
+	}
+	if strings.TrimSpace(name) != "" {
+		merged.Name = strings.TrimSpace(name)
+	}
+	fmt.Fprintln(r.out, "Content, end with a line holding a single dot:")
+	lines := []string{}
+	for {
+		line, err := r.in.ReadString('\n')
+		if err != nil && line == "" {
+			return merged, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "." {
+			break
+		}
+		lines = append(lines, line)
+	}
+	merged.Content = strings.Join(lines, "\n")
+	return merged, nil
+}
@@ -0,0 +1,34 @@
+package app
+
+import (
+	"fmt"
+
+	"notes/internal/storage"
+	"notes/internal/usecase"
+)
+
+// storageInfo describes the storage on /admin/storage
+func storageInfo(s storage.Storage) func() any {
+	return func() any {
+		info := map[string]any{"backend": fmt.Sprintf("%T", s), "notes": s.Count(storage.Filter{})}
+		if p, ok := s.(storage.Persistent); ok {
+			info["unsaved"] = p.Unsaved()
+		}
+		return info
+	}
+}
+
+// indexInfo describes the indexes on /admin/index, the semantic index
+// tells how many notes are waiting to be embedded
+func indexInfo(searcher usecase.Searcher, semantic usecase.Searcher) func() any {
+	return func() any {
+		info := map[string]any{"searcher": fmt.Sprintf("%T", searcher)}
+		if semantic != nil {
+			info["semantic"] = fmt.Sprintf("%T", semantic)
+		}
+		if p, ok := semantic.(interface{ Pending() int }); ok {
+			info["pendingEmbeddings"] = p.Pending()
+		}
+		return info
+	}
+}
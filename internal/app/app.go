@@ -0,0 +1,598 @@
+// Package app wires the storage, the usecases and an application
+// together. Every component has a default which an option replaces, so
+// the notes can be embedded or run against test doubles.
+package app
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	"notes/internal/audit"
+	"notes/internal/collab"
+	"notes/internal/color"
+	"notes/internal/command"
+	"notes/internal/expand"
+	"notes/internal/httpapi"
+	"notes/internal/joplin"
+	"notes/internal/mail"
+	"notes/internal/note"
+	"notes/internal/present"
+	"notes/internal/reminder"
+	"notes/internal/repl"
+	"notes/internal/retention"
+	"notes/internal/search"
+	"notes/internal/slack"
+	"notes/internal/storage"
+	"notes/internal/telegram"
+	"notes/internal/usecase"
+	"notes/internal/vault"
+)
+
+// Application
+type Application interface {
+	Run()
+}
+
+type AppMode string
+
+const (
+	HTTP AppMode = "HTTP"
+	REPL AppMode = "REPL"
+	CLI  AppMode = "CLI"
+	// SMTP receives emails as notes
+	SMTP AppMode = "SMTP"
+	// TELEGRAM answers the messages sent to a Telegram bot
+	TELEGRAM AppMode = "TELEGRAM"
+	// WATCH mirrors a folder of Markdown files into the notes
+	WATCH AppMode = "WATCH"
+)
+
+// Presenter writes the results of the commands, it is used by the REPL
+// and the HTTP applications alike
+type Presenter interface {
+	Present(o any, w io.Writer)
+}
+
+type options struct {
+	modes     []AppMode
+	storage   storage.Storage
+	clock     usecase.Clock
+	inbox     note.Notebook
+	audit     audit.Store
+	logger    *log.Logger
+	authorize usecase.Authorizer
+	listeners []usecase.Subscriber
+	presenter Presenter
+	formats   []present.Format
+	listener  net.Listener
+	repl      repl.Config
+	mail      mail.Config
+	mailer    usecase.Mailer
+	publisher usecase.Publisher
+	links     usecase.LinkChecker
+	model     usecase.LanguageModel
+	variables map[string]expand.Variable
+	searcher  usecase.Searcher
+	fuzziness int
+	semantic  usecase.Searcher
+	saved     usecase.SavedSearches
+	colors    usecase.NotebookColors
+	users     usecase.Users
+	tokens    usecase.Tokens
+	shares    usecase.Shares
+	sessions  httpapi.Sessions
+	apiTokens usecase.ApiTokens
+	collab    *collab.Hub
+	sealed    bool
+	quota     usecase.Quota
+	queue     int
+	journal   usecase.Journal
+	joplin    string
+	telegram  telegram.Config
+	vault     vault.Config
+	watcher   vault.Watcher
+	slack     string
+	debug     string
+	cacheTtl  time.Duration
+	cache     *httpapi.Cache
+	events    *httpapi.Events
+	admin     httpapi.Admin
+	recovery  string
+	reminders []reminder.Notifier
+	remindAt  time.Duration
+	policies  []usecase.Policy
+	archiveAt time.Duration
+	args      []string
+}
+
+// Option replaces a default of NewApplication
+type Option func(*options)
+
+// WithMode chooses the application, REPL by default
+// Several modes run together on the same storage until one of them
+// stops, such as an HTTP server alongside a REPL
+func WithMode(modes ...AppMode) Option {
+	return func(o *options) { o.modes = modes }
+}
+
+// WithStorage replaces the in-memory storage
+func WithStorage(s storage.Storage) Option {
+	return func(o *options) { o.storage = s }
+}
+
+// WithClock replaces the system clock
+func WithClock(clock usecase.Clock) Option {
+	return func(o *options) { o.clock = clock }
+}
+
+// WithInbox sets the notebook of quick captures, "inbox" by default
+func WithInbox(inbox note.Notebook) Option {
+	return func(o *options) { o.inbox = inbox }
+}
+
+// WithAudit replaces the in-memory audit log
+func WithAudit(store audit.Store) Option {
+	return func(o *options) { o.audit = store }
+}
+
+// WithLogger logs every command, nothing is logged by default
+func WithLogger(logger *log.Logger) Option {
+	return func(o *options) { o.logger = logger }
+}
+
+// WithAuthorizer checks every command with an authorizer, every
+// command is allowed by default
+func WithAuthorizer(a usecase.Authorizer) Option {
+	return func(o *options) { o.authorize = a }
+}
+
+// WithSubscriber subscribes to the note events, on top of the audit log
+func WithSubscriber(s usecase.Subscriber) Option {
+	return func(o *options) { o.listeners = append(o.listeners, s) }
+}
+
+// WithPresenter replaces the presenter of the application, text for the
+// REPL and json for HTTP
+func WithPresenter(p Presenter) Option {
+	return func(o *options) { o.presenter = p }
+}
+
+// WithFormat adds an output format to the REPL, chosen by --format, and
+// to HTTP, chosen by the Accept header
+func WithFormat(f present.Format) Option {
+	return func(o *options) { o.formats = append(o.formats, f) }
+}
+
+// WithListener serves HTTP on a listener instead of 127.0.0.1:80
+func WithListener(l net.Listener) Option {
+	return func(o *options) { o.listener = l }
+}
+
+// WithRepl configures the REPL and CLI applications
+func WithRepl(config repl.Config) Option {
+	return func(o *options) { o.repl = config }
+}
+
+// WithMail configures the SMTP application, the emails go to the inbox
+// notebook unless the configuration names another one
+func WithMail(config mail.Config) Option {
+	return func(o *options) { o.mail = config }
+}
+
+// WithMailer sends the notes emailed by the usecases, emailing fails
+// without a mailer
+func WithMailer(m usecase.Mailer) Option {
+	return func(o *options) { o.mailer = m }
+}
+
+// WithPublisher publishes the notes, publishing fails without a
+// publisher
+func WithPublisher(p usecase.Publisher) Option {
+	return func(o *options) { o.publisher = p }
+}
+
+// WithLinkChecker follows the web links of the notes when they are
+// linted, only the wiki links are checked without a link checker
+func WithLinkChecker(c usecase.LinkChecker) Option {
+	return func(o *options) { o.links = c }
+}
+
+// WithLanguageModel summarizes the notes and suggests their titles,
+// which fail without a language model
+func WithLanguageModel(m usecase.LanguageModel) Option {
+	return func(o *options) { o.model = m }
+}
+
+// WithSearcher replaces the in-memory index of the notes, a searcher
+// which is also a subscriber is kept up to date with the note events
+func WithSearcher(s usecase.Searcher) Option {
+	return func(o *options) { o.searcher = s }
+}
+
+// WithFuzziness sets the number of typos the in-memory index tolerates
+// in each word searched, 1 by default and none with 0
+func WithFuzziness(level int) Option {
+	return func(o *options) { o.fuzziness = level }
+}
+
+// WithSemanticSearcher finds the notes by meaning for the semantic
+// searches, which fail without it, a searcher which is also a subscriber
+// is kept up to date with the note events
+func WithSemanticSearcher(s usecase.Searcher) Option {
+	return func(o *options) { o.semantic = s }
+}
+
+// WithSavedSearches keeps the smart notebooks, in memory by default
+func WithSavedSearches(s usecase.SavedSearches) Option {
+	return func(o *options) { o.saved = s }
+}
+
+// WithNotebookColors keeps the colors of the notebooks, in memory by
+// default
+func WithNotebookColors(c usecase.NotebookColors) Option {
+	return func(o *options) { o.colors = c }
+}
+
+// WithAccounts lets several people share the server, each with their
+// own account, the HTTP API then requires a token from POST /login
+// The users share their notes with each other through the shares.
+func WithAccounts(users usecase.Users, tokens usecase.Tokens, shares usecase.Shares) Option {
+	return func(o *options) {
+		o.users = users
+		o.tokens = tokens
+		o.shares = shares
+	}
+}
+
+// WithSealedContent only keeps the contents the clients encrypted, see
+// package crypt, the server can't read the notes then
+func WithSealedContent() Option {
+	return func(o *options) { o.sealed = true }
+}
+
+// WithQuota limits the notes of each user, they are not limited by
+// default
+func WithQuota(q usecase.Quota) Option {
+	return func(o *options) { o.quota = q }
+}
+
+// WithWriteQueue queues up to size changes of the notes of a file
+// storage and saves to its file, the ones coming when the queue is full
+// are refused as busy, they are not queued by default
+func WithWriteQueue(size int) Option {
+	return func(o *options) { o.queue = size }
+}
+
+// WithJournal sets the notebook and the template of the daily notes,
+// see usecase.Journal
+func WithJournal(j usecase.Journal) Option {
+	return func(o *options) { o.journal = j }
+}
+
+// WithVariables adds variables to the placeholders of the notes created,
+// besides {{date}}, {{time}}, {{datetime}} and {{uuid}}, see package
+// expand
+func WithVariables(variables map[string]expand.Variable) Option {
+	return func(o *options) {
+		if o.variables == nil {
+			o.variables = map[string]expand.Variable{}
+		}
+		maps.Copy(o.variables, variables)
+	}
+}
+
+// WithApiTokens lets the users create personal tokens for their
+// scripts on /tokens
+func WithApiTokens(t usecase.ApiTokens) Option {
+	return func(o *options) { o.apiTokens = t }
+}
+
+// WithSessions keeps the sessions of the browsers logging in with an
+// account in a store, in memory by default
+func WithSessions(s httpapi.Sessions) Option {
+	return func(o *options) { o.sessions = s }
+}
+
+// WithJoplin serves a Joplin sync target on /joplin/ in HTTP mode, its
+// files are kept in dir, it is refused with accounts
+func WithJoplin(dir string) Option {
+	return func(o *options) { o.joplin = dir }
+}
+
+// WithTelegram configures the Telegram application
+func WithTelegram(config telegram.Config) Option {
+	return func(o *options) { o.telegram = config }
+}
+
+// WithVault configures the watch application
+func WithVault(config vault.Config) Option {
+	return func(o *options) { o.vault = config }
+}
+
+// WithSlack answers the /note slash command of a Slack app on
+// /slack/command in HTTP mode, secret is the signing secret of the app
+func WithSlack(secret string) Option {
+	return func(o *options) { o.slack = secret }
+}
+
+// WithDebug serves the profiles of net/http/pprof on a second address
+// in HTTP mode, see httpapi.Config
+func WithDebug(addr string) Option {
+	return func(o *options) { o.debug = addr }
+}
+
+// WithResponseCache caches the listings and searches of the HTTP mode
+// for ttl at most, until the notes change
+func WithResponseCache(ttl time.Duration) Option {
+	return func(o *options) { o.cacheTtl = ttl }
+}
+
+// WithAdmin serves the runtime information of the HTTP mode on /admin/
+// to the bearer of token, the storage, the indexes and the clients
+// editing notes together are described, see WithAdminInfo for more
+func WithAdmin(token string) Option {
+	return func(o *options) { o.admin.Token = token }
+}
+
+// WithAdminInfo adds a part to the runtime information of /admin/
+func WithAdminInfo(name string, info func() any) Option {
+	return func(o *options) {
+		if o.admin.Info == nil {
+			o.admin.Info = map[string]func() any{}
+		}
+		o.admin.Info[name] = info
+	}
+}
+
+// WithAdminReload reloads the settings on POST /admin/reload
+func WithAdminReload(reload func() error) Option {
+	return func(o *options) { o.admin.Reload = reload }
+}
+
+// WithRecovery writes the notes to dir when an application panics or
+// on SIGQUIT, the temporary directory by default
+func WithRecovery(dir string) Option {
+	return func(o *options) { o.recovery = dir }
+}
+
+// WithReminders runs a scheduler beside the applications other than
+// the CLI, it checks the due dates of the notes every interval and
+// gives the notes falling due to the notifiers, see package reminder
+func WithReminders(interval time.Duration, notifiers ...reminder.Notifier) Option {
+	return func(o *options) {
+		o.remindAt = interval
+		o.reminders = append(o.reminders, notifiers...)
+	}
+}
+
+// WithRetention archives the stale notes beside the applications other
+// than the CLI, the policies are applied every interval, see package
+// retention
+func WithRetention(interval time.Duration, policies ...usecase.Policy) Option {
+	return func(o *options) {
+		o.archiveAt = interval
+		o.policies = append(o.policies, policies...)
+	}
+}
+
+// WithArgs gives the command line run in CLI mode
+func WithArgs(args []string) Option {
+	return func(o *options) { o.args = args }
+}
+
+// NewApplication builds the application of the modes, it fails when a
+// component can't be built
+func NewApplication(opts ...Option) (Application, error) {
+	o := options{
+		modes:     []AppMode{REPL},
+		storage:   storage.NewInMemory(storage.NewSequence(0)),
+		clock:     usecase.SystemClock{},
+		inbox:     "inbox",
+		audit:     audit.NewMemory(),
+		fuzziness: 1,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	metrics := usecase.NewMetrics()
+	decorators := []usecase.Decorator{metrics.Decorator, usecase.Retrying(3, 50*time.Millisecond)}
+	if o.authorize != nil {
+		decorators = append(decorators, usecase.Authorizing(o.authorize, o.storage))
+	}
+	if o.sealed {
+		decorators = append(decorators, usecase.Sealing)
+	}
+	if _, ok := o.storage.(storage.Persistent); ok && o.queue > 0 {
+		queue := usecase.NewWriteQueue(o.queue)
+		decorators = append(decorators, usecase.Queueing(queue))
+		WithAdminInfo("writeQueue", func() any { return queue.Stats() })(&o)
+	}
+	if o.logger != nil {
+		decorators = append([]usecase.Decorator{usecase.Logging(o.logger)}, decorators...)
+	}
+	events := usecase.NewEventBus(o.clock)
+	events.Subscribe(audit.NewRecorder(o.audit, func(err error) {
+		fmt.Fprintln(os.Stderr, "notes: audit:", err)
+	}))
+	for _, s := range o.listeners {
+		events.Subscribe(s)
+	}
+	if o.searcher == nil {
+		o.searcher = search.NewIndex(o.storage.ReadAll(), o.fuzziness)
+	}
+	if s, ok := o.searcher.(usecase.Subscriber); ok {
+		events.Subscribe(s)
+	}
+	if s, ok := o.semantic.(usecase.Subscriber); ok {
+		events.Subscribe(s)
+	}
+	if o.saved == nil {
+		saved, err := search.NewSaved("")
+		if err != nil {
+			return nil, err
+		}
+		o.saved = saved
+	}
+	if o.colors == nil {
+		colors, err := color.NewStore("")
+		if err != nil {
+			return nil, err
+		}
+		o.colors = colors
+	}
+	expander := expand.New(expand.Builtins(o.clock.Now)).With(o.variables)
+	u := usecase.New(usecase.Deps{
+		Storage:   o.storage,
+		Clock:     o.clock,
+		Inbox:     o.inbox,
+		Events:    events,
+		Log:       o.audit,
+		Mailer:    o.mailer,
+		Publisher: o.publisher,
+		Searcher:  o.searcher,
+		Semantic:  o.semantic,
+		Saved:     o.saved,
+		Users:     o.users,
+		Tokens:    o.tokens,
+		Shares:    o.shares,
+		ApiTokens: o.apiTokens,
+		Links:     o.links,
+		Model:     o.model,
+		Colors:    o.colors,
+		Quota:     o.quota,
+		Journal:   o.journal,
+		Expander:  expander,
+	}, decorators...)
+	if len(o.modes) == 0 {
+		return nil, fmt.Errorf("no application mode")
+	}
+	if slices.Contains(o.modes, HTTP) {
+		o.collab = collab.NewHub(u)
+		events.Subscribe(o.collab)
+		o.events = httpapi.NewEvents()
+		events.Subscribe(o.events)
+		if o.cacheTtl > 0 {
+			o.cache = httpapi.NewCache(o.cacheTtl)
+			events.Subscribe(o.cache)
+		}
+		if o.admin.Token != "" {
+			WithAdminInfo("storage", storageInfo(o.storage))(&o)
+			WithAdminInfo("index", indexInfo(o.searcher, o.semantic))(&o)
+			WithAdminInfo("clients", func() any { return o.collab.Clients() })(&o)
+		}
+	}
+	if slices.Contains(o.modes, WATCH) {
+		if o.vault.Dir == "" {
+			return nil, fmt.Errorf("the watch mode needs a vault")
+		}
+		o.watcher = vault.New(u, o.vault, func(err error) {
+			fmt.Fprintln(os.Stderr, "notes:", err)
+		})
+		events.Subscribe(o.watcher)
+	}
+	r := recovery{storage: o.storage, dir: o.recovery}
+	applications := []Application{}
+	for _, mode := range o.modes {
+		a, err := newApplication(mode, u, metrics, o)
+		if err != nil {
+			return nil, err
+		}
+		applications = append(applications, a)
+	}
+	background := slices.ContainsFunc(o.modes, func(m AppMode) bool { return m != CLI })
+	report := func(err error) {
+		fmt.Fprintln(os.Stderr, "notes:", err)
+	}
+	if len(o.reminders) > 0 && background {
+		applications = append(applications, reminder.NewScheduler(u, o.clock, o.remindAt, o.reminders, report))
+	}
+	if len(o.policies) > 0 && background {
+		applications = append(applications, retention.NewArchiver(u, o.policies, o.archiveAt, report))
+	}
+	if len(applications) == 1 {
+		a := applications[0]
+		// a server alone still saves when interrupted
+		if _, ok := a.(Stopper); ok {
+			return group{applications: applications, usecase: u, recovery: r}, nil
+		}
+		return recovering{Application: a, recovery: r}, nil
+	}
+	return group{applications: applications, usecase: u, recovery: r}, nil
+}
+
+func newApplication(mode AppMode, u usecase.Usecase, metrics *usecase.Metrics, o options) (Application, error) {
+	switch mode {
+	case REPL:
+		return newRepl(u, o)
+	case CLI:
+		r, err := newRepl(u, o)
+		if err != nil {
+			return nil, err
+		}
+		return repl.NewCli(r, o.args), nil
+	case HTTP:
+		handlers := map[string]http.Handler{}
+		if o.joplin != "" {
+			// the sync target is one folder for everyone, its requests
+			// are neither authenticated nor limited to the notes of a user
+			if o.users != nil {
+				return nil, fmt.Errorf("the joplin sync target can't be served with accounts, it would share the notes of every user")
+			}
+			handlers["/joplin/"] = joplin.New(u, o.joplin, "/joplin/")
+		}
+		if o.slack != "" {
+			handlers["POST /slack/command"] = slack.New(u, o.slack, o.clock)
+		}
+		return httpapi.New(u, httpapi.Config{
+			Routes:    command.New(u).Routes,
+			Metrics:   metrics,
+			Presenter: o.presenter,
+			Formats:   o.formats,
+			Listener:  o.listener,
+			Handlers:  handlers,
+			Accounts:  o.users != nil,
+			Sessions:  o.sessions,
+			Collab:    o.collab,
+			Debug:     o.debug,
+			Cache:     o.cache,
+			Events:    o.events,
+			Admin:     o.admin,
+		}), nil
+	case SMTP:
+		config := o.mail
+		if config.Notebook == "" {
+			config.Notebook = o.inbox
+		}
+		return mail.NewServer(u, config), nil
+	case TELEGRAM:
+		if o.telegram.Token == "" {
+			return nil, fmt.Errorf("the telegram mode needs a bot token")
+		}
+		// anybody finding the bot could read and change the notes
+		if len(o.telegram.Users) == 0 {
+			return nil, fmt.Errorf("the telegram mode needs the users allowed to use the bot")
+		}
+		return telegram.New(u, o.telegram), nil
+	case WATCH:
+		return o.watcher, nil
+	default:
+		return nil, fmt.Errorf("unknown application mode %s", mode)
+	}
+}
+
+func newRepl(u usecase.Usecase, o options) (repl.Application, error) {
+	config := o.repl
+	config.Formats = append(config.Formats, o.formats...)
+	config.Commands = command.New(u).Repl
+	if o.presenter != nil {
+		config.Presenter = o.presenter
+	}
+	return repl.New(u, config)
+}
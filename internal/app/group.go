@@ -0,0 +1,65 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"notes/internal/usecase"
+)
+
+// Stopper is an application which can be told to stop running
+type Stopper interface {
+	Stop()
+}
+
+// group runs several applications on the same usecases, when one of
+// them returns, or on an interrupt, the others are stopped and the
+// changes saved, the programme exits with 1 when they can't be
+// Applications which can't be stopped, such as a REPL waiting for its
+// input, are left behind
+// The notes are dumped when one of them panics, see recovery.
+type group struct {
+	applications []Application
+	usecase      usecase.Usecase
+	recovery     recovery
+}
+
+func (g group) Run() {
+	g.recovery.watch()
+	done := make(chan int, len(g.applications))
+	for i, a := range g.applications {
+		go func() {
+			g.recovery.guard(a.Run)
+			done <- i
+		}()
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+	first := -1
+	select {
+	case first = <-done:
+	case <-interrupt:
+	}
+	running := 0
+	for i, a := range g.applications {
+		stopper, ok := a.(Stopper)
+		if !ok || i == first {
+			continue
+		}
+		stopper.Stop()
+		running++
+	}
+	for ; running > 0; running-- {
+		<-done
+	}
+	// the changes of a server are only written now, they are dumped
+	// rather than lost when they can't be
+	_, err := g.usecase.Save.Execute(usecase.SaveMessage{})
+	if err != nil {
+		g.recovery.dump(fmt.Sprint("saving failed: ", err))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/pprof"
+	"syscall"
+	"time"
+
+	"notes/internal/storage"
+)
+
+// recovery writes the notes to a file of the json storage when an
+// application panics or on SIGQUIT, the notes of the memory storage and
+// the unsaved changes of the json storage are not lost then
+type recovery struct {
+	storage storage.Storage
+	dir     string
+}
+
+// dumpTimeout gives up on dumping the notes, a storage hanging may be
+// why the programme is being quit
+const dumpTimeout = 5 * time.Second
+
+// dump writes the notes next to the others of the previous crashes,
+// the file is told on stderr
+func (r recovery) dump(reason any) {
+	dir := r.dir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "notes: recovery:", err)
+		return
+	}
+	path := filepath.Join(dir, fmt.Sprintf("notes-recovery-%s-%d.json", time.Now().Format("20060102-150405"), os.Getpid()))
+	done := make(chan error, 1)
+	go func() { done <- storage.Dump(r.storage, path) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "notes: recovery:", err)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "notes: %v, the notes were written to %s, run with -storage json -storage-path %s to get them back\n", reason, path, path)
+	case <-time.After(dumpTimeout):
+		fmt.Fprintln(os.Stderr, "notes: recovery: the notes couldn't be read in time")
+	}
+}
+
+// guard dumps the notes when run panics, which then goes on panicking
+func (r recovery) guard(run func()) {
+	defer func() {
+		if v := recover(); v != nil {
+			r.dump(fmt.Sprint("panic: ", v))
+			panic(v)
+		}
+	}()
+	run()
+}
+
+// watch dumps the notes on SIGQUIT, the stacks of the goroutines are
+// printed before exiting as the runtime would do
+func (r recovery) watch() {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGQUIT)
+	go func() {
+		<-quit
+		r.dump("quit")
+		pprof.Lookup("goroutine").WriteTo(os.Stderr, 2)
+		os.Exit(2)
+	}()
+}
+
+// recovering is an application running guarded
+type recovering struct {
+	Application
+	recovery recovery
+}
+
+func (a recovering) Run() {
+	a.recovery.watch()
+	a.recovery.guard(a.Application.Run)
+}
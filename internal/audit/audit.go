@@ -0,0 +1,85 @@
+// Package audit keeps an append-only log of every change made to the
+// notes, and of who read them. A Recorder subscribes to the note events
+// and appends them to a Store which can then be queried.
+package audit
+
+import (
+	"time"
+
+	"notes/internal/note"
+)
+
+// Entry is one change, Before is zero for a creation and After is zero
+// for a deletion
+// A view, of kind note.Viewed, is not a change: After is the note read,
+// without its content.
+type Entry struct {
+	At     time.Time      `json:"at"`
+	Actor  string         `json:"actor"`
+	Kind   note.EventKind `json:"kind"`
+	NoteId note.Id        `json:"noteId"`
+	Before note.Note      `json:"before"`
+	After  note.Note      `json:"after"`
+}
+
+// Query selects entries, zero fields match everything
+type Query struct {
+	NoteId note.Id
+	Actor  string
+	// Owner selects the changes of the notes of a user, before or after
+	// the change
+	Owner note.UserId
+	// Views selects the views of the notes along with their changes,
+	// which are selected alone otherwise
+	Views bool
+}
+
+func (q Query) matches(e Entry) bool {
+	if q.NoteId != 0 && e.NoteId != q.NoteId {
+		return false
+	}
+	if q.Actor != "" && e.Actor != q.Actor {
+		return false
+	}
+	if q.Owner != 0 && e.Before.Owner != q.Owner && e.After.Owner != q.Owner {
+		return false
+	}
+	return q.Views || e.Kind != note.Viewed
+}
+
+// Store appends entries and returns them in the order they were
+// appended, entries are never changed nor removed
+type Store interface {
+	Append(Entry) error
+	Query(Query) ([]Entry, error)
+}
+
+// Recorder turns note events into audit entries
+type Recorder struct {
+	store  Store
+	errors func(error)
+}
+
+// NewRecorder appends to store, failures to append are given to errors
+// since events can't fail
+func NewRecorder(store Store, errors func(error)) Recorder {
+	return Recorder{store: store, errors: errors}
+}
+
+func (r Recorder) Notify(e note.Event) {
+	entry := Entry{
+		At:     e.At,
+		Actor:  e.Actor,
+		Kind:   e.Kind,
+		NoteId: e.Note.Id,
+		Before: e.Previous,
+		After:  e.Note,
+	}
+	if e.Kind == note.Deleted {
+		entry.After = note.Note{}
+	}
+	err := r.store.Append(entry)
+	if err != nil && r.errors != nil {
+		r.errors(err)
+	}
+}
@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// File appends the entries to a file, one json object per line
+// The file is only ever opened for appending so past entries can't be
+// rewritten by the programme
+type File struct {
+	path  string
+	mutex *sync.Mutex
+}
+
+func NewFile(path string) File {
+	return File{path: path, mutex: &sync.Mutex{}}
+}
+
+func (s File) Append(e Entry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(line, '\n'))
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (s File) Query(q Query) ([]Entry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	entries := []Entry{}
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		e := Entry{}
+		err := json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			return nil, err
+		}
+		if q.matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,32 @@
+package audit
+
+import "sync"
+
+// Memory keeps the entries for the programme execution only
+type Memory struct {
+	mutex   *sync.Mutex
+	entries *[]Entry
+}
+
+func NewMemory() Memory {
+	return Memory{mutex: &sync.Mutex{}, entries: &[]Entry{}}
+}
+
+func (s Memory) Append(e Entry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	*s.entries = append(*s.entries, e)
+	return nil
+}
+
+func (s Memory) Query(q Query) ([]Entry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	entries := []Entry{}
+	for _, e := range *s.entries {
+		if q.matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,290 @@
+// Package collab lets several clients edit the content of a note at the
+// same time. The clients send operations on the revision of the content
+// they know, the hub transforms them against the operations they missed
+// and sends the result to the other clients, so every client converges
+// on the same content, which the hub saves in the note.
+package collab
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"notes/internal/crypt"
+	"notes/internal/note"
+	"notes/internal/usecase"
+)
+
+// saveDelay is how long the hub waits for the edits to pause before
+// saving the content
+const saveDelay = 2 * time.Second
+
+// Conn is the connection of a client, such as a websocket.Conn
+type Conn interface {
+	Read() ([]byte, error)
+	Write(message []byte) error
+	Close() error
+}
+
+// message between the hub and the clients
+// The clients send the revision they know and an operation on it. The
+// hub sends the content and its revision to a client joining, "init",
+// acknowledges the operations of a client with their new revision,
+// "ack", sends them to the other clients, "operation", tells when the
+// note is deleted, "deleted", or when a message fails, "error".
+type message struct {
+	Type      string    `json:"type,omitempty"`
+	Revision  int       `json:"revision"`
+	Operation Operation `json:"operation,omitempty"`
+	Text      string    `json:"text,omitempty"`
+	Writable  bool      `json:"writable,omitempty"`
+	User      string    `json:"user,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Hub keeps the notes being edited, a note is edited from the first
+// client joining until the last one leaves
+// It is a subscriber of the note events so a note changed by other
+// means is changed for the clients too.
+type Hub struct {
+	usecase   usecase.Usecase
+	mutex     *sync.Mutex
+	documents map[note.Id]*document
+}
+
+func NewHub(u usecase.Usecase) *Hub {
+	return &Hub{usecase: u, mutex: &sync.Mutex{}, documents: map[note.Id]*document{}}
+}
+
+// document is a note being edited, its revision is the number of
+// operations applied since the first client joined
+type document struct {
+	id      note.Id
+	mutex   sync.Mutex
+	text    string
+	history []Operation
+	clients map[*client]bool
+	// editor is the context of the last client editing, the content is
+	// saved on their behalf
+	editor usecase.Context
+	dirty  bool
+	timer  *time.Timer
+	// saving tells the content is being saved, the event of savingText
+	// is not a change by other means
+	saving     bool
+	savingText string
+	saveMutex  sync.Mutex
+}
+
+type client struct {
+	conn     Conn
+	context  usecase.Context
+	writable bool
+}
+
+// ErrSealed refuses to edit the notes encrypted by their clients, the
+// hub can't merge ciphertext
+var ErrSealed = fmt.Errorf("%w: the note is encrypted, it can't be edited together", note.ErrValidation)
+
+// Join edits a note with a client until the client leaves, the client
+// may read the note, it only sends operations when it may change it too
+func (h *Hub) Join(c usecase.Context, id note.Id, conn Conn) error {
+	defer conn.Close()
+	read, err := h.usecase.Read.Execute(usecase.ReadMessage{Context: c, Id: id})
+	if err != nil {
+		return err
+	}
+	if crypt.IsSealed(read.Note.Content) {
+		return ErrSealed
+	}
+	// a dry run tells whether the client may change the note
+	check := c
+	check.DryRun = true
+	_, err = h.usecase.Update.Execute(usecase.UpdateMessage{Context: check, Id: id})
+	cl := &client{conn: conn, context: c, writable: err == nil}
+	d := h.join(read.Note, cl)
+	defer h.leave(d, cl)
+	for {
+		data, err := conn.Read()
+		if err != nil {
+			return nil
+		}
+		m := message{}
+		err = json.Unmarshal(data, &m)
+		if err != nil {
+			send(cl, message{Type: "error", Error: fmt.Sprintf("invalid message: %v", err)})
+			continue
+		}
+		if !cl.writable {
+			send(cl, message{Type: "error", Error: "the note is read only"})
+			continue
+		}
+		err = d.receive(cl, m, h)
+		if err != nil {
+			send(cl, message{Type: "error", Revision: m.Revision, Error: err.Error()})
+		}
+	}
+}
+
+// Clients is the number of clients editing each note
+func (h *Hub) Clients() map[note.Id]int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	clients := map[note.Id]int{}
+	for id, d := range h.documents {
+		d.mutex.Lock()
+		clients[id] = len(d.clients)
+		d.mutex.Unlock()
+	}
+	return clients
+}
+
+func (h *Hub) join(n note.Note, cl *client) *document {
+	h.mutex.Lock()
+	d, ok := h.documents[n.Id]
+	if !ok {
+		d = &document{id: n.Id, text: n.Content, clients: map[*client]bool{}}
+		h.documents[n.Id] = d
+	}
+	h.mutex.Unlock()
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.clients[cl] = true
+	send(cl, message{Type: "init", Revision: len(d.history), Text: d.text, Writable: cl.writable})
+	return d
+}
+
+// leave saves the content once the last client left
+func (h *Hub) leave(d *document, cl *client) {
+	h.mutex.Lock()
+	d.mutex.Lock()
+	delete(d.clients, cl)
+	last := len(d.clients) == 0
+	if last {
+		if h.documents[d.id] == d {
+			delete(h.documents, d.id)
+		}
+		if d.timer != nil {
+			d.timer.Stop()
+		}
+	}
+	d.mutex.Unlock()
+	h.mutex.Unlock()
+	if last {
+		h.save(d)
+	}
+}
+
+// receive transforms the operation of a client against those it missed,
+// applies it and sends it to the other clients
+func (d *document) receive(cl *client, m message, h *Hub) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if m.Revision < 0 || m.Revision > len(d.history) {
+		return fmt.Errorf("unknown revision %d", m.Revision)
+	}
+	o := m.Operation
+	for _, missed := range d.history[m.Revision:] {
+		var err error
+		o, _, err = Transform(o, missed)
+		if err != nil {
+			return err
+		}
+	}
+	text, err := o.Apply(d.text)
+	if err != nil {
+		return err
+	}
+	d.apply(o, text, cl, cl.context.Actor)
+	d.editor = cl.context
+	d.dirty = true
+	if d.timer == nil {
+		d.timer = time.AfterFunc(saveDelay, func() { h.save(d) })
+	} else {
+		d.timer.Reset(saveDelay)
+	}
+	return nil
+}
+
+// apply records an operation of a user and sends it to the clients, the
+// client authoring it only gets its revision
+func (d *document) apply(o Operation, text string, author *client, name string) {
+	d.text = text
+	d.history = append(d.history, o)
+	revision := len(d.history)
+	for cl := range d.clients {
+		if cl == author {
+			send(cl, message{Type: "ack", Revision: revision})
+			continue
+		}
+		send(cl, message{Type: "operation", Revision: revision, Operation: o, User: name})
+	}
+}
+
+// save updates the note with the content when it changed, failures are
+// sent to the clients
+func (h *Hub) save(d *document) {
+	d.saveMutex.Lock()
+	defer d.saveMutex.Unlock()
+	d.mutex.Lock()
+	if !d.dirty {
+		d.mutex.Unlock()
+		return
+	}
+	d.dirty = false
+	d.saving, d.savingText = true, d.text
+	editor, text := d.editor, d.text
+	d.mutex.Unlock()
+	editor.DryRun = false
+	_, err := h.usecase.Update.Execute(usecase.UpdateMessage{Context: editor, Id: d.id, Content: text})
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.saving = false
+	if err != nil {
+		d.dirty = true
+		for cl := range d.clients {
+			send(cl, message{Type: "error", Revision: len(d.history), Error: fmt.Sprintf("not saved: %v", err)})
+		}
+	}
+}
+
+// Notify changes the content of a note being edited when it is changed
+// by other means, and tells the clients when it is deleted
+func (h *Hub) Notify(e note.Event) {
+	h.mutex.Lock()
+	d, ok := h.documents[e.Note.Id]
+	if ok && e.Kind == note.Deleted {
+		delete(h.documents, e.Note.Id)
+	}
+	h.mutex.Unlock()
+	if !ok {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	switch e.Kind {
+	case note.Deleted:
+		if d.timer != nil {
+			d.timer.Stop()
+		}
+		d.dirty = false
+		for cl := range d.clients {
+			send(cl, message{Type: "deleted", Revision: len(d.history)})
+			cl.conn.Close()
+		}
+	case note.Updated, note.Restored:
+		if d.saving && e.Note.Content == d.savingText || e.Note.Content == d.text {
+			return
+		}
+		d.apply(Diff(d.text, e.Note.Content), e.Note.Content, nil, e.Actor)
+	}
+}
+
+func send(cl *client, m message) {
+	data, _ := json.Marshal(m)
+	err := cl.conn.Write(data)
+	if err != nil {
+		cl.conn.Close()
+	}
+}
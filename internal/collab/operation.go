@@ -0,0 +1,233 @@
+package collab
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf16"
+)
+
+// Operation changes a text from start to end: it retains, inserts or
+// deletes characters in turn, as the TextOperation of ot.js
+// Lengths count UTF-16 code units, as JavaScript strings do, so the
+// operations of browsers apply as they are.
+// In json an operation is an array where a positive number retains, a
+// negative one deletes and a string inserts, [3, "ab", -2] keeps 3
+// characters, inserts "ab" and deletes the next 2.
+type Operation []component
+
+// component is one of retain, insert or delete
+type component struct {
+	retain int
+	insert string
+	delete int
+}
+
+func (c component) inserted() int {
+	return len(utf16.Encode([]rune(c.insert)))
+}
+
+func (o *Operation) Retain(n int) {
+	if n <= 0 {
+		return
+	}
+	if last := len(*o) - 1; last >= 0 && (*o)[last].retain > 0 {
+		(*o)[last].retain += n
+		return
+	}
+	*o = append(*o, component{retain: n})
+}
+
+// Insert keeps an insertion before a deletion right before it, so
+// equal operations have equal components
+func (o *Operation) Insert(s string) {
+	if s == "" {
+		return
+	}
+	last := len(*o) - 1
+	if last >= 0 && (*o)[last].insert != "" {
+		(*o)[last].insert += s
+		return
+	}
+	if last >= 0 && (*o)[last].delete > 0 {
+		if last > 0 && (*o)[last-1].insert != "" {
+			(*o)[last-1].insert += s
+			return
+		}
+		*o = append((*o)[:last], component{insert: s}, (*o)[last])
+		return
+	}
+	*o = append(*o, component{insert: s})
+}
+
+func (o *Operation) Delete(n int) {
+	if n <= 0 {
+		return
+	}
+	if last := len(*o) - 1; last >= 0 && (*o)[last].delete > 0 {
+		(*o)[last].delete += n
+		return
+	}
+	*o = append(*o, component{delete: n})
+}
+
+// BaseLen is the length of the texts the operation applies to
+func (o Operation) BaseLen() int {
+	n := 0
+	for _, c := range o {
+		n += c.retain + c.delete
+	}
+	return n
+}
+
+// Apply changes a text by the operation, which fails when the lengths
+// differ
+func (o Operation) Apply(text string) (string, error) {
+	units := utf16.Encode([]rune(text))
+	if o.BaseLen() != len(units) {
+		return "", fmt.Errorf("operation of a text of %d characters applied to %d", o.BaseLen(), len(units))
+	}
+	result, at := []uint16{}, 0
+	for _, c := range o {
+		switch {
+		case c.retain > 0:
+			result = append(result, units[at:at+c.retain]...)
+			at += c.retain
+		case c.insert != "":
+			result = append(result, utf16.Encode([]rune(c.insert))...)
+		default:
+			at += c.delete
+		}
+	}
+	return string(utf16.Decode(result)), nil
+}
+
+// Transform turns two operations made on the same text into a' and b'
+// such that applying a then b' gives the same text as b then a'
+// When both insert at the same place the insertion of a comes first.
+func Transform(a, b Operation) (Operation, Operation, error) {
+	if a.BaseLen() != b.BaseLen() {
+		return nil, nil, fmt.Errorf("operations of texts of %d and %d characters", a.BaseLen(), b.BaseLen())
+	}
+	a1, b1 := Operation{}, Operation{}
+	i, j := 0, 0
+	var op1, op2 *component
+	next := func(o Operation, k *int) *component {
+		if *k >= len(o) {
+			return nil
+		}
+		c := o[*k]
+		*k++
+		return &c
+	}
+	op1, op2 = next(a, &i), next(b, &j)
+	for op1 != nil || op2 != nil {
+		if op1 != nil && op1.insert != "" {
+			a1.Insert(op1.insert)
+			b1.Retain(op1.inserted())
+			op1 = next(a, &i)
+			continue
+		}
+		if op2 != nil && op2.insert != "" {
+			a1.Retain(op2.inserted())
+			b1.Insert(op2.insert)
+			op2 = next(b, &j)
+			continue
+		}
+		if op1 == nil || op2 == nil {
+			return nil, nil, fmt.Errorf("operations of texts of different lengths")
+		}
+		n := min(op1.retain+op1.delete, op2.retain+op2.delete)
+		switch {
+		case op1.retain > 0 && op2.retain > 0:
+			a1.Retain(n)
+			b1.Retain(n)
+		case op1.delete > 0 && op2.retain > 0:
+			a1.Delete(n)
+		case op1.retain > 0 && op2.delete > 0:
+			b1.Delete(n)
+		}
+		// deleted by both, nothing left to do
+		op1 = consume(op1, n, a, &i, next)
+		op2 = consume(op2, n, b, &j, next)
+	}
+	return a1, b1, nil
+}
+
+// consume takes n characters off a retain or a delete, moving to the
+// next component once it is used up
+func consume(c *component, n int, o Operation, k *int, next func(Operation, *int) *component) *component {
+	if c.retain > 0 {
+		c.retain -= n
+		if c.retain == 0 {
+			return next(o, k)
+		}
+		return c
+	}
+	c.delete -= n
+	if c.delete == 0 {
+		return next(o, k)
+	}
+	return c
+}
+
+// Diff is an operation turning a text into another, it retains what
+// they start and end with and replaces the rest
+func Diff(from, to string) Operation {
+	a, b := utf16.Encode([]rune(from)), utf16.Encode([]rune(to))
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	o := Operation{}
+	o.Retain(prefix)
+	o.Insert(string(utf16.Decode(b[prefix : len(b)-suffix])))
+	o.Delete(len(a) - prefix - suffix)
+	o.Retain(suffix)
+	return o
+}
+
+func (o Operation) MarshalJSON() ([]byte, error) {
+	values := make([]any, len(o))
+	for i, c := range o {
+		switch {
+		case c.retain > 0:
+			values[i] = c.retain
+		case c.insert != "":
+			values[i] = c.insert
+		default:
+			values[i] = -c.delete
+		}
+	}
+	return json.Marshal(values)
+}
+
+func (o *Operation) UnmarshalJSON(data []byte) error {
+	values := []any{}
+	err := json.Unmarshal(data, &values)
+	if err != nil {
+		return err
+	}
+	*o = Operation{}
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			o.Insert(v)
+		case float64:
+			if v != float64(int(v)) || v == 0 {
+				return fmt.Errorf("operation: %v is not a length", v)
+			}
+			if v > 0 {
+				o.Retain(int(v))
+			} else {
+				o.Delete(int(-v))
+			}
+		default:
+			return fmt.Errorf("operation: %v is neither a length nor a text", v)
+		}
+	}
+	return nil
+}
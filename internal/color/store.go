@@ -0,0 +1,97 @@
+// Package color keeps the colors of the notebooks, the colors of the
+// notes are in their metadata.
+package color
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"sync"
+
+	"notes/internal/note"
+)
+
+// Store keeps the colors of the notebooks of every user, in a json file
+// when it has a path and in memory otherwise
+type Store struct {
+	path   string
+	mutex  *sync.RWMutex
+	colors map[note.UserId]map[note.Notebook]string
+}
+
+// NewStore loads the colors kept in path, a missing file has none
+func NewStore(path string) (Store, error) {
+	s := Store{path: path, mutex: &sync.RWMutex{}, colors: map[note.UserId]map[note.Notebook]string{}}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	err = json.Unmarshal(data, &s.colors)
+	if err != nil {
+		return s, fmt.Errorf("notebook colors %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Set colors a notebook of a user, the empty color removes its color
+func (s Store) Set(user note.UserId, notebook note.Notebook, color string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	previous, existed := s.colors[user][notebook]
+	s.set(user, notebook, color)
+	err := s.write()
+	if err != nil && existed {
+		s.set(user, notebook, previous)
+	} else if err != nil {
+		s.set(user, notebook, "")
+	}
+	return err
+}
+
+func (s Store) set(user note.UserId, notebook note.Notebook, color string) {
+	if color == "" {
+		delete(s.colors[user], notebook)
+		if len(s.colors[user]) == 0 {
+			delete(s.colors, user)
+		}
+		return
+	}
+	if s.colors[user] == nil {
+		s.colors[user] = map[note.Notebook]string{}
+	}
+	s.colors[user][notebook] = color
+}
+
+// Of returns the colors of the notebooks of a user by name
+func (s Store) Of(user note.UserId) map[note.Notebook]string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	colors := maps.Clone(s.colors[user])
+	if colors == nil {
+		colors = map[note.Notebook]string{}
+	}
+	return colors
+}
+
+func (s Store) write() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.colors, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	err = os.WriteFile(tmp, data, 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,124 @@
+// Package command registers every usecase once, with its names in the
+// REPL and their parsers, and its routes over HTTP and their parsers.
+// The REPL and the HTTP server are given the tables built from the
+// registry, they only add the commands doing more than running a
+// usecase, such as UNDO or the Nextcloud routes.
+package command
+
+import (
+	"notes/internal/httpapi"
+	"notes/internal/note"
+	"notes/internal/repl"
+	"notes/internal/usecase"
+)
+
+// Tables are the REPL commands by name and the HTTP routes by pattern
+type Tables struct {
+	Repl   map[string]repl.Command
+	Routes map[string]httpapi.Route
+}
+
+// New registers the usecases in the tables
+// Adding a usecase means adding its line here.
+func New(u usecase.Usecase) Tables {
+	t := Tables{Repl: map[string]repl.Command{}, Routes: map[string]httpapi.Route{}}
+	quickNote := func(r usecase.QuickResult) note.Note { return r.Note }
+	todayNote := func(r usecase.TodayResult) note.Note {
+		if !r.Created {
+			return note.Note{}
+		}
+		return r.Note
+	}
+	on(t, u.Read).idRepl("READ", repl.ReadParser{})
+	on(t, u.ReadAll).repl("READALL", repl.ReadAllParser{})
+	on(t, u.Count).http("GET /notes/count", httpapi.CountParser{})
+	on(t, u.Create).http("POST /notes/{$}", httpapi.CreateParser{})
+	on(t, u.Quick).recorded("Q", repl.QuickParser{}, quickNote).recorded("QUICK", repl.QuickParser{}, quickNote)
+	on(t, u.Today).recorded("TODAY", repl.TodayParser{}, todayNote).http("GET /notes/today", httpapi.TodayParser{})
+	on(t, u.Update).http("PUT /notes/{id}", httpapi.UpdateParser{})
+	on(t, u.Rename).http("POST /notes/{id}/rename", httpapi.RenameParser{})
+	on(t, u.Move).http("POST /notes/{id}/move", httpapi.MoveParser{})
+	on(t, u.Color).idRepl("COLOR", repl.ColorParser{}).http("POST /notes/{id}/color", httpapi.ColorParser{}).http("POST /notebooks/{name}/color", httpapi.ColorParser{})
+	on(t, u.Pin).idRepl("PIN", repl.PinParser{}).idRepl("UNPIN", repl.PinParser{Unpin: true}).http("POST /notes/{id}/pin", httpapi.PinParser{}).http("DELETE /notes/{id}/pin", httpapi.PinParser{Unpin: true})
+	on(t, u.Lock).idRepl("LOCK", repl.LockParser{}).http("POST /notes/{id}/lock", httpapi.LockParser{})
+	on(t, u.Unlock).idRepl("UNLOCK", repl.UnlockParser{}).http("DELETE /notes/{id}/lock", httpapi.UnlockParser{})
+	on(t, u.Locked).idRepl("LOCKED", repl.LockedParser{}).http("GET /notes/{id}/lock", httpapi.LockedParser{})
+	on(t, u.Delete).http("DELETE /notes/{id}", httpapi.DeleteParser{})
+	on(t, u.Audit).http("GET /audit", httpapi.AuditParser{})
+	on(t, u.Activity).http("GET /notes/{id}/activity", httpapi.ActivityParser{})
+	on(t, u.Recent).repl("RECENT", repl.RecentParser{}).http("GET /notes/recent", httpapi.RecentParser{})
+	on(t, u.Email).idRepl("MAIL", repl.EmailParser{}).http("POST /notes/{id}/email", httpapi.EmailParser{})
+	on(t, u.Publish).idRepl("PUBLISH", repl.PublishParser{}).http("POST /notes/{id}/publish", httpapi.PublishParser{})
+	on(t, u.Search).repl("SEARCH", repl.SearchParser{}).http("GET /notes/search", httpapi.SearchParser{})
+	on(t, u.Similar).idRepl("SIMILAR", repl.SimilarParser{}).http("GET /notes/{id}/similar", httpapi.SimilarParser{})
+	on(t, u.Notebooks).repl("NOTEBOOKS", repl.NotebooksParser{}).http("GET /notebooks", httpapi.NotebooksParser{})
+	on(t, u.SaveSearch).repl("SAVESEARCH", repl.SaveSearchParser{}).http("POST /searches", httpapi.SaveSearchParser{})
+	on(t, u.DeleteSearch).repl("DELETESEARCH", repl.DeleteSearchParser{}).http("DELETE /searches/{name}", httpapi.DeleteSearchParser{})
+	on(t, u.Register).http("POST /register", httpapi.RegisterParser{})
+	on(t, u.Share).http("POST /notes/{id}/shares", httpapi.ShareParser{}).http("POST /notebooks/{name}/shares", httpapi.ShareParser{})
+	on(t, u.Unshare).http("DELETE /shares/{id}", httpapi.UnshareParser{})
+	on(t, u.Shares).http("GET /shares", httpapi.SharesParser{})
+	on(t, u.SharedWithMe).http("GET /shared-with-me", httpapi.SharedWithMeParser{})
+	on(t, u.CreateApiToken).http("POST /tokens", httpapi.CreateApiTokenParser{})
+	on(t, u.ApiTokens).http("GET /tokens", httpapi.ApiTokensParser{})
+	on(t, u.RevokeApiToken).http("DELETE /tokens/{id}", httpapi.RevokeApiTokenParser{})
+	on(t, u.EnrollTotp).http("POST /me/totp", httpapi.EnrollTotpParser{})
+	on(t, u.ConfirmTotp).http("POST /me/totp/confirm", httpapi.ConfirmTotpParser{})
+	on(t, u.DisableTotp).http("DELETE /me/totp", httpapi.DisableTotpParser{})
+	on(t, u.Usage).http("GET /me/usage", httpapi.UsageParser{})
+	on(t, u.Due).repl("DUE", repl.DueParser{})
+	on(t, u.Dashboard).repl("DASHBOARD", repl.DashboardParser{}).http("GET /dashboard", httpapi.DashboardParser{})
+	on(t, u.Dedupe).repl("DEDUPE", repl.DedupeParser{}).http("GET /notes/duplicates", httpapi.DedupeParser{})
+	on(t, u.Merge).idRepl("MERGE", repl.MergeParser{}).http("POST /notes/{id}/merge", httpapi.MergeParser{})
+	on(t, u.Lint).repl("LINT", repl.LintParser{}).http("GET /lint", httpapi.LintParser{}).http("POST /lint", httpapi.LintParser{Report: true})
+	on(t, u.Diff).idRepl("DIFF", repl.DiffParser{}).http("GET /notes/{id}/diff", httpapi.DiffParser{})
+	on(t, u.Summarize).idRepl("SUMMARIZE", repl.SummarizeParser{}).http("POST /notes/{id}/summarize", httpapi.SummarizeParser{})
+	on(t, u.SuggestTitle).repl("TITLE", repl.SuggestTitleParser{}).http("POST /notes/title", httpapi.SuggestTitleParser{})
+	return t
+}
+
+// CliCommands are the commands of the command line, see
+// repl.CliCommands
+func CliCommands() []string {
+	return repl.CliCommands(New(usecase.Usecase{}).Repl)
+}
+
+// CliIdCommands are the commands of the command line taking a note id
+func CliIdCommands() []string {
+	return repl.CliIdCommands(New(usecase.Usecase{}).Repl)
+}
+
+// entry registers a usecase under its names and routes
+type entry[Message, Result any] struct {
+	tables  Tables
+	command usecase.Command[Message, Result]
+}
+
+func on[Message, Result any](t Tables, c usecase.Command[Message, Result]) entry[Message, Result] {
+	return entry[Message, Result]{t, c}
+}
+
+// repl names the usecase in the REPL
+func (e entry[Message, Result]) repl(name string, p repl.Parser[Message]) entry[Message, Result] {
+	e.tables.Repl[name] = repl.Command{Run: repl.Presented(p, e.command)}
+	return e
+}
+
+// idRepl names the usecase in the REPL, its first argument is a note id
+func (e entry[Message, Result]) idRepl(name string, p repl.Parser[Message]) entry[Message, Result] {
+	e.tables.Repl[name] = repl.Command{Run: repl.Presented(p, e.command), Id: true}
+	return e
+}
+
+// recorded names a usecase creating a note in the REPL, so the creation
+// can be undone
+func (e entry[Message, Result]) recorded(name string, p repl.Parser[Message], created func(Result) note.Note) entry[Message, Result] {
+	e.tables.Repl[name] = repl.Command{Run: repl.Recorded(p, e.command, created)}
+	return e
+}
+
+// http serves the usecase under a route
+func (e entry[Message, Result]) http(pattern string, p httpapi.Parser[Message]) entry[Message, Result] {
+	e.tables.Routes[pattern] = httpapi.Served(p, e.command)
+	return e
+}
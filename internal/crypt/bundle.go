@@ -0,0 +1,108 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// bundlePrefix starts every bundle, it tells the version of the format:
+// the number of iterations and the salt of the key, then the nonce and
+// the ciphertext of AES-256-GCM
+// The key is derived from the password by PBKDF2 with HMAC-SHA256.
+const bundlePrefix = "notes-bundle:v1\n"
+
+const (
+	bundleIterations = 600_000
+	// bundleMaxIterations keeps a forged bundle from keeping the
+	// computer busy
+	bundleMaxIterations = 10_000_000
+	bundleSalt          = 16
+)
+
+// IsBundle tells whether data was sealed by SealWithPassword
+func IsBundle(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(bundlePrefix))
+}
+
+// SealWithPassword encrypts data with a key derived from a password,
+// such as a backup stored where others may read it
+func SealWithPassword(password string, data []byte) ([]byte, error) {
+	if password == "" {
+		return nil, errors.New("seal: the password is empty")
+	}
+	header := binary.BigEndian.AppendUint32([]byte(bundlePrefix), bundleIterations)
+	salt := make([]byte, bundleSalt)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+	header = append(header, salt...)
+	gcm, err := newGcm(derive(password, salt, bundleIterations))
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	// the header is authenticated with the data
+	return gcm.Seal(append(header, nonce...), nonce, data, header), nil
+}
+
+// OpenWithPassword decrypts data sealed by SealWithPassword
+func OpenWithPassword(password string, sealed []byte) ([]byte, error) {
+	if !IsBundle(sealed) {
+		return nil, errors.New("open: not a bundle")
+	}
+	headerSize := len(bundlePrefix) + 4 + bundleSalt
+	if len(sealed) < headerSize {
+		return nil, errors.New("open: the bundle is cut short")
+	}
+	header := sealed[:headerSize]
+	iterations := binary.BigEndian.Uint32(header[len(bundlePrefix):])
+	if iterations == 0 || iterations > bundleMaxIterations {
+		return nil, errors.New("open: the bundle is altered")
+	}
+	gcm, err := newGcm(derive(password, header[len(bundlePrefix)+4:], int(iterations)))
+	if err != nil {
+		return nil, err
+	}
+	rest := sealed[headerSize:]
+	if len(rest) < gcm.NonceSize() {
+		return nil, errors.New("open: the bundle is cut short")
+	}
+	plain, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], header)
+	if err != nil {
+		return nil, errors.New("open: wrong password or altered bundle")
+	}
+	return plain, nil
+}
+
+// Pbkdf2 derives a key of one SHA-256 block from a password, RFC 8018,
+// for the bundles and the password hashes of the accounts
+func Pbkdf2(password []byte, salt []byte, iterations int) []byte {
+	mac := hmac.New(sha256.New, password)
+	mac.Write(salt)
+	mac.Write(binary.BigEndian.AppendUint32(nil, 1))
+	u := mac.Sum(nil)
+	key := append([]byte{}, u...)
+	for range iterations - 1 {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for i := range key {
+			key[i] ^= u[i]
+		}
+	}
+	return key
+}
+
+// derive is the key of a bundle
+func derive(password string, salt []byte, iterations int) Key {
+	return Key(Pbkdf2([]byte(password), salt, iterations))
+}
@@ -0,0 +1,145 @@
+// Package crypt seals the content of the notes on the clients, so a
+// server in the end-to-end encrypted mode only keeps ciphertext. The
+// names and notebooks stay in the clear, they are what the server can
+// still list and search.
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"notes/internal/note"
+)
+
+// prefix starts every sealed content, it tells the version of the
+// format: AES-256-GCM, the nonce then the ciphertext, in base64
+const prefix = "notes-e2e:v1:"
+
+// Key encrypts the notes, every client of a person needs the same one
+type Key [32]byte
+
+// NewKey returns a random key
+func NewKey() (Key, error) {
+	k := Key{}
+	_, err := rand.Read(k[:])
+	return k, err
+}
+
+// ParseKey reads a key encoded by Encode
+func ParseKey(s string) (Key, error) {
+	k := Key{}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(b) != len(k) {
+		return k, errors.New("key: not a base64 key of 32 bytes")
+	}
+	copy(k[:], b)
+	return k, nil
+}
+
+// LoadKey reads the key file written by Save
+func LoadKey(path string) (Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Key{}, err
+	}
+	k, err := ParseKey(string(data))
+	if err != nil {
+		return Key{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return k, nil
+}
+
+// Save writes the key to a new file only its owner may read, an
+// existing file is never overwritten as the notes sealed with its key
+// would be lost
+func (k Key) Save(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = file.WriteString(k.Encode() + "\n")
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Encode is the key in base64, there is no String method so the key
+// isn't printed by mistake
+func (k Key) Encode() string {
+	return base64.StdEncoding.EncodeToString(k[:])
+}
+
+// Fingerprint tells the keys apart without giving them away, to check
+// two clients have the same
+func (k Key) Fingerprint() string {
+	sum := sha256.Sum256(k[:])
+	return hex.EncodeToString(sum[:8])
+}
+
+// IsSealed tells whether a content is sealed
+func IsSealed(content note.Content) bool {
+	return strings.HasPrefix(content, prefix)
+}
+
+// Seal encrypts a content, an empty content stays empty as it means no
+// change to the updates
+func Seal(k Key, content note.Content) (note.Content, error) {
+	if content == "" {
+		return "", nil
+	}
+	gcm, err := newGcm(k)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(content), nil)
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a sealed content, a content which isn't sealed is
+// returned as it is
+func Open(k Key, content note.Content) (note.Content, error) {
+	encoded, ok := strings.CutPrefix(content, prefix)
+	if !ok {
+		return content, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("open: %w", err)
+	}
+	gcm, err := newGcm(k)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("open: the content is cut short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", errors.New("open: wrong key or altered content")
+	}
+	return string(plain), nil
+}
+
+func newGcm(k Key) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
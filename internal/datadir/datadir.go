@@ -0,0 +1,62 @@
+// Package datadir finds the directories the notes keep their files in,
+// where each system expects them rather than in the working directory
+package datadir
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// name is the directory of the notes in the directories of the user
+const name = "notes"
+
+// Data is the directory of the notes of the user, $XDG_DATA_HOME/notes
+// or ~/.local/share/notes on Unix, ~/Library/Application Support/notes
+// on macOS and %LocalAppData%\notes on Windows
+// $XDG_DATA_HOME is honoured on macOS too, as other command line tools
+// do.
+func Data() (string, error) {
+	if runtime.GOOS == "windows" {
+		dir := os.Getenv("LocalAppData")
+		if dir == "" {
+			return "", errors.New("%LocalAppData% is not defined")
+		}
+		return filepath.Join(dir, name), nil
+	}
+	if dir := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, name), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	switch runtime.GOOS {
+	case "darwin", "ios":
+		return filepath.Join(home, "Library", "Application Support", name), nil
+	case "plan9":
+		return filepath.Join(home, "lib", name), nil
+	default:
+		return filepath.Join(home, ".local", "share", name), nil
+	}
+}
+
+// Config is the directory of the configuration of the user, see
+// os.UserConfigDir, $XDG_CONFIG_HOME is honoured on macOS too
+func Config() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); runtime.GOOS != "windows" && filepath.IsAbs(dir) {
+		return filepath.Join(dir, name), nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// Create makes a directory of the files of the user, along with its
+// parents, only they may read it
+func Create(dir string) error {
+	return os.MkdirAll(dir, 0o700)
+}
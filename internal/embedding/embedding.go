@@ -0,0 +1,249 @@
+// Package embedding searches the notes by meaning rather than by words.
+// Each note is turned into a vector by an embeddings provider, and a
+// query finds the notes whose vectors point the closest to its own.
+package embedding
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"notes/internal/crypt"
+	"notes/internal/note"
+	"notes/internal/query"
+)
+
+// Provider turns texts into vectors, one for each text in the same
+// order
+type Provider interface {
+	Embed(texts []string) ([][]float32, error)
+}
+
+// batchSize is the number of notes embedded in one request
+const batchSize = 16
+
+// maxHits of a search, every note is somewhat close to a query so only
+// the closest are kept
+const maxHits = 20
+
+// vector of a note and the hash of the text it was computed from, to
+// tell when the note changed
+type vector struct {
+	Hash   string    `json:"hash"`
+	Values []float32 `json:"values"`
+}
+
+// Index keeps a vector for each note, in a json file when it has a path
+// and in memory otherwise
+// It is a subscriber of the note events, the notes are embedded by a
+// goroutine so a slow provider doesn't slow down the commands, and a
+// note is only found once it is embedded.
+type Index struct {
+	provider Provider
+	path     string
+	errors   func(error)
+	mutex    *sync.RWMutex
+	vectors  map[note.Id]vector
+	queue    chan note.Event
+	done     *sync.WaitGroup
+}
+
+// NewIndex loads the vectors kept in path and embeds the notes which
+// have none or have changed since, failures to embed are given to
+// errors
+func NewIndex(provider Provider, path string, notes note.List, errors func(error)) (*Index, error) {
+	i := &Index{
+		provider: provider,
+		path:     path,
+		errors:   errors,
+		mutex:    &sync.RWMutex{},
+		vectors:  map[note.Id]vector{},
+		queue:    make(chan note.Event, 100),
+		done:     &sync.WaitGroup{},
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			err = json.Unmarshal(data, &i.vectors)
+			if err != nil {
+				return nil, fmt.Errorf("embeddings %s: %w", path, err)
+			}
+		}
+	}
+	stale := note.List{}
+	stored := map[note.Id]bool{}
+	for _, n := range notes {
+		stored[n.Id] = true
+		if i.vectors[n.Id].Hash != hash(n) {
+			stale = append(stale, n)
+		}
+	}
+	for id := range i.vectors {
+		if !stored[id] {
+			delete(i.vectors, id)
+		}
+	}
+	i.done.Add(1)
+	go i.run(stale)
+	return i, nil
+}
+
+func (i *Index) Notify(e note.Event) {
+	select {
+	case i.queue <- e:
+	default:
+		i.fail(fmt.Errorf("queue full, note %d not embedded", e.Note.Id))
+	}
+}
+
+// Pending is the number of note events waiting to be embedded
+func (i *Index) Pending() int {
+	return len(i.queue)
+}
+
+// Close embeds the notes still queued and writes the vectors
+func (i *Index) Close() error {
+	close(i.queue)
+	i.done.Wait()
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.save()
+}
+
+// run embeds the stale notes, then the notes of the events
+func (i *Index) run(stale note.List) {
+	defer i.done.Done()
+	for len(stale) > 0 {
+		batch := stale[:min(batchSize, len(stale))]
+		stale = stale[len(batch):]
+		i.embed(batch)
+	}
+	for e := range i.queue {
+		if e.Kind == note.Deleted {
+			i.mutex.Lock()
+			delete(i.vectors, e.Note.Id)
+			err := i.save()
+			i.mutex.Unlock()
+			if err != nil {
+				i.fail(err)
+			}
+			continue
+		}
+		i.embed(note.List{e.Note})
+	}
+}
+
+func (i *Index) embed(notes note.List) {
+	texts := make([]string, len(notes))
+	for j, n := range notes {
+		texts[j] = text(n)
+	}
+	values, err := i.provider.Embed(texts)
+	if err == nil && len(values) != len(notes) {
+		err = fmt.Errorf("%d vectors for %d notes", len(values), len(notes))
+	}
+	if err != nil {
+		i.fail(err)
+		return
+	}
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	for j, n := range notes {
+		i.vectors[n.Id] = vector{Hash: hash(n), Values: values[j]}
+	}
+	err = i.save()
+	if err != nil {
+		i.fail(err)
+	}
+}
+
+// Search embeds the query and returns the notes the closest to it, by
+// the cosine of their vectors, the closest first
+func (i *Index) Search(q string) ([]query.Hit, error) {
+	if strings.TrimSpace(q) == "" {
+		return nil, fmt.Errorf("%w query: nothing to search", note.ErrValidation)
+	}
+	values, err := i.provider.Embed([]string{q})
+	if err != nil {
+		return nil, fmt.Errorf("embeddings: %w", err)
+	}
+	if len(values) != 1 {
+		return nil, fmt.Errorf("embeddings: %d vectors for 1 query", len(values))
+	}
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+	hits := []query.Hit{}
+	for id, v := range i.vectors {
+		if score := cosine(values[0], v.Values); score > 0 {
+			hits = append(hits, query.Hit{Id: id, Score: score})
+		}
+	}
+	slices.SortFunc(hits, func(a, b query.Hit) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), a.Id-b.Id)
+	})
+	return hits[:min(maxHits, len(hits))], nil
+}
+
+func (i *Index) save() error {
+	if i.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(i.vectors)
+	if err != nil {
+		return err
+	}
+	tmp := i.path + ".tmp"
+	err = os.WriteFile(tmp, data, 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, i.path)
+}
+
+func (i *Index) fail(err error) {
+	if i.errors != nil {
+		i.errors(fmt.Errorf("embeddings: %w", err))
+	}
+}
+
+// text of a note given to the provider, only the name of the notes
+// encrypted by their clients
+func text(n note.Note) string {
+	if crypt.IsSealed(n.Content) {
+		return n.Name
+	}
+	return n.Name + "\n\n" + n.Content
+}
+
+func hash(n note.Note) string {
+	sum := sha256.Sum256([]byte(text(n)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// cosine of the angle between two vectors, 0 when their lengths differ
+// as they come from different models
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	dot, na, nb := 0.0, 0.0, 0.0
+	for j := range a {
+		dot += float64(a[j]) * float64(b[j])
+		na += float64(a[j]) * float64(a[j])
+		nb += float64(b[j]) * float64(b[j])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
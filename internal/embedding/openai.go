@@ -0,0 +1,77 @@
+package embedding
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Config of an OpenAI compatible provider
+type Config struct {
+	// URL of the API, https://api.openai.com/v1 by default, a local model
+	// served by Ollama is at http://localhost:11434/v1
+	URL   string
+	Model string
+	// Key is sent as a bearer token when not empty
+	Key string
+}
+
+// OpenAI asks the embeddings endpoint of an OpenAI compatible API
+type OpenAI struct {
+	config Config
+	http   *http.Client
+}
+
+// NewOpenAI builds the provider of config
+func NewOpenAI(config Config) OpenAI {
+	if config.URL == "" {
+		config.URL = "https://api.openai.com/v1"
+	}
+	return OpenAI{config: config, http: &http.Client{Timeout: 60 * time.Second}}
+}
+
+func (p OpenAI) Embed(texts []string) ([][]float32, error) {
+	data, err := json.Marshal(map[string]any{"model": p.config.Model, "input": texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(p.config.URL, "/")+"/embeddings", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.config.Key != "" {
+		req.Header.Set("Authorization", "Bearer "+p.config.Key)
+	}
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	answer := struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&answer)
+	if err != nil {
+		return nil, err
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range answer.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("vector %d of %d texts", d.Index, len(texts))
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
@@ -0,0 +1,70 @@
+package exchange
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+
+	"notes/internal/crypt"
+	"notes/internal/note"
+)
+
+// WriteBundle writes the notes as json lines, compressed with gzip and
+// sealed with a password, see crypt.SealWithPassword
+// Only the size of a bundle tells anything about the notes, it may be
+// kept in a cloud drive as a backup.
+func WriteBundle(w io.Writer, password string, notes iter.Seq[note.Note]) (int, error) {
+	plain := bytes.Buffer{}
+	zw := gzip.NewWriter(&plain)
+	encoder := json.NewEncoder(zw)
+	count := 0
+	for n := range notes {
+		err := encoder.Encode(n)
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	err := zw.Close()
+	if err != nil {
+		return count, err
+	}
+	sealed, err := crypt.SealWithPassword(password, plain.Bytes())
+	if err != nil {
+		return count, err
+	}
+	_, err = w.Write(sealed)
+	return count, err
+}
+
+// ReadBundle reads the notes of a bundle written by WriteBundle
+// The notes have no id, ids are given when they are created
+func ReadBundle(r io.Reader, password string) (note.List, error) {
+	sealed, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := crypt.OpenWithPassword(password, sealed)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	notes := note.List{}
+	decoder := json.NewDecoder(zr)
+	for decoder.More() {
+		n := note.Note{}
+		err := decoder.Decode(&n)
+		if err != nil {
+			return nil, fmt.Errorf("bundle: note %d: %w", len(notes)+1, err)
+		}
+		n.Id = 0
+		notes = append(notes, n)
+	}
+	return notes, nil
+}
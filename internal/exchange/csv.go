@@ -0,0 +1,91 @@
+package exchange
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"iter"
+	"slices"
+	"strconv"
+
+	"notes/internal/note"
+)
+
+// csvColumns are the columns written by WriteCSV, and the default
+// columns read by ReadCSV
+var csvColumns = []string{"id", "name", "content", "notebook"}
+
+// WriteCSV writes the notes with a header row, in the order they come
+func WriteCSV(w io.Writer, notes iter.Seq[note.Note]) error {
+	out := csv.NewWriter(w)
+	err := out.Write(csvColumns)
+	if err != nil {
+		return err
+	}
+	for n := range notes {
+		err := out.Write([]string{strconv.Itoa(n.Id), n.Name, n.Content, n.Notebook})
+		if err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// ReadCSV reads notes from a csv file with a header row, mapping tells
+// the column of each field, name, content and notebook, when it isn't
+// named after it
+// The notes have no id, ids are given when they are created
+func ReadCSV(r io.Reader, mapping map[string]string) (note.List, error) {
+	in := csv.NewReader(r)
+	in.FieldsPerRecord = -1
+	header, err := in.Read()
+	if err == io.EOF {
+		return note.List{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	index := map[string]int{}
+	for i, column := range header {
+		index[column] = i
+	}
+	for field := range mapping {
+		if !slices.Contains(csvColumns[1:], field) {
+			return nil, fmt.Errorf("%w csv: unknown field %q", note.ErrValidation, field)
+		}
+	}
+	// the notebook column is optional unless it is mapped
+	columns := map[string]int{}
+	for _, field := range csvColumns[1:] {
+		column, mapped := mapping[field]
+		if !mapped {
+			column = field
+		}
+		i, ok := index[column]
+		if !ok && (mapped || field != "notebook") {
+			return nil, fmt.Errorf("%w csv: no %q column for the %s", note.ErrValidation, column, field)
+		}
+		if ok {
+			columns[field] = i
+		}
+	}
+	notes := note.List{}
+	for {
+		record, err := in.Read()
+		if err == io.EOF {
+			return notes, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		value := func(field string) string {
+			i, ok := columns[field]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return record[i]
+		}
+		notes = append(notes, note.Note{Name: value("name"), Content: value("content"), Notebook: value("notebook")})
+	}
+}
@@ -0,0 +1,160 @@
+package exchange
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+
+	"notes/internal/note"
+)
+
+// Email is a note read from an email, the message id and the sender
+// tell emails apart
+type Email struct {
+	MessageId string
+	From      string
+	Note      note.Note
+}
+
+// email collects the parts of a message, the plain text is preferred
+// to the html of the same message
+type email struct {
+	plain       []string
+	html        []string
+	links       []string
+	attachments string
+	report      []string
+}
+
+// ReadEmail reads an email as a note named after its subject, its
+// content is the text of the body
+// Attachments are saved to the attachments directory and linked at the
+// end of the content, they are listed in the report when it is empty.
+func ReadEmail(r io.Reader, attachments string) (Email, []string, error) {
+	m, err := mail.ReadMessage(r)
+	if err != nil {
+		return Email{}, nil, fmt.Errorf("%w email: %v", note.ErrValidation, err)
+	}
+	decoder := mime.WordDecoder{}
+	subject, err := decoder.DecodeHeader(m.Header.Get("Subject"))
+	if err != nil {
+		subject = m.Header.Get("Subject")
+	}
+	subject = strings.TrimSpace(subject)
+	if subject == "" {
+		subject = "Untitled"
+	}
+	result := Email{MessageId: strings.TrimSpace(m.Header.Get("Message-Id")), From: m.Header.Get("From")}
+	if from, err := mail.ParseAddress(result.From); err == nil {
+		result.From = from.Address
+	}
+	e := email{attachments: attachments}
+	err = e.part(textproto.MIMEHeader(m.Header), m.Body)
+	if err != nil {
+		return Email{}, e.report, fmt.Errorf("%w email: %v", note.ErrValidation, err)
+	}
+	content := strings.Join(e.plain, "\n\n")
+	if len(e.plain) == 0 {
+		for _, html := range e.html {
+			text, err := markdownOfEnml(html, nil, func(format string, a ...any) {})
+			if err != nil {
+				e.report = append(e.report, fmt.Sprintf("html body: %v", err))
+				continue
+			}
+			content = strings.TrimSpace(content + "\n\n" + text)
+		}
+	}
+	content = strings.TrimSpace(strings.ReplaceAll(content, "\r\n", "\n"))
+	if len(e.links) > 0 {
+		content = strings.TrimSpace(content + "\n\n" + strings.Join(e.links, "\n"))
+	}
+	result.Note = note.Note{Name: subject, Content: content}
+	return result, e.report, nil
+}
+
+// part reads a part of the message, multiparts are read part by part
+func (e *email) part(header textproto.MIMEHeader, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		parts := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := parts.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			err = e.part(p.Header, p)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	data, err := io.ReadAll(decoded(header, body))
+	if err != nil {
+		return err
+	}
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	name := dispositionParams["filename"]
+	if name == "" {
+		name = params["name"]
+	}
+	switch {
+	case disposition != "attachment" && name == "" && mediaType == "text/plain":
+		e.plain = append(e.plain, strings.TrimSpace(string(data)))
+	case disposition != "attachment" && name == "" && mediaType == "text/html":
+		e.html = append(e.html, string(data))
+	default:
+		return e.attachment(name, mediaType, data)
+	}
+	return nil
+}
+
+// attachment saves an attachment, it is named after its hash when the
+// email doesn't name it
+func (e *email) attachment(name string, mediaType string, data []byte) error {
+	name = filepath.Base(name)
+	if name == "" || name == "." || name == ".." || name == "/" {
+		sum := md5.Sum(data)
+		name = hex.EncodeToString(sum[:])
+		extensions, _ := mime.ExtensionsByType(mediaType)
+		if len(extensions) > 0 {
+			name += extensions[0]
+		}
+	}
+	if e.attachments == "" {
+		e.report = append(e.report, fmt.Sprintf("attachment %s", name))
+		return nil
+	}
+	path, err := saveAttachment(e.attachments, name, data)
+	if err != nil {
+		return err
+	}
+	e.links = append(e.links, attachmentLink(path, mediaType))
+	return nil
+}
+
+// decoded undoes the transfer encoding of a part
+func decoded(header textproto.MIMEHeader, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	default:
+		return body
+	}
+}
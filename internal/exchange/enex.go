@@ -0,0 +1,341 @@
+package exchange
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"notes/internal/note"
+)
+
+// Evernote export, the content of a note is ENML, an xhtml document,
+// and its attachments are resources referenced by the md5 hash of their
+// data
+type enexExport struct {
+	Notes []enexNote `xml:"note"`
+}
+
+type enexNote struct {
+	Title     string         `xml:"title"`
+	Content   string         `xml:"content"`
+	Tags      []string       `xml:"tag"`
+	Resources []enexResource `xml:"resource"`
+}
+
+type enexResource struct {
+	Data     string `xml:"data"`
+	Mime     string `xml:"mime"`
+	FileName string `xml:"resource-attributes>file-name"`
+}
+
+// ReadEnex reads the notes of an Evernote export and converts their
+// content to markdown
+// Attachments are saved to the attachments directory and linked from
+// the content, they are skipped when it is empty. Everything which
+// can't be imported, such as tags and encrypted text, is listed in the
+// report.
+func ReadEnex(r io.Reader, attachments string) (note.List, []string, error) {
+	export := enexExport{}
+	err := xml.NewDecoder(r).Decode(&export)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w enex: %v", note.ErrValidation, err)
+	}
+	notes := note.List{}
+	report := []string{}
+	for _, en := range export.Notes {
+		name := strings.TrimSpace(en.Title)
+		if name == "" {
+			name = "Untitled"
+		}
+		skip := func(format string, a ...any) {
+			report = append(report, fmt.Sprintf("note %q: ", name)+fmt.Sprintf(format, a...))
+		}
+		if len(en.Tags) > 0 {
+			skip("tags %s", strings.Join(en.Tags, ", "))
+		}
+		media := map[string]string{}
+		for _, resource := range en.Resources {
+			data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(resource.Data), ""))
+			if err != nil {
+				skip("attachment %s: %v", resource.FileName, err)
+				continue
+			}
+			sum := md5.Sum(data)
+			hash := hex.EncodeToString(sum[:])
+			if attachments == "" {
+				skip("attachment %s", attachmentName(resource, hash))
+				continue
+			}
+			path, err := saveAttachment(attachments, attachmentName(resource, hash), data)
+			if err != nil {
+				return nil, report, err
+			}
+			media[hash] = attachmentLink(path, resource.Mime)
+		}
+		content, err := markdownOfEnml(en.Content, media, skip)
+		if err != nil {
+			skip("the whole note: %v", err)
+			continue
+		}
+		notes = append(notes, note.Note{Name: name, Content: content})
+	}
+	return notes, report, nil
+}
+
+// attachmentName is the file name of a resource, or its hash when the
+// export doesn't name it
+func attachmentName(resource enexResource, hash string) string {
+	name := filepath.Base(resource.FileName)
+	if resource.FileName != "" && name != "." && name != ".." && name != "/" {
+		return name
+	}
+	extensions, _ := mime.ExtensionsByType(resource.Mime)
+	if len(extensions) > 0 {
+		return hash + extensions[0]
+	}
+	return hash
+}
+
+// saveAttachment writes an attachment to dir without overwriting
+// another attachment of the same name
+func saveAttachment(dir string, name string, data []byte) (string, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	for i := 2; ; i++ {
+		_, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			break
+		}
+		ext := filepath.Ext(name)
+		path = filepath.Join(dir, fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), i, ext))
+	}
+	return path, os.WriteFile(path, data, 0o644)
+}
+
+// attachmentLink links a saved attachment from the content, images are
+// shown
+func attachmentLink(path string, mimeType string) string {
+	link := fmt.Sprintf("[%s](%s)", filepath.Base(path), filepath.ToSlash(path))
+	if strings.HasPrefix(mimeType, "image/") {
+		link = "!" + link
+	}
+	return link
+}
+
+// enml converts ENML, and html in general, to markdown, it keeps the
+// text of the elements it doesn't know
+type enml struct {
+	out   strings.Builder
+	lists []int
+	links []string
+	pre   bool
+	media map[string]string
+	skip  func(string, ...any)
+}
+
+func markdownOfEnml(content string, media map[string]string, skip func(string, ...any)) (string, error) {
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	c := enml{media: media, skip: skip}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "en-crypt" {
+				c.skip("encrypted text")
+				decoder.Skip()
+				continue
+			}
+			// html emails have a head and styles which aren't text
+			if t.Name.Local == "head" || t.Name.Local == "style" || t.Name.Local == "script" {
+				decoder.Skip()
+				continue
+			}
+			c.start(t)
+		case xml.EndElement:
+			c.end(t)
+		case xml.CharData:
+			c.text(string(t))
+		}
+	}
+	return tidy(c.out.String()), nil
+}
+
+func attr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (c *enml) start(e xml.StartElement) {
+	switch e.Name.Local {
+	case "p", "blockquote", "table":
+		c.block()
+	case "div":
+		c.line()
+	case "br":
+		c.out.WriteString("\n")
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		c.block()
+		c.out.WriteString(strings.Repeat("#", int(e.Name.Local[1]-'0')) + " ")
+	case "b", "strong":
+		c.out.WriteString("**")
+	case "i", "em":
+		c.out.WriteString("_")
+	case "s", "strike", "del":
+		c.out.WriteString("~~")
+	case "code":
+		if !c.pre {
+			c.out.WriteString("`")
+		}
+	case "pre":
+		c.block()
+		c.out.WriteString("```\n")
+		c.pre = true
+	case "a":
+		c.links = append(c.links, attr(e, "href"))
+		c.out.WriteString("[")
+	case "ul":
+		c.line()
+		c.lists = append(c.lists, 0)
+	case "ol":
+		c.line()
+		c.lists = append(c.lists, 1)
+	case "li":
+		c.line()
+		if len(c.lists) == 0 {
+			c.out.WriteString("- ")
+			break
+		}
+		c.out.WriteString(strings.Repeat("  ", len(c.lists)-1))
+		last := len(c.lists) - 1
+		if c.lists[last] == 0 {
+			c.out.WriteString("- ")
+			break
+		}
+		fmt.Fprintf(&c.out, "%d. ", c.lists[last])
+		c.lists[last]++
+	case "en-todo":
+		if attr(e, "checked") == "true" {
+			c.out.WriteString("[x] ")
+		} else {
+			c.out.WriteString("[ ] ")
+		}
+	case "en-media":
+		hash := attr(e, "hash")
+		if link, ok := c.media[hash]; ok {
+			c.out.WriteString(link)
+		}
+	case "img":
+		fmt.Fprintf(&c.out, "![%s](%s)", attr(e, "alt"), attr(e, "src"))
+	case "hr":
+		c.block()
+		c.out.WriteString("---")
+		c.block()
+	case "tr":
+		c.line()
+	case "td", "th":
+		c.out.WriteString("| ")
+	}
+}
+
+func (c *enml) end(e xml.EndElement) {
+	switch e.Name.Local {
+	case "p", "blockquote", "table", "h1", "h2", "h3", "h4", "h5", "h6":
+		c.block()
+	case "div", "tr":
+		c.line()
+	case "b", "strong":
+		c.out.WriteString("**")
+	case "i", "em":
+		c.out.WriteString("_")
+	case "s", "strike", "del":
+		c.out.WriteString("~~")
+	case "code":
+		if !c.pre {
+			c.out.WriteString("`")
+		}
+	case "pre":
+		c.line()
+		c.out.WriteString("```")
+		c.block()
+		c.pre = false
+	case "a":
+		href := ""
+		if len(c.links) > 0 {
+			href = c.links[len(c.links)-1]
+			c.links = c.links[:len(c.links)-1]
+		}
+		fmt.Fprintf(&c.out, "](%s)", href)
+	case "ul", "ol":
+		if len(c.lists) > 0 {
+			c.lists = c.lists[:len(c.lists)-1]
+		}
+		c.block()
+	case "td", "th":
+		c.out.WriteString(" ")
+	}
+}
+
+var spaces = regexp.MustCompile(`\s+`)
+
+// text collapses the white space as a browser would, except in pre
+func (c *enml) text(s string) {
+	if c.pre {
+		c.out.WriteString(s)
+		return
+	}
+	s = spaces.ReplaceAllString(s, " ")
+	if strings.HasSuffix(c.out.String(), "\n") || c.out.Len() == 0 {
+		s = strings.TrimLeft(s, " ")
+	}
+	c.out.WriteString(s)
+}
+
+// line starts a new line unless the text is already at one
+func (c *enml) line() {
+	if c.out.Len() > 0 && !strings.HasSuffix(c.out.String(), "\n") {
+		c.out.WriteString("\n")
+	}
+}
+
+// block leaves a blank line after the text
+func (c *enml) block() {
+	c.line()
+	if c.out.Len() > 0 && !strings.HasSuffix(c.out.String(), "\n\n") {
+		c.out.WriteString("\n")
+	}
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// tidy removes trailing spaces and extra blank lines
+func tidy(s string) string {
+	lines := strings.Split(s, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " ")
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
@@ -0,0 +1,75 @@
+package exchange
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"notes/internal/crypt"
+	"notes/internal/note"
+)
+
+// htmlStyle keeps the page readable without a stylesheet of its own
+const htmlStyle = `body { max-width: 46em; margin: 2em auto; padding: 0 1em; font: 16px/1.5 sans-serif; color: #222 }
+.notebook { color: #777; margin-top: -0.5em }
+ul { list-style: none; padding-left: 0 }
+li .marker { display: inline-block; min-width: 1.5em }
+blockquote { margin-left: 0; padding-left: 1em; border-left: 3px solid #ccc; color: #555; font-style: italic }
+pre { background: #f3f3f3; padding: 0.5em; overflow-x: auto }
+article + article { border-top: 1px solid #ccc; margin-top: 2em }`
+
+// WriteHTML renders the Markdown of the notes to an html page, as
+// WritePDF renders them, the sealed contents are left out
+func WriteHTML(w io.Writer, title string, notes []note.Note) error {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "<!doctype html>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s\n</style>\n", html.EscapeString(title), htmlStyle)
+	for _, n := range notes {
+		fmt.Fprintf(b, "<article id=\"note-%d\">\n<h1>%s</h1>\n", n.Id, html.EscapeString(n.Name))
+		if n.Notebook != "" {
+			fmt.Fprintf(b, "<p class=\"notebook\">%s</p>\n", html.EscapeString(n.Notebook))
+		}
+		if crypt.IsSealed(n.Content) {
+			fmt.Fprint(b, "<p><em>This note is encrypted, its content can't be rendered.</em></p>\n")
+		} else {
+			writeHTMLBlocks(b, parseMarkdown(n.Content))
+		}
+		fmt.Fprint(b, "</article>\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeHTMLBlocks writes the blocks of a note, its headings a level
+// under its name
+func writeHTMLBlocks(b *strings.Builder, blocks []mdBlock) {
+	list := false
+	for _, block := range blocks {
+		if block.kind == mdItem && !list {
+			fmt.Fprint(b, "<ul>\n")
+			list = true
+		}
+		switch block.kind {
+		case mdHeading:
+			level := min(block.level+1, 6)
+			fmt.Fprintf(b, "<h%d>%s</h%d>\n", level, html.EscapeString(block.text), level)
+		case mdParagraph:
+			fmt.Fprintf(b, "<p>%s</p>\n", html.EscapeString(block.text))
+		case mdItem:
+			fmt.Fprintf(b, "<li style=\"margin-left: %gem\"><span class=\"marker\">%s</span>%s</li>\n", 1.5*float64(block.level), html.EscapeString(block.marker), html.EscapeString(block.text))
+		case mdQuote:
+			fmt.Fprintf(b, "<blockquote>%s</blockquote>\n", html.EscapeString(block.text))
+		case mdCode:
+			fmt.Fprintf(b, "<pre><code>%s</code></pre>\n", html.EscapeString(strings.Join(block.lines, "\n")))
+		case mdRule:
+			fmt.Fprint(b, "<hr>\n")
+		}
+		if block.kind == mdItem && block.last {
+			fmt.Fprint(b, "</ul>\n")
+			list = false
+		}
+	}
+	if list {
+		fmt.Fprint(b, "</ul>\n")
+	}
+}
@@ -0,0 +1,73 @@
+// Package exchange converts notes from and to the formats other notes
+// tools understand.
+package exchange
+
+import (
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"notes/internal/note"
+)
+
+// WriteMarkdown writes each note to dir as slug.md with a yaml front
+// matter, the notes of a notebook go to a subdirectory named after it
+// Notes whose slugs collide get their id appended, the first one keeps
+// the slug so the notes should come in the order of their ids
+func WriteMarkdown(dir string, notes iter.Seq[note.Note]) error {
+	taken := map[string]bool{}
+	for n := range notes {
+		folder := dir
+		if n.Notebook != "" {
+			folder = filepath.Join(dir, Slug(n.Notebook))
+		}
+		path := filepath.Join(folder, Slug(n.Name)+".md")
+		if taken[path] {
+			path = filepath.Join(folder, fmt.Sprintf("%s-%d.md", Slug(n.Name), n.Id))
+		}
+		taken[path] = true
+		err := os.MkdirAll(folder, 0o755)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(path, []byte(markdown(n)), 0o644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markdown is the front matter followed by the content, strings are
+// quoted so any name is valid yaml
+func markdown(n note.Note) string {
+	b := strings.Builder{}
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "id: %d\n", n.Id)
+	fmt.Fprintf(&b, "name: %s\n", strconv.Quote(n.Name))
+	if n.Notebook != "" {
+		fmt.Fprintf(&b, "notebook: %s\n", strconv.Quote(n.Notebook))
+	}
+	b.WriteString("---\n\n")
+	b.WriteString(n.Content)
+	if !strings.HasSuffix(n.Content, "\n") {
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Slug keeps the lowercase letters and digits of a name, separated by
+// dashes
+func Slug(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return "note"
+	}
+	return strings.Join(words, "-")
+}
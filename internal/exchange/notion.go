@@ -0,0 +1,122 @@
+package exchange
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"notes/internal/note"
+)
+
+// Notion names every page and database after its title followed by its
+// id, a page is a markdown file and a database a csv file with the
+// pages of its rows in a directory of the same name
+var notionId = regexp.MustCompile(` [0-9a-f]{32}$`)
+
+var markdownLink = regexp.MustCompile(`\[([^\]]*)\]\(([^)\s]+)\)`)
+
+// notionTitle strips the extension and the id of a Notion file name
+func notionTitle(name string) string {
+	name = path.Base(name)
+	name = strings.TrimSuffix(name, path.Ext(name))
+	return notionId.ReplaceAllString(name, "")
+}
+
+// ReadNotion reads the pages of a Notion "Markdown & CSV" export, the
+// rows of a database go to a notebook named after it and links between
+// pages become [[wiki links]]
+// Files which are not pages, such as images, are listed in the report.
+func ReadNotion(r io.ReaderAt, size int64) (note.List, []string, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w notion: %v", note.ErrValidation, err)
+	}
+	notes := note.List{}
+	report := []string{}
+	err = readNotionArchive(archive, &notes, &report)
+	return notes, report, err
+}
+
+// readNotionArchive reads the pages of an archive, Notion wraps large
+// exports in an archive of archives
+func readNotionArchive(archive *zip.Reader, notes *note.List, report *[]string) error {
+	databases := map[string]bool{}
+	for _, f := range archive.File {
+		if path.Ext(f.Name) == ".csv" {
+			databases[strings.TrimSuffix(f.Name, ".csv")] = true
+		}
+	}
+	files := append([]*zip.File{}, archive.File...)
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	for _, f := range files {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		switch path.Ext(f.Name) {
+		case ".md":
+			content, err := readZipFile(f)
+			if err != nil {
+				return err
+			}
+			n := notionPage(f.Name, string(content))
+			if databases[path.Dir(f.Name)] {
+				n.Notebook = notionTitle(path.Dir(f.Name))
+			}
+			*notes = append(*notes, n)
+		case ".csv":
+		case ".zip":
+			content, err := readZipFile(f)
+			if err != nil {
+				return err
+			}
+			inner, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+			if err != nil {
+				return fmt.Errorf("%w notion %s: %v", note.ErrValidation, f.Name, err)
+			}
+			err = readNotionArchive(inner, notes, report)
+			if err != nil {
+				return err
+			}
+		default:
+			*report = append(*report, fmt.Sprintf("file %s", f.Name))
+		}
+	}
+	return nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// notionPage names the note after the title heading of the page, or its
+// file name, and rewrites the links to other pages
+func notionPage(name string, content string) note.Note {
+	title := notionTitle(name)
+	if heading, rest, ok := strings.Cut(content, "\n"); ok && strings.HasPrefix(heading, "# ") {
+		title = strings.TrimSpace(strings.TrimPrefix(heading, "# "))
+		content = rest
+	}
+	content = markdownLink.ReplaceAllStringFunc(content, func(link string) string {
+		target := markdownLink.FindStringSubmatch(link)[2]
+		if strings.Contains(target, "://") || path.Ext(target) != ".md" {
+			return link
+		}
+		unescaped, err := url.PathUnescape(target)
+		if err != nil {
+			return link
+		}
+		return "[[" + notionTitle(unescaped) + "]]"
+	})
+	return note.Note{Name: title, Content: strings.TrimSpace(content)}
+}
@@ -0,0 +1,516 @@
+package exchange
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"notes/internal/crypt"
+	"notes/internal/note"
+)
+
+// the pages are A4, in points
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+	margin     = 56.0
+	// footer is the room left under the text for the page numbers
+	footer = 24.0
+)
+
+type pdfFont int
+
+const (
+	regular pdfFont = iota
+	bold
+	italic
+	mono
+)
+
+// pdfFonts are standard fonts, every reader has them
+var pdfFonts = []string{"Helvetica", "Helvetica-Bold", "Helvetica-Oblique", "Courier"}
+
+// WritePDF renders the Markdown of the notes to a PDF document, each
+// note on its own pages
+// Several notes open with a table of contents linking to them, under the
+// title. The fonts are the standard ones, the characters they lack are
+// printed as ?, and the sealed contents are left out.
+func WritePDF(w io.Writer, title string, notes []note.Note) error {
+	d := &pdfDocument{}
+	starts := make([]int, len(notes))
+	for i, n := range notes {
+		starts[i] = len(d.pages)
+		d.writeNote(n)
+	}
+	if len(notes) > 1 {
+		// the contents are laid out once to count their pages
+		toc := &pdfDocument{}
+		toc.writeContents(title, notes, starts, 0)
+		offset := len(toc.pages)
+		toc = &pdfDocument{}
+		toc.writeContents(title, notes, starts, offset)
+		d.pages = append(toc.pages, d.pages...)
+	}
+	if len(d.pages) == 0 {
+		d.newPage()
+	}
+	d.numberPages()
+	return d.write(w, title)
+}
+
+// pdfLink is a link of a page to the top of another one
+type pdfLink struct {
+	x, y, width, height float64
+	page                int
+}
+
+type pdfPage struct {
+	content bytes.Buffer
+	links   []pdfLink
+}
+
+// pdfDocument lays out the text top down, y is where the next line goes
+type pdfDocument struct {
+	pages []*pdfPage
+	y     float64
+}
+
+func (d *pdfDocument) newPage() {
+	d.pages = append(d.pages, &pdfPage{})
+	d.y = pageHeight - margin
+}
+
+// room starts a new page unless height fits above the footer
+func (d *pdfDocument) room(height float64) {
+	if len(d.pages) == 0 || d.y-height < margin+footer {
+		d.newPage()
+	}
+}
+
+func (d *pdfDocument) page() *pdfPage {
+	return d.pages[len(d.pages)-1]
+}
+
+func (d *pdfDocument) text(font pdfFont, size float64, gray float64, x float64, y float64, s string) {
+	fmt.Fprintf(&d.page().content, "BT /F%d %.1f Tf %.2f g %.2f %.2f Td (%s) Tj ET\n", font+1, size, gray, x, y, pdfString(s))
+}
+
+func (d *pdfDocument) rect(gray float64, x float64, y float64, width float64, height float64) {
+	fmt.Fprintf(&d.page().content, "%.2f g %.2f %.2f %.2f %.2f re f\n", gray, x, y, width, height)
+}
+
+func (d *pdfDocument) rule(gray float64, x float64, y float64, width float64) {
+	fmt.Fprintf(&d.page().content, "%.2f G 0.5 w %.2f %.2f m %.2f %.2f l S\n", gray, x, y, x+width, y)
+}
+
+// line writes one line of text, leading is the height it takes
+func (d *pdfDocument) line(font pdfFont, size float64, leading float64, gray float64, x float64, s string) {
+	d.room(leading)
+	d.y -= leading
+	d.text(font, size, gray, x, d.y+leading*0.25, s)
+}
+
+// paragraph wraps the text in the width right of x
+func (d *pdfDocument) paragraph(font pdfFont, size float64, leading float64, gray float64, x float64, s string) {
+	for _, l := range wrap(s, font, size, pageWidth-margin-x) {
+		d.line(font, size, leading, gray, x, l)
+	}
+}
+
+// writeNote starts a page with the name of the note and renders its
+// content
+func (d *pdfDocument) writeNote(n note.Note) {
+	d.newPage()
+	d.paragraph(bold, 20, 26, 0, margin, n.Name)
+	if n.Notebook != "" {
+		d.line(regular, 10, 14, 0.45, margin, n.Notebook)
+	}
+	d.y -= 6
+	d.rule(0.75, margin, d.y, pageWidth-2*margin)
+	d.y -= 10
+	if crypt.IsSealed(n.Content) {
+		d.line(italic, 11, 15, 0.4, margin, "This note is encrypted, its content can't be rendered.")
+		return
+	}
+	for _, b := range parseMarkdown(n.Content) {
+		d.writeBlock(b)
+	}
+}
+
+// headingSizes are the font sizes of the headings by level
+var headingSizes = []float64{18, 15, 13, 12, 11, 11}
+
+func (d *pdfDocument) writeBlock(b mdBlock) {
+	switch b.kind {
+	case mdHeading:
+		size := headingSizes[b.level-1]
+		// a heading stays with the line after it
+		d.room(size*1.3 + 30)
+		d.y -= 8
+		d.paragraph(bold, size, size*1.3, 0, margin, b.text)
+		d.y -= 2
+	case mdParagraph:
+		d.paragraph(regular, 11, 15, 0, margin, b.text)
+		d.y -= 6
+	case mdItem:
+		x := margin + 16*float64(b.level+1)
+		lines := wrap(b.text, regular, 11, pageWidth-margin-x)
+		for i, l := range lines {
+			d.line(regular, 11, 15, 0, x, l)
+			if i == 0 {
+				d.text(regular, 11, 0, x-width(b.marker, regular, 11)-5, d.y+15*0.25, b.marker)
+			}
+		}
+		if b.last {
+			d.y -= 6
+		}
+	case mdQuote:
+		for _, l := range wrap(b.text, italic, 11, pageWidth-2*margin-14) {
+			d.line(italic, 11, 15, 0.35, margin+14, l)
+			d.rect(0.8, margin+2, d.y, 2.5, 15)
+		}
+		d.y -= 6
+	case mdCode:
+		room := pageWidth - 2*margin - 12
+		perLine := int(room / (0.6 * 9.5))
+		for _, l := range b.lines {
+			for _, part := range splitRunes(strings.ReplaceAll(l, "\t", "    "), perLine) {
+				d.room(12.5)
+				d.y -= 12.5
+				d.rect(0.95, margin, d.y, pageWidth-2*margin, 12.5)
+				d.text(mono, 9.5, 0.1, margin+6, d.y+12.5*0.25, part)
+			}
+		}
+		d.y -= 8
+	case mdRule:
+		d.room(14)
+		d.y -= 7
+		d.rule(0.75, margin, d.y, pageWidth-2*margin)
+		d.y -= 7
+	}
+}
+
+// tocLeading is the height of a line of the table of contents
+const tocLeading = 18.0
+
+// writeContents lists the notes with their page, the pages of the
+// notes come offset pages after their start
+func (d *pdfDocument) writeContents(title string, notes []note.Note, starts []int, offset int) {
+	d.newPage()
+	if title != "" {
+		d.paragraph(bold, 20, 26, 0, margin, title)
+		d.y -= 4
+	}
+	d.line(bold, 14, 20, 0.3, margin, "Contents")
+	d.y -= 6
+	for i, n := range notes {
+		page := fmt.Sprint(starts[i] + offset + 1)
+		name := n.Name
+		if n.Notebook != "" && title != n.Notebook {
+			name += " (" + n.Notebook + ")"
+		}
+		room := pageWidth - 2*margin - width(page, regular, 11) - 24
+		name = truncate(name, regular, 11, room)
+		d.line(regular, 11, tocLeading, 0, margin, name)
+		d.text(regular, 11, 0, pageWidth-margin-width(page, regular, 11), d.y+tocLeading*0.25, page)
+		left := margin + width(name, regular, 11) + 6
+		right := pageWidth - margin - width(page, regular, 11) - 6
+		if right > left {
+			fmt.Fprintf(&d.page().content, "0.7 G 0.5 w [1 2] 0 d %.2f %.2f m %.2f %.2f l S [] 0 d\n", left, d.y+tocLeading*0.25, right, d.y+tocLeading*0.25)
+		}
+		d.page().links = append(d.page().links, pdfLink{x: margin, y: d.y, width: pageWidth - 2*margin, height: tocLeading, page: starts[i] + offset})
+	}
+}
+
+// numberPages writes the page numbers in the footers
+func (d *pdfDocument) numberPages() {
+	for i, p := range d.pages {
+		number := fmt.Sprintf("%d / %d", i+1, len(d.pages))
+		fmt.Fprintf(&p.content, "BT /F1 9 Tf 0.5 g %.2f %.2f Td (%s) Tj ET\n", (pageWidth-width(number, regular, 9))/2, margin/2, number)
+	}
+}
+
+// write writes the objects of the document: the catalog, the page
+// tree, the fonts and the information, then each page and its content
+func (d *pdfDocument) write(w io.Writer, title string) error {
+	out := &bytes.Buffer{}
+	offsets := []int{}
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	const firstPage = 8
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	kids := []string{}
+	for i := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", firstPage+2*i))
+	}
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	for _, f := range pdfFonts {
+		object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", f))
+	}
+	object(fmt.Sprintf("<< /Title (%s) /Producer (notes) >>", pdfString(title)))
+	for i, p := range d.pages {
+		annots := []string{}
+		for _, l := range p.links {
+			annots = append(annots, fmt.Sprintf("<< /Type /Annot /Subtype /Link /Rect [%.2f %.2f %.2f %.2f] /Border [0 0 0] /Dest [%d 0 R /XYZ 0 %.0f 0] >>",
+				l.x, l.y, l.x+l.width, l.y+l.height, firstPage+2*l.page, pageHeight))
+		}
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R /F3 5 0 R /F4 6 0 R >> >> /Contents %d 0 R /Annots [%s] >>",
+			pageWidth, pageHeight, firstPage+2*i+1, strings.Join(annots, " ")))
+		compressed := bytes.Buffer{}
+		z := zlib.NewWriter(&compressed)
+		z.Write(p.content.Bytes())
+		z.Close()
+		object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), compressed.Bytes()))
+	}
+	xref := out.Len()
+	fmt.Fprintf(out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, o := range offsets {
+		fmt.Fprintf(out, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(out, "trailer\n<< /Size %d /Root 1 0 R /Info 7 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// winAnsi maps the characters of the Windows code page the standard
+// fonts are encoded in which are not at the same place in Latin-1
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// pdfString encodes a string of a content stream, the parentheses and
+// backslashes escaped
+func pdfString(s string) string {
+	b := strings.Builder{}
+	for _, r := range s {
+		c, ok := winAnsi[r]
+		switch {
+		case ok:
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			c = byte(r)
+		default:
+			c = '?'
+		}
+		if c == '(' || c == ')' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// helvetica and helveticaBold are the widths of the ascii characters
+// from the space, in thousandths of the font size, the oblique font is
+// as wide as the regular one and every Courier character is 600
+var helvetica = []int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBold = []int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
+
+// width of a text in points, the characters beyond ascii are taken as
+// wide as a digit
+func width(s string, font pdfFont, size float64) float64 {
+	widths := helvetica
+	if font == bold {
+		widths = helveticaBold
+	}
+	total := 0
+	for _, r := range s {
+		switch {
+		case font == mono:
+			total += 600
+		case r >= ' ' && r <= '~':
+			total += widths[r-' ']
+		default:
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// wrap cuts a text into lines no wider than max, between words unless a
+// word is wider than a line
+func wrap(s string, font pdfFont, size float64, max float64) []string {
+	lines := []string{}
+	current := ""
+	for _, word := range strings.Fields(s) {
+		candidate := word
+		if current != "" {
+			candidate = current + " " + word
+		}
+		if width(candidate, font, size) <= max {
+			current = candidate
+			continue
+		}
+		if current != "" {
+			lines = append(lines, current)
+		}
+		current = word
+		for width(current, font, size) > max && utf8.RuneCountInString(current) > 1 {
+			runes := []rune(current)
+			cut := len(runes) - 1
+			for cut > 1 && width(string(runes[:cut]), font, size) > max {
+				cut--
+			}
+			lines = append(lines, string(runes[:cut]))
+			current = string(runes[cut:])
+		}
+	}
+	if current != "" || len(lines) == 0 {
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+// truncate shortens a text to max points, ending it with an ellipsis
+func truncate(s string, font pdfFont, size float64, max float64) string {
+	if width(s, font, size) <= max {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && width(string(runes)+"…", font, size) > max {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+// splitRunes cuts a line of code every n characters
+func splitRunes(s string, n int) []string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return []string{s}
+	}
+	parts := []string{}
+	for len(runes) > n {
+		parts = append(parts, string(runes[:n]))
+		runes = runes[n:]
+	}
+	return append(parts, string(runes))
+}
+
+type mdKind int
+
+const (
+	mdParagraph mdKind = iota
+	mdHeading
+	mdItem
+	mdQuote
+	mdCode
+	mdRule
+)
+
+// mdBlock is a block of Markdown, the level of a heading or the nesting
+// of a list item
+type mdBlock struct {
+	kind   mdKind
+	level  int
+	text   string
+	marker string
+	lines  []string
+	// last tells the item ends its list
+	last bool
+}
+
+var (
+	mdHeadingLine = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	mdItemLine    = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.*)$`)
+	mdRuleLine    = regexp.MustCompile(`^\s*((-\s*){3,}|(\*\s*){3,}|(_\s*){3,})$`)
+	mdImage       = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink        = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)[^)]*\)`)
+	mdEmphasis    = regexp.MustCompile(`(^|[^\w*])[*_]([^*_\s][^*_]*)[*_]([^\w*]|$)`)
+)
+
+// parseMarkdown cuts a content into blocks, the inline markup is
+// removed from their text as the fonts don't change within a line
+func parseMarkdown(content string) []mdBlock {
+	blocks := []mdBlock{}
+	// open is the block the next line may continue
+	open := false
+	code := false
+	for _, l := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(l)
+		if code {
+			if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+				code = false
+				continue
+			}
+			blocks[len(blocks)-1].lines = append(blocks[len(blocks)-1].lines, l)
+			continue
+		}
+		last := len(blocks) - 1
+		if trimmed == "" || mdRuleLine.MatchString(l) || strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") || mdHeadingLine.MatchString(trimmed) {
+			if last >= 0 && blocks[last].kind == mdItem {
+				blocks[last].last = true
+			}
+		}
+		switch m := mdItemLine.FindStringSubmatch(l); {
+		case trimmed == "":
+			open = false
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			code = true
+			open = false
+			blocks = append(blocks, mdBlock{kind: mdCode, lines: []string{}})
+		case mdRuleLine.MatchString(l):
+			open = false
+			blocks = append(blocks, mdBlock{kind: mdRule})
+		case mdHeadingLine.MatchString(trimmed):
+			h := mdHeadingLine.FindStringSubmatch(trimmed)
+			open = false
+			blocks = append(blocks, mdBlock{kind: mdHeading, level: len(h[1]), text: inline(h[2])})
+		case m != nil:
+			marker := "•"
+			if m[2] != "-" && m[2] != "*" && m[2] != "+" {
+				marker = m[2]
+			}
+			open = true
+			blocks = append(blocks, mdBlock{kind: mdItem, level: min(len(strings.ReplaceAll(m[1], "\t", "  "))/2, 4), marker: marker, text: inline(m[3])})
+		case strings.HasPrefix(trimmed, ">"):
+			text := inline(strings.TrimSpace(strings.TrimPrefix(trimmed, ">")))
+			if open && blocks[last].kind == mdQuote {
+				blocks[last].text += " " + text
+				continue
+			}
+			open = true
+			blocks = append(blocks, mdBlock{kind: mdQuote, text: text})
+		case open:
+			blocks[last].text += " " + inline(trimmed)
+		default:
+			open = true
+			blocks = append(blocks, mdBlock{kind: mdParagraph, text: inline(trimmed)})
+		}
+	}
+	if len(blocks) > 0 && blocks[len(blocks)-1].kind == mdItem {
+		blocks[len(blocks)-1].last = true
+	}
+	return blocks
+}
+
+// inline removes the emphasis and code markers of a text, the links keep
+// their text followed by their URL
+func inline(s string) string {
+	s = mdImage.ReplaceAllString(s, "$1")
+	s = mdLink.ReplaceAllString(s, "$1 ($2)")
+	s = strings.NewReplacer("**", "", "__", "", "`", "").Replace(s)
+	return mdEmphasis.ReplaceAllString(s, "$1$2$3")
+}
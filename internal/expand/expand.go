@@ -0,0 +1,116 @@
+// Package expand replaces the placeholders of a text, such as {{date}} or
+// {{uuid}}, by the values of their variables. A placeholder may give an
+// argument to its variable after a colon, as {{date:Monday 2 January}}.
+package expand
+
+import (
+	"crypto/rand"
+	"fmt"
+	"maps"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Variable gives the value of a placeholder, arg is what follows its name
+// after a colon, empty without
+type Variable func(arg string) (string, error)
+
+// Expander knows the variables of the placeholders, the placeholders of
+// the other names are left as they are
+type Expander struct {
+	variables map[string]Variable
+}
+
+// New expands the placeholders of the variables
+func New(variables map[string]Variable) Expander {
+	return Expander{variables: maps.Clone(variables)}
+}
+
+// With adds variables to a copy of the expander, they replace those of
+// the same name
+func (e Expander) With(variables map[string]Variable) Expander {
+	copied := maps.Clone(e.variables)
+	if copied == nil {
+		copied = map[string]Variable{}
+	}
+	maps.Copy(copied, variables)
+	return Expander{variables: copied}
+}
+
+var placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z][\w.-]*)(?::([^{}]*?))?\s*\}\}`)
+
+// Expand replaces the placeholders of a text, it fails with the first
+// variable failing
+func (e Expander) Expand(text string) (string, error) {
+	if len(e.variables) == 0 || !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	var err error
+	expanded := placeholder.ReplaceAllStringFunc(text, func(match string) string {
+		m := placeholder.FindStringSubmatch(match)
+		v, ok := e.variables[m[1]]
+		if !ok || err != nil {
+			return match
+		}
+		value, e := v(m[2])
+		if e != nil {
+			err = fmt.Errorf("{{%s}}: %w", m[1], e)
+			return match
+		}
+		return value
+	})
+	return expanded, err
+}
+
+// Funcs are the variables as the functions of a text/template, so a
+// template can use {{date}} or {{date "Monday"}}
+func (e Expander) Funcs() template.FuncMap {
+	funcs := template.FuncMap{}
+	for name, v := range e.variables {
+		funcs[name] = func(args ...string) (string, error) {
+			return v(strings.Join(args, " "))
+		}
+	}
+	return funcs
+}
+
+// Builtins are the variables of the time and the uuids, now tells the
+// time
+// {{date}} and {{time}} take a layout of package time, {{datetime}} is
+// both, {{uuid}} is a random uuid.
+func Builtins(now func() time.Time) map[string]Variable {
+	formatted := func(layout string) Variable {
+		return func(arg string) (string, error) {
+			if arg == "" {
+				arg = layout
+			}
+			return now().Format(arg), nil
+		}
+	}
+	return map[string]Variable{
+		"date":     formatted("2006-01-02"),
+		"time":     formatted("15:04"),
+		"datetime": formatted("2006-01-02 15:04"),
+		"uuid":     uuid,
+	}
+}
+
+// Value is the variable of a fixed value, such as the custom variables
+// of the configuration
+func Value(value string) Variable {
+	return func(string) (string, error) { return value, nil }
+}
+
+// uuid is a random uuid, version 4
+func uuid(string) (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,159 @@
+// Package gist publishes notes as GitHub Gists.
+package gist
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"notes/internal/exchange"
+	"notes/internal/note"
+)
+
+// Config of the publisher
+type Config struct {
+	// Token is a GitHub token allowed to create gists
+	Token string
+	// State is the json file of the gists of the notes, they are only
+	// kept in memory when empty
+	State string
+	// API defaults to https://api.github.com
+	API string
+}
+
+// Gist a note was published as
+type Gist struct {
+	Id  string `json:"id"`
+	URL string `json:"url"`
+	// File is the name of the note in the gist, a renamed note renames
+	// it
+	File string `json:"file"`
+}
+
+// Publisher publishes each note as its own gist, publishing a note
+// again updates its gist
+// Notes have no metadata, the gist of each note is kept in the state
+// file.
+type Publisher struct {
+	config Config
+	http   *http.Client
+	mutex  *sync.Mutex
+	gists  map[note.Id]Gist
+}
+
+// New loads the gists of the state file, a missing file is no gist
+func New(config Config) (Publisher, error) {
+	if config.API == "" {
+		config.API = "https://api.github.com"
+	}
+	p := Publisher{
+		config: config,
+		http:   &http.Client{Timeout: 30 * time.Second},
+		mutex:  &sync.Mutex{},
+		gists:  map[note.Id]Gist{},
+	}
+	if config.State == "" {
+		return p, nil
+	}
+	data, err := os.ReadFile(config.State)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	err = json.Unmarshal(data, &p.gists)
+	return p, err
+}
+
+type gistFile struct {
+	Content string `json:"content"`
+}
+
+// Publish creates the gist of a note or updates it, public only
+// matters to a new gist since GitHub can't change the visibility of a
+// gist
+func (p Publisher) Publish(n note.Note, public bool) (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	file := exchange.Slug(n.Name) + ".md"
+	// a nil file is deleted from the gist
+	files := map[string]*gistFile{file: {Content: n.Content}}
+	body := map[string]any{"description": n.Name, "files": files}
+	g, ok := p.gists[n.Id]
+	if !ok {
+		body["public"] = public
+		err := p.do(http.MethodPost, "/gists", body, &g)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		if g.File != file {
+			files[g.File] = nil
+		}
+		err := p.do(http.MethodPatch, "/gists/"+g.Id, body, &g)
+		if err != nil {
+			return "", err
+		}
+	}
+	g.File = file
+	p.gists[n.Id] = g
+	return g.URL, p.save()
+}
+
+// do sends a request to the API and decodes its answer
+func (p Publisher) do(method string, path string, body any, result *Gist) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, p.config.API+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+p.config.Token)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("gist: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	answer := struct {
+		Id      string `json:"id"`
+		HtmlURL string `json:"html_url"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&answer)
+	if err != nil {
+		return err
+	}
+	result.Id = answer.Id
+	result.URL = answer.HtmlURL
+	return nil
+}
+
+func (p Publisher) save() error {
+	if p.config.State == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(p.gists, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := p.config.State + ".tmp"
+	err = os.WriteFile(tmp, data, 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, p.config.State)
+}
@@ -0,0 +1,126 @@
+// Package hooks runs executables of a directory when notes change, like
+// git hooks. on-create, on-update and on-delete receive the note as
+// json on their standard input.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"notes/internal/jobs"
+	"notes/internal/note"
+)
+
+// Hooks is a subscriber of the note events, the hooks run as jobs of a
+// queue and are killed after the timeout
+// A hook failing or timing out runs again as many times as the queue
+// retries its jobs.
+type Hooks struct {
+	settings *atomic.Pointer[settings]
+	queue    jobs.Queue
+	errors   func(error)
+}
+
+// settings may be reloaded while the hooks run
+type settings struct {
+	dir     string
+	timeout time.Duration
+}
+
+type hookNote struct {
+	Id       note.Id       `json:"id"`
+	Name     note.Name     `json:"name"`
+	Content  note.Content  `json:"content"`
+	Notebook note.Notebook `json:"notebook,omitempty"`
+}
+
+// New runs the hooks of dir on queue, no hook runs when dir is empty
+// The hooks which can't be queued are given to errors, the queue reports
+// the hooks failing
+func New(dir string, timeout time.Duration, queue jobs.Queue, errors func(error)) Hooks {
+	h := Hooks{settings: &atomic.Pointer[settings]{}, queue: queue, errors: errors}
+	h.Reload(dir, timeout)
+	return h
+}
+
+// Reload changes the directory and the timeout of the hooks, the hooks
+// already queued run with the previous ones
+func (h Hooks) Reload(dir string, timeout time.Duration) {
+	h.settings.Store(&settings{dir: dir, timeout: timeout})
+}
+
+// hook names the hook of an event, restoring a deleted note is a
+// creation and restoring a previous version an update
+func hook(e note.Event) string {
+	switch e.Kind {
+	case note.Created:
+		return "on-create"
+	case note.Updated, note.Renamed, note.Moved:
+		return "on-update"
+	case note.Deleted:
+		return "on-delete"
+	case note.Restored:
+		if e.Previous.Id == 0 {
+			return "on-create"
+		}
+		return "on-update"
+	default:
+		return ""
+	}
+}
+
+func (h Hooks) Notify(e note.Event) {
+	name := hook(e)
+	current := h.settings.Load()
+	if current.dir == "" || name == "" {
+		return
+	}
+	path := filepath.Join(current.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+	input, err := json.Marshal(hookNote{Id: e.Note.Id, Name: e.Note.Name, Content: e.Note.Content, Notebook: e.Note.Notebook})
+	if err != nil {
+		h.fail(name, err)
+		return
+	}
+	err = h.queue.Submit("hook "+name, func() error {
+		return h.run(path, current.timeout, e, input)
+	})
+	if err != nil {
+		h.fail(name, err)
+	}
+}
+
+// run gives the note to the hook, along with the kind of event and the
+// actor in NOTES_EVENT and NOTES_ACTOR
+func (h Hooks) run(path string, timeout time.Duration, e note.Event, input []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(), "NOTES_EVENT="+string(e.Kind), "NOTES_ACTOR="+e.Actor)
+	// children of a killed hook may still hold its output open
+	cmd.WaitDelay = time.Second
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(output))
+	}
+	return nil
+}
+
+func (h Hooks) fail(name string, err error) {
+	if h.errors != nil {
+		h.errors(fmt.Errorf("hook %s: %w", name, err))
+	}
+}
@@ -0,0 +1,82 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"notes/internal/note"
+)
+
+// Admin serves the runtime information of the server on /admin/, to the
+// bearer of its token only, nothing is served without a token
+type Admin struct {
+	Token string
+	// Info gives each part of the information by name, such as storage,
+	// on /admin/{name} as json
+	Info map[string]func() any
+	// Reload reloads the settings which may change while the server
+	// runs on POST /admin/reload, when not nil
+	Reload func() error
+}
+
+// adminRoutes are served beside the API, the accounts of the API can't
+// reach them
+func (app Application) adminRoutes() map[string]http.HandlerFunc {
+	if app.admin.Token == "" {
+		return nil
+	}
+	return map[string]http.HandlerFunc{
+		"GET /admin/{$}":     app.asAdmin(app.handleAdminIndex),
+		"GET /admin/{name}":  app.asAdmin(app.handleAdminInfo),
+		"POST /admin/reload": app.asAdmin(app.handleAdminReload),
+	}
+}
+
+func (app Application) asAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !sameToken(token, app.admin.Token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="notes admin"`)
+			app.fail(w, fmt.Errorf("%w: the admin token is missing or wrong", note.ErrUnauthorized))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleAdminIndex lists the names of the information
+func (app Application) handleAdminIndex(w http.ResponseWriter, r *http.Request) {
+	names := []string{}
+	for name := range app.admin.Info {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(names)
+}
+
+func (app Application) handleAdminInfo(w http.ResponseWriter, r *http.Request) {
+	info, ok := app.admin.Info[r.PathValue("name")]
+	if !ok {
+		app.fail(w, fmt.Errorf("admin %s %w", r.PathValue("name"), note.ErrNotFound))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info())
+}
+
+func (app Application) handleAdminReload(w http.ResponseWriter, r *http.Request) {
+	if app.admin.Reload == nil {
+		app.fail(w, fmt.Errorf("admin reload %w", note.ErrNotFound))
+		return
+	}
+	err := app.admin.Reload()
+	if err != nil {
+		app.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
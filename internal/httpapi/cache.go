@@ -0,0 +1,133 @@
+package httpapi
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"notes/internal/note"
+)
+
+// cachedRoutes are the listings and searches whose responses are cached
+var cachedRoutes = []string{"GET /notes/{$}", "GET /notes/search", "GET /notebooks", "GET /shared-with-me"}
+
+// cacheSize is the number of responses kept, the cache is emptied when
+// it is full
+const cacheSize = 1024
+
+// Cache keeps the responses of the listings and searches, by user and
+// url, until the notes change
+// Every note event and every request of the API which isn't a read
+// starts a new revision of the notes, the responses of the previous
+// revisions are dropped. The responses are also dropped after a while,
+// the indexes updated in the background may be behind the events.
+type Cache struct {
+	mutex     sync.Mutex
+	revision  int
+	responses map[string]cachedResponse
+	ttl       time.Duration
+}
+
+type cachedResponse struct {
+	revision    int
+	at          time.Time
+	contentType string
+	body        []byte
+}
+
+// NewCache keeps the responses for ttl at most, it subscribes to the
+// note events
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{responses: map[string]cachedResponse{}, ttl: ttl}
+}
+
+// Notify starts a new revision on every change of a note
+func (c *Cache) Notify(note.Event) {
+	c.invalidate()
+}
+
+func (c *Cache) invalidate() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.revision++
+	clear(c.responses)
+}
+
+// get returns the response of a key of the current revision, and the
+// revision a new response would be of
+func (c *Cache) get(key string) (cachedResponse, bool, int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	r, ok := c.responses[key]
+	if !ok || r.revision != c.revision || time.Since(r.at) > c.ttl {
+		return cachedResponse{}, false, c.revision
+	}
+	return r, true, c.revision
+}
+
+// put keeps a response unless the notes changed while it was written
+func (c *Cache) put(key string, r cachedResponse) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if r.revision != c.revision {
+		return
+	}
+	if len(c.responses) >= cacheSize {
+		clear(c.responses)
+	}
+	c.responses[key] = r
+}
+
+// cached serves the cached routes from the cache, and starts a new
+// revision after the other requests but the reads
+func (app Application) cached(pattern string, next http.HandlerFunc) http.HandlerFunc {
+	if app.cache == nil {
+		return next
+	}
+	if !slices.Contains(cachedRoutes, pattern) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			next(w, r)
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			default:
+				app.cache.invalidate()
+			}
+		}
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := fmt.Sprintf("%d %s %s", messageContext(r).User, app.formatOf(r).Name, r.URL.RequestURI())
+		response, ok, revision := app.cache.get(key)
+		if ok {
+			if response.contentType != "" {
+				w.Header().Set("Content-Type", response.contentType)
+			}
+			w.Write(response.body)
+			return
+		}
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r)
+		if recorder.status == http.StatusOK {
+			app.cache.put(key, cachedResponse{revision: revision, at: time.Now(), contentType: w.Header().Get("Content-Type"), body: recorder.body.Bytes()})
+		}
+	}
+}
+
+// responseRecorder keeps a copy of the response it writes
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
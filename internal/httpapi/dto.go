@@ -0,0 +1,234 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+	"time"
+
+	"notes/internal/note"
+	"notes/internal/query"
+	"notes/internal/usecase"
+)
+
+// The notes are sent and received as the DTOs below rather than as the
+// entities, so the fields the entities gain or change stay out of the
+// HTTP API until they are mapped
+// The names of the fields are those the API always had.
+
+// noteRequest is the body of the requests writing a note, a form or a
+// json object with the same fields
+type noteRequest struct {
+	Name     note.Name     `json:"name"`
+	Content  note.Content  `json:"content"`
+	Notebook note.Notebook `json:"notebook"`
+}
+
+// noteRequestOf reads the body of a request, as json or as a form
+func noteRequestOf(r *http.Request) (noteRequest, error) {
+	body := noteRequest{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			return body, fmt.Errorf("%w body: %v", note.ErrValidation, err)
+		}
+		return body, nil
+	}
+	body.Name = r.FormValue("name")
+	body.Content = r.FormValue("content")
+	body.Notebook = r.FormValue("notebook")
+	return body, nil
+}
+
+type noteResponse struct {
+	Id       note.Id           `json:"Id"`
+	Name     note.Name         `json:"Name"`
+	Content  note.Content      `json:"Content"`
+	Notebook note.Notebook     `json:"Notebook"`
+	Owner    note.UserId       `json:"Owner"`
+	Metadata map[string]string `json:"Metadata,omitempty"`
+	Length   lengthResponse    `json:"Length"`
+}
+
+type lengthResponse struct {
+	Words          int `json:"Words"`
+	Characters     int `json:"Characters"`
+	ReadingMinutes int `json:"ReadingMinutes"`
+}
+
+type summaryResponse struct {
+	Id       note.Id        `json:"Id"`
+	Name     note.Name      `json:"Name"`
+	Notebook note.Notebook  `json:"Notebook"`
+	Owner    note.UserId    `json:"Owner"`
+	Preview  note.Content   `json:"Preview"`
+	Size     int            `json:"Size"`
+	Length   lengthResponse `json:"Length"`
+	Color    string         `json:"Color,omitempty"`
+}
+
+type snippetResponse struct {
+	NoteId  note.Id  `json:"NoteId"`
+	Field   string   `json:"Field"`
+	Text    string   `json:"Text"`
+	Matches [][2]int `json:"Matches"`
+}
+
+// readResponse is a note read
+type readResponse struct {
+	Note noteResponse `json:"Note"`
+}
+
+// writeResponse is a note written, or which would be on a dry run
+type writeResponse struct {
+	Note   noteResponse `json:"Note"`
+	DryRun bool         `json:"DryRun"`
+}
+
+type notesResponse struct {
+	Notes []noteResponse `json:"Notes"`
+}
+
+// dashboardResponse is the pinned notes, those due today and the recent
+// ones
+type dashboardResponse struct {
+	Pinned []noteResponse     `json:"Pinned"`
+	Due    []reminderResponse `json:"Due"`
+	Recent []recentResponse   `json:"Recent"`
+}
+
+type reminderResponse struct {
+	Note noteResponse `json:"Note"`
+	Due  time.Time    `json:"Due"`
+}
+
+type recentResponse struct {
+	Note noteResponse `json:"Note"`
+	At   time.Time    `json:"At"`
+}
+
+// eventResponse is a change of a note sent on /events, Previous is
+// missing for a creation
+type eventResponse struct {
+	Kind     note.EventKind `json:"Kind"`
+	Note     noteResponse   `json:"Note"`
+	Previous *noteResponse  `json:"Previous,omitempty"`
+	Actor    string         `json:"Actor"`
+	At       time.Time      `json:"At"`
+}
+
+type listResponse struct {
+	Notes []summaryResponse `json:"Notes"`
+}
+
+type searchResponse struct {
+	Notes    []noteResponse    `json:"Notes"`
+	Snippets []snippetResponse `json:"Snippets"`
+}
+
+func noteResponseOf(n note.Note) noteResponse {
+	return noteResponse{
+		Id:       n.Id,
+		Name:     n.Name,
+		Content:  n.Content,
+		Notebook: n.Notebook,
+		Owner:    n.Owner,
+		Metadata: maps.Clone(n.Metadata),
+		Length:   lengthResponseOf(n.Length),
+	}
+}
+
+func eventResponseOf(e note.Event) eventResponse {
+	event := eventResponse{Kind: e.Kind, Note: noteResponseOf(e.Note), Actor: e.Actor, At: e.At}
+	if e.Previous.Id != 0 {
+		previous := noteResponseOf(e.Previous)
+		event.Previous = &previous
+	}
+	return event
+}
+
+func lengthResponseOf(l note.Length) lengthResponse {
+	return lengthResponse{Words: l.Words, Characters: l.Characters, ReadingMinutes: l.ReadingMinutes}
+}
+
+// notesResponseOf keeps a missing list missing
+func notesResponseOf(notes note.List) []noteResponse {
+	if notes == nil {
+		return nil
+	}
+	out := make([]noteResponse, 0, len(notes))
+	for _, n := range notes {
+		out = append(out, noteResponseOf(n))
+	}
+	return out
+}
+
+func summariesResponseOf(summaries []note.Summary) []summaryResponse {
+	if summaries == nil {
+		return nil
+	}
+	out := make([]summaryResponse, 0, len(summaries))
+	for _, s := range summaries {
+		out = append(out, summaryResponse{
+			Id:       s.Id,
+			Name:     s.Name,
+			Notebook: s.Notebook,
+			Owner:    s.Owner,
+			Preview:  s.Preview,
+			Size:     s.Size,
+			Length:   lengthResponseOf(s.Length),
+			Color:    s.Color,
+		})
+	}
+	return out
+}
+
+func snippetsResponseOf(snippets []query.Snippet) []snippetResponse {
+	if snippets == nil {
+		return nil
+	}
+	out := make([]snippetResponse, 0, len(snippets))
+	for _, s := range snippets {
+		out = append(out, snippetResponse{NoteId: s.NoteId, Field: s.Field, Text: s.Text, Matches: s.Matches})
+	}
+	return out
+}
+
+// responseOf maps the results holding notes to their DTOs, the other
+// results are sent as they are
+func responseOf(result any) any {
+	switch r := result.(type) {
+	case usecase.ReadResult:
+		return readResponse{Note: noteResponseOf(r.Note)}
+	case usecase.CreateResult:
+		return writeResponse{Note: noteResponseOf(r.Note), DryRun: r.DryRun}
+	case usecase.QuickResult:
+		return writeResponse{Note: noteResponseOf(r.Note), DryRun: r.DryRun}
+	case usecase.UpdateResult:
+		return writeResponse{Note: noteResponseOf(r.Note), DryRun: r.DryRun}
+	case usecase.RenameResult:
+		return writeResponse{Note: noteResponseOf(r.Note), DryRun: r.DryRun}
+	case usecase.MoveResult:
+		return writeResponse{Note: noteResponseOf(r.Note), DryRun: r.DryRun}
+	case usecase.DeleteResult:
+		return writeResponse{Note: noteResponseOf(r.Note), DryRun: r.DryRun}
+	case usecase.ReadAllResult:
+		return notesResponse{Notes: notesResponseOf(r.Notes)}
+	case usecase.ListResult:
+		return listResponse{Notes: summariesResponseOf(r.Notes)}
+	case usecase.SearchResult:
+		return searchResponse{Notes: notesResponseOf(r.Notes), Snippets: snippetsResponseOf(r.Snippets)}
+	case usecase.DashboardResult:
+		dashboard := dashboardResponse{Pinned: notesResponseOf(r.Pinned), Due: []reminderResponse{}, Recent: []recentResponse{}}
+		for _, d := range r.Due {
+			dashboard.Due = append(dashboard.Due, reminderResponse{Note: noteResponseOf(d.Note), Due: d.Due})
+		}
+		for _, n := range r.Recent {
+			dashboard.Recent = append(dashboard.Recent, recentResponse{Note: noteResponseOf(n.Note), At: n.At})
+		}
+		return dashboard
+	}
+	return result
+}
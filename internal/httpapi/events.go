@@ -0,0 +1,95 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"notes/internal/note"
+)
+
+// eventsBuffer is the number of events kept for a client reading them
+// slower than they come, the next ones are dropped for that client
+const eventsBuffer = 64
+
+// eventsPing is how often a comment is sent to the clients waiting for
+// events, so the proxies keep their connections open
+const eventsPing = 30 * time.Second
+
+// Events sends the note events to the clients of GET /events as
+// server-sent events
+// With accounts a client only receives the events of the notes of its
+// user, the events of the notes shared with them are not sent.
+type Events struct {
+	mutex   *sync.Mutex
+	clients map[chan note.Event]bool
+}
+
+// NewEvents subscribes to the note events
+func NewEvents() *Events {
+	return &Events{mutex: &sync.Mutex{}, clients: map[chan note.Event]bool{}}
+}
+
+// Notify hands an event to every client without waiting for them
+func (e *Events) Notify(event note.Event) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for c := range e.clients {
+		select {
+		case c <- event:
+		default:
+		}
+	}
+}
+
+func (e *Events) join() chan note.Event {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	c := make(chan note.Event, eventsBuffer)
+	e.clients[c] = true
+	return c
+}
+
+func (e *Events) leave(c chan note.Event) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	delete(e.clients, c)
+}
+
+// handleEvents streams the note events until the client goes away, the
+// kind of an event is the name of the server-sent event and its data the
+// json of the event
+func (app Application) handleEvents(w http.ResponseWriter, r *http.Request) {
+	c := messageContext(r)
+	events := app.events.join()
+	defer app.events.leave(events)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	controller := http.NewResponseController(w)
+	controller.Flush()
+	ping := time.NewTicker(eventsPing)
+	defer ping.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ping.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case e := <-events:
+			if c.User != 0 && e.Note.Owner != c.User && e.Previous.Owner != c.User {
+				continue
+			}
+			data, err := json.Marshal(eventResponseOf(e))
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Kind, data)
+		}
+		if controller.Flush() != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"strings"
+
+	"notes/internal/present"
+	"notes/internal/usecase"
+)
+
+// htmlPresenter writes the results as an html page for browsers, the
+// matches of a search are marked in its snippets, other results are
+// shown as json
+type htmlPresenter struct{}
+
+func (p htmlPresenter) Present(o any, w io.Writer) {
+	fmt.Fprint(w, "<!doctype html>\n<meta charset=\"utf-8\">\n<title>Notes</title>\n")
+	switch o := o.(type) {
+	case searchResponse:
+		presentSearch(o, w)
+	case usecase.DiffResult:
+		presentDiff(o, w)
+	case listResponse:
+		presentList(o, w)
+	case usecase.NotebooksResult:
+		presentNotebooks(o, w)
+	default:
+		data, _ := json.MarshalIndent(o, "", "  ")
+		fmt.Fprintf(w, "<pre>%s</pre>\n", html.EscapeString(string(data)))
+	}
+}
+
+// presentDiff shows the versions side by side, the removed lines
+// struck through on the left and the added ones underlined on the right
+func presentDiff(result usecase.DiffResult, w io.Writer) {
+	fmt.Fprintf(w, "<h1><a href=\"/notes/%d\">%s</a></h1>\n", result.To.Id, html.EscapeString(result.To.Name))
+	if result.From.Name != result.To.Name {
+		fmt.Fprintf(w, "<p>Renamed <del>%s</del> to <ins>%s</ins></p>\n", html.EscapeString(result.From.Name), html.EscapeString(result.To.Name))
+	}
+	fmt.Fprint(w, "<table class=\"diff\">\n")
+	row := func(from string, to string) {
+		fmt.Fprintf(w, "<tr><td><pre>%s</pre></td><td><pre>%s</pre></td></tr>\n", from, to)
+	}
+	// the lines removed and added in a row are paired
+	removed, added := []string{}, []string{}
+	flush := func() {
+		for k := range max(len(removed), len(added)) {
+			from, to := "", ""
+			if k < len(removed) {
+				from = "<del>" + html.EscapeString(removed[k]) + "</del>"
+			}
+			if k < len(added) {
+				to = "<ins>" + html.EscapeString(added[k]) + "</ins>"
+			}
+			row(from, to)
+		}
+		removed, added = removed[:0], added[:0]
+	}
+	for _, l := range result.Lines {
+		switch l.Kind {
+		case "-":
+			removed = append(removed, l.Text)
+		case "+":
+			added = append(added, l.Text)
+		default:
+			flush()
+			row(html.EscapeString(l.Text), html.EscapeString(l.Text))
+		}
+	}
+	flush()
+	fmt.Fprint(w, "</table>\n")
+}
+
+// diffPresenter writes the diffs in the unified format, as the diff
+// tools read them, and the other results as plain text
+type diffPresenter struct{}
+
+func (p diffPresenter) Present(o any, w io.Writer) {
+	if d, ok := o.(usecase.DiffResult); ok {
+		fmt.Fprint(w, d.Unified)
+		return
+	}
+	present.Plain.Presenter.Present(o, w)
+}
+
+func presentSearch(result searchResponse, w io.Writer) {
+	if len(result.Notes) == 0 {
+		fmt.Fprint(w, "<p>No notes</p>\n")
+		return
+	}
+	fmt.Fprint(w, "<ol>\n")
+	for _, n := range result.Notes {
+		fmt.Fprintf(w, "<li>%s<a href=\"/notes/%d\">%s</a>\n", label(n.Metadata[usecase.ColorKey]), n.Id, html.EscapeString(n.Name))
+		for _, s := range result.Snippets {
+			if s.NoteId != n.Id {
+				continue
+			}
+			text, last := strings.Builder{}, 0
+			for _, m := range s.Matches {
+				text.WriteString(html.EscapeString(s.Text[last:m[0]]))
+				text.WriteString("<mark>" + html.EscapeString(s.Text[m[0]:m[1]]) + "</mark>")
+				last = m[1]
+			}
+			text.WriteString(html.EscapeString(s.Text[last:]))
+			fmt.Fprintf(w, "<p class=\"%s\">%s</p>\n", s.Field, text.String())
+		}
+		fmt.Fprint(w, "</li>\n")
+	}
+	fmt.Fprint(w, "</ol>\n")
+}
+
+// label is the dot of a color, nothing without a color
+// The colors of the palette are named as in CSS.
+func label(color string) string {
+	if color == "" {
+		return ""
+	}
+	return fmt.Sprintf("<span style=\"color: %s\" title=\"%s\">●</span> ", color, color)
+}
+
+// presentList lists the notes with their color and length
+func presentList(result listResponse, w io.Writer) {
+	if len(result.Notes) == 0 {
+		fmt.Fprint(w, "<p>No notes</p>\n")
+		return
+	}
+	fmt.Fprint(w, "<ul>\n")
+	for _, n := range result.Notes {
+		fmt.Fprintf(w, "<li>%s<a href=\"/notes/%d\">%s</a> <small>%d words, %d min</small></li>\n",
+			label(n.Color), n.Id, html.EscapeString(n.Name), n.Length.Words, n.Length.ReadingMinutes)
+	}
+	fmt.Fprint(w, "</ul>\n")
+}
+
+// presentNotebooks lists the notebooks with their color, the smart ones
+// with their query
+func presentNotebooks(result usecase.NotebooksResult, w io.Writer) {
+	if len(result.Notebooks) == 0 {
+		fmt.Fprint(w, "<p>No notebooks</p>\n")
+		return
+	}
+	fmt.Fprint(w, "<ul>\n")
+	for _, n := range result.Notebooks {
+		if n.Query != "" {
+			fmt.Fprintf(w, "<li>%s (%d) <code>%s</code></li>\n", html.EscapeString(n.Name), n.Count, html.EscapeString(n.Query))
+			continue
+		}
+		fmt.Fprintf(w, "<li>%s<a href=\"/notebooks/%s/html\">%s</a> (%d)</li>\n",
+			label(n.Color), url.PathEscape(n.Name), html.EscapeString(n.Name), n.Count)
+	}
+	fmt.Fprint(w, "</ul>\n")
+}
@@ -0,0 +1,538 @@
+// Package httpapi serves the usecases over HTTP as a json API.
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"notes/internal/collab"
+	"notes/internal/crypt"
+	"notes/internal/exchange"
+	"notes/internal/note"
+	"notes/internal/present"
+	"notes/internal/usecase"
+	"notes/internal/user"
+	"notes/internal/websocket"
+)
+
+// Presenter writes the results of the commands to the responses
+type Presenter interface {
+	Present(o any, w io.Writer)
+}
+
+// Config of an HTTP application
+type Config struct {
+	// Metrics are served on /metrics when not nil, they may come from
+	// the decorator of the usecases
+	Metrics *usecase.Metrics
+	// Presenter answers every request with the results of the usecases
+	// when set, the format of a response is negotiated by the Accept
+	// header of its request otherwise, json by default or html for the
+	// browsers
+	Presenter Presenter
+	// Formats are negotiated beside json, yaml, xml, html, plain, table
+	// and diff, a format replaces the one of the same name
+	Formats []present.Format
+	// Listener defaults to 127.0.0.1:80
+	Listener net.Listener
+	// Handlers are served beside the API, by pattern
+	Handlers map[string]http.Handler
+	// Accounts requires every request to the API, but those to /register
+	// and /login, to be authenticated by a bearer token from /login or,
+	// for the Nextcloud clients, by the name and password of a user
+	// Browsers log in on the /login page and are known by their session.
+	Accounts bool
+	// Sessions keeps the sessions of the browsers with accounts, in
+	// memory by default
+	Sessions Sessions
+	// Collab lets the clients edit notes together over a websocket on
+	// /notes/{id}/collab when not nil
+	Collab *collab.Hub
+	// Debug is the address of a second server for the profiles of
+	// net/http/pprof on /debug/pprof/, there is none when empty
+	// Anyone reaching it may profile the server, keep it on a private
+	// address such as 127.0.0.1:6060.
+	Debug string
+	// Cache keeps the responses of the listings and searches when not
+	// nil, it must be subscribed to the note events
+	Cache *Cache
+	// Events streams the note events on /events when not nil, it must be
+	// subscribed to the note events
+	Events *Events
+	// Admin serves the runtime information of the server when it has a
+	// token
+	Admin Admin
+	// Routes run the usecases by pattern, see package command
+	Routes map[string]Route
+}
+
+// Application serves the notes on /notes/, their changes on /audit, the
+// notebooks on /notebooks, the smart notebooks on /searches, the
+// accounts on /register and /login, their API tokens on /tokens, the
+// sessions of the browsers on /login and /logout, the notes edited
+// together on /notes/{id}/collab, their changes as they happen on /events
+// and the command metrics on /metrics
+// The notes are also served by the Nextcloud Notes API.
+type Application struct {
+	routes  map[string]http.HandlerFunc
+	usecase usecase.Usecase
+	// presenter is nil unless configured, the formats are negotiated
+	presenter Presenter
+	formats   present.Registry
+	metrics   *usecase.Metrics
+	handlers  map[string]http.Handler
+	listener  net.Listener
+	server    *http.Server
+	accounts  bool
+	sessions  Sessions
+	logins    *logins
+	collab    *collab.Hub
+	debug     *http.Server
+	cache     *Cache
+	events    *Events
+	admin     Admin
+}
+
+// htmlFormat is for the browsers and diffFormat for the diff tools,
+// beside the default formats
+var (
+	htmlFormat = present.Format{Name: "html", MediaType: "text/html; charset=utf-8", Presenter: htmlPresenter{}}
+	diffFormat = present.Format{Name: "diff", MediaType: "text/x-diff; charset=utf-8", Presenter: diffPresenter{}}
+)
+
+// New builds the HTTP application on top of the usecases
+func New(u usecase.Usecase, config Config) Application {
+	if config.Accounts && config.Sessions == nil {
+		config.Sessions, _ = user.NewSessions("", 24*time.Hour)
+	}
+	logins := newLogins()
+	u.Login = logins.limited(u.Login)
+	app := Application{
+		usecase:   u,
+		presenter: config.Presenter,
+		formats:   present.Default().With(htmlFormat, diffFormat).With(config.Formats...),
+		metrics:   config.Metrics,
+		handlers:  config.Handlers,
+		listener:  config.Listener,
+		server:    &http.Server{Addr: "127.0.0.1:80"},
+		accounts:  config.Accounts,
+		sessions:  config.Sessions,
+		logins:    logins,
+		collab:    config.Collab,
+		cache:     config.Cache,
+		events:    config.Events,
+		admin:     config.Admin,
+	}
+	if config.Debug != "" {
+		app.debug = newDebugServer(config.Debug)
+	}
+	// the routes doing more than running a usecase, the others come from
+	// the registry of package command
+	app.routes = map[string]http.HandlerFunc{
+		"GET /notes/{$}":             app.handleList,
+		"GET /notes/{id}":            app.orExists(served(app, ReadParser{}, u.Read)),
+		"POST /login":                app.orSession(served(app, LoginParser{}, u.Login)),
+		"GET /export":                app.handleExport,
+		"GET /notes/{id}/pdf":        app.handleNoteDocument(pdfDocument),
+		"GET /notes/{id}/html":       app.handleNoteDocument(htmlDocument),
+		"GET /notebooks/{name}/pdf":  app.handleNotebookDocument(pdfDocument),
+		"GET /notebooks/{name}/html": app.handleNotebookDocument(htmlDocument),
+	}
+	for pattern, route := range config.Routes {
+		app.routes[pattern] = route(app)
+	}
+	for pattern, handler := range app.nextcloudRoutes() {
+		app.routes[pattern] = handler
+	}
+	if app.collab != nil {
+		app.routes["GET /notes/{id}/collab"] = app.handleCollab
+	}
+	if app.events != nil {
+		app.routes["GET /events"] = app.handleEvents
+	}
+	if app.sessions != nil {
+		for pattern, handler := range app.sessionRoutes() {
+			app.routes[pattern] = handler
+		}
+	}
+	return app
+}
+
+// Route registry
+// Route handles the requests of a route of the application
+type Route func(app Application) http.HandlerFunc
+
+// Served runs a usecase under a route, see served
+func Served[Message, Result any](p Parser[Message], c usecase.Command[Message, Result]) Route {
+	return func(app Application) http.HandlerFunc {
+		return served(app, p, c)
+	}
+}
+
+// served runs a usecase, the request is parsed into the message and the
+// result presented as json
+func served[Message, Result any](app Application, p Parser[Message], c usecase.Command[Message, Result]) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		message, err := p.fromHttp(r)
+		if err != nil {
+			app.fail(w, err)
+			return
+		}
+		usecase.SetContext(&message, messageContext(r))
+		result, err := c.Execute(message)
+		if err != nil {
+			app.fail(w, err)
+			return
+		}
+		format := app.formatOf(r)
+		if format.MediaType != "" {
+			w.Header().Set("Content-Type", format.MediaType)
+		}
+		// a configured presenter knows the results themselves
+		var response any = result
+		if app.presenter == nil {
+			response = responseOf(result)
+		}
+		format.Presenter.Present(response, w)
+	}
+}
+
+// formatOf chooses the format of the response to a request, the
+// configured presenter has none
+func (app Application) formatOf(r *http.Request) present.Format {
+	if app.presenter != nil {
+		return present.Format{Presenter: app.presenter}
+	}
+	return app.formats.Negotiate(r.Header.Get("Accept"))
+}
+
+// orExists answers HEAD requests, which GET routes also match, with
+// whether the note exists instead of reading it
+func (app Application) orExists(get http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			app.handleExists(w, r)
+			return
+		}
+		get(w, r)
+	}
+}
+
+// handleCollab edits a note with the other clients editing it over a
+// websocket, see package collab
+// The note is read first so a missing one is answered 404 rather than
+// upgraded.
+func (app Application) handleCollab(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		app.fail(w, err)
+		return
+	}
+	c := messageContext(r)
+	read, err := app.usecase.Read.Execute(usecase.ReadMessage{Context: c, Id: id})
+	if err != nil {
+		app.fail(w, err)
+		return
+	}
+	if crypt.IsSealed(read.Note.Content) {
+		app.fail(w, collab.ErrSealed)
+		return
+	}
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	app.collab.Join(c, id, conn)
+}
+
+// exportFlush is how many notes are exported between two flushes of the
+// response
+const exportFlush = 100
+
+// handleList lists the notes with a preview of their content, the
+// whole content comes with ?include=content, ?sort=length or
+// ?sort=-length orders them by length and ?color=red lists the notes of a
+// color
+func (app Application) handleList(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("include") == "content" {
+		served(app, ReadAllParser{}, app.usecase.ReadAll)(w, r)
+		return
+	}
+	served(app, ListParser{}, app.usecase.List)(w, r)
+}
+
+// handleExport streams the notes of the user as json lines, one note a
+// line, as they are read from the storage, only those matching the search
+// query of ?q= when given
+func (app Application) handleExport(w http.ResponseWriter, r *http.Request) {
+	result, err := app.usecase.Export.Execute(usecase.ExportMessage{Context: messageContext(r), Query: r.URL.Query().Get("q")})
+	if err != nil {
+		app.fail(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(w)
+	controller := http.NewResponseController(w)
+	count := 0
+	for n := range result.Notes {
+		// the client went away
+		if encoder.Encode(noteResponseOf(n)) != nil {
+			return
+		}
+		count++
+		if count%exportFlush == 0 {
+			controller.Flush()
+		}
+	}
+}
+
+// document is a format the notes are rendered to
+type document struct {
+	write       func(w io.Writer, title string, notes []note.Note) error
+	contentType string
+	extension   string
+}
+
+var (
+	pdfDocument  = document{exchange.WritePDF, "application/pdf", ".pdf"}
+	htmlDocument = document{exchange.WriteHTML, "text/html; charset=utf-8", ".html"}
+)
+
+// handleNoteDocument renders a note, with the notes it embeds
+func (app Application) handleNoteDocument(d document) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		message, err := ReadParser{}.fromHttp(r)
+		if err != nil {
+			app.fail(w, err)
+			return
+		}
+		message.Context = messageContext(r)
+		message.Transclude = true
+		result, err := app.usecase.Read.Execute(message)
+		if err != nil {
+			app.fail(w, err)
+			return
+		}
+		app.writeDocument(w, d, result.Note.Name, []note.Note{result.Note})
+	}
+}
+
+// handleNotebookDocument renders the notes of a notebook, with the notes
+// they embed, the PDF starts with their table of contents
+func (app Application) handleNotebookDocument(d document) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		notebook := r.PathValue("name")
+		result, err := app.usecase.Export.Execute(usecase.ExportMessage{Context: messageContext(r), Notebook: notebook, Transclude: true})
+		if err != nil {
+			app.fail(w, err)
+			return
+		}
+		notes := slices.Collect(result.Notes)
+		if len(notes) == 0 {
+			app.fail(w, fmt.Errorf("notebook %s %w", notebook, note.ErrNotFound))
+			return
+		}
+		app.writeDocument(w, d, notebook, notes)
+	}
+}
+
+// writeDocument answers with the document inline, named after its title
+func (app Application) writeDocument(w http.ResponseWriter, d document, title string, notes []note.Note) {
+	rendered := bytes.Buffer{}
+	err := d.write(&rendered, title, notes)
+	if err != nil {
+		app.fail(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", d.contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": exchange.Slug(title) + d.extension}))
+	w.Write(rendered.Bytes())
+}
+
+// handleExists answers with the status only, 404 when the note doesn't
+// exist
+func (app Application) handleExists(w http.ResponseWriter, r *http.Request) {
+	message, err := ExistsParser{}.fromHttp(r)
+	if err != nil {
+		app.fail(w, err)
+		return
+	}
+	message.Context = messageContext(r)
+	result, err := app.usecase.Exists.Execute(message)
+	if err != nil {
+		app.fail(w, err)
+		return
+	}
+	if !result.Exists {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// messageContext reads the usecase context of a request,
+// ?dryRun=true makes the request a dry run and the changes are
+// attributed to the authenticated user or else to the remote address
+func messageContext(r *http.Request) usecase.Context {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	if id, ok := r.Context().Value(identityKey{}).(identity); ok {
+		return usecase.Context{DryRun: dryRun, Actor: id.user.Name, User: id.user.Id, ReadOnly: id.readOnly}
+	}
+	actor, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		actor = r.RemoteAddr
+	}
+	return usecase.Context{DryRun: dryRun, Actor: actor}
+}
+
+// identityKey is where the identity of the sender is kept in the
+// context of a request
+type identityKey struct{}
+
+// identity of the sender of a request, a read-only API token only lets
+// it read
+type identity struct {
+	user     user.User
+	readOnly bool
+}
+
+// publicRoutes may be requested without authentication
+var publicRoutes = []string{"POST /register", "POST /login", "GET /login", "GET /logout"}
+
+// authenticated finds who sends a request before handling it, with
+// accounts a request without credentials is refused unless its route is
+// public
+func (app Application) authenticated(pattern string, next http.HandlerFunc) http.HandlerFunc {
+	if !app.accounts || slices.Contains(publicRoutes, pattern) {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := app.authenticate(r)
+		// browsers go to the login page
+		if errors.Is(err, note.ErrUnauthorized) && r.Method == http.MethodGet && app.sessions != nil && strings.Contains(r.Header.Get("Accept"), "text/html") {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer, Basic realm="notes"`)
+			app.fail(w, err)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+	}
+}
+
+// authenticate checks the bearer token of a request, a token from
+// /login or an API token, the name and password of basic
+// authentication, or the session of a browser
+// The name and password are only hashed again after basicTtl, the
+// clients should still prefer the tokens.
+func (app Application) authenticate(r *http.Request) (identity, error) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		result, err := app.usecase.Authenticate.Execute(usecase.AuthenticateMessage{Context: messageContext(r), Token: user.Secret(token)})
+		return identity{user: result.User, readOnly: result.Scope == user.ReadOnly}, err
+	}
+	if name, password, ok := r.BasicAuth(); ok {
+		found, err := app.logins.basic(app.usecase.Login, usecase.LoginMessage{Context: messageContext(r), Name: name, Password: user.Secret(password)})
+		return identity{user: found}, err
+	}
+	if _, err := r.Cookie(sessionCookie); err == nil && app.sessions != nil {
+		session := app.session(r)
+		if session.User == 0 {
+			return identity{}, fmt.Errorf("%w: the session expired, log in again", note.ErrUnauthorized)
+		}
+		return identity{user: user.User{Id: session.User, Name: session.Name}}, checkCsrf(r, session)
+	}
+	return identity{}, fmt.Errorf("%w: log in first, POST /login gives a token", note.ErrUnauthorized)
+}
+
+// busyRetry is the number of seconds the clients wait before sending a
+// change the server was too busy for again
+const busyRetry = 1
+
+// fail maps the domain errors to HTTP status codes
+func (app Application) fail(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, note.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, note.ErrValidation):
+		status = http.StatusBadRequest
+	case errors.Is(err, note.ErrConflict):
+		status = http.StatusConflict
+	case errors.Is(err, note.ErrForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, note.ErrTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, errTooManyLogins):
+		status = http.StatusTooManyRequests
+		w.Header().Set("Retry-After", strconv.Itoa(int(loginWindow.Seconds())))
+	case errors.Is(err, note.ErrUnauthorized):
+		status = http.StatusUnauthorized
+	case errors.Is(err, note.ErrBusy):
+		status = http.StatusServiceUnavailable
+		w.Header().Set("Retry-After", strconv.Itoa(busyRetry))
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// Handler routes the requests to the usecases
+func (app Application) Handler() http.Handler {
+	mux := http.NewServeMux()
+	for pattern, handler := range app.routes {
+		mux.HandleFunc(pattern, app.authenticated(pattern, app.cached(pattern, handler)))
+	}
+	for pattern, handler := range app.handlers {
+		mux.Handle(pattern, handler)
+	}
+	for pattern, handler := range app.adminRoutes() {
+		mux.HandleFunc(pattern, handler)
+	}
+	if app.metrics != nil {
+		mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+			app.metrics.Write(w)
+		})
+	}
+	return mux
+}
+
+// Run serves until Stop is called, failing to listen is reported on
+// stderr
+func (app Application) Run() {
+	app.server.Handler = app.Handler()
+	if app.debug != nil {
+		go app.runDebug()
+	}
+	var err error
+	if app.listener == nil {
+		err = app.server.ListenAndServe()
+	} else {
+		err = app.server.Serve(app.listener)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintln(os.Stderr, "notes:", err)
+	}
+}
+
+// Stop lets the requests being served finish, for a few seconds at most
+func (app Application) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if app.debug != nil {
+		app.debug.Shutdown(ctx)
+	}
+	app.server.Shutdown(ctx)
+}
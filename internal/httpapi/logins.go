@@ -0,0 +1,132 @@
+package httpapi
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+
+	"notes/internal/note"
+	"notes/internal/usecase"
+	"notes/internal/user"
+)
+
+const (
+	// basicTtl is how long the name and password of basic authentication
+	// are trusted once checked, hashing the password again on every
+	// request would keep the server busy
+	// A password changed or an account removed is still accepted
+	// meanwhile, the tokens of /login don't have this delay.
+	basicTtl = time.Minute
+	// loginFailures are the failed logins a client may make in
+	// loginWindow, its logins are refused for the rest of the window
+	loginFailures = 5
+	loginWindow   = time.Minute
+	// loginsSize is the number of clients and credentials kept, the
+	// expired ones are dropped when it is reached
+	loginsSize = 1024
+)
+
+// errTooManyLogins refuses a client after loginFailures failed logins
+var errTooManyLogins = fmt.Errorf("%w: too many failed logins, retry in a minute", note.ErrUnauthorized)
+
+// logins keeps the credentials of basic authentication recently checked
+// and the failed logins of every client
+type logins struct {
+	mutex    sync.Mutex
+	checked  map[[sha256.Size]byte]checkedLogin
+	failures map[string]failedLogins
+}
+
+type checkedLogin struct {
+	user  user.User
+	until time.Time
+}
+
+type failedLogins struct {
+	count int
+	since time.Time
+}
+
+func newLogins() *logins {
+	return &logins{checked: map[[sha256.Size]byte]checkedLogin{}, failures: map[string]failedLogins{}}
+}
+
+// limited refuses the logins of the clients which failed too often, the
+// client is the actor of the message, its address before it logs in
+func (l *logins) limited(next usecase.Command[usecase.LoginMessage, usecase.LoginResult]) usecase.Command[usecase.LoginMessage, usecase.LoginResult] {
+	return limitedLogin{l, next}
+}
+
+type limitedLogin struct {
+	logins *logins
+	next   usecase.Command[usecase.LoginMessage, usecase.LoginResult]
+}
+
+func (c limitedLogin) Execute(i usecase.LoginMessage) (usecase.LoginResult, error) {
+	if c.logins.refused(i.Actor) {
+		return usecase.LoginResult{}, errTooManyLogins
+	}
+	result, err := c.next.Execute(i)
+	c.logins.record(i.Actor, err == nil)
+	return result, err
+}
+
+func (l *logins) refused(client string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	f := l.failures[client]
+	return f.count >= loginFailures && time.Since(f.since) < loginWindow
+}
+
+func (l *logins) record(client string, succeeded bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if succeeded {
+		delete(l.failures, client)
+		return
+	}
+	f := l.failures[client]
+	if time.Since(f.since) >= loginWindow {
+		f = failedLogins{since: time.Now()}
+	}
+	f.count++
+	if len(l.failures) >= loginsSize {
+		for c, f := range l.failures {
+			if time.Since(f.since) >= loginWindow {
+				delete(l.failures, c)
+			}
+		}
+	}
+	l.failures[client] = f
+}
+
+// basic logs in with the name and password of basic authentication,
+// unless they were checked less than basicTtl ago
+func (l *logins) basic(login usecase.Command[usecase.LoginMessage, usecase.LoginResult], message usecase.LoginMessage) (user.User, error) {
+	key := sha256.Sum256([]byte(message.Name + "\x00" + string(message.Password)))
+	l.mutex.Lock()
+	checked, ok := l.checked[key]
+	l.mutex.Unlock()
+	if ok && time.Now().Before(checked.until) {
+		return checked.user, nil
+	}
+	result, err := login.Execute(message)
+	if err != nil {
+		return user.User{}, err
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if len(l.checked) >= loginsSize {
+		for k, c := range l.checked {
+			if time.Now().After(c.until) {
+				delete(l.checked, k)
+			}
+		}
+	}
+	if len(l.checked) >= loginsSize {
+		clear(l.checked)
+	}
+	l.checked[key] = checkedLogin{result.User, time.Now().Add(basicTtl)}
+	return result.User, nil
+}
@@ -0,0 +1,272 @@
+package httpapi
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"notes/internal/note"
+	"notes/internal/usecase"
+)
+
+// Nextcloud Notes API v1, so the clients of Nextcloud Notes can sync
+// with the notes, see
+// https://github.com/nextcloud/notes/blob/main/docs/api/v1.md
+// The title is the name of a note and the category its notebook. Notes
+// are never favorites nor read only, and the time of their last change
+// comes from the audit log.
+const nextcloudNotes = "/index.php/apps/notes/api/v1/notes"
+
+type nextcloudNote struct {
+	Id       note.Id `json:"id"`
+	Etag     string  `json:"etag,omitempty"`
+	Readonly *bool   `json:"readonly,omitempty"`
+	Content  *string `json:"content,omitempty"`
+	Title    *string `json:"title,omitempty"`
+	Category *string `json:"category,omitempty"`
+	Favorite *bool   `json:"favorite,omitempty"`
+	Modified *int64  `json:"modified,omitempty"`
+}
+
+// nextcloudRoutes are added to the routes of the application
+func (app Application) nextcloudRoutes() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"GET " + nextcloudNotes:              app.handleNextcloudList,
+		"GET " + nextcloudNotes + "/{id}":    app.handleNextcloudRead,
+		"POST " + nextcloudNotes:             app.handleNextcloudCreate,
+		"PUT " + nextcloudNotes + "/{id}":    app.handleNextcloudUpdate,
+		"DELETE " + nextcloudNotes + "/{id}": app.handleNextcloudDelete,
+		"GET /index.php/apps/notes/api/v1/settings": func(w http.ResponseWriter, r *http.Request) {
+			writeJson(w, map[string]string{"notesPath": "Notes", "fileSuffix": ".md"})
+		},
+		"GET /ocs/v2.php/cloud/capabilities": func(w http.ResponseWriter, r *http.Request) {
+			writeJson(w, map[string]any{"ocs": map[string]any{
+				"meta": map[string]any{"status": "ok", "statuscode": 200},
+				"data": map[string]any{"capabilities": map[string]any{
+					"notes": map[string]any{"api_version": []string{"1.3"}, "version": "4.8.0"},
+				}},
+			}})
+		},
+	}
+}
+
+func writeJson(w http.ResponseWriter, o any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(o)
+}
+
+// etag changes whenever a field of the note does
+func etag(n note.Note) string {
+	sum := md5.Sum([]byte(n.Name + "\x00" + n.Content + "\x00" + n.Notebook))
+	return hex.EncodeToString(sum[:])
+}
+
+// modified tells when each note was changed last, according to the
+// audit log
+func (app Application) modified(ctx usecase.Context) map[note.Id]int64 {
+	times := map[note.Id]int64{}
+	result, err := app.usecase.Audit.Execute(usecase.AuditMessage{Context: ctx})
+	if err != nil {
+		return times
+	}
+	for _, e := range result.Entries {
+		times[e.NoteId] = e.At.Unix()
+	}
+	return times
+}
+
+// nextcloudOf converts a note, the excluded fields are left out
+func nextcloudOf(n note.Note, modified int64, exclude []string) nextcloudNote {
+	no := false
+	out := nextcloudNote{Id: n.Id, Etag: etag(n), Readonly: &no, Content: &n.Content, Title: &n.Name, Category: &n.Notebook, Favorite: &no, Modified: &modified}
+	for _, field := range exclude {
+		switch field {
+		case "etag":
+			out.Etag = ""
+		case "readonly":
+			out.Readonly = nil
+		case "content":
+			out.Content = nil
+		case "title":
+			out.Title = nil
+		case "category":
+			out.Category = nil
+		case "favorite":
+			out.Favorite = nil
+		case "modified":
+			out.Modified = nil
+		}
+	}
+	return out
+}
+
+// handleNextcloudList takes ?category=, ?exclude=content,... and
+// ?pruneBefore=, notes not changed since are only given by id
+func (app Application) handleNextcloudList(w http.ResponseWriter, r *http.Request) {
+	ctx := messageContext(r)
+	result, err := app.usecase.ReadAll.Execute(usecase.ReadAllMessage{Context: ctx})
+	if err != nil {
+		app.fail(w, err)
+		return
+	}
+	query := r.URL.Query()
+	exclude := strings.Split(query.Get("exclude"), ",")
+	pruneBefore, _ := strconv.ParseInt(query.Get("pruneBefore"), 10, 64)
+	modified := app.modified(ctx)
+	notes := append(note.List{}, result.Notes...)
+	slices.SortFunc(notes, func(a, b note.Note) int { return a.Id - b.Id })
+	out := []nextcloudNote{}
+	for _, n := range notes {
+		if query.Has("category") && n.Notebook != query.Get("category") {
+			continue
+		}
+		if pruneBefore > 0 && modified[n.Id] < pruneBefore {
+			out = append(out, nextcloudNote{Id: n.Id})
+			continue
+		}
+		out = append(out, nextcloudOf(n, modified[n.Id], exclude))
+	}
+	writeJson(w, out)
+}
+
+func (app Application) handleNextcloudRead(w http.ResponseWriter, r *http.Request) {
+	ctx := messageContext(r)
+	id, err := idParam(r)
+	if err != nil {
+		app.fail(w, err)
+		return
+	}
+	result, err := app.usecase.Read.Execute(usecase.ReadMessage{Context: ctx, Id: id})
+	if err != nil {
+		app.fail(w, err)
+		return
+	}
+	exclude := strings.Split(r.URL.Query().Get("exclude"), ",")
+	writeJson(w, nextcloudOf(result.Note, app.modified(ctx)[id], exclude))
+}
+
+// nextcloudBody reads the fields sent by a client, as json or as a form
+func nextcloudBody(r *http.Request) (nextcloudNote, error) {
+	body := nextcloudNote{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			return body, fmt.Errorf("%w body: %v", note.ErrValidation, err)
+		}
+		return body, nil
+	}
+	r.ParseForm()
+	body.Title = formField(r, "title")
+	body.Content = formField(r, "content")
+	body.Category = formField(r, "category")
+	return body, nil
+}
+
+// formField is nil when the field is not sent
+func formField(r *http.Request, name string) *string {
+	if !r.Form.Has(name) {
+		return nil
+	}
+	value := r.Form.Get(name)
+	return &value
+}
+
+// title of a note created without one, the first line of its content
+// as Nextcloud does
+func title(content string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(content), "\n")
+	line = strings.TrimSpace(strings.TrimLeft(line, "# "))
+	if line == "" {
+		return "New note"
+	}
+	return line
+}
+
+func (app Application) handleNextcloudCreate(w http.ResponseWriter, r *http.Request) {
+	ctx := messageContext(r)
+	body, err := nextcloudBody(r)
+	if err != nil {
+		app.fail(w, err)
+		return
+	}
+	message := usecase.CreateMessage{Context: ctx}
+	if body.Content != nil {
+		message.Content = *body.Content
+	}
+	message.Name = title(message.Content)
+	if body.Title != nil && strings.TrimSpace(*body.Title) != "" {
+		message.Name = *body.Title
+	}
+	if body.Category != nil {
+		message.Notebook = *body.Category
+	}
+	result, err := app.usecase.Create.Execute(message)
+	if err != nil {
+		app.fail(w, err)
+		return
+	}
+	writeJson(w, nextcloudOf(result.Note, app.modified(ctx)[result.Note.Id], nil))
+}
+
+// handleNextcloudUpdate answers 412 when If-Match is not the etag of
+// the note, a category change is ignored since the notebook of a note
+// can't change
+func (app Application) handleNextcloudUpdate(w http.ResponseWriter, r *http.Request) {
+	ctx := messageContext(r)
+	id, err := idParam(r)
+	if err != nil {
+		app.fail(w, err)
+		return
+	}
+	body, err := nextcloudBody(r)
+	if err != nil {
+		app.fail(w, err)
+		return
+	}
+	read, err := app.usecase.Read.Execute(usecase.ReadMessage{Context: ctx, Id: id})
+	if err != nil {
+		app.fail(w, err)
+		return
+	}
+	match := strings.Trim(r.Header.Get("If-Match"), `"`)
+	if match != "" && match != "*" && match != etag(read.Note) {
+		http.Error(w, "note changed since", http.StatusPreconditionFailed)
+		return
+	}
+	n := read.Note
+	message := usecase.UpdateMessage{Context: ctx, Id: id}
+	if body.Title != nil {
+		message.Name = *body.Title
+	}
+	if body.Content != nil {
+		message.Content = *body.Content
+	}
+	if message.Name != "" || message.Content != "" {
+		result, err := app.usecase.Update.Execute(message)
+		if err != nil {
+			app.fail(w, err)
+			return
+		}
+		n = result.Note
+	}
+	writeJson(w, nextcloudOf(n, app.modified(ctx)[id], nil))
+}
+
+func (app Application) handleNextcloudDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		app.fail(w, err)
+		return
+	}
+	_, err = app.usecase.Delete.Execute(usecase.DeleteMessage{Context: messageContext(r), Id: id})
+	if err != nil {
+		app.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,610 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"notes/internal/note"
+	"notes/internal/share"
+	"notes/internal/usecase"
+	"notes/internal/user"
+)
+
+// Parser turns a request into a usecase message
+type Parser[Message any] interface {
+	fromHttp(*http.Request) (Message, error)
+}
+
+// idParam parses the note id of the path
+func idParam(r *http.Request) (note.Id, error) {
+	id := r.PathValue("id")
+	if id == "" {
+		return 0, fmt.Errorf("%w id: missing", note.ErrValidation)
+	}
+	number, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, fmt.Errorf("%w id: %q is not a number", note.ErrValidation, id)
+	}
+	return number, nil
+}
+
+type ReadAllParser struct{}
+
+// fromHttp reads the order of the notes from ?sort= and their color
+// from ?color=
+func (c ReadAllParser) fromHttp(r *http.Request) (usecase.ReadAllMessage, error) {
+	return usecase.ReadAllMessage{Sort: r.URL.Query().Get("sort"), Color: r.URL.Query().Get("color")}, nil
+}
+
+type ListParser struct{}
+
+func (c ListParser) fromHttp(r *http.Request) (usecase.ListMessage, error) {
+	return usecase.ListMessage{Sort: r.URL.Query().Get("sort"), Color: r.URL.Query().Get("color")}, nil
+}
+
+type TodayParser struct{}
+
+func (c TodayParser) fromHttp(r *http.Request) (usecase.TodayMessage, error) {
+	return usecase.TodayMessage{}, nil
+}
+
+type ReadParser struct{}
+
+// fromHttp reads the note id and the optional ?at= time of the version
+func (c ReadParser) fromHttp(r *http.Request) (usecase.ReadMessage, error) {
+	id, err := idParam(r)
+	if err != nil {
+		return usecase.ReadMessage{}, err
+	}
+	at, err := timeQuery(r, "at")
+	if err != nil {
+		return usecase.ReadMessage{}, err
+	}
+	return usecase.ReadMessage{
+		Id: id,
+		At: at,
+	}, nil
+}
+
+// timeQuery reads a time of the query, zero when it is not given
+func timeQuery(r *http.Request, name string) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return usecase.ParseTime(value)
+}
+
+type DiffParser struct{}
+
+// fromHttp reads the note id and the ?from= and ?to= times or numbers
+// of the versions, as ?from=v3&to=v5, to is now when not given
+func (c DiffParser) fromHttp(r *http.Request) (usecase.DiffMessage, error) {
+	id, err := idParam(r)
+	if err != nil {
+		return usecase.DiffMessage{}, err
+	}
+	message := usecase.DiffMessage{Id: id}
+	if from := r.URL.Query().Get("from"); from != "" {
+		message.From, message.FromVersion, err = usecase.ParseVersion(from)
+		if err != nil {
+			return usecase.DiffMessage{}, err
+		}
+	}
+	if to := r.URL.Query().Get("to"); to != "" {
+		message.To, message.ToVersion, err = usecase.ParseVersion(to)
+		if err != nil {
+			return usecase.DiffMessage{}, err
+		}
+	}
+	return message, nil
+}
+
+type CountParser struct{}
+
+// fromHttp reads the optional ?notebook= filter
+func (c CountParser) fromHttp(r *http.Request) (usecase.CountMessage, error) {
+	return usecase.CountMessage{
+		Notebook: r.URL.Query().Get("notebook"),
+	}, nil
+}
+
+type ExistsParser struct{}
+
+func (c ExistsParser) fromHttp(r *http.Request) (usecase.ExistsMessage, error) {
+	id, err := idParam(r)
+	if err != nil {
+		return usecase.ExistsMessage{}, err
+	}
+	return usecase.ExistsMessage{
+		Id: id,
+	}, nil
+}
+
+type CreateParser struct{}
+
+func (c CreateParser) fromHttp(r *http.Request) (usecase.CreateMessage, error) {
+	body, err := noteRequestOf(r)
+	if err != nil {
+		return usecase.CreateMessage{}, err
+	}
+	return usecase.CreateMessage{
+		Name:     body.Name,
+		Content:  body.Content,
+		Notebook: body.Notebook,
+		Expand:   true,
+	}, nil
+}
+
+type QuickParser struct{}
+
+func (c QuickParser) fromHttp(r *http.Request) (usecase.QuickMessage, error) {
+	body, err := noteRequestOf(r)
+	if err != nil {
+		return usecase.QuickMessage{}, err
+	}
+	return usecase.QuickMessage{
+		Content: body.Content,
+	}, nil
+}
+
+type UpdateParser struct{}
+
+func (c UpdateParser) fromHttp(r *http.Request) (usecase.UpdateMessage, error) {
+	id, err := idParam(r)
+	if err != nil {
+		return usecase.UpdateMessage{}, err
+	}
+	body, err := noteRequestOf(r)
+	if err != nil {
+		return usecase.UpdateMessage{}, err
+	}
+	return usecase.UpdateMessage{
+		Id:      id,
+		Name:    body.Name,
+		Content: body.Content,
+	}, nil
+}
+
+type RenameParser struct{}
+
+func (c RenameParser) fromHttp(r *http.Request) (usecase.RenameMessage, error) {
+	id, err := idParam(r)
+	if err != nil {
+		return usecase.RenameMessage{}, err
+	}
+	body, err := noteRequestOf(r)
+	if err != nil {
+		return usecase.RenameMessage{}, err
+	}
+	return usecase.RenameMessage{
+		Id:   id,
+		Name: body.Name,
+	}, nil
+}
+
+type MoveParser struct{}
+
+func (c MoveParser) fromHttp(r *http.Request) (usecase.MoveMessage, error) {
+	id, err := idParam(r)
+	if err != nil {
+		return usecase.MoveMessage{}, err
+	}
+	body, err := noteRequestOf(r)
+	if err != nil {
+		return usecase.MoveMessage{}, err
+	}
+	return usecase.MoveMessage{
+		Id:       id,
+		Notebook: body.Notebook,
+	}, nil
+}
+
+// PinParser pins a note, or unpins it
+type PinParser struct {
+	Unpin bool
+}
+
+func (c PinParser) fromHttp(r *http.Request) (usecase.PinMessage, error) {
+	id, err := idParam(r)
+	if err != nil {
+		return usecase.PinMessage{}, err
+	}
+	return usecase.PinMessage{
+		Id:    id,
+		Unpin: c.Unpin,
+	}, nil
+}
+
+type DashboardParser struct{}
+
+func (c DashboardParser) fromHttp(r *http.Request) (usecase.DashboardMessage, error) {
+	return usecase.DashboardMessage{}, nil
+}
+
+type LockParser struct{}
+
+func (c LockParser) fromHttp(r *http.Request) (usecase.LockMessage, error) {
+	id, err := idParam(r)
+	if err != nil {
+		return usecase.LockMessage{}, err
+	}
+	return usecase.LockMessage{
+		Id: id,
+	}, nil
+}
+
+type UnlockParser struct{}
+
+func (c UnlockParser) fromHttp(r *http.Request) (usecase.UnlockMessage, error) {
+	id, err := idParam(r)
+	if err != nil {
+		return usecase.UnlockMessage{}, err
+	}
+	return usecase.UnlockMessage{
+		Id: id,
+	}, nil
+}
+
+type LockedParser struct{}
+
+func (c LockedParser) fromHttp(r *http.Request) (usecase.LockedMessage, error) {
+	id, err := idParam(r)
+	if err != nil {
+		return usecase.LockedMessage{}, err
+	}
+	return usecase.LockedMessage{
+		Id: id,
+	}, nil
+}
+
+type EmailParser struct{}
+
+func (c EmailParser) fromHttp(r *http.Request) (usecase.EmailMessage, error) {
+	id, err := idParam(r)
+	if err != nil {
+		return usecase.EmailMessage{}, err
+	}
+	return usecase.EmailMessage{
+		Id: id,
+		To: r.FormValue("to"),
+	}, nil
+}
+
+type SummarizeParser struct{}
+
+func (c SummarizeParser) fromHttp(r *http.Request) (usecase.SummarizeMessage, error) {
+	id, err := idParam(r)
+	if err != nil {
+		return usecase.SummarizeMessage{}, err
+	}
+	return usecase.SummarizeMessage{
+		Id: id,
+	}, nil
+}
+
+type SuggestTitleParser struct{}
+
+func (c SuggestTitleParser) fromHttp(r *http.Request) (usecase.SuggestTitleMessage, error) {
+	return usecase.SuggestTitleMessage{
+		Content: r.FormValue("content"),
+	}, nil
+}
+
+type PublishParser struct{}
+
+// fromHttp makes a new gist secret unless public=true is given
+func (c PublishParser) fromHttp(r *http.Request) (usecase.PublishMessage, error) {
+	id, err := idParam(r)
+	if err != nil {
+		return usecase.PublishMessage{}, err
+	}
+	public, _ := strconv.ParseBool(r.FormValue("public"))
+	return usecase.PublishMessage{
+		Id:     id,
+		Public: public,
+	}, nil
+}
+
+type DeleteParser struct{}
+
+func (c DeleteParser) fromHttp(r *http.Request) (usecase.DeleteMessage, error) {
+	id, err := idParam(r)
+	if err != nil {
+		return usecase.DeleteMessage{}, err
+	}
+	return usecase.DeleteMessage{
+		Id: id,
+	}, nil
+}
+
+type AuditParser struct{}
+
+// fromHttp reads the optional ?noteId= filter
+func (c AuditParser) fromHttp(r *http.Request) (usecase.AuditMessage, error) {
+	noteId := r.URL.Query().Get("noteId")
+	if noteId == "" {
+		return usecase.AuditMessage{}, nil
+	}
+	id, err := strconv.Atoi(noteId)
+	if err != nil {
+		return usecase.AuditMessage{}, fmt.Errorf("%w noteId: %q is not a number", note.ErrValidation, noteId)
+	}
+	return usecase.AuditMessage{
+		NoteId: id,
+	}, nil
+}
+
+type SearchParser struct{}
+
+// fromHttp reads the ?q= query, ?semantic=true searches by meaning
+func (c SearchParser) fromHttp(r *http.Request) (usecase.SearchMessage, error) {
+	semantic, _ := strconv.ParseBool(r.URL.Query().Get("semantic"))
+	return usecase.SearchMessage{
+		Query:    r.URL.Query().Get("q"),
+		Semantic: semantic,
+	}, nil
+}
+
+type NotebooksParser struct{}
+
+func (c NotebooksParser) fromHttp(r *http.Request) (usecase.NotebooksMessage, error) {
+	return usecase.NotebooksMessage{Color: r.URL.Query().Get("color")}, nil
+}
+
+type ColorParser struct{}
+
+// fromHttp colors the note of the path, or its notebook when it names
+// one, with the form field color, an empty color removes it
+func (c ColorParser) fromHttp(r *http.Request) (usecase.ColorMessage, error) {
+	message := usecase.ColorMessage{Color: r.FormValue("color")}
+	if notebook := r.PathValue("name"); notebook != "" {
+		message.Notebook = notebook
+		return message, nil
+	}
+	id, err := idParam(r)
+	if err != nil {
+		return usecase.ColorMessage{}, err
+	}
+	message.Id = id
+	return message, nil
+}
+
+type SaveSearchParser struct{}
+
+func (c SaveSearchParser) fromHttp(r *http.Request) (usecase.SaveSearchMessage, error) {
+	return usecase.SaveSearchMessage{
+		Name:  r.FormValue("name"),
+		Query: r.FormValue("query"),
+	}, nil
+}
+
+type DeleteSearchParser struct{}
+
+func (c DeleteSearchParser) fromHttp(r *http.Request) (usecase.DeleteSearchMessage, error) {
+	return usecase.DeleteSearchMessage{
+		Name: r.PathValue("name"),
+	}, nil
+}
+
+type RecentParser struct{}
+
+// fromHttp reads the ?kind= of the recent notes, viewed or edited, and
+// the optional ?limit=
+func (c RecentParser) fromHttp(r *http.Request) (usecase.RecentMessage, error) {
+	limit := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil {
+			return usecase.RecentMessage{}, fmt.Errorf("%w limit: %q is not a number", note.ErrValidation, l)
+		}
+	}
+	return usecase.RecentMessage{
+		Kind:  r.URL.Query().Get("kind"),
+		Limit: limit,
+	}, nil
+}
+
+type SimilarParser struct{}
+
+// fromHttp reads the note id and the optional ?limit=
+func (c SimilarParser) fromHttp(r *http.Request) (usecase.SimilarMessage, error) {
+	id, err := idParam(r)
+	if err != nil {
+		return usecase.SimilarMessage{}, err
+	}
+	limit := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil {
+			return usecase.SimilarMessage{}, fmt.Errorf("%w limit: %q is not a number", note.ErrValidation, l)
+		}
+	}
+	return usecase.SimilarMessage{
+		Id:    id,
+		Limit: limit,
+	}, nil
+}
+
+type DedupeParser struct{}
+
+// fromHttp reads the optional ?threshold= of the near duplicates
+func (c DedupeParser) fromHttp(r *http.Request) (usecase.DedupeMessage, error) {
+	threshold := 0.0
+	if t := r.URL.Query().Get("threshold"); t != "" {
+		var err error
+		threshold, err = strconv.ParseFloat(t, 64)
+		if err != nil {
+			return usecase.DedupeMessage{}, fmt.Errorf("%w threshold: %q is not a number", note.ErrValidation, t)
+		}
+	}
+	return usecase.DedupeMessage{
+		Threshold: threshold,
+	}, nil
+}
+
+type MergeParser struct{}
+
+// fromHttp reads the note kept and the duplicate fields, one id each
+func (c MergeParser) fromHttp(r *http.Request) (usecase.MergeMessage, error) {
+	id, err := idParam(r)
+	if err != nil {
+		return usecase.MergeMessage{}, err
+	}
+	r.ParseForm()
+	duplicates := []note.Id{}
+	for _, d := range r.Form["duplicate"] {
+		number, err := strconv.Atoi(d)
+		if err != nil {
+			return usecase.MergeMessage{}, fmt.Errorf("%w duplicate: %q is not a number", note.ErrValidation, d)
+		}
+		duplicates = append(duplicates, number)
+	}
+	return usecase.MergeMessage{
+		Id:         id,
+		Duplicates: duplicates,
+	}, nil
+}
+
+// LintParser reports the broken links, POST also writes them to the
+// report note
+type LintParser struct {
+	Report bool
+}
+
+func (c LintParser) fromHttp(r *http.Request) (usecase.LintMessage, error) {
+	return usecase.LintMessage{
+		Report: c.Report,
+	}, nil
+}
+
+type RegisterParser struct{}
+
+func (c RegisterParser) fromHttp(r *http.Request) (usecase.RegisterMessage, error) {
+	return usecase.RegisterMessage{
+		Name:     r.FormValue("name"),
+		Password: user.Secret(r.FormValue("password")),
+	}, nil
+}
+
+type LoginParser struct{}
+
+func (c LoginParser) fromHttp(r *http.Request) (usecase.LoginMessage, error) {
+	return usecase.LoginMessage{
+		Name:     r.FormValue("name"),
+		Password: user.Secret(r.FormValue("password")),
+		Code:     user.Secret(r.FormValue("code")),
+	}, nil
+}
+
+type ShareParser struct{}
+
+// fromHttp reads the note id or the notebook name of the path, with
+// whom it is shared and their access
+func (c ShareParser) fromHttp(r *http.Request) (usecase.ShareMessage, error) {
+	message := usecase.ShareMessage{
+		Notebook: r.PathValue("name"),
+		With:     r.FormValue("with"),
+		Access:   share.Access(r.FormValue("access")),
+	}
+	if message.Notebook != "" {
+		return message, nil
+	}
+	id, err := idParam(r)
+	message.Id = id
+	return message, err
+}
+
+type UnshareParser struct{}
+
+func (c UnshareParser) fromHttp(r *http.Request) (usecase.UnshareMessage, error) {
+	id, err := idParam(r)
+	if err != nil {
+		return usecase.UnshareMessage{}, err
+	}
+	return usecase.UnshareMessage{
+		Id: id,
+	}, nil
+}
+
+type SharesParser struct{}
+
+func (c SharesParser) fromHttp(r *http.Request) (usecase.SharesMessage, error) {
+	return usecase.SharesMessage{}, nil
+}
+
+type SharedWithMeParser struct{}
+
+func (c SharedWithMeParser) fromHttp(r *http.Request) (usecase.SharedWithMeMessage, error) {
+	return usecase.SharedWithMeMessage{}, nil
+}
+
+type CreateApiTokenParser struct{}
+
+func (c CreateApiTokenParser) fromHttp(r *http.Request) (usecase.CreateApiTokenMessage, error) {
+	return usecase.CreateApiTokenMessage{
+		Name:  r.FormValue("name"),
+		Scope: user.Scope(r.FormValue("scope")),
+	}, nil
+}
+
+type ApiTokensParser struct{}
+
+func (c ApiTokensParser) fromHttp(r *http.Request) (usecase.ApiTokensMessage, error) {
+	return usecase.ApiTokensMessage{}, nil
+}
+
+type RevokeApiTokenParser struct{}
+
+func (c RevokeApiTokenParser) fromHttp(r *http.Request) (usecase.RevokeApiTokenMessage, error) {
+	id, err := idParam(r)
+	if err != nil {
+		return usecase.RevokeApiTokenMessage{}, err
+	}
+	return usecase.RevokeApiTokenMessage{
+		Id: id,
+	}, nil
+}
+
+type ActivityParser struct{}
+
+func (c ActivityParser) fromHttp(r *http.Request) (usecase.ActivityMessage, error) {
+	id, err := idParam(r)
+	if err != nil {
+		return usecase.ActivityMessage{}, err
+	}
+	return usecase.ActivityMessage{
+		Id: id,
+	}, nil
+}
+
+type EnrollTotpParser struct{}
+
+func (c EnrollTotpParser) fromHttp(r *http.Request) (usecase.EnrollTotpMessage, error) {
+	return usecase.EnrollTotpMessage{}, nil
+}
+
+type ConfirmTotpParser struct{}
+
+func (c ConfirmTotpParser) fromHttp(r *http.Request) (usecase.ConfirmTotpMessage, error) {
+	return usecase.ConfirmTotpMessage{
+		Code: user.Secret(r.FormValue("code")),
+	}, nil
+}
+
+type DisableTotpParser struct{}
+
+func (c DisableTotpParser) fromHttp(r *http.Request) (usecase.DisableTotpMessage, error) {
+	return usecase.DisableTotpMessage{
+		Code: user.Secret(r.FormValue("code")),
+	}, nil
+}
+
+type UsageParser struct{}
+
+func (c UsageParser) fromHttp(r *http.Request) (usecase.UsageMessage, error) {
+	return usecase.UsageMessage{}, nil
+}
@@ -0,0 +1,69 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"notes/internal/note"
+	"notes/internal/storage"
+	"notes/internal/usecase"
+)
+
+// blockingStorage holds every create until release is closed, started
+// tells a create is being held
+type blockingStorage struct {
+	storage.InMemory
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s blockingStorage) Create(name note.Name, content note.Content, notebook note.Notebook, owner note.UserId) note.Note {
+	s.started <- struct{}{}
+	<-s.release
+	return s.InMemory.Create(name, content, notebook, owner)
+}
+
+func create(handler http.Handler) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/notes/", strings.NewReader("name=groceries&content=milk"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestFullWriteQueue(t *testing.T) {
+	s := blockingStorage{storage.NewInMemory(storage.NewSequence(0)), make(chan struct{}, 1), make(chan struct{})}
+	queue := usecase.NewWriteQueue(1)
+	u := usecase.New(usecase.Deps{Storage: s}, usecase.Queueing(queue))
+	handler := New(u, Config{Routes: map[string]Route{"POST /notes/{$}": Served(CreateParser{}, u.Create)}}).Handler()
+	// the first create runs and the second one waits in the queue
+	waiting := sync.WaitGroup{}
+	for range 2 {
+		waiting.Add(1)
+		go func() {
+			defer waiting.Done()
+			create(handler)
+		}()
+	}
+	<-s.started
+	for queue.Stats().Queued == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	w := create(handler)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("the status is %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("there is no Retry-After")
+	}
+	if queue.Stats().Refused != 1 {
+		t.Errorf("%d creates are refused, want 1", queue.Stats().Refused)
+	}
+	close(s.release)
+	<-s.started
+	waiting.Wait()
+}
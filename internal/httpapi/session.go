@@ -0,0 +1,175 @@
+package httpapi
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+
+	"notes/internal/note"
+	"notes/internal/usecase"
+	"notes/internal/user"
+)
+
+// Sessions keeps the sessions of the browsers, see user.Sessions
+// Reading a missing or expired session returns the zero session.
+type Sessions interface {
+	Create(u user.User) (string, user.Session, error)
+	Read(id string) user.Session
+	Delete(id string) error
+}
+
+const (
+	// sessionCookie holds the id of the session of a browser
+	sessionCookie = "notes_session"
+	// loginCookie holds the csrf token of the login form, which is
+	// posted before there is a session
+	loginCookie = "notes_login"
+	// csrfField is the form field of the csrf token, the X-CSRF-Token
+	// header may carry it instead
+	csrfField = "csrf"
+)
+
+// sessionRoutes are the pages of the browsers logging in and out
+func (app Application) sessionRoutes() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"GET /login":   app.handleLoginPage,
+		"GET /logout":  app.handleLogoutPage,
+		"POST /logout": app.handleLogout,
+	}
+}
+
+// orSession logs a browser in with a session when the login form posts
+// its csrf token, API clients still get a token
+func (app Application) orSession(api http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if app.sessions == nil || r.PostFormValue(csrfField) == "" {
+			api(w, r)
+			return
+		}
+		app.handleSessionLogin(w, r)
+	}
+}
+
+func (app Application) handleLoginPage(w http.ResponseWriter, r *http.Request) {
+	token, err := user.RandomToken()
+	if err != nil {
+		app.fail(w, err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: loginCookie, Value: token, Path: "/login", HttpOnly: true, Secure: true, SameSite: http.SameSiteStrictMode})
+	presentLogin(w, token, r.URL.Query().Get("error"))
+}
+
+// handleSessionLogin checks the csrf token of the login form against its
+// cookie, then the name, password and code, and starts a session
+func (app Application) handleSessionLogin(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(loginCookie)
+	if err != nil || !sameToken(cookie.Value, r.PostFormValue(csrfField)) {
+		app.fail(w, fmt.Errorf("%w: the login form expired, reload it", note.ErrForbidden))
+		return
+	}
+	message := usecase.LoginMessage{Context: messageContext(r), Name: r.PostFormValue("name"), Password: user.Secret(r.PostFormValue("password")), Code: user.Secret(r.PostFormValue("code"))}
+	result, err := app.usecase.Login.Execute(message)
+	if errors.Is(err, errTooManyLogins) {
+		http.Redirect(w, r, "/login?error="+url.QueryEscape("too many failed logins, retry in a minute"), http.StatusSeeOther)
+		return
+	}
+	// which of the name, password or code is wrong isn't told
+	if errors.Is(err, note.ErrUnauthorized) {
+		http.Redirect(w, r, "/login?error="+url.QueryEscape("wrong name, password or code"), http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		app.fail(w, err)
+		return
+	}
+	id, session, err := app.sessions.Create(result.User)
+	if err != nil {
+		app.fail(w, err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: loginCookie, Path: "/login", MaxAge: -1})
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: id, Path: "/", Expires: session.Expires, HttpOnly: true, Secure: true, SameSite: http.SameSiteLaxMode})
+	http.Redirect(w, r, "/notes/", http.StatusSeeOther)
+}
+
+func (app Application) handleLogoutPage(w http.ResponseWriter, r *http.Request) {
+	session := app.session(r)
+	if session.User == 0 {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	presentLogout(w, session)
+}
+
+// handleLogout ends the session, the authentication checked its csrf
+// token already
+func (app Application) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		err = app.sessions.Delete(cookie.Value)
+		if err != nil {
+			app.fail(w, err)
+			return
+		}
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// session returns the session of the cookie of a request, the zero
+// session without one
+func (app Application) session(r *http.Request) user.Session {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil || app.sessions == nil {
+		return user.Session{}
+	}
+	return app.sessions.Read(cookie.Value)
+}
+
+// checkCsrf refuses the requests of a session changing something
+// without its csrf token, other sites can make a browser send them but
+// can't read the token
+func checkCsrf(r *http.Request, session user.Session) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return nil
+	}
+	token := r.Header.Get("X-CSRF-Token")
+	if token == "" {
+		token = r.PostFormValue(csrfField)
+	}
+	if !sameToken(token, session.CSRF) {
+		return fmt.Errorf("%w: missing or wrong csrf token", note.ErrForbidden)
+	}
+	return nil
+}
+
+func sameToken(a, b string) bool {
+	return a != "" && subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func presentLogin(w http.ResponseWriter, csrf string, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, "<!doctype html>\n<meta charset=\"utf-8\">\n<title>Log in - Notes</title>\n")
+	if message != "" {
+		fmt.Fprintf(w, "<p class=\"error\">%s</p>\n", html.EscapeString(message))
+	}
+	fmt.Fprint(w, "<form method=\"post\" action=\"/login\">\n")
+	fmt.Fprintf(w, "<input type=\"hidden\" name=\"%s\" value=\"%s\">\n", csrfField, html.EscapeString(csrf))
+	fmt.Fprint(w, "<label>Name <input name=\"name\" autocomplete=\"username\" required></label>\n")
+	fmt.Fprint(w, "<label>Password <input name=\"password\" type=\"password\" autocomplete=\"current-password\" required></label>\n")
+	fmt.Fprint(w, "<label>Code, with two-factor authentication <input name=\"code\" autocomplete=\"one-time-code\"></label>\n")
+	fmt.Fprint(w, "<button>Log in</button>\n</form>\n")
+}
+
+func presentLogout(w http.ResponseWriter, session user.Session) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, "<!doctype html>\n<meta charset=\"utf-8\">\n<title>Log out - Notes</title>\n")
+	fmt.Fprintf(w, "<p>Logged in as %s</p>\n", html.EscapeString(session.Name))
+	fmt.Fprint(w, "<form method=\"post\" action=\"/logout\">\n")
+	fmt.Fprintf(w, "<input type=\"hidden\" name=\"%s\" value=\"%s\">\n", csrfField, html.EscapeString(session.CSRF))
+	fmt.Fprint(w, "<button>Log out</button>\n</form>\n")
+}
@@ -0,0 +1,59 @@
+package i18n
+
+// french messages
+var french = map[string]string{
+	// kinds of errors
+	"not found":    "introuvable",
+	"invalid":      "invalide",
+	"conflict":     "conflit",
+	"forbidden":    "interdit",
+	"too large":    "trop grand",
+	"unauthorized": "non autorisé",
+	"busy":         "occupé",
+
+	// REPL
+	"Error: %s\n":                 "Erreur : %s\n",
+	"Aborted\n":                   "Annulé\n",
+	" [y/N] ":                     " [o/N] ",
+	"y":                           "o",
+	"yes":                         "oui",
+	"Delete note %d %q?":          "Supprimer la note %d %q ?",
+	"Would delete note %d %q\n":   "La note %d %q serait supprimée\n",
+	"Deleted note %d %q\n":        "Note %d %q supprimée\n",
+	"Copied note %d %q\n":         "Note %d %q copiée\n",
+	"Exported %d notes to %s\n":   "%d notes exportées dans %s\n",
+	"Nothing to save\n":           "Rien à enregistrer\n",
+	"Saved\n":                     "Enregistré\n",
+	"Using notebook %s\n":         "Carnet %s utilisé\n",
+	"No current notebook\n":       "Aucun carnet en cours\n",
+	"No changes\n":                "Aucune modification\n",
+	"Nothing to undo\n":           "Rien à annuler\n",
+	"Nothing to redo\n":           "Rien à rétablir\n",
+	"command: %s":                 "commande : %s",
+	"format: %s":                  "format : %s",
+	"Nothing picked\n":            "Aucune note choisie\n",
+	"1 note due today\n":          "1 note à échéance aujourd’hui\n",
+	"%d notes due today\n":        "%d notes à échéance aujourd’hui\n",
+	"No notebooks\n":              "Aucun carnet\n",
+	"No notes\n":                  "Aucune note\n",
+	"No notes similar to %d %s\n": "Aucune note similaire à %d %s\n",
+	"Similar to %d %s:\n":         "Similaires à %d %s :\n",
+	"No notes due\n":              "Aucune note à échéance\n",
+	"No duplicates\n":             "Aucun doublon\n",
+	"Same content:\n":             "Même contenu :\n",
+	"Similar content, %.0f%%:\n":  "Contenu similaire, %.0f %% :\n",
+	"  MERGE;%s or DELETE;%s\n":   "  MERGE;%s ou DELETE;%s\n",
+	"No broken links\n":           "Aucun lien cassé\n",
+	"Report written to %d %s\n":   "Rapport écrit dans %d %s\n",
+	"Renamed %s to %s\n":          "%s renommée en %s\n",
+	"Note %d locked until %s\n":   "Note %d verrouillée jusqu’à %s\n",
+	"Note %d unlocked\n":          "Note %d déverrouillée\n",
+	"Not locked\n":                "Non verrouillée\n",
+	"Pinned %d %s\n":              "%d %s épinglée\n",
+	"Unpinned %d %s\n":            "%d %s désépinglée\n",
+	"Pinned:\n":                   "Épinglées :\n",
+	"Due today:\n":                "À échéance aujourd’hui :\n",
+	"Recent:\n":                   "Récentes :\n",
+	"Note %d is being edited by %s until %s\n":          "La note %d est en cours de modification par %s jusqu’à %s\n",
+	"Warning: note %d is being edited by %s until %s\n": "Attention : la note %d est en cours de modification par %s jusqu’à %s\n",
+}
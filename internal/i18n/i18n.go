@@ -0,0 +1,108 @@
+// Package i18n translates the messages shown to the users. The messages
+// are their English format strings, a catalog gives their translations
+// in a language, the messages it lacks stay in English.
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"notes/internal/note"
+)
+
+// English is the language of the messages themselves
+const English = "en"
+
+// catalogs of the translations by language, English has none
+var catalogs = map[string]map[string]string{
+	"fr": french,
+}
+
+// Languages are those with messages, English first
+func Languages() []string {
+	languages := []string{English}
+	for language := range catalogs {
+		languages = append(languages, language)
+	}
+	slices.Sort(languages[1:])
+	return languages
+}
+
+// Detect finds the language of the first locale set, such as fr_FR.UTF-8,
+// English when it has no messages
+// The locales are given by order of precedence, as the configuration,
+// LC_ALL, LC_MESSAGES and LANG.
+func Detect(locales ...string) string {
+	for _, locale := range locales {
+		if locale == "" {
+			continue
+		}
+		language, _, _ := strings.Cut(locale, ".")
+		language, _, _ = strings.Cut(language, "@")
+		language, _, _ = strings.Cut(strings.ReplaceAll(language, "-", "_"), "_")
+		language = strings.ToLower(language)
+		if _, ok := catalogs[language]; ok {
+			return language
+		}
+		return English
+	}
+	return English
+}
+
+// Messages translates to a language, the zero value leaves the messages
+// in English
+type Messages struct {
+	catalog map[string]string
+}
+
+// New translates to the language, a language without messages is
+// English
+func New(language string) Messages {
+	return Messages{catalog: catalogs[language]}
+}
+
+// text is the translation of a message
+func (m Messages) text(message string) string {
+	if translated, ok := m.catalog[message]; ok {
+		return translated
+	}
+	return message
+}
+
+func (m Messages) Sprintf(format string, args ...any) string {
+	return fmt.Sprintf(m.text(format), args...)
+}
+
+func (m Messages) Fprintf(w io.Writer, format string, args ...any) {
+	fmt.Fprintf(w, m.text(format), args...)
+}
+
+// sentinels are the kinds of errors, their text is part of the messages
+// of the errors
+var sentinels = []error{
+	note.ErrNotFound,
+	note.ErrValidation,
+	note.ErrConflict,
+	note.ErrForbidden,
+	note.ErrTooLarge,
+	note.ErrUnauthorized,
+	note.ErrBusy,
+}
+
+// Error is the message of an error, the kind of the error is translated
+// when the whole message isn't
+func (m Messages) Error(err error) string {
+	message := err.Error()
+	if translated, ok := m.catalog[message]; ok {
+		return translated
+	}
+	for _, sentinel := range sentinels {
+		if errors.Is(err, sentinel) {
+			return strings.Replace(message, sentinel.Error(), m.text(sentinel.Error()), 1)
+		}
+	}
+	return message
+}
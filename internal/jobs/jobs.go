@@ -0,0 +1,137 @@
+// Package jobs runs work in the background on a fixed number of
+// workers, rather than on a goroutine each. A failing job is tried
+// again a few times, waiting longer each time, and closing the queue
+// waits for the jobs queued so far.
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrFull is returned by Submit when every worker is busy and the
+	// queue holds as many jobs as it may
+	ErrFull = errors.New("jobs: queue full")
+	// ErrClosed is returned by Submit once the queue is closed
+	ErrClosed = errors.New("jobs: queue closed")
+)
+
+// Config of a queue, the zero fields take their default
+type Config struct {
+	// Workers run the jobs, 4 by default
+	Workers int
+	// Size is the number of jobs waiting for a worker, 100 by default
+	Size int
+	// Retries of a failing job, none by default
+	Retries int
+	// Backoff is the wait before the first retry, doubled before each
+	// of the next ones, a second by default
+	Backoff time.Duration
+}
+
+type job struct {
+	name string
+	run  func() error
+}
+
+// Queue runs the jobs submitted to it, the jobs failing every time are
+// given to errors
+type Queue struct {
+	config  Config
+	errors  func(error)
+	mutex   *sync.RWMutex
+	closed  *bool
+	jobs    chan job
+	running *sync.WaitGroup
+	busy    *atomic.Int64
+}
+
+// Stats of a queue, the jobs waiting for a worker and those running
+type Stats struct {
+	Workers int
+	Queued  int
+	Running int
+}
+
+// New starts the workers of a queue
+func New(config Config, errors func(error)) Queue {
+	if config.Workers <= 0 {
+		config.Workers = 4
+	}
+	if config.Size <= 0 {
+		config.Size = 100
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = time.Second
+	}
+	q := Queue{config: config, errors: errors, mutex: &sync.RWMutex{}, closed: new(bool), jobs: make(chan job, config.Size), running: &sync.WaitGroup{}, busy: &atomic.Int64{}}
+	q.running.Add(config.Workers)
+	for range config.Workers {
+		go q.work()
+	}
+	return q
+}
+
+// Submit queues a job, name tells which job failed
+func (q Queue) Submit(name string, run func() error) error {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+	if *q.closed {
+		return ErrClosed
+	}
+	select {
+	case q.jobs <- job{name: name, run: run}:
+		return nil
+	default:
+		return ErrFull
+	}
+}
+
+// Close refuses the new jobs and returns once the queued ones are done,
+// retries included
+func (q Queue) Close() {
+	q.mutex.Lock()
+	if !*q.closed {
+		*q.closed = true
+		close(q.jobs)
+	}
+	q.mutex.Unlock()
+	q.running.Wait()
+}
+
+// Stats tells how busy the queue is
+func (q Queue) Stats() Stats {
+	return Stats{Workers: q.config.Workers, Queued: len(q.jobs), Running: int(q.busy.Load())}
+}
+
+func (q Queue) work() {
+	defer q.running.Done()
+	for j := range q.jobs {
+		q.busy.Add(1)
+		q.run(j)
+		q.busy.Add(-1)
+	}
+}
+
+// run tries a job until it succeeds or has no retry left
+func (q Queue) run(j job) {
+	backoff := q.config.Backoff
+	for attempt := 0; ; attempt++ {
+		err := j.run()
+		if err == nil {
+			return
+		}
+		if attempt == q.config.Retries {
+			if q.errors != nil {
+				q.errors(fmt.Errorf("%s: %w", j.name, err))
+			}
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
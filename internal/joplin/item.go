@@ -0,0 +1,139 @@
+package joplin
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// Item types of Joplin
+const (
+	typeNote   = "1"
+	typeFolder = "2"
+)
+
+// item is a Joplin item as serialized in the sync target, its title
+// and body followed by its properties, one "key: value" per line
+type item struct {
+	title string
+	body  string
+	props []prop
+}
+
+type prop struct {
+	key   string
+	value string
+}
+
+func (it item) get(key string) string {
+	for _, p := range it.props {
+		if p.key == key {
+			return p.value
+		}
+	}
+	return ""
+}
+
+func (it *item) set(key string, value string) {
+	for i, p := range it.props {
+		if p.key == key {
+			it.props[i].value = value
+			return
+		}
+	}
+	it.props = append(it.props, prop{key, value})
+}
+
+// parseItem reads the properties from the end, up to the blank line
+// after the body
+func parseItem(data string) item {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	it := item{}
+	text, props := "", data
+	if i := strings.LastIndex(data, "\n\n"); i >= 0 {
+		text, props = data[:i], data[i+2:]
+	}
+	for _, line := range strings.Split(props, "\n") {
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			key, value, _ = strings.Cut(strings.TrimSuffix(line, ":"), ":")
+		}
+		it.props = append(it.props, prop{key, value})
+	}
+	title, body, _ := strings.Cut(text, "\n")
+	it.title = title
+	it.body = strings.TrimPrefix(body, "\n")
+	return it
+}
+
+func (it item) String() string {
+	b := strings.Builder{}
+	b.WriteString(it.title)
+	b.WriteString("\n\n")
+	if it.get("type_") == typeNote {
+		b.WriteString(it.body)
+		b.WriteString("\n\n")
+	}
+	for i, p := range it.props {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(p.key + ": " + p.value)
+	}
+	return b.String()
+}
+
+// timestamp formats a time as Joplin does
+func timestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// newId is a random id, Joplin ids are 32 hex digits
+func newId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func newFolder(title string, now time.Time) item {
+	t := timestamp(now)
+	return item{title: title, props: []prop{
+		{"id", newId()},
+		{"created_time", t},
+		{"updated_time", t},
+		{"user_created_time", t},
+		{"user_updated_time", t},
+		{"encryption_cipher_text", ""},
+		{"encryption_applied", "0"},
+		{"parent_id", ""},
+		{"is_shared", "0"},
+		{"type_", typeFolder},
+	}}
+}
+
+func newNote(title string, body string, parent string, now time.Time) item {
+	t := timestamp(now)
+	return item{title: title, body: body, props: []prop{
+		{"id", newId()},
+		{"parent_id", parent},
+		{"created_time", t},
+		{"updated_time", t},
+		{"is_conflict", "0"},
+		{"author", ""},
+		{"source_url", ""},
+		{"is_todo", "0"},
+		{"todo_due", "0"},
+		{"todo_completed", "0"},
+		{"source", "notes"},
+		{"source_application", "notes"},
+		{"order", "0"},
+		{"user_created_time", t},
+		{"user_updated_time", t},
+		{"encryption_cipher_text", ""},
+		{"encryption_applied", "0"},
+		{"markup_language", "1"},
+		{"is_shared", "0"},
+		{"type_", typeNote},
+	}}
+}
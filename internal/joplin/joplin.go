@@ -0,0 +1,462 @@
+// Package joplin is a sync target of Joplin, the WebDAV target, so
+// Joplin clients can sync their notes with the notes.
+package joplin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"notes/internal/note"
+	"notes/internal/usecase"
+)
+
+// stateFile maps the Joplin notes to the notes, it is hidden from Joplin
+const stateFile = ".notes-state.json"
+
+// Target keeps the files Joplin syncs in a directory, the notes among
+// them are the notes of the usecases
+// Notes changed on this side are written to their files the next time
+// Joplin lists the directory, notes Joplin writes are created or
+// updated here. Notebooks are Joplin folders. Tags, resources and
+// encrypted items are kept for Joplin but don't become anything here.
+type Target struct {
+	usecase usecase.Usecase
+	dir     string
+	prefix  string
+	mutex   *sync.Mutex
+}
+
+type synced struct {
+	LocalId note.Id `json:"localId"`
+	// Hash of the note when its file was last written
+	Hash string `json:"hash"`
+}
+
+type state struct {
+	// Notes by Joplin id
+	Notes map[string]synced `json:"notes"`
+	// DefaultFolder holds the notes without a notebook
+	DefaultFolder string `json:"defaultFolder"`
+}
+
+// New serves the target under prefix, such as /joplin/, which is the
+// url Joplin is given
+func New(u usecase.Usecase, dir string, prefix string) Target {
+	return Target{usecase: u, dir: dir, prefix: "/" + strings.Trim(prefix, "/") + "/", mutex: &sync.Mutex{}}
+}
+
+func (t Target) loadState() (state, error) {
+	s := state{Notes: map[string]synced{}}
+	data, err := os.ReadFile(filepath.Join(t.dir, stateFile))
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	err = json.Unmarshal(data, &s)
+	return s, err
+}
+
+func (t Target) saveState(s state) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(t.dir, stateFile), data, 0o644)
+}
+
+func hash(n note.Note) string {
+	sum := sha256.Sum256([]byte(n.Name + "\x00" + n.Content + "\x00" + n.Notebook))
+	return hex.EncodeToString(sum[:])
+}
+
+func (t Target) readItem(id string) (item, error) {
+	data, err := os.ReadFile(filepath.Join(t.dir, id+".md"))
+	if err != nil {
+		return item{}, err
+	}
+	return parseItem(string(data)), nil
+}
+
+func (t Target) writeItem(it item) error {
+	return os.WriteFile(filepath.Join(t.dir, it.get("id")+".md"), []byte(it.String()), 0o644)
+}
+
+// folders lists the folders Joplin knows by id
+func (t Target) folders() (map[string]item, error) {
+	folders := map[string]item{}
+	entries, err := os.ReadDir(t.dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".md" {
+			continue
+		}
+		it, err := t.readItem(strings.TrimSuffix(e.Name(), ".md"))
+		if err != nil {
+			return nil, err
+		}
+		if it.get("type_") == typeFolder && it.get("encryption_applied") != "1" {
+			folders[it.get("id")] = it
+		}
+	}
+	return folders, nil
+}
+
+// reconcile writes the files of the notes changed on this side and
+// removes those of the deleted notes
+func (t Target) reconcile(ctx usecase.Context) error {
+	s, err := t.loadState()
+	if err != nil {
+		return err
+	}
+	result, err := t.usecase.ReadAll.Execute(usecase.ReadAllMessage{Context: ctx})
+	if err != nil {
+		return err
+	}
+	notes := map[note.Id]note.Note{}
+	for _, n := range result.Notes {
+		notes[n.Id] = n
+	}
+	folders, err := t.folders()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for id, m := range s.Notes {
+		n, ok := notes[m.LocalId]
+		delete(notes, m.LocalId)
+		if !ok {
+			delete(s.Notes, id)
+			os.Remove(filepath.Join(t.dir, id+".md"))
+			continue
+		}
+		if hash(n) == m.Hash {
+			continue
+		}
+		it, err := t.readItem(id)
+		if err != nil {
+			return err
+		}
+		it.title = n.Name
+		it.body = n.Content
+		it.set("updated_time", timestamp(now))
+		it.set("user_updated_time", timestamp(now))
+		err = t.writeItem(it)
+		if err != nil {
+			return err
+		}
+		s.Notes[id] = synced{LocalId: n.Id, Hash: hash(n)}
+	}
+	for _, n := range notes {
+		parent, err := t.folder(&s, folders, n.Notebook, now)
+		if err != nil {
+			return err
+		}
+		it := newNote(n.Name, n.Content, parent, now)
+		err = t.writeItem(it)
+		if err != nil {
+			return err
+		}
+		s.Notes[it.get("id")] = synced{LocalId: n.Id, Hash: hash(n)}
+	}
+	return t.saveState(s)
+}
+
+// folder finds the folder of a notebook or creates it
+func (t Target) folder(s *state, folders map[string]item, notebook note.Notebook, now time.Time) (string, error) {
+	if notebook == "" {
+		if _, ok := folders[s.DefaultFolder]; ok {
+			return s.DefaultFolder, nil
+		}
+		f := newFolder("Notes", now)
+		s.DefaultFolder = f.get("id")
+		folders[f.get("id")] = f
+		return f.get("id"), t.writeItem(f)
+	}
+	for id, f := range folders {
+		if f.title == notebook && id != s.DefaultFolder && f.get("parent_id") == "" {
+			return id, nil
+		}
+	}
+	f := newFolder(notebook, now)
+	folders[f.get("id")] = f
+	return f.get("id"), t.writeItem(f)
+}
+
+// apply creates or updates the note of an item written by Joplin, other
+// items are only kept as files
+func (t Target) apply(ctx usecase.Context, data string) error {
+	it := parseItem(data)
+	if it.get("type_") != typeNote || it.get("encryption_applied") == "1" {
+		return nil
+	}
+	s, err := t.loadState()
+	if err != nil {
+		return err
+	}
+	name := it.title
+	if strings.TrimSpace(name) == "" {
+		name = "Untitled"
+	}
+	id := it.get("id")
+	m, ok := s.Notes[id]
+	var n note.Note
+	if ok {
+		result, err := t.usecase.Update.Execute(usecase.UpdateMessage{Context: ctx, Id: m.LocalId, Name: name, Content: it.body})
+		// a note deleted here and changed in Joplin is created again
+		if errors.Is(err, note.ErrNotFound) {
+			ok = false
+		} else if err != nil {
+			return err
+		}
+		n = result.Note
+	}
+	if !ok {
+		notebook := ""
+		parent := it.get("parent_id")
+		if parent != s.DefaultFolder {
+			folders, err := t.folders()
+			if err != nil {
+				return err
+			}
+			notebook = folders[parent].title
+		}
+		result, err := t.usecase.Create.Execute(usecase.CreateMessage{Context: ctx, Name: name, Content: it.body, Notebook: notebook})
+		if err != nil {
+			return err
+		}
+		n = result.Note
+	}
+	s.Notes[id] = synced{LocalId: n.Id, Hash: hash(n)}
+	return t.saveState(s)
+}
+
+// remove deletes the note of an item Joplin deleted
+func (t Target) remove(ctx usecase.Context, id string) error {
+	s, err := t.loadState()
+	if err != nil {
+		return err
+	}
+	m, ok := s.Notes[id]
+	if !ok {
+		return nil
+	}
+	_, err = t.usecase.Delete.Execute(usecase.DeleteMessage{Context: ctx, Id: m.LocalId})
+	if err != nil && !errors.Is(err, note.ErrNotFound) {
+		return err
+	}
+	delete(s.Notes, id)
+	return t.saveState(s)
+}
+
+// file maps the path of a request to a file of the directory, the state
+// file is not served
+func (t Target) file(p string) (string, string, bool) {
+	rel := strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(p, t.prefix)), "/")
+	if rel == stateFile {
+		return "", "", false
+	}
+	return rel, filepath.Join(t.dir, filepath.FromSlash(rel)), true
+}
+
+// ServeHTTP speaks the part of WebDAV Joplin uses
+func (t Target) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	actor, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		actor = r.RemoteAddr
+	}
+	ctx := usecase.Context{Actor: actor}
+	err = os.MkdirAll(t.dir, 0o755)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rel, file, ok := t.file(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case "OPTIONS":
+		w.Header().Set("DAV", "1")
+		w.Header().Set("Allow", "OPTIONS, GET, PUT, DELETE, MKCOL, MOVE, PROPFIND")
+	case "PROPFIND":
+		if rel == "" {
+			err = t.reconcile(ctx)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		t.propfind(w, r, rel, file)
+	case http.MethodGet:
+		http.ServeFile(w, r, file)
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !strings.Contains(rel, "/") && path.Ext(rel) == ".md" {
+			err = t.apply(ctx, string(data))
+			if err != nil {
+				http.Error(w, err.Error(), status(err))
+				return
+			}
+		}
+		err = os.MkdirAll(filepath.Dir(file), 0o755)
+		if err == nil {
+			err = os.WriteFile(file, data, 0o644)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		if !strings.Contains(rel, "/") && path.Ext(rel) == ".md" {
+			err = t.remove(ctx, strings.TrimSuffix(rel, ".md"))
+			if err != nil {
+				http.Error(w, err.Error(), status(err))
+				return
+			}
+		}
+		err = os.RemoveAll(file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "MKCOL":
+		err = os.Mkdir(file, 0o755)
+		if os.IsExist(err) {
+			http.Error(w, "exists", http.StatusMethodNotAllowed)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	case "MOVE":
+		destination, err := url.Parse(r.Header.Get("Destination"))
+		if err != nil {
+			http.Error(w, "bad destination", http.StatusBadRequest)
+			return
+		}
+		_, target, ok := t.file(destination.Path)
+		if !ok {
+			http.Error(w, "bad destination", http.StatusBadRequest)
+			return
+		}
+		err = os.Rename(file, target)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func status(err error) int {
+	switch {
+	case errors.Is(err, note.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, note.ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, note.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, note.ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, note.ErrBusy):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+type multistatus struct {
+	XMLName   xml.Name   `xml:"d:multistatus"`
+	Namespace string     `xml:"xmlns:d,attr"`
+	Responses []response `xml:"d:response"`
+}
+
+type response struct {
+	Href string   `xml:"d:href"`
+	Prop propstat `xml:"d:propstat"`
+}
+
+type propstat struct {
+	Collection   *struct{} `xml:"d:prop>d:resourcetype>d:collection"`
+	LastModified string    `xml:"d:prop>d:getlastmodified"`
+	Length       int64     `xml:"d:prop>d:getcontentlength,omitempty"`
+	Status       string    `xml:"d:status"`
+}
+
+// propfind lists a file, or a directory and its children with Depth: 1
+func (t Target) propfind(w http.ResponseWriter, r *http.Request, rel string, file string) {
+	info, err := os.Stat(file)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	href := t.prefix + rel
+	if info.IsDir() && !strings.HasSuffix(href, "/") {
+		href += "/"
+	}
+	result := multistatus{Namespace: "DAV:", Responses: []response{davResponse(href, info)}}
+	if info.IsDir() && r.Header.Get("Depth") != "0" {
+		entries, err := os.ReadDir(file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, e := range entries {
+			if rel == "" && e.Name() == stateFile {
+				continue
+			}
+			child, err := e.Info()
+			if err != nil {
+				continue
+			}
+			childHref := href + e.Name()
+			if child.IsDir() {
+				childHref += "/"
+			}
+			result.Responses = append(result.Responses, davResponse(childHref, child))
+		}
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(result)
+}
+
+func davResponse(href string, info os.FileInfo) response {
+	p := propstat{LastModified: info.ModTime().UTC().Format(http.TimeFormat), Status: "HTTP/1.1 200 OK"}
+	if info.IsDir() {
+		p.Collection = &struct{}{}
+	} else {
+		p.Length = info.Size()
+	}
+	return response{Href: (&url.URL{Path: href}).EscapedPath(), Prop: p}
+}
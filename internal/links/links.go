@@ -0,0 +1,104 @@
+// Package links follows the web links of the notes, to find the broken
+// ones.
+package links
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	neturl "net/url"
+	"syscall"
+	"time"
+)
+
+// Checker follows a link with a HEAD request, or a GET when the server
+// doesn't answer HEAD, the link is broken when it can't be reached or its
+// status is an error
+// The links are followed from the server, the ones to its own network,
+// such as loopback, private or link-local addresses, are refused so the
+// notes can't make it reach what is only open to it.
+type Checker struct {
+	client *http.Client
+}
+
+// maxRedirects is the number of redirects a link may go through
+const maxRedirects = 5
+
+// NewChecker gives up a link after the timeout, 10s when 0
+func NewChecker(timeout time.Duration) Checker {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	// every connection is checked, those of the redirects too, and
+	// there is no proxy which would be the only address checked
+	dialer := &net.Dialer{Timeout: timeout, Control: public}
+	transport := &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: timeout}
+	return Checker{client: &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("more than %d redirects", maxRedirects)
+			}
+			return nil
+		},
+	}}
+}
+
+// public refuses to connect to an address which isn't on the internet
+func public(network string, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	ip := addrPort.Addr().Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || sharedSpace.Contains(ip) {
+		return fmt.Errorf("%s is not a public address", ip)
+	}
+	return nil
+}
+
+// sharedSpace is the address space of carrier-grade NAT, RFC 6598, which
+// netip doesn't count as private
+var sharedSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func (c Checker) Check(url string) error {
+	status, err := c.request(http.MethodHead, url)
+	if err != nil {
+		return err
+	}
+	// some servers refuse HEAD but serve the page
+	switch status {
+	case http.StatusMethodNotAllowed, http.StatusForbidden, http.StatusNotImplemented:
+		status, err = c.request(http.MethodGet, url)
+		if err != nil {
+			return err
+		}
+	}
+	if status >= 400 {
+		return fmt.Errorf("%d %s", status, http.StatusText(status))
+	}
+	return nil
+}
+
+func (c Checker) request(method string, url string) (int, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "notes link checker")
+	resp, err := c.client.Do(req)
+	// the error of the request, without its method and url
+	if e := (*neturl.Error)(nil); errors.As(err, &e) {
+		return 0, e.Err
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	return resp.StatusCode, nil
+}
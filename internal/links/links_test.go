@@ -0,0 +1,26 @@
+package links
+
+import "testing"
+
+func TestPublic(t *testing.T) {
+	for address, allowed := range map[string]bool{
+		"93.184.215.14:443":     true,
+		"[2606:4700::1111]:80":  true,
+		"127.0.0.1:80":          false,
+		"[::1]:80":              false,
+		"10.1.2.3:80":           false,
+		"172.16.0.1:80":         false,
+		"192.168.1.1:80":        false,
+		"169.254.169.254:80":    false,
+		"[fe80::1]:80":          false,
+		"[fd00::1]:80":          false,
+		"100.64.0.1:80":         false,
+		"0.0.0.0:80":            false,
+		"[::ffff:127.0.0.1]:80": false,
+	} {
+		err := public("tcp", address, nil)
+		if (err == nil) != allowed {
+			t.Errorf("%s: got %v, want allowed %v", address, err, allowed)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Package llm asks a large language model to write about the notes, such
+// as their summaries, through an OpenAI compatible API.
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Config of an OpenAI compatible API
+type Config struct {
+	// URL of the API, https://api.openai.com/v1 by default, a local model
+	// served by Ollama is at http://localhost:11434/v1
+	URL   string
+	Model string
+	// Key is sent as a bearer token when not empty
+	Key string
+}
+
+// OpenAI asks the chat completions endpoint of an OpenAI compatible API,
+// it is a usecase.LanguageModel
+type OpenAI struct {
+	config Config
+	http   *http.Client
+}
+
+// NewOpenAI builds the language model of config
+func NewOpenAI(config Config) OpenAI {
+	if config.URL == "" {
+		config.URL = "https://api.openai.com/v1"
+	}
+	return OpenAI{config: config, http: &http.Client{Timeout: 120 * time.Second}}
+}
+
+type message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+func (p OpenAI) Complete(instructions string, prompt string) (string, error) {
+	data, err := json.Marshal(map[string]any{
+		"model":    p.config.Model,
+		"messages": []message{{"system", instructions}, {"user", prompt}},
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(p.config.URL, "/")+"/chat/completions", bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.config.Key != "" {
+		req.Header.Set("Authorization", "Bearer "+p.config.Key)
+	}
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	answer := struct {
+		Choices []struct {
+			Message message `json:"message"`
+		} `json:"choices"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&answer)
+	if err != nil {
+		return "", err
+	}
+	if len(answer.Choices) == 0 {
+		return "", fmt.Errorf("no answer")
+	}
+	return answer.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,321 @@
+// Package mail turns emails into notes and sends notes by email.
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"notes/internal/exchange"
+	"notes/internal/note"
+	"notes/internal/usecase"
+)
+
+// maxSize of an email, larger emails are refused
+const maxSize = 25 << 20
+
+// Config of the inbound SMTP server
+type Config struct {
+	// Addr defaults to 127.0.0.1:2525, the server has neither TLS nor
+	// authentication and is meant to receive the emails forwarded by a
+	// mail server
+	Addr string
+	// Listener replaces Addr when not nil
+	Listener net.Listener
+	// Recipients are the addresses whose emails become notes, any
+	// address when empty
+	Recipients []string
+	// Notebook of the notes
+	Notebook note.Notebook
+	// Attachments is the directory of the attachments, they are dropped
+	// when empty
+	Attachments string
+	// Seen is the file of the message ids already turned into notes,
+	// they are only kept in memory when empty
+	Seen string
+}
+
+// Server is an SMTP server turning the emails it receives into notes,
+// an email with the message id of a previous one is dropped
+// The sender of an email is the actor of the note creation.
+type Server struct {
+	usecase usecase.Usecase
+	config  Config
+	seen    *seen
+	stop    chan struct{}
+	mutex   *sync.Mutex
+	// listener is set once the server runs
+	listener *net.Listener
+}
+
+func NewServer(u usecase.Usecase, config Config) Server {
+	if config.Addr == "" {
+		config.Addr = "127.0.0.1:2525"
+	}
+	return Server{
+		usecase:  u,
+		config:   config,
+		seen:     &seen{path: config.Seen},
+		stop:     make(chan struct{}),
+		mutex:    &sync.Mutex{},
+		listener: new(net.Listener),
+	}
+}
+
+// Run serves until Stop is called, failing to listen is reported on
+// stderr
+func (s Server) Run() {
+	s.mutex.Lock()
+	select {
+	case <-s.stop:
+		s.mutex.Unlock()
+		return
+	default:
+	}
+	l := s.config.Listener
+	if l == nil {
+		var err error
+		l, err = net.Listen("tcp", s.config.Addr)
+		if err != nil {
+			s.mutex.Unlock()
+			fmt.Fprintln(os.Stderr, "notes: smtp:", err)
+			return
+		}
+	}
+	*s.listener = l
+	s.mutex.Unlock()
+	err := s.seen.load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "notes: smtp:", err)
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-s.stop:
+			default:
+				fmt.Fprintln(os.Stderr, "notes: smtp:", err)
+			}
+			return
+		}
+		go s.serve(conn)
+	}
+}
+
+// Stop closes the listener, the emails being received are dropped
+func (s Server) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	close(s.stop)
+	if *s.listener != nil {
+		(*s.listener).Close()
+	}
+}
+
+// serve speaks enough SMTP to receive emails, see RFC 5321
+func (s Server) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	reply := func(code int, message string) {
+		conn.SetDeadline(time.Now().Add(5 * time.Minute))
+		text.PrintfLine("%d %s", code, message)
+	}
+	reply(220, "notes ESMTP")
+	from := ""
+	recipients := 0
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			reply(250, "notes")
+		case "EHLO":
+			text.PrintfLine("250-notes")
+			reply(250, fmt.Sprintf("SIZE %d", maxSize))
+		case "MAIL":
+			from = address(arg, "FROM:")
+			recipients = 0
+			reply(250, "OK")
+		case "RCPT":
+			if !s.accepts(address(arg, "TO:")) {
+				reply(550, "no such mailbox")
+				continue
+			}
+			recipients++
+			reply(250, "OK")
+		case "DATA":
+			if recipients == 0 {
+				reply(503, "no recipient")
+				continue
+			}
+			reply(354, "end with <CRLF>.<CRLF>")
+			data, err := io.ReadAll(io.LimitReader(text.DotReader(), maxSize+1))
+			if err != nil {
+				return
+			}
+			if len(data) > maxSize {
+				reply(552, "too large")
+				continue
+			}
+			err = s.receive(from, data)
+			if err != nil {
+				reply(554, err.Error())
+				continue
+			}
+			reply(250, "OK")
+		case "RSET":
+			from = ""
+			recipients = 0
+			reply(250, "OK")
+		case "NOOP":
+			reply(250, "OK")
+		case "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			reply(502, "not implemented")
+		}
+	}
+}
+
+// address reads the address of a MAIL or RCPT command
+func address(arg string, prefix string) string {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return ""
+	}
+	arg = strings.TrimSpace(arg[len(prefix):])
+	if i := strings.Index(arg, ">"); strings.HasPrefix(arg, "<") && i > 0 {
+		return arg[1:i]
+	}
+	addr, _, _ := strings.Cut(arg, " ")
+	return addr
+}
+
+func (s Server) accepts(recipient string) bool {
+	if len(s.config.Recipients) == 0 {
+		return true
+	}
+	for _, r := range s.config.Recipients {
+		if strings.EqualFold(r, recipient) {
+			return true
+		}
+	}
+	return false
+}
+
+// receive creates the note of an email, a duplicate is accepted and
+// dropped so the sender doesn't try again
+func (s Server) receive(from string, data []byte) error {
+	// the id is checked first so the attachments of a duplicate are not
+	// saved again
+	header, _ := textproto.NewReader(bufio.NewReader(bytes.NewReader(data))).ReadMIMEHeader()
+	id := strings.TrimSpace(header.Get("Message-Id"))
+	if !s.seen.add(id) {
+		return nil
+	}
+	email, report, err := exchange.ReadEmail(bytes.NewReader(data), s.config.Attachments)
+	if err != nil {
+		s.seen.remove(id)
+		return err
+	}
+	if email.From != "" {
+		from = email.From
+	}
+	n := email.Note
+	_, err = s.usecase.Create.Execute(usecase.CreateMessage{
+		Context:  usecase.Context{Actor: from},
+		Name:     n.Name,
+		Content:  n.Content,
+		Notebook: s.config.Notebook,
+	})
+	if err != nil {
+		s.seen.remove(email.MessageId)
+		return err
+	}
+	for _, line := range report {
+		fmt.Fprintf(os.Stderr, "notes: smtp: %s: skipped %s\n", email.MessageId, line)
+	}
+	// the note exists, failing to remember the id only matters after a
+	// restart
+	err = s.seen.save(email.MessageId)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "notes: smtp:", err)
+	}
+	return nil
+}
+
+// seen keeps the message ids of the emails turned into notes, one per
+// line of a file
+type seen struct {
+	path  string
+	mutex sync.Mutex
+	ids   map[string]bool
+}
+
+func (s *seen) load() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.ids = map[string]bool{}
+	if s.path == "" {
+		return nil
+	}
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		s.ids[scanner.Text()] = true
+	}
+	return scanner.Err()
+}
+
+// add tells whether the id wasn't seen yet, emails without an id are
+// never duplicates
+func (s *seen) add(id string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if id == "" {
+		return true
+	}
+	if s.ids[id] {
+		return false
+	}
+	s.ids[id] = true
+	return true
+}
+
+func (s *seen) remove(id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.ids, id)
+}
+
+func (s *seen) save(id string) error {
+	if s.path == "" || id == "" {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(file, id)
+	return errors.Join(err, file.Close())
+}
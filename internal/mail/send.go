@@ -0,0 +1,122 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+
+	"notes/internal/note"
+)
+
+// SenderConfig of the SMTP server notes are sent through
+type SenderConfig struct {
+	// Server is the host:port of the SMTP server, STARTTLS is used when
+	// the server offers it
+	Server string
+	// Username and Password authenticate with PLAIN when the username
+	// is not empty, which needs TLS unless the server is local
+	Username string
+	Password string
+	// From is the sender address
+	From string
+	// Subject and Body are text/templates of the note, which they use
+	// as {{.Id}}, {{.Name}}, {{.Content}} and {{.Notebook}}
+	// They default to the name and the content
+	Subject string
+	Body    string
+}
+
+// Sender sends the notes rendered by its templates, it is the mailer
+// of the usecases
+type Sender struct {
+	config  SenderConfig
+	subject *template.Template
+	body    *template.Template
+}
+
+// NewSender fails when a template doesn't parse or the sender is not
+// an address
+func NewSender(config SenderConfig) (Sender, error) {
+	if config.Subject == "" {
+		config.Subject = "{{.Name}}"
+	}
+	if config.Body == "" {
+		config.Body = "{{.Content}}"
+	}
+	_, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return Sender{}, fmt.Errorf("mail from: %w", err)
+	}
+	subject, err := template.New("subject").Parse(config.Subject)
+	if err != nil {
+		return Sender{}, err
+	}
+	body, err := template.New("body").Parse(config.Body)
+	if err != nil {
+		return Sender{}, err
+	}
+	return Sender{config: config, subject: subject, body: body}, nil
+}
+
+func (s Sender) Send(to string, n note.Note) error {
+	from, err := mail.ParseAddress(s.config.From)
+	if err != nil {
+		return err
+	}
+	recipient, err := mail.ParseAddress(to)
+	if err != nil {
+		return err
+	}
+	message, err := s.message(from, recipient, n)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if s.config.Username != "" {
+		host, _, _ := net.SplitHostPort(s.config.Server)
+		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, host)
+	}
+	return smtp.SendMail(s.config.Server, auth, from.Address, []string{recipient.Address}, message)
+}
+
+// message renders the note as a plain text email
+func (s Sender) message(from *mail.Address, to *mail.Address, n note.Note) ([]byte, error) {
+	subject := strings.Builder{}
+	err := s.subject.Execute(&subject, n)
+	if err != nil {
+		return nil, err
+	}
+	body := bytes.Buffer{}
+	err = s.body.Execute(&body, n)
+	if err != nil {
+		return nil, err
+	}
+	out := bytes.Buffer{}
+	fmt.Fprintf(&out, "From: %s\r\n", from)
+	fmt.Fprintf(&out, "To: %s\r\n", to)
+	// the subject is a single line, encoding it also keeps line breaks
+	// out of the headers
+	fmt.Fprintf(&out, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())))
+	fmt.Fprintf(&out, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&out, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&out, "Content-Type: text/plain; charset=utf-8\r\n")
+	fmt.Fprintf(&out, "Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	w := quotedprintable.NewWriter(&out)
+	text := strings.ReplaceAll(body.String(), "\r\n", "\n")
+	_, err = w.Write([]byte(strings.ReplaceAll(text, "\n", "\r\n")))
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,287 @@
+// Package mqtt publishes the note events to an MQTT broker, so other
+// devices can react to note changes. It speaks just enough MQTT 3.1.1
+// to publish.
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"notes/internal/note"
+)
+
+// Config of the publisher
+type Config struct {
+	// Broker is the host:port of the broker, such as localhost:1883
+	Broker string
+	// Prefix of the topics, events are published to PREFIX/ID/KIND, such
+	// as notes/3/updated
+	Prefix string
+	// QoS is 0, at most once, or 1, at least once
+	QoS byte
+	// Retain asks the broker to keep the last event of each topic for
+	// new subscribers
+	Retain   bool
+	ClientId string
+	Username string
+	Password string
+}
+
+// Publisher is a subscriber of the note events, the events are queued
+// and published by a goroutine so a slow broker doesn't slow down the
+// commands
+type Publisher struct {
+	config Config
+	errors func(error)
+	queue  chan message
+	done   *sync.WaitGroup
+	// conn is only used by the goroutine
+	conn     net.Conn
+	reader   *bufio.Reader
+	packetId uint16
+}
+
+type message struct {
+	topic   string
+	payload []byte
+}
+
+type payload struct {
+	Event    note.EventKind `json:"event"`
+	Actor    string         `json:"actor"`
+	At       time.Time      `json:"at"`
+	Note     jsonNote       `json:"note"`
+	Previous *jsonNote      `json:"previous,omitempty"`
+}
+
+type jsonNote struct {
+	Id       note.Id       `json:"id"`
+	Name     note.Name     `json:"name"`
+	Content  note.Content  `json:"content"`
+	Notebook note.Notebook `json:"notebook,omitempty"`
+	Owner    note.UserId   `json:"owner,omitempty"`
+	Metadata note.Metadata `json:"metadata,omitempty"`
+	Length   note.Length   `json:"length"`
+}
+
+// New publishes to the broker of config, failures to publish are given
+// to errors
+func New(config Config, errors func(error)) *Publisher {
+	if config.Prefix == "" {
+		config.Prefix = "notes"
+	}
+	if config.ClientId == "" {
+		config.ClientId = "notes-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	p := &Publisher{config: config, errors: errors, queue: make(chan message, 100), done: &sync.WaitGroup{}}
+	p.done.Add(1)
+	go p.run()
+	return p
+}
+
+// topicKinds are the last level of the topics
+var topicKinds = map[note.EventKind]string{
+	note.Created:  "created",
+	note.Updated:  "updated",
+	note.Deleted:  "deleted",
+	note.Restored: "restored",
+	note.Renamed:  "renamed",
+	note.Moved:    "moved",
+}
+
+func (p *Publisher) Notify(e note.Event) {
+	body := payload{Event: e.Kind, Actor: e.Actor, At: e.At, Note: jsonNote(e.Note)}
+	// created notes have no previous version
+	if e.Previous.Id != 0 {
+		previous := jsonNote(e.Previous)
+		body.Previous = &previous
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		p.fail(err)
+		return
+	}
+	topic := fmt.Sprintf("%s/%d/%s", strings.TrimSuffix(p.config.Prefix, "/"), e.Note.Id, topicKinds[e.Kind])
+	select {
+	case p.queue <- message{topic: topic, payload: data}:
+	default:
+		p.fail(fmt.Errorf("queue full, event of note %d dropped", e.Note.Id))
+	}
+}
+
+func (p *Publisher) fail(err error) {
+	if p.errors != nil {
+		p.errors(fmt.Errorf("mqtt: %w", err))
+	}
+}
+
+// Close publishes the queued events and disconnects
+func (p *Publisher) Close() {
+	close(p.queue)
+	p.done.Wait()
+}
+
+// run publishes the queue, a message failing on a connection which was
+// open is tried again on a new connection
+func (p *Publisher) run() {
+	defer p.done.Done()
+	for m := range p.queue {
+		fresh := p.conn == nil
+		err := p.publish(m)
+		if err != nil && !fresh {
+			err = p.publish(m)
+		}
+		if err != nil {
+			p.fail(err)
+		}
+	}
+	if p.conn != nil {
+		p.conn.Write([]byte{0xe0, 0})
+		p.conn.Close()
+	}
+}
+
+func (p *Publisher) publish(m message) error {
+	if p.conn == nil {
+		err := p.connect()
+		if err != nil {
+			return err
+		}
+	}
+	err := p.send(m)
+	if err != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+	return err
+}
+
+// connect opens a clean session without keep alive, a dropped
+// connection is noticed on the next publish
+func (p *Publisher) connect() error {
+	conn, err := net.DialTimeout("tcp", p.config.Broker, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	flags := byte(0x02)
+	body := appendString(nil, "MQTT")
+	payload := appendString(nil, p.config.ClientId)
+	if p.config.Username != "" {
+		flags |= 0x80
+		payload = appendString(payload, p.config.Username)
+		if p.config.Password != "" {
+			flags |= 0x40
+			payload = appendString(payload, p.config.Password)
+		}
+	}
+	body = append(body, 4, flags, 0, 0)
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	_, err = conn.Write(packet(0x10, append(body, payload...)))
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	reader := bufio.NewReader(conn)
+	kind, ack, err := readPacket(reader)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if kind != 0x20 || len(ack) != 2 || ack[1] != 0 {
+		conn.Close()
+		return fmt.Errorf("connection refused by %s", p.config.Broker)
+	}
+	p.conn = conn
+	p.reader = reader
+	return nil
+}
+
+// send publishes a message and waits for its acknowledgement at QoS 1
+func (p *Publisher) send(m message) error {
+	header := byte(0x30)
+	if p.config.Retain {
+		header |= 0x01
+	}
+	body := appendString(nil, m.topic)
+	if p.config.QoS > 0 {
+		header |= 0x02
+		p.packetId++
+		if p.packetId == 0 {
+			p.packetId = 1
+		}
+		body = binary.BigEndian.AppendUint16(body, p.packetId)
+	}
+	p.conn.SetDeadline(time.Now().Add(10 * time.Second))
+	_, err := p.conn.Write(packet(header, append(body, m.payload...)))
+	if err != nil || p.config.QoS == 0 {
+		return err
+	}
+	for {
+		kind, ack, err := readPacket(p.reader)
+		if err != nil {
+			return err
+		}
+		if kind == 0x40 && len(ack) == 2 && binary.BigEndian.Uint16(ack) == p.packetId {
+			return nil
+		}
+	}
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// packet prefixes a body with its fixed header, the remaining length is
+// a variable length integer
+func packet(header byte, body []byte) []byte {
+	out := []byte{header}
+	length := len(body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		out = append(out, digit)
+		if length == 0 {
+			break
+		}
+	}
+	return append(out, body...)
+}
+
+// readPacket returns the type of a packet, without its flags, and its
+// body
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(digit&0x7f) * multiplier
+		multiplier *= 128
+		if digit&0x80 == 0 {
+			break
+		}
+		if i == 3 {
+			return 0, nil, fmt.Errorf("malformed packet")
+		}
+	}
+	body := make([]byte, length)
+	_, err = io.ReadFull(r, body)
+	return header & 0xf0, body, err
+}
@@ -0,0 +1,28 @@
+package note
+
+import "time"
+
+type EventKind string
+
+const (
+	Created  EventKind = "NoteCreated"
+	Updated  EventKind = "NoteUpdated"
+	Deleted  EventKind = "NoteDeleted"
+	Restored EventKind = "NoteRestored"
+	Renamed  EventKind = "NoteRenamed"
+	// Moved is a note put in another notebook, as when it is archived
+	Moved EventKind = "NoteMoved"
+	// Viewed is only recorded in the audit log, reading a note publishes
+	// no event
+	Viewed EventKind = "NoteViewed"
+)
+
+// Event tells what happened to a note. Previous is the note before the
+// change and is zero for a creation. Actor is who made the change.
+type Event struct {
+	Kind     EventKind
+	Note     Note
+	Previous Note
+	Actor    string
+	At       time.Time
+}
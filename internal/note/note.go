@@ -0,0 +1,127 @@
+// Package note holds the entities of the notes application: the note
+// itself, the errors reported about notes and the events published when
+// they change.
+package note
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type Id = int
+type Name = string
+type Content = string
+type Notebook = string
+
+// UserId is the id of a user account, see package user, 0 stands for no
+// account as when the server hosts a single person
+type UserId = int
+
+// Note is the main entity of the application. The zero note, with an Id
+// of 0, stands for a note that does not exist.
+// Owner is the user who created the note.
+type Note struct {
+	Id       Id
+	Name     Name
+	Content  Content
+	Notebook Notebook
+	Owner    UserId
+	Metadata Metadata `json:",omitempty"`
+	// Length is measured by the storage when the content is written
+	Length Length
+}
+
+// Length of a content, a sealed content has none as its words are
+// unknown
+type Length struct {
+	Words      int
+	Characters int
+	// ReadingMinutes is the time to read the words, rounded up
+	ReadingMinutes int
+}
+
+// WordsPerMinute is the reading speed of the reading times
+const WordsPerMinute = 200
+
+// LengthOf measures a content, the words are separated by spaces
+func LengthOf(content Content) Length {
+	words := len(strings.Fields(content))
+	return Length{
+		Words:          words,
+		Characters:     utf8.RuneCountInString(content),
+		ReadingMinutes: (words + WordsPerMinute - 1) / WordsPerMinute,
+	}
+}
+
+// Metadata is what is known of a note besides its content, by key, such
+// as its summary
+// It is replaced rather than changed, the notes read share it.
+type Metadata map[string]string
+
+// String lists the metadata by key, it is empty without any
+func (m Metadata) String() string {
+	keys := slices.Sorted(maps.Keys(m))
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + strconv.Quote(m[k])
+	}
+	return strings.Join(pairs, " ")
+}
+
+// With is a copy of the metadata with the value of a key
+func (m Metadata) With(key string, value string) Metadata {
+	copied := maps.Clone(m)
+	if copied == nil {
+		copied = Metadata{}
+	}
+	copied[key] = value
+	return copied
+}
+
+// Without is a copy of the metadata without a key
+func (m Metadata) Without(key string) Metadata {
+	copied := maps.Clone(m)
+	delete(copied, key)
+	return copied
+}
+
+// Colors are the palette of the labels of the notes and notebooks
+var Colors = []string{"red", "orange", "yellow", "green", "blue", "purple", "gray"}
+
+type List []Note
+
+// Summary is a note listed without all of its content, Preview is the
+// start of it and Size the length of the whole content in bytes
+type Summary struct {
+	Id       Id
+	Name     Name
+	Notebook Notebook
+	Owner    UserId
+	Preview  Content
+	Size     int
+	Length   Length
+	Color    string `json:",omitempty"`
+}
+
+// Domain errors
+// Usecases wrap them with the details of what went wrong, applications
+// use errors.Is to map them to their own error reporting
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrValidation = errors.New("invalid")
+	ErrConflict   = errors.New("conflict")
+	ErrForbidden  = errors.New("forbidden")
+	// ErrTooLarge is about a content exceeding a limit, such as the
+	// quota of a user
+	ErrTooLarge = errors.New("too large")
+	// ErrUnauthorized is about who runs a command, unknown or with the
+	// wrong password, rather than about notes
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrBusy is about a server too busy to run a command now, it may
+	// be run again later
+	ErrBusy = errors.New("busy")
+)
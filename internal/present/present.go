@@ -0,0 +1,137 @@
+// Package present writes the results of the usecases in an output format,
+// the applications choose the format of a result by its name, as the
+// --format of the REPL, or by its media type, as the Accept header of an
+// HTTP request.
+package present
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Presenter writes the results of the commands
+type Presenter interface {
+	Present(o any, w io.Writer)
+}
+
+// Format is a presenter known by its name and media type
+type Format struct {
+	Name      string
+	MediaType string
+	Presenter Presenter
+}
+
+var (
+	JSON  = Format{Name: "json", MediaType: "application/json", Presenter: jsonPresenter{}}
+	YAML  = Format{Name: "yaml", MediaType: "application/yaml", Presenter: yamlPresenter{}}
+	XML   = Format{Name: "xml", MediaType: "application/xml", Presenter: xmlPresenter{}}
+	Plain = Format{Name: "plain", MediaType: "text/plain; charset=utf-8", Presenter: plainPresenter{}}
+	Table = Format{Name: "table", MediaType: "text/x-table; charset=utf-8", Presenter: tablePresenter{}}
+)
+
+// Registry of the formats, the first one is the default
+type Registry struct {
+	formats []Format
+}
+
+// NewRegistry knows the formats, the first one is the default
+func NewRegistry(formats ...Format) Registry {
+	return Registry{}.With(formats...)
+}
+
+// Default knows the formats of this package, json first
+func Default() Registry {
+	return NewRegistry(JSON, YAML, XML, Plain, Table)
+}
+
+// With adds formats to a copy of the registry, a format replaces the one
+// of the same name
+func (r Registry) With(formats ...Format) Registry {
+	r.formats = slices.Clone(r.formats)
+	for _, f := range formats {
+		k := slices.IndexFunc(r.formats, func(known Format) bool { return known.Name == f.Name })
+		if k < 0 {
+			r.formats = append(r.formats, f)
+			continue
+		}
+		r.formats[k] = f
+	}
+	return r
+}
+
+// Names of the formats, the default first
+func (r Registry) Names() []string {
+	names := []string{}
+	for _, f := range r.formats {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// Lookup finds a format by its name
+func (r Registry) Lookup(name string) (Format, bool) {
+	for _, f := range r.formats {
+		if f.Name == strings.ToLower(name) {
+			return f, true
+		}
+	}
+	return Format{}, false
+}
+
+// Negotiate chooses the format an Accept header prefers, by the quality
+// of its media ranges, the default one when it accepts none of them
+func (r Registry) Negotiate(accept string) Format {
+	type mediaRange struct {
+		mediaType string
+		quality   float64
+	}
+	ranges := []mediaRange{}
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		m := mediaRange{mediaType: strings.ToLower(strings.TrimSpace(params[0])), quality: 1}
+		for _, p := range params[1:] {
+			if q, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				m.quality, _ = strconv.ParseFloat(q, 64)
+			}
+		}
+		if m.mediaType != "" && m.quality > 0 {
+			ranges = append(ranges, m)
+		}
+	}
+	slices.SortStableFunc(ranges, func(a, b mediaRange) int { return cmp.Compare(b.quality, a.quality) })
+	for _, m := range ranges {
+		for _, f := range r.formats {
+			if accepts(m.mediaType, f.MediaType) {
+				return f
+			}
+		}
+	}
+	return r.formats[0]
+}
+
+// accepts tells whether a media range, such as text/*, matches a media
+// type, without its parameters
+func accepts(mediaRange string, mediaType string) bool {
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	kind, _, _ := strings.Cut(mediaType, "/")
+	return mediaRange == "*/*" || mediaRange == kind+"/*" || mediaRange == strings.TrimSpace(mediaType)
+}
+
+// jsonPresenter encodes the results as json
+type jsonPresenter struct{}
+
+func (p jsonPresenter) Present(o any, w io.Writer) {
+	json.NewEncoder(w).Encode(o)
+}
+
+// plainPresenter prints the results as they are
+type plainPresenter struct{}
+
+func (p plainPresenter) Present(o any, w io.Writer) {
+	fmt.Fprintln(w, o)
+}
@@ -0,0 +1,128 @@
+package present
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+// tablePresenter writes the first list of a result as a table, one row
+// by item, the results without a list are a table of their fields
+// The nested fields are columns named by their path, such as Note.Id,
+// and the cells are cut to their first line.
+type tablePresenter struct{}
+
+// cellLength is the number of characters of a cell
+const cellLength = 40
+
+func (p tablePresenter) Present(o any, w io.Writer) {
+	v, err := tree(o)
+	if err != nil {
+		plainPresenter{}.Present(o, w)
+		return
+	}
+	t := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	defer t.Flush()
+	items, ok := listOf(v)
+	if !ok {
+		cells := object{}
+		flatten("", v, &cells)
+		for _, c := range cells {
+			fmt.Fprintf(t, "%s\t%s\n", c.key, cell(c.value))
+		}
+		return
+	}
+	columns := []string{}
+	rows := []object{}
+	for _, item := range items {
+		cells := object{}
+		flatten("", item, &cells)
+		for _, c := range cells {
+			if !slices.Contains(columns, c.key) {
+				columns = append(columns, c.key)
+			}
+		}
+		rows = append(rows, cells)
+	}
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Fprintln(t, strings.Join(columns, "\t"))
+	for _, cells := range rows {
+		line := []string{}
+		for _, column := range columns {
+			k := slices.IndexFunc(cells, func(c field) bool { return c.key == column })
+			if k < 0 {
+				line = append(line, "")
+				continue
+			}
+			line = append(line, cell(cells[k].value))
+		}
+		fmt.Fprintln(t, strings.Join(line, "\t"))
+	}
+}
+
+// listOf finds the first list of a result, depth first
+func listOf(v any) ([]any, bool) {
+	switch v := v.(type) {
+	case []any:
+		return v, true
+	case object:
+		for _, f := range v {
+			if items, ok := listOf(f.value); ok {
+				return items, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// flatten lists the fields of the nested objects under their path, the
+// lists are kept as cells
+func flatten(path string, v any, cells *object) {
+	o, ok := v.(object)
+	if !ok {
+		*cells = append(*cells, field{key: path, value: v})
+		return
+	}
+	for _, f := range o {
+		key := f.key
+		if path != "" {
+			key = path + "." + f.key
+		}
+		flatten(key, f.value, cells)
+	}
+}
+
+// cell is the text of a value cut to its first line, the lists of
+// scalars are joined by commas, the other lists are their length
+func cell(v any) string {
+	text := ""
+	switch v := v.(type) {
+	case nil:
+	case []any:
+		values := []string{}
+		for _, item := range v {
+			switch item.(type) {
+			case object, []any:
+				return fmt.Sprintf("[%d]", len(v))
+			}
+			values = append(values, fmt.Sprint(item))
+		}
+		text = strings.Join(values, ", ")
+	default:
+		text = fmt.Sprint(v)
+	}
+	line, _, cut := strings.Cut(text, "\n")
+	line = strings.ReplaceAll(line, "\t", " ")
+	if utf8.RuneCountInString(line) > cellLength {
+		return string([]rune(line)[:cellLength]) + "…"
+	}
+	if cut {
+		return line + "…"
+	}
+	return line
+}
@@ -0,0 +1,66 @@
+package present
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// The results are written from their json, so every format shows the
+// same fields under the same names, a value of the tree is an object, a
+// []any, a string, a json.Number, a bool or nil
+
+// field of an object
+type field struct {
+	key   string
+	value any
+}
+
+// object keeps its fields in the order of the json
+type object []field
+
+// tree decodes the json of a result
+func tree(o any) (any, error) {
+	data, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	return decode(d)
+}
+
+func decode(d *json.Decoder) (any, error) {
+	token, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		o := object{}
+		for d.More() {
+			key, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decode(d)
+			if err != nil {
+				return nil, err
+			}
+			o = append(o, field{key: key.(string), value: value})
+		}
+		_, err := d.Token()
+		return o, err
+	case json.Delim('['):
+		a := []any{}
+		for d.More() {
+			value, err := decode(d)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, value)
+		}
+		_, err := d.Token()
+		return a, err
+	}
+	return token, nil
+}
@@ -0,0 +1,70 @@
+package present
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// xmlPresenter writes the results as an xml document named after their
+// type, the fields are elements and the items of a list are item
+// elements
+// A key which is no xml name, as those of the metadata, is the key
+// attribute of an entry element.
+type xmlPresenter struct{}
+
+func (p xmlPresenter) Present(o any, w io.Writer) {
+	v, err := tree(o)
+	if err != nil {
+		plainPresenter{}.Present(o, w)
+		return
+	}
+	name := "result"
+	if t := reflect.TypeOf(o); t != nil && t.Name() != "" {
+		name = t.Name()
+	}
+	io.WriteString(w, xml.Header)
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	writeXml(e, name, v)
+	e.Close()
+	fmt.Fprintln(w)
+}
+
+func writeXml(e *xml.Encoder, name string, v any) {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if !xmlName(name) {
+		start = xml.StartElement{Name: xml.Name{Local: "entry"}, Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}}}
+	}
+	e.EncodeToken(start)
+	switch v := v.(type) {
+	case object:
+		for _, f := range v {
+			writeXml(e, f.key, f.value)
+		}
+	case []any:
+		for _, item := range v {
+			writeXml(e, "item", item)
+		}
+	case nil:
+	default:
+		e.EncodeToken(xml.CharData(fmt.Sprint(v)))
+	}
+	e.EncodeToken(start.End())
+}
+
+// xmlName tells whether a key can name an element
+func xmlName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+	for k, r := range name {
+		if !unicode.IsLetter(r) && r != '_' && (k == 0 || !unicode.IsDigit(r) && r != '-' && r != '.') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,120 @@
+package present
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// yamlPresenter writes the results as a yaml document, the contents with
+// several lines as literal blocks
+type yamlPresenter struct{}
+
+func (p yamlPresenter) Present(o any, w io.Writer) {
+	v, err := tree(o)
+	if err != nil {
+		plainPresenter{}.Present(o, w)
+		return
+	}
+	var lines []string
+	if inline, ok := yamlInline(v); ok {
+		lines = []string{inline}
+	} else {
+		lines = yamlLines(v)
+	}
+	fmt.Fprintln(w, strings.Join(lines, "\n"))
+}
+
+// yamlLines are the lines of an object or an array, without indentation
+func yamlLines(v any) []string {
+	lines := []string{}
+	switch v := v.(type) {
+	case object:
+		for _, f := range v {
+			lines = append(lines, yamlEntry(yamlString(f.key)+":", f.value)...)
+		}
+	case []any:
+		for _, item := range v {
+			lines = append(lines, yamlEntry("-", item)...)
+		}
+	}
+	return lines
+}
+
+// yamlEntry is a value after its key or its dash
+func yamlEntry(prefix string, v any) []string {
+	if s, ok := v.(string); ok {
+		if header, body, ok := yamlLiteral(s); ok {
+			return append([]string{prefix + " " + header}, indented(body)...)
+		}
+	}
+	if inline, ok := yamlInline(v); ok {
+		return []string{prefix + " " + inline}
+	}
+	lines := yamlLines(v)
+	if prefix == "-" {
+		return append([]string{"- " + lines[0]}, indented(lines[1:])...)
+	}
+	return append([]string{prefix}, indented(lines)...)
+}
+
+func indented(lines []string) []string {
+	for k, l := range lines {
+		if l != "" {
+			lines[k] = "  " + l
+		}
+	}
+	return lines
+}
+
+// yamlInline writes the scalars and the empty objects and arrays on the
+// line of their key
+func yamlInline(v any) (string, bool) {
+	switch v := v.(type) {
+	case nil:
+		return "null", true
+	case bool:
+		return fmt.Sprint(v), true
+	case json.Number:
+		return v.String(), true
+	case string:
+		return yamlString(v), true
+	case object:
+		return "{}", len(v) == 0
+	case []any:
+		return "[]", len(v) == 0
+	}
+	return "", false
+}
+
+// yamlPlain are the strings written without quotes
+var yamlPlain = regexp.MustCompile(`^[A-Za-z_/][A-Za-z0-9_ ./@+-]*$`)
+
+// yamlString quotes a string unless it can't be taken for something else,
+// the json quotes are those of yaml
+func yamlString(s string) string {
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "y", "n":
+	default:
+		if yamlPlain.MatchString(s) && !strings.HasSuffix(s, " ") {
+			return s
+		}
+	}
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
+
+// yamlLiteral is the header and lines of a literal block, for the strings
+// of several lines which keep their spacing as a block
+func yamlLiteral(s string) (string, []string, bool) {
+	if !strings.Contains(strings.TrimSuffix(s, "\n"), "\n") || strings.ContainsAny(s, "\r\t") || strings.HasPrefix(s, " ") || strings.HasSuffix(s, "\n\n") {
+		return "", nil, false
+	}
+	header := "|-"
+	if strings.HasSuffix(s, "\n") {
+		header = "|"
+	}
+	return header, strings.Split(strings.TrimSuffix(s, "\n"), "\n"), true
+}
@@ -0,0 +1,222 @@
+package query
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenPhrase
+	tokenOpen
+	tokenClose
+)
+
+// token is a word, which may be field:value, a quoted phrase or a
+// parenthesis
+type token struct {
+	kind tokenKind
+	text string
+	// field and value of field:value and field:"phrase"
+	field string
+	value string
+}
+
+var fields = []string{"name", "content", "notebook", "tag", "created", "updated", "words", "minutes"}
+
+// tokenize splits a query, an unterminated quote runs to the end
+func tokenize(s string) []token {
+	tokens := []token{}
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if s == "" {
+			return tokens
+		}
+		switch s[0] {
+		case '(':
+			tokens = append(tokens, token{kind: tokenOpen, text: "("})
+			s = s[1:]
+			continue
+		case ')':
+			tokens = append(tokens, token{kind: tokenClose, text: ")"})
+			s = s[1:]
+			continue
+		case '"':
+			phrase, rest := quoted(s)
+			tokens = append(tokens, token{kind: tokenPhrase, text: `"` + phrase + `"`, value: phrase})
+			s = rest
+			continue
+		}
+		end := strings.IndexAny(s, " \t\r\n()")
+		if end < 0 {
+			end = len(s)
+		}
+		t := token{kind: tokenWord, text: s[:end]}
+		s = s[end:]
+		if field, value, ok := strings.Cut(t.text, ":"); ok && slices.Contains(fields, strings.ToLower(field)) {
+			t.field = strings.ToLower(field)
+			t.value = value
+			// field:"a phrase" runs to the closing quote
+			if strings.HasPrefix(value, `"`) {
+				t.value, s = quoted(value + s)
+			}
+		}
+		tokens = append(tokens, t)
+	}
+}
+
+// quoted reads the phrase of s which starts with a quote
+func quoted(s string) (string, string) {
+	phrase, rest, ok := strings.Cut(s[1:], `"`)
+	if !ok {
+		return s[1:], ""
+	}
+	return phrase, rest
+}
+
+// parser is a recursive descent parser of the tokens, OR binds less
+// than AND which binds less than NOT
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) operator(name string) bool {
+	t, ok := p.peek()
+	if ok && t.kind == tokenWord && t.field == "" && t.text == name {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.operator("OR") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = or{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind == tokenClose || (t.kind == tokenWord && t.field == "" && t.text == "OR") {
+			return left, nil
+		}
+		p.operator("AND")
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = and{left, right}
+	}
+}
+
+func (p *parser) unary() (node, error) {
+	if p.operator("NOT") {
+		n, err := p.unary()
+		return not{n}, err
+	}
+	t, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("unexpected end")
+	}
+	p.pos++
+	switch t.kind {
+	case tokenOpen:
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		t, ok := p.peek()
+		if !ok || t.kind != tokenClose {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return n, nil
+	case tokenClose:
+		return nil, fmt.Errorf("unexpected )")
+	}
+	// -word excludes what the word would match
+	if rest := tokenize(strings.TrimPrefix(t.text, "-")); t.kind == tokenWord && t.field == "" && len(t.text) > 1 && t.text[0] == '-' && len(rest) == 1 {
+		n, err := term(rest[0])
+		return not{n}, err
+	}
+	return term(t)
+}
+
+func term(t token) (node, error) {
+	switch {
+	case t.kind == tokenPhrase && len(Words(t.value)) == 0:
+		return nil, fmt.Errorf("empty phrase %s", t.text)
+	case t.kind == tokenPhrase:
+		return text{words: Words(t.value)}, nil
+	case t.field != "":
+		return fieldNode(t)
+	default:
+		return word(t.text), nil
+	}
+}
+
+func fieldNode(t token) (node, error) {
+	if strings.TrimSpace(t.value) == "" {
+		return nil, fmt.Errorf("%s: needs a value", t.field)
+	}
+	switch t.field {
+	case "notebook":
+		return notebook(t.value), nil
+	case "tag":
+		return tag(strings.TrimPrefix(t.value, "#")), nil
+	case "created", "updated":
+		op, value := comparison(t.value)
+		day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q is not a date such as 2024-01-31", t.field, value)
+		}
+		return date{field: t.field, op: op, day: day}, nil
+	case "words", "minutes":
+		op, value := comparison(t.value)
+		count, err := strconv.Atoi(value)
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("%s: %q is not a number", t.field, value)
+		}
+		return length{field: t.field, op: op, count: count}, nil
+	default:
+		return text{field: t.field, words: Words(t.value)}, nil
+	}
+}
+
+// comparison splits the operator of a field compared, such as >= in
+// created:>=2024-01-01, from its value
+func comparison(value string) (string, string) {
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if strings.HasPrefix(value, op) {
+			return op, value[len(op):]
+		}
+	}
+	return "", value
+}
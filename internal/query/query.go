@@ -0,0 +1,306 @@
+// Package query parses the advanced search syntax, such as
+//
+//	tag:work AND (name:meeting OR content:"action items") created:>2024-01-01
+//
+// into a tree of conditions a note matches or not.
+// Bare words are left to the search index, phrases in quotes have to
+// appear as is, name:, content:, notebook: and tag: look at one field,
+// a tag being a #word of the content, and created: and updated: compare
+// the day of a change with >, >=, <, <= or, without them, the same day.
+// words: and minutes: compare the number of words and the reading time
+// of the content the same way, as in words:>500.
+// Conditions are combined with AND, which is implied between them, OR,
+// NOT or a leading -, and parentheses.
+package query
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"notes/internal/note"
+)
+
+// Document is what a query is matched against, the times are zero when
+// unknown
+type Document struct {
+	Note    note.Note
+	Created time.Time
+	Updated time.Time
+}
+
+// Found tells whether the index found a note for a bare word
+type Found func(word string, id note.Id) bool
+
+// Hit is a note an index found and how relevant it is, the higher the
+// score the better
+type Hit struct {
+	Id    note.Id
+	Score float64
+}
+
+// Query is a parsed query
+type Query struct {
+	root node
+}
+
+// Parse parses a query, a malformed one fails with note.ErrValidation
+func Parse(s string) (Query, error) {
+	p := parser{tokens: tokenize(s)}
+	if len(p.tokens) == 0 {
+		return Query{}, fmt.Errorf("%w query: nothing to search", note.ErrValidation)
+	}
+	root, err := p.or()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %s", p.tokens[p.pos].text)
+	}
+	if err != nil {
+		return Query{}, fmt.Errorf("%w query: %v", note.ErrValidation, err)
+	}
+	return Query{root: root}, nil
+}
+
+// Plain tells whether the query is only bare words, which the index
+// can search by itself
+func (q Query) Plain() bool {
+	return plain(q.root)
+}
+
+func plain(n node) bool {
+	switch n := n.(type) {
+	case word:
+		return true
+	case and:
+		return plain(n.left) && plain(n.right)
+	default:
+		return false
+	}
+}
+
+// Words are the bare words of the query, to be searched in the index
+func (q Query) Words() []string {
+	words := []string{}
+	walk(q.root, func(n node) {
+		if w, ok := n.(word); ok {
+			words = append(words, string(w))
+		}
+	})
+	return words
+}
+
+// Dated tells whether the query compares the times of the documents, so
+// they are only looked up when needed
+func (q Query) Dated() bool {
+	dated := false
+	walk(q.root, func(n node) {
+		if _, ok := n.(date); ok {
+			dated = true
+		}
+	})
+	return dated
+}
+
+func walk(n node, f func(node)) {
+	f(n)
+	switch n := n.(type) {
+	case and:
+		walk(n.left, f)
+		walk(n.right, f)
+	case or:
+		walk(n.left, f)
+		walk(n.right, f)
+	case not:
+		walk(n.node, f)
+	}
+}
+
+// Match tells whether a document matches the query, found answers for
+// its bare words
+func (q Query) Match(d Document, found Found) bool {
+	return q.root.match(d, found)
+}
+
+// node is a condition of the tree
+type node interface {
+	match(d Document, found Found) bool
+}
+
+type and struct{ left, right node }
+
+func (n and) match(d Document, found Found) bool {
+	return n.left.match(d, found) && n.right.match(d, found)
+}
+
+type or struct{ left, right node }
+
+func (n or) match(d Document, found Found) bool {
+	return n.left.match(d, found) || n.right.match(d, found)
+}
+
+type not struct{ node node }
+
+func (n not) match(d Document, found Found) bool {
+	return !n.node.match(d, found)
+}
+
+// word is a bare word, it is given to the index as typed
+type word string
+
+func (n word) match(d Document, found Found) bool {
+	return found(string(n), d.Note.Id)
+}
+
+// text is a phrase, or a word of a field, whose words have to follow
+// each other in the field, or in the name or content without a field
+type text struct {
+	field string
+	words []string
+}
+
+func (n text) match(d Document, found Found) bool {
+	switch n.field {
+	case "name":
+		return contains(Words(d.Note.Name), n.words)
+	case "content":
+		return contains(Words(d.Note.Content), n.words)
+	default:
+		return contains(Words(d.Note.Name), n.words) || contains(Words(d.Note.Content), n.words)
+	}
+}
+
+// contains tells whether phrase appears in words
+func contains(words []string, phrase []string) bool {
+	if len(phrase) == 0 {
+		return true
+	}
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		if slices.Equal(words[i:i+len(phrase)], phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+type notebook string
+
+func (n notebook) match(d Document, found Found) bool {
+	return strings.EqualFold(d.Note.Notebook, string(n))
+}
+
+type tag string
+
+func (n tag) match(d Document, found Found) bool {
+	for _, t := range Tags(d.Note.Content) {
+		if strings.EqualFold(t, string(n)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tags are the #words of a text, without their #
+func Tags(s string) []string {
+	tags := []string{}
+	for _, t := range tagsAt(s) {
+		tags = append(tags, t.word)
+	}
+	return tags
+}
+
+// Due is the date of the first due: word of a text, as in due:2026-10-20
+// or due:2026-10-20T09:30, a day alone is due at its start
+// The dates are in the local time.
+func Due(s string) (time.Time, bool) {
+	for _, word := range strings.Fields(s) {
+		date, ok := strings.CutPrefix(strings.ToLower(word), "due:")
+		if !ok {
+			continue
+		}
+		date = strings.TrimRight(date, ".,;:!?)")
+		for _, layout := range []string{"2006-01-02t15:04", "2006-01-02"} {
+			if t, err := time.ParseInLocation(layout, date, time.Local); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// tagsAt finds the tags of a text, their offsets include the #
+func tagsAt(s string) []wordAt {
+	tags := []wordAt{}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '#' || (i > 0 && !unicode.IsSpace(rune(s[i-1]))) {
+			continue
+		}
+		end := i + 1 + strings.IndexFunc(s[i+1:], func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_'
+		})
+		if end == i {
+			end = len(s)
+		}
+		if t := strings.TrimRight(s[i+1:end], "-_"); t != "" {
+			tags = append(tags, wordAt{t, i, i + 1 + len(t)})
+		}
+	}
+	return tags
+}
+
+// date compares the day of a change with day, the start of a day
+type date struct {
+	field string
+	op    string
+	day   time.Time
+}
+
+func (n date) match(d Document, found Found) bool {
+	t := d.Created
+	if n.field == "updated" {
+		t = d.Updated
+	}
+	if t.IsZero() {
+		return false
+	}
+	next := n.day.AddDate(0, 0, 1)
+	switch n.op {
+	case ">":
+		return !t.Before(next)
+	case ">=":
+		return !t.Before(n.day)
+	case "<":
+		return t.Before(n.day)
+	case "<=":
+		return t.Before(next)
+	default:
+		return !t.Before(n.day) && t.Before(next)
+	}
+}
+
+// length compares the number of words or the reading minutes of a
+// content with count
+type length struct {
+	field string
+	op    string
+	count int
+}
+
+func (n length) match(d Document, found Found) bool {
+	value := d.Note.Length.Words
+	if n.field == "minutes" {
+		value = d.Note.Length.ReadingMinutes
+	}
+	switch n.op {
+	case ">":
+		return value > n.count
+	case ">=":
+		return value >= n.count
+	case "<":
+		return value < n.count
+	case "<=":
+		return value <= n.count
+	default:
+		return value == n.count
+	}
+}
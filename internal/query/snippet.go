@@ -0,0 +1,233 @@
+package query
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"notes/internal/note"
+)
+
+// Span is where a query matched a field of a note, name or content,
+// between byte offsets
+type Span struct {
+	Field string
+	Start int
+	End   int
+}
+
+// Snippet is an extract of a field of a note showing why it matched,
+// Matches are the byte offsets of the matches in Text
+type Snippet struct {
+	NoteId  note.Id
+	Field   string
+	Text    string
+	Matches [][2]int
+}
+
+// context is the number of bytes of content kept around a match
+const context = 40
+
+// maxSnippets of the content of a note
+const maxSnippets = 3
+
+// Words splits a text in lower case words, punctuation separates them
+func Words(s string) []string {
+	words := []string{}
+	for _, w := range wordsAt(s) {
+		words = append(words, w.word)
+	}
+	return words
+}
+
+// wordAt is a lower case word and its offsets in the text
+type wordAt struct {
+	word  string
+	start int
+	end   int
+}
+
+func wordsAt(s string) []wordAt {
+	words := []wordAt{}
+	start := -1
+	for i, r := range s {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			words = append(words, wordAt{strings.ToLower(s[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, wordAt{strings.ToLower(s[start:]), start, len(s)})
+	}
+	return words
+}
+
+// Find returns the spans of the words of a field for which match is
+// true
+func Find(field string, text string, match func(word string) bool) []Span {
+	spans := []Span{}
+	for _, w := range wordsAt(text) {
+		if match(w.word) {
+			spans = append(spans, Span{field, w.start, w.end})
+		}
+	}
+	return spans
+}
+
+// Spans tells where the query matched a document, highlight gives the
+// spans of a bare word, as the index matched it
+// Conditions under a NOT match nothing to show.
+func (q Query) Spans(d Document, highlight func(word string) []Span) []Span {
+	spans := []Span{}
+	var visit func(n node)
+	visit = func(n node) {
+		switch n := n.(type) {
+		case and:
+			visit(n.left)
+			visit(n.right)
+		case or:
+			visit(n.left)
+			visit(n.right)
+		case word:
+			spans = append(spans, highlight(string(n))...)
+		case text:
+			if n.field != "content" {
+				spans = append(spans, phraseSpans("name", d.Note.Name, n.words)...)
+			}
+			if n.field != "name" {
+				spans = append(spans, phraseSpans("content", d.Note.Content, n.words)...)
+			}
+		case tag:
+			for _, t := range tagsAt(d.Note.Content) {
+				if strings.EqualFold(t.word, string(n)) {
+					spans = append(spans, Span{"content", t.start, t.end})
+				}
+			}
+		}
+	}
+	visit(q.root)
+	return spans
+}
+
+// phraseSpans are the spans of each occurrence of phrase in a field
+func phraseSpans(field string, text string, phrase []string) []Span {
+	spans := []Span{}
+	words := wordsAt(text)
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		match := true
+		for j, p := range phrase {
+			if words[i+j].word != p {
+				match = false
+				break
+			}
+		}
+		if match {
+			spans = append(spans, Span{field, words[i].start, words[i+len(phrase)-1].end})
+		}
+	}
+	return spans
+}
+
+// Snippets extract the fields of a note around spans, the name is kept
+// whole and the content is cut around its matches, with "…" where it
+// is cut
+func Snippets(n note.Note, spans []Span) []Snippet {
+	snippets := []Snippet{}
+	name := merge(spans, "name")
+	if len(name) > 0 {
+		snippets = append(snippets, Snippet{NoteId: n.Id, Field: "name", Text: oneLine(n.Name), Matches: offsets(name, 0)})
+	}
+	content := merge(spans, "content")
+	for i := 0; len(content) > 0 && i < maxSnippets; i++ {
+		start := wordStart(n.Content, content[0].Start-context)
+		end := wordEnd(n.Content, content[0].End+context)
+		// matches close to each other share a snippet
+		count := 1
+		for count < len(content) && content[count].Start < end {
+			end = max(end, wordEnd(n.Content, content[count].End+context))
+			count++
+		}
+		text, shift := n.Content[start:end], -start
+		if start > 0 {
+			text, shift = "…"+text, shift+len("…")
+		}
+		if end < len(n.Content) {
+			text += "…"
+		}
+		snippets = append(snippets, Snippet{NoteId: n.Id, Field: "content", Text: oneLine(text), Matches: offsets(content[:count], shift)})
+		content = content[count:]
+	}
+	return snippets
+}
+
+// merge sorts the spans of a field and joins those which overlap
+func merge(spans []Span, field string) []Span {
+	merged := []Span{}
+	for _, s := range spans {
+		if s.Field == field {
+			merged = append(merged, s)
+		}
+	}
+	slices.SortFunc(merged, func(a, b Span) int { return a.Start - b.Start })
+	out := []Span{}
+	for _, s := range merged {
+		if len(out) > 0 && s.Start <= out[len(out)-1].End {
+			out[len(out)-1].End = max(out[len(out)-1].End, s.End)
+			continue
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func offsets(spans []Span, shift int) [][2]int {
+	out := [][2]int{}
+	for _, s := range spans {
+		out = append(out, [2]int{s.Start + shift, s.End + shift})
+	}
+	return out
+}
+
+// wordStart moves an offset back to the start of its word, so snippets
+// don't start in the middle of one
+func wordStart(s string, i int) int {
+	if i <= 0 {
+		return 0
+	}
+	for i > 0 && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	if j := strings.LastIndexAny(s[:i], " \n\t"); j >= 0 && i-j < context/2 {
+		return j + 1
+	}
+	return i
+}
+
+// wordEnd moves an offset forward to the end of its word
+func wordEnd(s string, i int) int {
+	if i >= len(s) {
+		return len(s)
+	}
+	for i < len(s) && !utf8.RuneStart(s[i]) {
+		i++
+	}
+	if j := strings.IndexAny(s[i:], " \n\t"); j >= 0 && j < context/2 {
+		return i + j
+	}
+	return i
+}
+
+// oneLine replaces the line breaks, byte for byte so the offsets hold
+func oneLine(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '\t' {
+			return ' '
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,84 @@
+package reminder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+
+	"notes/internal/note"
+	"notes/internal/usecase"
+)
+
+// Desktop shows the reminders as desktop notifications, with
+// notify-send on Linux and osascript on macOS
+type Desktop struct{}
+
+func (Desktop) Notify(r usecase.Reminder) error {
+	title := string(r.Note.Name)
+	body := "Due " + r.Due.Format("Monday 2 January 15:04")
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", strconv.Quote(body), strconv.Quote(title))
+		cmd = exec.Command("osascript", "-e", script)
+	case "windows", "plan9":
+		return fmt.Errorf("no desktop notifications on %s", runtime.GOOS)
+	default:
+		cmd = exec.Command("notify-send", "--app-name=notes", title, body)
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(output))
+	}
+	return nil
+}
+
+// Webhook posts the reminders as json to a URL, which must answer with
+// a 2xx status
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+type webhookReminder struct {
+	Id       note.Id       `json:"id"`
+	Name     note.Name     `json:"name"`
+	Notebook note.Notebook `json:"notebook,omitempty"`
+	Owner    note.UserId   `json:"owner,omitempty"`
+	Due      time.Time     `json:"due"`
+}
+
+func (w Webhook) Notify(r usecase.Reminder) error {
+	body, err := json.Marshal(webhookReminder{Id: r.Note.Id, Name: r.Note.Name, Notebook: r.Note.Notebook, Owner: r.Note.Owner, Due: r.Due})
+	if err != nil {
+		return err
+	}
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Post(w.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+// Email sends the notes falling due to an address
+type Email struct {
+	Mailer usecase.Mailer
+	To     string
+}
+
+func (e Email) Notify(r usecase.Reminder) error {
+	return e.Mailer.Send(e.To, r.Note)
+}
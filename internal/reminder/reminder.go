@@ -0,0 +1,96 @@
+// Package reminder tells when notes fall due. A note is due at the date
+// of a due: word of its content, as in due:2026-10-20T09:30, and the
+// scheduler hands it to notifiers at that time.
+package reminder
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"notes/internal/usecase"
+)
+
+// Notifier reminds of a note falling due
+type Notifier interface {
+	Notify(usecase.Reminder) error
+}
+
+// NotifierFunc lets a plain function be a notifier
+type NotifierFunc func(usecase.Reminder) error
+
+func (f NotifierFunc) Notify(r usecase.Reminder) error {
+	return f(r)
+}
+
+// Scheduler checks the due dates every interval and gives the notes
+// which fell due since the last check to every notifier
+// The notes due before the scheduler started are not dispatched, and a
+// note is dispatched again when its due date is moved forward.
+type Scheduler struct {
+	usecase   usecase.Usecase
+	clock     usecase.Clock
+	interval  time.Duration
+	notifiers []Notifier
+	errors    func(error)
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+// NewScheduler checks every minute when interval is 0, the notifiers
+// failing are given to errors
+func NewScheduler(u usecase.Usecase, clock usecase.Clock, interval time.Duration, notifiers []Notifier, errors func(error)) Scheduler {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return Scheduler{usecase: u, clock: clock, interval: interval, notifiers: notifiers, errors: errors, ctx: ctx, cancel: cancel}
+}
+
+// Run checks the due dates until Stop is called
+func (s Scheduler) Run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	since := s.clock.Now()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now := s.clock.Now()
+		s.dispatch(since, now)
+		since = now
+	}
+}
+
+// Stop ends Run
+func (s Scheduler) Stop() {
+	s.cancel()
+}
+
+// dispatch notifies the notes due in (since, now], of every user
+func (s Scheduler) dispatch(since time.Time, now time.Time) {
+	result, err := s.usecase.Due.Execute(usecase.DueMessage{
+		Context: usecase.Context{Actor: "reminder"},
+		After:   since,
+		Before:  now,
+	})
+	if err != nil {
+		s.fail(err)
+		return
+	}
+	for _, r := range result.Reminders {
+		for _, n := range s.notifiers {
+			if err := n.Notify(r); err != nil {
+				s.fail(fmt.Errorf("note %d: %w", r.Note.Id, err))
+			}
+		}
+	}
+}
+
+func (s Scheduler) fail(err error) {
+	if s.errors != nil {
+		s.errors(fmt.Errorf("reminder: %w", err))
+	}
+}
@@ -0,0 +1,144 @@
+package remote
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"notes/internal/note"
+	"notes/internal/query"
+	"notes/internal/usecase"
+)
+
+// ChangeKind is what applying a change does to a note of the server
+type ChangeKind string
+
+const (
+	Create ChangeKind = "create"
+	Update ChangeKind = "update"
+	Delete ChangeKind = "delete"
+)
+
+// Change makes a note of the server match the manifest
+type Change struct {
+	Kind ChangeKind
+	// Note is the note of the server, zero for a creation
+	Note note.Note
+	// Want is the note as the manifest declares it, zero for a deletion
+	Want note.Note
+	// Lines compare the contents of an update, they are empty when only
+	// its notebook changes
+	Lines []usecase.DiffLine
+}
+
+// Moved tells an update moves the note to another notebook
+func (c Change) Moved() bool {
+	return c.Kind == Update && c.Note.Notebook != c.Want.Notebook
+}
+
+// Name is the name of the note of the change
+func (c Change) Name() note.Name {
+	if c.Kind == Delete {
+		return c.Note.Name
+	}
+	return c.Want.Name
+}
+
+// contentOf is the content of a note of the manifest, with the tags it
+// lacks on a last line
+func contentOf(n ManifestNote) note.Content {
+	missing := []string{}
+	present := query.Tags(n.Content)
+	for _, t := range n.Tags {
+		t = strings.TrimPrefix(t, "#")
+		if !slices.Contains(present, t) && !slices.Contains(missing, "#"+t) {
+			missing = append(missing, "#"+t)
+		}
+	}
+	if len(missing) == 0 {
+		return n.Content
+	}
+	content := strings.TrimRight(n.Content, "\n")
+	if content != "" {
+		content += "\n\n"
+	}
+	return content + strings.Join(missing, " ") + "\n"
+}
+
+// Plan lists the changes making the notes of the managed notebooks of
+// the server match a manifest, the creations and updates in the order of
+// the manifest and then the deletions
+// A note of the manifest is the note of the same name in any managed
+// notebook, the other notes of the same name are deleted.
+func Plan(c Client, m Manifest) ([]Change, error) {
+	notes, err := c.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	current := map[note.Name]note.Note{}
+	managed := []note.Note{}
+	for _, n := range notes {
+		if !slices.Contains(m.Notebooks, n.Notebook) {
+			continue
+		}
+		managed = append(managed, n)
+		if _, ok := current[n.Name]; !ok {
+			current[n.Name] = n
+		}
+	}
+	changes := []Change{}
+	kept := map[note.Id]bool{}
+	for _, declared := range m.Notes {
+		want := note.Note{Name: declared.Name, Notebook: declared.Notebook, Content: contentOf(declared)}
+		n, ok := current[declared.Name]
+		if !ok {
+			change := Change{Kind: Create, Want: want}
+			for _, line := range strings.Split(strings.TrimSuffix(want.Content, "\n"), "\n") {
+				change.Lines = append(change.Lines, usecase.DiffLine{Kind: "+", Text: line})
+			}
+			changes = append(changes, change)
+			continue
+		}
+		kept[n.Id] = true
+		want.Id = n.Id
+		if n.Content == want.Content && n.Notebook == want.Notebook {
+			continue
+		}
+		change := Change{Kind: Update, Note: n, Want: want}
+		if n.Content != want.Content {
+			change.Lines = usecase.DiffContents(n.Content, want.Content)
+		}
+		changes = append(changes, change)
+	}
+	for _, n := range managed {
+		if !kept[n.Id] {
+			changes = append(changes, Change{Kind: Delete, Note: n})
+		}
+	}
+	return changes, nil
+}
+
+// Apply makes the changes of a plan on the server, in order, it stops at
+// the first change failing
+func Apply(c Client, changes []Change) error {
+	for _, change := range changes {
+		var err error
+		switch change.Kind {
+		case Create:
+			_, err = c.Create(change.Want)
+		case Update:
+			if change.Moved() {
+				_, err = c.Move(change.Note.Id, change.Want.Notebook)
+			}
+			if err == nil && len(change.Lines) > 0 {
+				_, err = c.Update(change.Note.Id, change.Want)
+			}
+		case Delete:
+			err = c.Delete(change.Note.Id)
+		}
+		if err != nil {
+			return fmt.Errorf("%s note %q: %w", change.Kind, change.Name(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+// Package remote talks to another notes server through its HTTP API
+// and synchronizes the local notes with it.
+package remote
+
+import (
+	"fmt"
+	"time"
+
+	"notes/internal/crypt"
+	"notes/internal/note"
+	"notes/pkg/client"
+)
+
+// Client calls the HTTP API of a notes server through package client
+// With a key the content of the notes is sealed before it is sent and
+// opened once received, the server never sees it.
+type Client struct {
+	api client.Client
+	key *crypt.Key
+}
+
+// NewClient calls the server at base, such as http://example.org:8080
+func NewClient(base string, options ...client.Option) Client {
+	return Client{api: client.New(base, options...)}
+}
+
+// WithKey is the client sealing the content of the notes with a key
+func (c Client) WithKey(k crypt.Key) Client {
+	c.key = &k
+	return c
+}
+
+// noteOf maps a note of the API to the entity
+func noteOf(n client.Note) note.Note {
+	return note.Note{
+		Id:       n.Id,
+		Name:     n.Name,
+		Content:  n.Content,
+		Notebook: n.Notebook,
+		Owner:    n.Owner,
+		Metadata: n.Metadata,
+		Length:   note.Length{Words: n.Length.Words, Characters: n.Length.Characters, ReadingMinutes: n.Length.ReadingMinutes},
+	}
+}
+
+// ReadAll fails when a note was sealed with another key, syncing it
+// would replace its content by ciphertext
+func (c Client) ReadAll() (note.List, error) {
+	notes, err := c.api.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	result := note.List{}
+	for _, n := range notes {
+		opened, err := c.open(noteOf(n))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, opened)
+	}
+	return result, nil
+}
+
+func (c Client) Create(n note.Note) (note.Note, error) {
+	content, err := c.seal(n.Content)
+	if err != nil {
+		return note.Note{}, err
+	}
+	created, err := c.api.Create(client.Note{Name: n.Name, Content: content, Notebook: n.Notebook})
+	if err != nil {
+		return note.Note{}, err
+	}
+	return c.open(noteOf(created))
+}
+
+func (c Client) Update(id note.Id, n note.Note) (note.Note, error) {
+	content, err := c.seal(n.Content)
+	if err != nil {
+		return note.Note{}, err
+	}
+	updated, err := c.api.Update(id, client.Note{Name: n.Name, Content: content})
+	if err != nil {
+		return note.Note{}, err
+	}
+	return c.open(noteOf(updated))
+}
+
+func (c Client) seal(content note.Content) (note.Content, error) {
+	if c.key == nil {
+		return content, nil
+	}
+	return crypt.Seal(*c.key, content)
+}
+
+func (c Client) open(n note.Note) (note.Note, error) {
+	if c.key == nil || !crypt.IsSealed(n.Content) {
+		return n, nil
+	}
+	content, err := crypt.Open(*c.key, n.Content)
+	if err != nil {
+		return n, fmt.Errorf("note %d %q: %w", n.Id, n.Name, err)
+	}
+	n.Content = content
+	return n, nil
+}
+
+func (c Client) Move(id note.Id, notebook note.Notebook) (note.Note, error) {
+	moved, err := c.api.Move(id, notebook)
+	if err != nil {
+		return note.Note{}, err
+	}
+	return c.open(noteOf(moved))
+}
+
+func (c Client) Delete(id note.Id) error {
+	return c.api.Delete(id)
+}
+
+// ChangedAt is the time of the last change of a note in the audit log
+// of the server, zero when the log doesn't know the note
+func (c Client) ChangedAt(id note.Id) (time.Time, error) {
+	changes, err := c.api.Changes(id)
+	if err != nil || len(changes) == 0 {
+		return time.Time{}, err
+	}
+	return changes[len(changes)-1].At, nil
+}
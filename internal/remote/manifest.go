@@ -0,0 +1,319 @@
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"notes/internal/note"
+)
+
+// Manifest declares the notes a server holds, see Plan
+// The notebooks of the manifest are managed by it: their notes missing
+// from the manifest are deleted, those of the other notebooks are left
+// as they are.
+type Manifest struct {
+	// Notebooks are managed even without notes, which empties them
+	Notebooks []note.Notebook `json:"notebooks"`
+	Notes     []ManifestNote  `json:"notes"`
+}
+
+// ManifestNote is known by its name among the notes of the managed
+// notebooks, its tags are written as #tags at the end of its content
+type ManifestNote struct {
+	Name     note.Name     `json:"name"`
+	Notebook note.Notebook `json:"notebook"`
+	Content  note.Content  `json:"content"`
+	Tags     []string      `json:"tags"`
+}
+
+// ParseManifest reads a manifest in json or in the subset of yaml of
+// parseYaml, such as
+//
+//	notebooks: [runbooks]
+//	notes:
+//	  - name: Restart the API
+//	    notebook: runbooks
+//	    tags: [ops, api]
+//	    content: |
+//	      systemctl restart api
+func ParseManifest(data []byte) (Manifest, error) {
+	m := Manifest{}
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		tree, err := parseYaml(string(data))
+		if err != nil {
+			return m, err
+		}
+		data, err = json.Marshal(tree)
+		if err != nil {
+			return m, err
+		}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&m)
+	if err != nil {
+		return m, fmt.Errorf("%w manifest: %v", note.ErrValidation, err)
+	}
+	names := map[note.Name]bool{}
+	for _, n := range m.Notes {
+		if n.Name == "" || n.Notebook == "" {
+			return m, fmt.Errorf("%w manifest: every note needs a name and a notebook", note.ErrValidation)
+		}
+		if names[n.Name] {
+			return m, fmt.Errorf("%w manifest: the note %q is declared twice", note.ErrValidation, n.Name)
+		}
+		names[n.Name] = true
+		if !slices.Contains(m.Notebooks, n.Notebook) {
+			m.Notebooks = append(m.Notebooks, n.Notebook)
+		}
+	}
+	return m, nil
+}
+
+// yaml reads the lines of a document, i is the next line to read
+type yaml struct {
+	lines []string
+	i     int
+}
+
+// parseYaml reads the subset of yaml the manifests are written in: the
+// mappings, the sequences, the flow sequences of scalars such as [a, b],
+// the plain and quoted scalars, the literal blocks of | and |- and the
+// comments
+// The scalars are strings, the tree is made of maps, slices and strings.
+func parseYaml(text string) (any, error) {
+	y := &yaml{lines: strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")}
+	v, err := y.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if y.next() {
+		return nil, y.errorf("unexpected indentation")
+	}
+	return v, nil
+}
+
+func (y *yaml) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w manifest: line %d: %s", note.ErrValidation, y.i+1, fmt.Sprintf(format, args...))
+}
+
+// next skips the blank lines and the comments, false at the end
+func (y *yaml) next() bool {
+	for ; y.i < len(y.lines); y.i++ {
+		text := strings.TrimSpace(y.lines[y.i])
+		if text != "" && !strings.HasPrefix(text, "#") && text != "---" {
+			return true
+		}
+	}
+	return false
+}
+
+// line is the indentation and the text of the next line
+func (y *yaml) line() (int, string) {
+	line := y.lines[y.i]
+	text := strings.TrimLeft(line, " ")
+	return len(line) - len(text), strings.TrimRight(text, " ")
+}
+
+func isItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// value reads the block indented by indent or more, nil when there is
+// none
+func (y *yaml) value(indent int) (any, error) {
+	if !y.next() {
+		return nil, nil
+	}
+	n, text := y.line()
+	if n < indent {
+		return nil, nil
+	}
+	if strings.HasPrefix(y.lines[y.i], "\t") {
+		return nil, y.errorf("tabs can't indent")
+	}
+	if isItem(text) {
+		return y.sequence(n)
+	}
+	return y.mapping(n)
+}
+
+func (y *yaml) sequence(indent int) ([]any, error) {
+	items := []any{}
+	for y.next() {
+		n, text := y.line()
+		if n != indent || !isItem(text) {
+			break
+		}
+		rest := strings.TrimSpace(strings.TrimPrefix(text, "-"))
+		switch {
+		case rest == "":
+			y.i++
+			item, err := y.value(indent + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		case isItem(rest) || isKey(rest):
+			// the item is a block starting on the line of its dash, which is
+			// read as its indentation
+			y.lines[y.i] = strings.Repeat(" ", indent+len(text)-len(rest)) + rest
+			item, err := y.value(indent + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		default:
+			y.i++
+			item, err := y.scalar(rest)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// isKey tells a line starts a mapping
+func isKey(text string) bool {
+	key, _, ok := cutKey(text)
+	return ok && !strings.HasPrefix(key, "[")
+}
+
+// cutKey splits the key of a mapping from its value
+func cutKey(text string) (string, string, bool) {
+	if strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'") {
+		return "", "", false
+	}
+	if key, ok := strings.CutSuffix(text, ":"); ok {
+		return key, "", true
+	}
+	key, rest, ok := strings.Cut(text, ": ")
+	return key, strings.TrimSpace(rest), ok
+}
+
+func (y *yaml) mapping(indent int) (map[string]any, error) {
+	m := map[string]any{}
+	for y.next() {
+		n, text := y.line()
+		if n < indent {
+			break
+		}
+		if n > indent || isItem(text) {
+			return nil, y.errorf("unexpected indentation")
+		}
+		key, rest, ok := cutKey(text)
+		if !ok {
+			return nil, y.errorf("%q is not a key: value", text)
+		}
+		if _, ok := m[key]; ok {
+			return nil, y.errorf("%s is set twice", key)
+		}
+		y.i++
+		var v any
+		var err error
+		switch {
+		case rest == "|" || rest == "|-":
+			v = y.literal(indent, rest == "|")
+		case rest == "":
+			v, err = y.value(indent + 1)
+			// the items of a sequence may be indented as its key
+			if v == nil && err == nil && y.next() {
+				if n, text := y.line(); n == indent && isItem(text) {
+					v, err = y.sequence(indent)
+				}
+			}
+		default:
+			v, err = y.scalar(rest)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+// literal reads the lines of a block indented more than its key, they
+// end with a line break when keep is set
+func (y *yaml) literal(indent int, keep bool) string {
+	lines := []string{}
+	block := -1
+	for ; y.i < len(y.lines); y.i++ {
+		line := strings.TrimRight(y.lines[y.i], " ")
+		text := strings.TrimLeft(line, " ")
+		if text == "" {
+			lines = append(lines, "")
+			continue
+		}
+		n := len(line) - len(text)
+		if block < 0 {
+			block = n
+		}
+		if n <= indent || n < block {
+			break
+		}
+		lines = append(lines, line[block:])
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		y.i--
+	}
+	text := strings.Join(lines, "\n")
+	if keep && text != "" {
+		text += "\n"
+	}
+	return text
+}
+
+// scalar reads a quoted or plain scalar, or a flow sequence of them
+func (y *yaml) scalar(text string) (any, error) {
+	if strings.HasPrefix(text, "[") {
+		inner, ok := strings.CutSuffix(uncomment(text), "]")
+		if !ok {
+			return nil, y.errorf("%q has no closing ]", text)
+		}
+		items := []any{}
+		for _, item := range strings.Split(strings.TrimPrefix(inner, "["), ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			v, err := y.scalar(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	}
+	switch {
+	case strings.HasPrefix(text, `"`):
+		end := strings.LastIndex(text, `"`)
+		s, err := strconv.Unquote(text[:end+1])
+		if err != nil || end == 0 {
+			return nil, y.errorf("%s is not a quoted string", text)
+		}
+		return s, nil
+	case strings.HasPrefix(text, "'"):
+		end := strings.LastIndex(text, "'")
+		if end == 0 {
+			return nil, y.errorf("%s is not a quoted string", text)
+		}
+		return strings.ReplaceAll(text[1:end], "''", "'"), nil
+	}
+	return uncomment(text), nil
+}
+
+// uncomment cuts the comment at the end of a plain scalar
+func uncomment(text string) string {
+	if i := strings.Index(text, " #"); i >= 0 {
+		text = text[:i]
+	}
+	return strings.TrimSpace(text)
+}
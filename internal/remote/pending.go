@@ -0,0 +1,45 @@
+package remote
+
+import (
+	"fmt"
+
+	"notes/internal/note"
+	"notes/internal/usecase"
+)
+
+// Pending lists the local changes the next sync pushes, one line per
+// note, without asking the server
+// The local notes are a copy of those of the server as of the last sync,
+// they are read and changed offline and the changes made since are
+// found by comparing them with the state.
+func Pending(u usecase.Usecase, state State, actor string) ([]string, error) {
+	result, err := u.ReadAll.Execute(usecase.ReadAllMessage{Context: usecase.Context{Actor: actor}})
+	if err != nil {
+		return nil, err
+	}
+	locals := map[note.Id]note.Note{}
+	for _, n := range result.Notes {
+		locals[n.Id] = n
+	}
+	pending := []string{}
+	synced := map[note.Id]bool{}
+	for _, s := range state.Notes {
+		if s.LocalId == 0 {
+			continue
+		}
+		synced[s.LocalId] = true
+		local, ok := locals[s.LocalId]
+		switch {
+		case !ok:
+			pending = append(pending, fmt.Sprintf("deleted note %d", s.LocalId))
+		case hash(local) != s.Hash:
+			pending = append(pending, fmt.Sprintf("updated note %d %q", local.Id, local.Name))
+		}
+	}
+	for _, n := range result.Notes {
+		if !synced[n.Id] {
+			pending = append(pending, fmt.Sprintf("created note %d %q", n.Id, n.Name))
+		}
+	}
+	return pending, nil
+}
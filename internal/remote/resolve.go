@@ -0,0 +1,60 @@
+package remote
+
+import (
+	"time"
+
+	"notes/internal/note"
+)
+
+// Version of a conflicting note on one side, the note is zero when it
+// was deleted on that side
+type Version struct {
+	Note note.Note
+	// ChangedAt is the time of the last change, zero when unknown
+	ChangedAt time.Time
+}
+
+// Resolution tells which version of a conflicting note is kept
+type Resolution int
+
+const (
+	// Skip leaves the conflict for a later sync
+	Skip Resolution = iota
+	KeepLocal
+	KeepRemote
+	// KeepBoth keeps the local version and a copy of the remote one
+	KeepBoth
+	// Merge keeps the note given by the resolver on both sides
+	Merge
+)
+
+// Resolver decides what happens to a note changed on both sides since
+// the last sync
+type Resolver interface {
+	Resolve(local Version, remote Version) (Resolution, note.Note, error)
+}
+
+// LastWriterWins keeps the version changed last, the local one when
+// the times are unknown
+type LastWriterWins struct{}
+
+func (LastWriterWins) Resolve(local Version, remote Version) (Resolution, note.Note, error) {
+	if remote.ChangedAt.After(local.ChangedAt) {
+		return KeepRemote, note.Note{}, nil
+	}
+	return KeepLocal, note.Note{}, nil
+}
+
+// KeepBothVersions keeps both versions, the remote one becomes a
+// conflict copy
+type KeepBothVersions struct{}
+
+func (KeepBothVersions) Resolve(local Version, remote Version) (Resolution, note.Note, error) {
+	return KeepBoth, note.Note{}, nil
+}
+
+// conflictCopy names the copy of a remote version kept aside
+func conflictCopy(n note.Note) note.Note {
+	n.Name += " (conflict copy)"
+	return n
+}
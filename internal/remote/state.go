@@ -0,0 +1,59 @@
+package remote
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+
+	"notes/internal/note"
+)
+
+// Synced is what a note was at the last sync, on both sides
+type Synced struct {
+	LocalId  note.Id `json:"localId"`
+	RemoteId note.Id `json:"remoteId"`
+	// Hash of the note as it was on both sides
+	Hash string `json:"hash"`
+}
+
+// State keeps the last sync of every note, in a json file
+type State struct {
+	path  string
+	Notes []Synced `json:"notes"`
+}
+
+// LoadState reads the state of a json file, a missing file is a first
+// sync
+func LoadState(path string) (State, error) {
+	state := State{path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
+
+func (s State) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	err = os.WriteFile(tmp, data, 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// hash tells whether a note changed since the last sync, ids differ
+// between both sides so they are left out
+func hash(n note.Note) string {
+	sum := sha256.Sum256([]byte(n.Name + "\x00" + n.Content + "\x00" + n.Notebook))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,269 @@
+package remote
+
+import (
+	"fmt"
+
+	"notes/internal/note"
+	"notes/internal/usecase"
+)
+
+// Report lists what a sync did, one line per note
+type Report struct {
+	Pushed    []string
+	Pulled    []string
+	Conflicts []string
+	// Failed notes are tried again on the next sync
+	Failed []string
+}
+
+// syncer applies the changes of one sync to both sides
+type syncer struct {
+	u      usecase.Usecase
+	c      Client
+	ctx    usecase.Context
+	report *Report
+	synced []Synced
+}
+
+// Sync brings the local notes and those of the server to the same
+// state, a note changed on one side only since the last sync takes the
+// changes of that side
+// Notes changed on both sides are conflicts given to the resolver, they
+// are left as they are when it is nil or skips them
+// Only failing to list the notes of either side is an error, notes
+// failing to sync are reported
+func Sync(u usecase.Usecase, c Client, state State, actor string, resolver Resolver) (State, Report, error) {
+	report := Report{}
+	s := syncer{u: u, c: c, ctx: usecase.Context{Actor: actor}, report: &report}
+	localNotes, err := u.ReadAll.Execute(usecase.ReadAllMessage{Context: s.ctx})
+	if err != nil {
+		return state, report, err
+	}
+	remoteNotes, err := c.ReadAll()
+	if err != nil {
+		return state, report, err
+	}
+	locals := map[note.Id]note.Note{}
+	for _, n := range localNotes.Notes {
+		locals[n.Id] = n
+	}
+	remotes := map[note.Id]note.Note{}
+	for _, n := range remoteNotes {
+		remotes[n.Id] = n
+	}
+	for _, synced := range state.Notes {
+		local, hasLocal := locals[synced.LocalId]
+		remote, hasRemote := remotes[synced.RemoteId]
+		delete(locals, synced.LocalId)
+		delete(remotes, synced.RemoteId)
+		localChanged := !hasLocal || hash(local) != synced.Hash
+		remoteChanged := !hasRemote || hash(remote) != synced.Hash
+		switch {
+		case !hasLocal && !hasRemote:
+		case !localChanged && !remoteChanged:
+			s.synced = append(s.synced, synced)
+		case localChanged && remoteChanged:
+			s.conflict(synced, local, remote, resolver)
+		case localChanged:
+			s.push(synced, local)
+		default:
+			s.pull(synced, remote)
+		}
+	}
+	for _, local := range locals {
+		s.push(Synced{LocalId: local.Id}, local)
+	}
+	for _, remote := range remotes {
+		s.pull(Synced{RemoteId: remote.Id}, remote)
+	}
+	state.Notes = s.synced
+	return state, report, nil
+}
+
+// failed reports a note which couldn't be synced, notes synced before
+// stay in the state to be tried again
+func (s *syncer) failed(synced Synced, err error) {
+	s.report.Failed = append(s.report.Failed, fmt.Sprintf("note %d: %v", synced.LocalId, err))
+	if synced.Hash != "" {
+		s.synced = append(s.synced, synced)
+	}
+}
+
+// push gives the server the local version of a note, a zero note
+// deletes it
+func (s *syncer) push(synced Synced, local note.Note) {
+	var err error
+	remote := note.Note{Id: synced.RemoteId}
+	switch {
+	case local.Id == 0:
+		err = s.c.Delete(synced.RemoteId)
+		s.report.Pushed = append(s.report.Pushed, fmt.Sprintf("deleted note %d", synced.LocalId))
+	case synced.RemoteId == 0:
+		remote, err = s.c.Create(local)
+		s.report.Pushed = append(s.report.Pushed, fmt.Sprintf("created note %d %q", local.Id, local.Name))
+	default:
+		err = s.update(synced.RemoteId, local)
+		s.report.Pushed = append(s.report.Pushed, fmt.Sprintf("updated note %d %q", local.Id, local.Name))
+	}
+	if err != nil {
+		s.report.Pushed = s.report.Pushed[:len(s.report.Pushed)-1]
+		s.failed(synced, err)
+		return
+	}
+	if local.Id != 0 {
+		s.synced = append(s.synced, Synced{LocalId: local.Id, RemoteId: remote.Id, Hash: hash(local)})
+	}
+}
+
+// pull takes the remote version of a note, a zero note deletes it
+func (s *syncer) pull(synced Synced, remote note.Note) {
+	local := note.Note{Id: synced.LocalId}
+	var err error
+	switch {
+	case remote.Id == 0:
+		_, err = s.u.Delete.Execute(usecase.DeleteMessage{Context: s.ctx, Id: synced.LocalId})
+		s.report.Pulled = append(s.report.Pulled, fmt.Sprintf("deleted note %d", synced.LocalId))
+	case synced.LocalId == 0:
+		var result usecase.CreateResult
+		result, err = s.u.Create.Execute(usecase.CreateMessage{Context: s.ctx, Name: remote.Name, Content: remote.Content, Notebook: remote.Notebook})
+		local = result.Note
+		s.report.Pulled = append(s.report.Pulled, fmt.Sprintf("created note %d %q", local.Id, remote.Name))
+	default:
+		err = s.updateLocal(synced.LocalId, remote)
+		s.report.Pulled = append(s.report.Pulled, fmt.Sprintf("updated note %d %q", synced.LocalId, remote.Name))
+	}
+	if err != nil {
+		s.report.Pulled = s.report.Pulled[:len(s.report.Pulled)-1]
+		s.failed(synced, err)
+		return
+	}
+	if remote.Id != 0 {
+		s.synced = append(s.synced, Synced{LocalId: local.Id, RemoteId: remote.Id, Hash: hash(remote)})
+	}
+}
+
+// update gives the server the name, content and notebook of a note
+func (s *syncer) update(remoteId note.Id, n note.Note) error {
+	updated, err := s.c.Update(remoteId, n)
+	if err == nil && updated.Notebook != n.Notebook {
+		_, err = s.c.Move(remoteId, n.Notebook)
+	}
+	return err
+}
+
+// updateLocal gives a local note the name, content and notebook of a
+// note of the server
+func (s *syncer) updateLocal(localId note.Id, n note.Note) error {
+	result, err := s.u.Update.Execute(usecase.UpdateMessage{Context: s.ctx, Id: localId, Name: n.Name, Content: n.Content})
+	if err == nil && result.Note.Notebook != n.Notebook {
+		_, err = s.u.Move.Execute(usecase.MoveMessage{Context: s.ctx, Id: localId, Notebook: n.Notebook})
+	}
+	return err
+}
+
+// conflict asks the resolver which version of a note changed on both
+// sides is kept
+func (s *syncer) conflict(synced Synced, local note.Note, remote note.Note, resolver Resolver) {
+	resolution := Skip
+	merged := note.Note{}
+	if resolver != nil {
+		var err error
+		resolution, merged, err = resolver.Resolve(s.localVersion(synced.LocalId, local), s.remoteVersion(synced.RemoteId, remote))
+		if err != nil {
+			s.failed(synced, err)
+			return
+		}
+	}
+	// a deleted note has nothing to keep a copy of
+	if resolution == KeepBoth && local.Id == 0 {
+		resolution = KeepRemote
+	}
+	if resolution == KeepBoth && remote.Id == 0 {
+		resolution = KeepLocal
+	}
+	switch resolution {
+	case KeepLocal:
+		s.push(synced, local)
+	case KeepRemote:
+		s.pull(synced, remote)
+	case KeepBoth:
+		s.push(synced, local)
+		copied := conflictCopy(remote)
+		result, err := s.u.Create.Execute(usecase.CreateMessage{Context: s.ctx, Name: copied.Name, Content: copied.Content, Notebook: copied.Notebook})
+		if err != nil {
+			s.failed(Synced{LocalId: synced.LocalId}, err)
+			return
+		}
+		s.report.Pulled = append(s.report.Pulled, fmt.Sprintf("created note %d %q", result.Note.Id, copied.Name))
+		s.push(Synced{LocalId: result.Note.Id}, result.Note)
+	case Merge:
+		s.merge(synced, local, remote, merged)
+	default:
+		name := local.Name
+		if name == "" {
+			name = remote.Name
+		}
+		s.report.Conflicts = append(s.report.Conflicts, fmt.Sprintf("note %d %q changed on both sides", synced.LocalId, name))
+		s.synced = append(s.synced, synced)
+	}
+}
+
+// merge sets both sides to the merged note, notes deleted on one side
+// are created again
+func (s *syncer) merge(synced Synced, local note.Note, remote note.Note, merged note.Note) {
+	merged.Notebook = local.Notebook
+	if local.Id == 0 {
+		merged.Notebook = remote.Notebook
+	}
+	localId := synced.LocalId
+	if local.Id == 0 {
+		result, err := s.u.Create.Execute(usecase.CreateMessage{Context: s.ctx, Name: merged.Name, Content: merged.Content, Notebook: merged.Notebook})
+		if err != nil {
+			s.failed(synced, err)
+			return
+		}
+		localId = result.Note.Id
+	} else {
+		_, err := s.u.Update.Execute(usecase.UpdateMessage{Context: s.ctx, Id: localId, Name: merged.Name, Content: merged.Content})
+		if err != nil {
+			s.failed(synced, err)
+			return
+		}
+	}
+	remoteId := synced.RemoteId
+	if remote.Id == 0 {
+		created, err := s.c.Create(merged)
+		if err != nil {
+			s.failed(Synced{LocalId: localId}, err)
+			return
+		}
+		remoteId = created.Id
+	} else {
+		err := s.update(remoteId, merged)
+		if err != nil {
+			s.failed(Synced{LocalId: localId}, err)
+			return
+		}
+	}
+	s.report.Pushed = append(s.report.Pushed, fmt.Sprintf("merged note %d %q", localId, merged.Name))
+	s.synced = append(s.synced, Synced{LocalId: localId, RemoteId: remoteId, Hash: hash(merged)})
+}
+
+// localVersion tells when a local note was changed last according to
+// the local audit log
+func (s *syncer) localVersion(id note.Id, n note.Note) Version {
+	v := Version{Note: n}
+	result, err := s.u.Audit.Execute(usecase.AuditMessage{Context: s.ctx, NoteId: id})
+	if err == nil && len(result.Entries) > 0 {
+		v.ChangedAt = result.Entries[len(result.Entries)-1].At
+	}
+	return v
+}
+
+// remoteVersion tells when a remote note was changed last according to
+// the audit log of the server
+func (s *syncer) remoteVersion(id note.Id, n note.Note) Version {
+	v := Version{Note: n}
+	v.ChangedAt, _ = s.c.ChangedAt(id)
+	return v
+}
@@ -0,0 +1,21 @@
+package repl
+
+import "strings"
+
+// Cli runs a single REPL command given on the command line, `notes read
+// 3` is the same as `READ;3` in the REPL
+type Cli struct {
+	repl Application
+	args []string
+}
+
+// NewCli runs the command of args with a REPL application
+func NewCli(repl Application, args []string) Cli {
+	return Cli{repl: repl, args: args}
+}
+
+func (app Cli) Run() {
+	args := append([]string{strings.ToUpper(app.args[0])}, app.args[1:]...)
+	app.repl.dispatch(args)
+	app.repl.save()
+}
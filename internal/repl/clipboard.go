@@ -0,0 +1,55 @@
+package repl
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// Clipboard reads and writes the text of a clipboard
+type Clipboard interface {
+	Read() (string, error)
+	Write(string) error
+}
+
+// SystemClipboard goes through the clipboard tool of the platform
+type SystemClipboard struct{}
+
+// clipboardTools lists the paste and copy commands of each platform, the
+// first one installed is used
+var clipboardTools = [][2][]string{
+	{{"pbpaste"}, {"pbcopy"}},
+	{{"wl-paste", "--no-newline"}, {"wl-copy"}},
+	{{"xclip", "-selection", "clipboard", "-o"}, {"xclip", "-selection", "clipboard"}},
+	{{"xsel", "--clipboard", "--output"}, {"xsel", "--clipboard", "--input"}},
+	{{"powershell.exe", "-noprofile", "-command", "Get-Clipboard"}, {"clip.exe"}},
+}
+
+func (c SystemClipboard) tool() ([2][]string, error) {
+	for _, tool := range clipboardTools {
+		_, err := exec.LookPath(tool[0][0])
+		if err == nil {
+			return tool, nil
+		}
+	}
+	return [2][]string{}, errors.New("no clipboard tool found")
+}
+
+func (c SystemClipboard) Read() (string, error) {
+	tool, err := c.tool()
+	if err != nil {
+		return "", err
+	}
+	out, err := exec.Command(tool[0][0], tool[0][1:]...).Output()
+	return string(out), err
+}
+
+func (c SystemClipboard) Write(text string) error {
+	tool, err := c.tool()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(tool[1][0], tool[1][1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
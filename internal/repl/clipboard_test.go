@@ -0,0 +1,83 @@
+package repl
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"notes/internal/audit"
+	"notes/internal/storage"
+	"notes/internal/usecase"
+)
+
+// fakeClipboard keeps the text in memory, err fails every read and write
+type fakeClipboard struct {
+	text string
+	err  error
+}
+
+func (c *fakeClipboard) Read() (string, error) {
+	return c.text, c.err
+}
+
+func (c *fakeClipboard) Write(text string) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.text = text
+	return nil
+}
+
+// runBatch runs the REPL commands of input on a memory storage and
+// returns what it printed
+func runBatch(t *testing.T, input string, clipboard Clipboard) string {
+	t.Helper()
+	u := usecase.New(usecase.Deps{Storage: storage.NewInMemory(storage.NewSequence(0)), Inbox: "inbox", Log: audit.NewMemory()})
+	out := bytes.Buffer{}
+	commands := map[string]Command{"READ": {Run: Presented(ReadParser{}, u.Read), Id: true}}
+	app, err := New(u, Config{Input: strings.NewReader(input), Output: &out, Batch: true, Clipboard: clipboard, Commands: commands})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.Run()
+	return out.String()
+}
+
+func TestCopy(t *testing.T) {
+	clipboard := &fakeClipboard{}
+	out := runBatch(t, "CREATE;groceries;milk and eggs\nCOPY;1\n", clipboard)
+	if clipboard.text != "milk and eggs" {
+		t.Errorf("the clipboard holds %q, want the content of the note", clipboard.text)
+	}
+	if !strings.Contains(out, `Copied note 1 "groceries"`) {
+		t.Errorf("the copy isn't told:\n%s", out)
+	}
+}
+
+func TestCopyMissingNote(t *testing.T) {
+	clipboard := &fakeClipboard{text: "before"}
+	out := runBatch(t, "COPY;7\n", clipboard)
+	if clipboard.text != "before" {
+		t.Errorf("the clipboard holds %q, want it untouched", clipboard.text)
+	}
+	if !strings.Contains(out, "Error:") {
+		t.Errorf("the missing note isn't reported:\n%s", out)
+	}
+}
+
+func TestCopyFailingClipboard(t *testing.T) {
+	clipboard := &fakeClipboard{err: errors.New("no clipboard tool found")}
+	out := runBatch(t, "CREATE;groceries;milk\nCOPY;1\n", clipboard)
+	if !strings.Contains(out, "clipboard: no clipboard tool found") {
+		t.Errorf("the failure of the clipboard isn't reported:\n%s", out)
+	}
+}
+
+func TestCreateFromClipboard(t *testing.T) {
+	clipboard := &fakeClipboard{text: "pasted {{date}}"}
+	out := runBatch(t, "CREATE;--from-clipboard;pasted\nREAD;1\n", clipboard)
+	if !strings.Contains(out, "pasted {{date}}") {
+		t.Errorf("the note doesn't hold the clipboard as it is:\n%s", out)
+	}
+}
@@ -0,0 +1,57 @@
+package repl
+
+import "notes/internal/note"
+
+// History of mutating operations
+// A change keeps the note before and after the operation, a zero note
+// stands for "did not exist" so a create has no before and a delete
+// has no after
+type change struct {
+	before note.Note
+	after  note.Note
+}
+
+type history struct {
+	size   int
+	done   []change
+	undone []change
+}
+
+func newHistory(size int) *history {
+	return &history{size: size}
+}
+
+func (h *history) record(c change) {
+	h.done = append(h.done, c)
+	if len(h.done) > h.size {
+		h.done = h.done[len(h.done)-h.size:]
+	}
+	h.undone = nil
+}
+
+// undo gives the last change done to apply it backwards, it is only
+// moved to the undone ones once applied, a failed undo can be retried
+func (h *history) undo(apply func(change) bool) bool {
+	if len(h.done) == 0 {
+		return false
+	}
+	c := h.done[len(h.done)-1]
+	if apply(c) {
+		h.done = h.done[:len(h.done)-1]
+		h.undone = append(h.undone, c)
+	}
+	return true
+}
+
+// redo gives the last change undone to apply it again, as undo does
+func (h *history) redo(apply func(change) bool) bool {
+	if len(h.undone) == 0 {
+		return false
+	}
+	c := h.undone[len(h.undone)-1]
+	if apply(c) {
+		h.undone = h.undone[:len(h.undone)-1]
+		h.done = append(h.done, c)
+	}
+	return true
+}
@@ -0,0 +1,29 @@
+package repl
+
+import (
+	"testing"
+
+	"notes/internal/note"
+)
+
+func TestFailedUndoIsKept(t *testing.T) {
+	h := newHistory(10)
+	h.record(change{after: note.Note{Id: 1}})
+	if !h.undo(func(change) bool { return false }) {
+		t.Fatal("there is nothing to undo, want the change")
+	}
+	undone := note.Id(0)
+	h.undo(func(c change) bool {
+		undone = c.after.Id
+		return true
+	})
+	if undone != 1 {
+		t.Errorf("undid note %d, want the failed undo retried on note 1", undone)
+	}
+	if h.undo(func(change) bool { return true }) {
+		t.Error("the change is undone twice")
+	}
+	if !h.redo(func(change) bool { return true }) {
+		t.Error("there is nothing to redo, want the undone change")
+	}
+}
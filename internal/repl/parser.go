@@ -0,0 +1,509 @@
+package repl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"notes/internal/note"
+	"notes/internal/usecase"
+)
+
+// Parser turns the arguments of a REPL command into a usecase message
+type Parser[Message any] interface {
+	fromRepl([]string) (Message, error)
+}
+
+// arg returns the argument at position i, missing arguments are
+// validation errors
+func arg(s []string, i int, name string) (string, error) {
+	if i >= len(s) {
+		return "", fmt.Errorf("%w %s: missing", note.ErrValidation, name)
+	}
+	return s[i], nil
+}
+
+// idArg parses the note id at position i of the REPL arguments
+func idArg(s []string, i int) (note.Id, error) {
+	id, err := arg(s, i, "id")
+	if err != nil {
+		return 0, err
+	}
+	number, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, fmt.Errorf("%w id: %q is not a number", note.ErrValidation, id)
+	}
+	return number, nil
+}
+
+type ReadAllParser struct{}
+
+// fromRepl reads the order of the notes, as in READALL;-length
+func (c ReadAllParser) fromRepl(s []string) (usecase.ReadAllMessage, error) {
+	if len(s) < 2 {
+		return usecase.ReadAllMessage{}, nil
+	}
+	return usecase.ReadAllMessage{Sort: s[1]}, nil
+}
+
+type DueParser struct{}
+
+func (c DueParser) fromRepl(s []string) (usecase.DueMessage, error) {
+	return usecase.DueMessage{}, nil
+}
+
+type DedupeParser struct{}
+
+// fromRepl reads the optional threshold of the near duplicates
+func (c DedupeParser) fromRepl(s []string) (usecase.DedupeMessage, error) {
+	if len(s) < 2 {
+		return usecase.DedupeMessage{}, nil
+	}
+	threshold, err := strconv.ParseFloat(s[1], 64)
+	if err != nil {
+		return usecase.DedupeMessage{}, fmt.Errorf("%w threshold: %q is not a number", note.ErrValidation, s[1])
+	}
+	return usecase.DedupeMessage{Threshold: threshold}, nil
+}
+
+type MergeParser struct{}
+
+// fromRepl reads the note kept then its duplicates
+func (c MergeParser) fromRepl(s []string) (usecase.MergeMessage, error) {
+	id, err := idArg(s, 1)
+	if err != nil {
+		return usecase.MergeMessage{}, err
+	}
+	duplicates := []note.Id{}
+	for i := 2; i < len(s); i++ {
+		d, err := idArg(s, i)
+		if err != nil {
+			return usecase.MergeMessage{}, err
+		}
+		duplicates = append(duplicates, d)
+	}
+	return usecase.MergeMessage{Id: id, Duplicates: duplicates}, nil
+}
+
+type LintParser struct{}
+
+// fromRepl reads the optional --report flag writing the report note
+func (c LintParser) fromRepl(s []string) (usecase.LintMessage, error) {
+	_, report := withoutFlag(s, "--report")
+	return usecase.LintMessage{Report: report}, nil
+}
+
+type ReadParser struct{}
+
+// fromRepl reads the note id, then the optional time of the version
+// after an @, as in READ;3;@2024-06-01T00:00:00Z, and the --preview flag,
+// which shows the notes the content embeds in place of their ![[embeds]]
+func (c ReadParser) fromRepl(s []string) (usecase.ReadMessage, error) {
+	s, preview := withoutFlag(s, "--preview")
+	id, err := idArg(s, 1)
+	if err != nil {
+		return usecase.ReadMessage{}, err
+	}
+	at := time.Time{}
+	if len(s) > 2 && strings.HasPrefix(s[2], "@") {
+		value := strings.TrimSpace(strings.TrimPrefix(s[2], "@"))
+		// the time may follow the @ alone, as in READ;3;@;2024-06-01
+		if value == "" && len(s) > 3 {
+			value = s[3]
+		}
+		at, err = usecase.ParseTime(value)
+		if err != nil {
+			return usecase.ReadMessage{}, err
+		}
+	}
+	return usecase.ReadMessage{
+		Id:         id,
+		At:         at,
+		Transclude: preview,
+	}, nil
+}
+
+type DiffParser struct{}
+
+// fromRepl reads the note id and the times or the numbers of the
+// versions, as DIFF;1;v3;v5, the second one is now when not given
+func (c DiffParser) fromRepl(s []string) (usecase.DiffMessage, error) {
+	id, err := idArg(s, 1)
+	if err != nil {
+		return usecase.DiffMessage{}, err
+	}
+	value, err := arg(s, 2, "from")
+	if err != nil {
+		return usecase.DiffMessage{}, err
+	}
+	message := usecase.DiffMessage{Id: id}
+	message.From, message.FromVersion, err = usecase.ParseVersion(value)
+	if err != nil {
+		return usecase.DiffMessage{}, err
+	}
+	if len(s) > 3 {
+		message.To, message.ToVersion, err = usecase.ParseVersion(s[3])
+		if err != nil {
+			return usecase.DiffMessage{}, err
+		}
+	}
+	return message, nil
+}
+
+type CreateParser struct{}
+
+func (c CreateParser) fromRepl(s []string) (usecase.CreateMessage, error) {
+	name, err := arg(s, 1, "name")
+	if err != nil {
+		return usecase.CreateMessage{}, err
+	}
+	content, err := arg(s, 2, "content")
+	if err != nil {
+		return usecase.CreateMessage{}, err
+	}
+	notebook := ""
+	if len(s) > 3 {
+		notebook = s[3]
+	}
+	return usecase.CreateMessage{
+		Name:     name,
+		Content:  content,
+		Notebook: notebook,
+		Expand:   true,
+	}, nil
+}
+
+type QuickParser struct{}
+
+// fromRepl keeps the whole line, the captured text may contain ";"
+func (c QuickParser) fromRepl(s []string) (usecase.QuickMessage, error) {
+	return usecase.QuickMessage{
+		Content: strings.Join(s[1:], ";"),
+	}, nil
+}
+
+type TodayParser struct{}
+
+func (c TodayParser) fromRepl(s []string) (usecase.TodayMessage, error) {
+	return usecase.TodayMessage{}, nil
+}
+
+type UpdateParser struct{}
+
+func (c UpdateParser) fromRepl(s []string) (usecase.UpdateMessage, error) {
+	id, err := idArg(s, 1)
+	if err != nil {
+		return usecase.UpdateMessage{}, err
+	}
+	name, err := arg(s, 2, "name")
+	if err != nil {
+		return usecase.UpdateMessage{}, err
+	}
+	content, err := arg(s, 3, "content")
+	if err != nil {
+		return usecase.UpdateMessage{}, err
+	}
+	return usecase.UpdateMessage{
+		Id:      id,
+		Name:    name,
+		Content: content,
+	}, nil
+}
+
+type RenameParser struct{}
+
+func (c RenameParser) fromRepl(s []string) (usecase.RenameMessage, error) {
+	id, err := idArg(s, 1)
+	if err != nil {
+		return usecase.RenameMessage{}, err
+	}
+	name, err := arg(s, 2, "name")
+	if err != nil {
+		return usecase.RenameMessage{}, err
+	}
+	return usecase.RenameMessage{
+		Id:   id,
+		Name: name,
+	}, nil
+}
+
+type DeleteParser struct{}
+
+func (c DeleteParser) fromRepl(s []string) (usecase.DeleteMessage, error) {
+	id, err := idArg(s, 1)
+	if err != nil {
+		return usecase.DeleteMessage{}, err
+	}
+	return usecase.DeleteMessage{
+		Id: id,
+	}, nil
+}
+
+type AuditParser struct{}
+
+// fromRepl takes an optional note id, every change is listed without it
+func (c AuditParser) fromRepl(s []string) (usecase.AuditMessage, error) {
+	if len(s) < 2 {
+		return usecase.AuditMessage{}, nil
+	}
+	id, err := idArg(s, 1)
+	if err != nil {
+		return usecase.AuditMessage{}, err
+	}
+	return usecase.AuditMessage{
+		NoteId: id,
+	}, nil
+}
+
+type MoveParser struct{}
+
+func (c MoveParser) fromRepl(s []string) (usecase.MoveMessage, error) {
+	id, err := idArg(s, 1)
+	if err != nil {
+		return usecase.MoveMessage{}, err
+	}
+	notebook := ""
+	if len(s) > 2 {
+		notebook = s[2]
+	}
+	return usecase.MoveMessage{
+		Id:       id,
+		Notebook: notebook,
+	}, nil
+}
+
+// PinParser pins a note as in PIN;ID, or unpins it as in UNPIN;ID
+type PinParser struct {
+	Unpin bool
+}
+
+func (c PinParser) fromRepl(s []string) (usecase.PinMessage, error) {
+	id, err := idArg(s, 1)
+	if err != nil {
+		return usecase.PinMessage{}, err
+	}
+	return usecase.PinMessage{
+		Id:    id,
+		Unpin: c.Unpin,
+	}, nil
+}
+
+type DashboardParser struct{}
+
+func (c DashboardParser) fromRepl(s []string) (usecase.DashboardMessage, error) {
+	return usecase.DashboardMessage{}, nil
+}
+
+type LockParser struct{}
+
+func (c LockParser) fromRepl(s []string) (usecase.LockMessage, error) {
+	id, err := idArg(s, 1)
+	if err != nil {
+		return usecase.LockMessage{}, err
+	}
+	return usecase.LockMessage{
+		Id: id,
+	}, nil
+}
+
+type UnlockParser struct{}
+
+func (c UnlockParser) fromRepl(s []string) (usecase.UnlockMessage, error) {
+	id, err := idArg(s, 1)
+	if err != nil {
+		return usecase.UnlockMessage{}, err
+	}
+	return usecase.UnlockMessage{
+		Id: id,
+	}, nil
+}
+
+type LockedParser struct{}
+
+func (c LockedParser) fromRepl(s []string) (usecase.LockedMessage, error) {
+	id, err := idArg(s, 1)
+	if err != nil {
+		return usecase.LockedMessage{}, err
+	}
+	return usecase.LockedMessage{
+		Id: id,
+	}, nil
+}
+
+type EmailParser struct{}
+
+func (c EmailParser) fromRepl(s []string) (usecase.EmailMessage, error) {
+	id, err := idArg(s, 1)
+	if err != nil {
+		return usecase.EmailMessage{}, err
+	}
+	to, err := arg(s, 2, "address")
+	if err != nil {
+		return usecase.EmailMessage{}, err
+	}
+	return usecase.EmailMessage{
+		Id: id,
+		To: to,
+	}, nil
+}
+
+type SummarizeParser struct{}
+
+func (c SummarizeParser) fromRepl(s []string) (usecase.SummarizeMessage, error) {
+	id, err := idArg(s, 1)
+	if err != nil {
+		return usecase.SummarizeMessage{}, err
+	}
+	return usecase.SummarizeMessage{Id: id}, nil
+}
+
+type SuggestTitleParser struct{}
+
+func (c SuggestTitleParser) fromRepl(s []string) (usecase.SuggestTitleMessage, error) {
+	content, err := arg(s, 1, "content")
+	if err != nil {
+		return usecase.SuggestTitleMessage{}, err
+	}
+	return usecase.SuggestTitleMessage{Content: content}, nil
+}
+
+type PublishParser struct{}
+
+// fromRepl makes a new gist secret unless --public is given
+func (c PublishParser) fromRepl(s []string) (usecase.PublishMessage, error) {
+	s, public := withoutFlag(s, "--public")
+	id, err := idArg(s, 1)
+	if err != nil {
+		return usecase.PublishMessage{}, err
+	}
+	return usecase.PublishMessage{
+		Id:     id,
+		Public: public,
+	}, nil
+}
+
+type SearchParser struct{}
+
+// fromRepl searches every word given, the words may be separated by ";"
+// as the command line gives them, a leading --semantic searches by
+// meaning
+func (c SearchParser) fromRepl(s []string) (usecase.SearchMessage, error) {
+	query, err := arg(s, 1, "query")
+	if err != nil {
+		return usecase.SearchMessage{}, err
+	}
+	query = strings.Join(append([]string{query}, s[2:]...), " ")
+	query, semantic := strings.CutPrefix(query, "--semantic")
+	if semantic && query != "" && query[0] != ' ' {
+		query, semantic = "--semantic"+query, false
+	}
+	return usecase.SearchMessage{
+		Query:    strings.TrimSpace(query),
+		Semantic: semantic,
+	}, nil
+}
+
+type NotebooksParser struct{}
+
+// fromRepl only lists the notebooks of a color when one is given, as in
+// NOTEBOOKS;red
+func (c NotebooksParser) fromRepl(s []string) (usecase.NotebooksMessage, error) {
+	if len(s) < 2 {
+		return usecase.NotebooksMessage{}, nil
+	}
+	return usecase.NotebooksMessage{Color: s[1]}, nil
+}
+
+type ColorParser struct{}
+
+// fromRepl colors a note as in COLOR;ID;red, or a notebook as in
+// COLOR;--notebook=work;red, without a color the label is removed
+func (c ColorParser) fromRepl(s []string) (usecase.ColorMessage, error) {
+	s, notebook := flagValue(s, "--notebook")
+	message := usecase.ColorMessage{Notebook: notebook}
+	next := 1
+	if notebook == "" {
+		id, err := idArg(s, 1)
+		if err != nil {
+			return usecase.ColorMessage{}, err
+		}
+		message.Id = id
+		next = 2
+	}
+	if len(s) > next {
+		message.Color = s[next]
+	}
+	return message, nil
+}
+
+type SaveSearchParser struct{}
+
+// fromRepl saves the query under a name, the rest of the arguments are
+// the query
+func (c SaveSearchParser) fromRepl(s []string) (usecase.SaveSearchMessage, error) {
+	name, err := arg(s, 1, "name")
+	if err != nil {
+		return usecase.SaveSearchMessage{}, err
+	}
+	query, err := arg(s, 2, "query")
+	if err != nil {
+		return usecase.SaveSearchMessage{}, err
+	}
+	return usecase.SaveSearchMessage{
+		Name:  name,
+		Query: strings.Join(append([]string{query}, s[3:]...), " "),
+	}, nil
+}
+
+type DeleteSearchParser struct{}
+
+func (c DeleteSearchParser) fromRepl(s []string) (usecase.DeleteSearchMessage, error) {
+	name, err := arg(s, 1, "name")
+	if err != nil {
+		return usecase.DeleteSearchMessage{}, err
+	}
+	return usecase.DeleteSearchMessage{
+		Name: name,
+	}, nil
+}
+
+type RecentParser struct{}
+
+// fromRepl takes the kind of the recent notes, viewed or edited, and an
+// optional number of notes, as in RECENT;viewed;5
+func (c RecentParser) fromRepl(s []string) (usecase.RecentMessage, error) {
+	message := usecase.RecentMessage{}
+	if len(s) > 1 {
+		message.Kind = s[1]
+	}
+	if len(s) > 2 {
+		limit, err := strconv.Atoi(s[2])
+		if err != nil {
+			return usecase.RecentMessage{}, fmt.Errorf("%w limit: %q is not a number", note.ErrValidation, s[2])
+		}
+		message.Limit = limit
+	}
+	return message, nil
+}
+
+type SimilarParser struct{}
+
+// fromRepl takes the note id and an optional number of notes to find
+func (c SimilarParser) fromRepl(s []string) (usecase.SimilarMessage, error) {
+	id, err := idArg(s, 1)
+	if err != nil {
+		return usecase.SimilarMessage{}, err
+	}
+	limit := 0
+	if len(s) > 2 {
+		limit, err = strconv.Atoi(s[2])
+		if err != nil {
+			return usecase.SimilarMessage{}, fmt.Errorf("%w limit: %q is not a number", note.ErrValidation, s[2])
+		}
+	}
+	return usecase.SimilarMessage{
+		Id:    id,
+		Limit: limit,
+	}, nil
+}
@@ -0,0 +1,121 @@
+package repl
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"notes/internal/note"
+	"notes/internal/usecase"
+)
+
+// Fuzzy picker
+// fuzzyScore tells whether the characters of the pattern appear in
+// order in the text, ignoring case, it returns -1 when they don't
+// Consecutive characters and characters starting a word score higher
+func fuzzyScore(pattern string, text string) int {
+	pattern = strings.ToLower(pattern)
+	runes := []rune(strings.ToLower(text))
+	score := 0
+	last := -2
+	i := 0
+	for _, p := range pattern {
+		for i < len(runes) && runes[i] != p {
+			i++
+		}
+		if i == len(runes) {
+			return -1
+		}
+		score++
+		if i == last+1 {
+			score += 2
+		}
+		if i == 0 || runes[i-1] == ' ' || runes[i-1] == '-' || runes[i-1] == '_' {
+			score += 3
+		}
+		last = i
+		i++
+	}
+	return score
+}
+
+// Number of candidates listed by the picker
+const pickSize = 10
+
+func (app Application) pickCandidates(notes note.List, query string) note.List {
+	scores := map[note.Id]int{}
+	candidates := note.List{}
+	for _, n := range notes {
+		score := fuzzyScore(query, n.Name)
+		if score < 0 {
+			continue
+		}
+		scores[n.Id] = score
+		candidates = append(candidates, n)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if scores[a.Id] != scores[b.Id] {
+			return scores[a.Id] > scores[b.Id]
+		}
+		return a.Id < b.Id
+	})
+	if len(candidates) > pickSize {
+		candidates = candidates[:pickSize]
+	}
+	return candidates
+}
+
+// pick lets the user narrow the notes down by typing part of their name
+// A number picks the listed note, any other text filters the list again
+// and an empty line cancels
+func (app Application) pick() (note.Id, bool) {
+	result, err := app.usecase.ReadAll.Execute(usecase.ReadAllMessage{Context: app.context})
+	if err != nil {
+		app.fail(err)
+		return 0, false
+	}
+	notes := result.Notes
+	query := ""
+	for {
+		candidates := app.pickCandidates(notes, query)
+		if len(candidates) == 1 && query != "" {
+			return candidates[0].Id, true
+		}
+		for i, n := range candidates {
+			fmt.Fprintf(app.out, "%3d) %s [%d]\n", i+1, n.Name, n.Id)
+		}
+		answer, ok := app.ask("PICK " + query + "> ")
+		if !ok || answer == "" {
+			return 0, false
+		}
+		number, err := strconv.Atoi(answer)
+		if err == nil && number >= 1 && number <= len(candidates) {
+			return candidates[number-1].Id, true
+		}
+		query = answer
+	}
+}
+
+// resolvePick replaces PICK in the arguments of a command taking an id
+// by the id of the note picked
+func (app Application) resolvePick(args []string) ([]string, bool) {
+	if args[0] == "PICK" {
+		args = []string{"READ", "PICK"}
+	}
+	if !app.commands[args[0]].Id {
+		return args, true
+	}
+	for i := range args {
+		if args[i] != "PICK" {
+			continue
+		}
+		id, ok := app.pick()
+		if !ok {
+			return args, false
+		}
+		args[i] = strconv.Itoa(id)
+	}
+	return args, true
+}
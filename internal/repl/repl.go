@@ -0,0 +1,1034 @@
+// Package repl runs the usecases from an interactive prompt, from a
+// batch file of REPL commands or from a single command line.
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"notes/internal/exchange"
+	"notes/internal/i18n"
+	"notes/internal/note"
+	"notes/internal/present"
+	"notes/internal/usecase"
+)
+
+// Presenter writes the results of the commands
+type Presenter interface {
+	Present(o any, w io.Writer)
+}
+
+// textPresenter prints the results in their default format, with the
+// matches of a search highlighted when color is set
+type textPresenter struct {
+	color    bool
+	messages i18n.Messages
+}
+
+func (p textPresenter) Present(o any, w io.Writer) {
+	switch o := o.(type) {
+	case usecase.SearchResult:
+		presentSearch(o, w, p.messages, p.color)
+	case usecase.SimilarResult:
+		presentSimilar(o, w, p.messages)
+	case usecase.DueResult:
+		presentDue(o, w, p.messages)
+	case usecase.RecentResult:
+		presentRecent(o, w, p.messages)
+	case usecase.DashboardResult:
+		presentDashboard(o, w, p.messages)
+	case usecase.PinResult:
+		if o.Pinned {
+			p.messages.Fprintf(w, "Pinned %d %s\n", o.Note.Id, o.Note.Name)
+		} else {
+			p.messages.Fprintf(w, "Unpinned %d %s\n", o.Note.Id, o.Note.Name)
+		}
+	case usecase.LockResult:
+		p.messages.Fprintf(w, "Note %d locked until %s\n", o.Lock.Id, o.Lock.Until.Format("15:04:05"))
+	case usecase.UnlockResult:
+		p.messages.Fprintf(w, "Note %d unlocked\n", o.Lock.Id)
+	case usecase.LockedResult:
+		presentLocked(o, w, p.messages)
+	case usecase.DedupeResult:
+		presentDuplicates(o, w, p.messages)
+	case usecase.LintResult:
+		presentLint(o, w, p.messages)
+	case usecase.DiffResult:
+		presentDiff(o, w, p.messages, p.color)
+	case usecase.SummarizeResult:
+		fmt.Fprintf(w, "%d %s: %s\n", o.Note.Id, o.Note.Name, o.Summary)
+	case usecase.SuggestTitleResult:
+		fmt.Fprintln(w, o.Title)
+	case usecase.NotebooksResult:
+		presentNotebooks(o, w, p.messages, p.color)
+	default:
+		fmt.Fprintln(w, o)
+	}
+}
+
+// presentSimilar lists the notes similar to a note, the most similar
+// first
+func presentSimilar(result usecase.SimilarResult, w io.Writer, m i18n.Messages) {
+	if len(result.Similar) == 0 {
+		m.Fprintf(w, "No notes similar to %d %s\n", result.Note.Id, result.Note.Name)
+		return
+	}
+	m.Fprintf(w, "Similar to %d %s:\n", result.Note.Id, result.Note.Name)
+	for _, n := range result.Similar {
+		fmt.Fprintf(w, "  %d %s\n", n.Id, n.Name)
+	}
+}
+
+// presentDue lists the notes with a due date, the earliest first
+func presentDue(result usecase.DueResult, w io.Writer, m i18n.Messages) {
+	if len(result.Reminders) == 0 {
+		m.Fprintf(w, "No notes due\n")
+		return
+	}
+	for _, r := range result.Reminders {
+		fmt.Fprintf(w, "%s %d %s\n", r.Due.Format("2006-01-02 15:04"), r.Note.Id, r.Note.Name)
+	}
+}
+
+// presentRecent lists the notes last viewed or changed, the latest first
+func presentRecent(result usecase.RecentResult, w io.Writer, m i18n.Messages) {
+	if len(result.Notes) == 0 {
+		m.Fprintf(w, "No notes\n")
+		return
+	}
+	for _, r := range result.Notes {
+		fmt.Fprintf(w, "%s %d %s\n", r.At.Format("2006-01-02 15:04"), r.Note.Id, r.Note.Name)
+	}
+}
+
+// presentDashboard lists the notes pinned, those due today and those
+// changed last, the empty lists are left out
+func presentDashboard(result usecase.DashboardResult, w io.Writer, m i18n.Messages) {
+	if len(result.Pinned) == 0 && len(result.Due) == 0 && len(result.Recent) == 0 {
+		m.Fprintf(w, "No notes\n")
+		return
+	}
+	if len(result.Pinned) > 0 {
+		m.Fprintf(w, "Pinned:\n")
+		for _, n := range result.Pinned {
+			fmt.Fprintf(w, "  %d %s\n", n.Id, n.Name)
+		}
+	}
+	if len(result.Due) > 0 {
+		m.Fprintf(w, "Due today:\n")
+		for _, r := range result.Due {
+			fmt.Fprintf(w, "  %s %d %s\n", r.Due.Format("15:04"), r.Note.Id, r.Note.Name)
+		}
+	}
+	if len(result.Recent) > 0 {
+		m.Fprintf(w, "Recent:\n")
+		for _, r := range result.Recent {
+			fmt.Fprintf(w, "  %s %d %s\n", r.At.Format("2006-01-02 15:04"), r.Note.Id, r.Note.Name)
+		}
+	}
+}
+
+// presentLocked tells who is editing a note
+func presentLocked(result usecase.LockedResult, w io.Writer, m i18n.Messages) {
+	if !result.Locked {
+		m.Fprintf(w, "Not locked\n")
+		return
+	}
+	m.Fprintf(w, "Note %d is being edited by %s until %s\n", result.Lock.Id, result.Lock.Holder, result.Lock.Until.Format("15:04:05"))
+}
+
+// presentDuplicates lists the groups of duplicates with the commands
+// removing them
+func presentDuplicates(result usecase.DedupeResult, w io.Writer, m i18n.Messages) {
+	if len(result.Duplicates) == 0 {
+		m.Fprintf(w, "No duplicates\n")
+		return
+	}
+	for _, d := range result.Duplicates {
+		if d.Exact {
+			m.Fprintf(w, "Same content:\n")
+		} else {
+			m.Fprintf(w, "Similar content, %.0f%%:\n", d.Similarity*100)
+		}
+		ids := []string{}
+		for _, n := range d.Notes {
+			fmt.Fprintf(w, "  %d %s\n", n.Id, n.Name)
+			ids = append(ids, strconv.Itoa(n.Id))
+		}
+		m.Fprintf(w, "  MERGE;%s or DELETE;%s\n", strings.Join(ids, ";"), ids[1])
+	}
+}
+
+// presentLint lists the broken links with their line
+func presentLint(result usecase.LintResult, w io.Writer, m i18n.Messages) {
+	if len(result.Problems) == 0 {
+		m.Fprintf(w, "No broken links\n")
+	}
+	for _, p := range result.Problems {
+		fmt.Fprintf(w, "%d %s:%d %s, %s\n  %s\n", p.Id, p.Name, p.Line, p.Link, p.Reason, p.Context)
+	}
+	if result.Report.Id != 0 {
+		m.Fprintf(w, "Report written to %d %s\n", result.Report.Id, result.Report.Name)
+	}
+}
+
+// presentDiff prints the lines of the versions as a unified diff, the
+// removed ones in red and the added ones in green when color is set
+func presentDiff(result usecase.DiffResult, w io.Writer, m i18n.Messages, color bool) {
+	if result.From.Name != result.To.Name {
+		m.Fprintf(w, "Renamed %s to %s\n", result.From.Name, result.To.Name)
+	}
+	colors := map[string]string{}
+	end := ""
+	if color {
+		colors["-"], colors["+"], end = "\x1b[31m", "\x1b[32m", "\x1b[0m"
+	}
+	for _, l := range result.Lines {
+		if c, ok := colors[l.Kind]; ok {
+			fmt.Fprintf(w, "%s%s%s%s\n", c, l.Kind, l.Text, end)
+			continue
+		}
+		fmt.Fprintf(w, "%s%s\n", l.Kind, l.Text)
+	}
+}
+
+// labels are the terminal colors of the palette
+var labels = map[string]string{
+	"red":    "\x1b[31m",
+	"orange": "\x1b[38;5;208m",
+	"yellow": "\x1b[33m",
+	"green":  "\x1b[32m",
+	"blue":   "\x1b[34m",
+	"purple": "\x1b[35m",
+	"gray":   "\x1b[90m",
+}
+
+// presentNotebooks lists the notebooks with their number of notes and
+// their color, a dot of it when color is set, the smart notebooks with
+// their query
+func presentNotebooks(result usecase.NotebooksResult, w io.Writer, m i18n.Messages, color bool) {
+	if len(result.Notebooks) == 0 {
+		m.Fprintf(w, "No notebooks\n")
+		return
+	}
+	for _, n := range result.Notebooks {
+		switch {
+		case n.Query != "":
+			fmt.Fprintf(w, "%s (%d) %s\n", n.Name, n.Count, n.Query)
+		case n.Color != "" && color:
+			fmt.Fprintf(w, "%s●\x1b[0m %s (%d)\n", labels[n.Color], n.Name, n.Count)
+		case n.Color != "":
+			fmt.Fprintf(w, "%s (%d) %s\n", n.Name, n.Count, n.Color)
+		default:
+			fmt.Fprintf(w, "%s (%d)\n", n.Name, n.Count)
+		}
+	}
+}
+
+// presentSearch lists the notes found with their snippets
+func presentSearch(result usecase.SearchResult, w io.Writer, m i18n.Messages, color bool) {
+	if len(result.Notes) == 0 {
+		m.Fprintf(w, "No notes\n")
+		return
+	}
+	start, end := "", ""
+	if color {
+		start, end = "\x1b[1;33m", "\x1b[0m"
+	}
+	for _, n := range result.Notes {
+		fmt.Fprintf(w, "%d %s\n", n.Id, n.Name)
+		for _, s := range result.Snippets {
+			if s.NoteId != n.Id {
+				continue
+			}
+			text, last := strings.Builder{}, 0
+			for _, m := range s.Matches {
+				text.WriteString(s.Text[last:m[0]] + start + s.Text[m[0]:m[1]] + end)
+				last = m[1]
+			}
+			text.WriteString(s.Text[last:])
+			fmt.Fprintf(w, "  %s: %s\n", s.Field, text.String())
+		}
+	}
+}
+
+// terminal tells whether w is a terminal which may show colors, see
+// https://no-color.org
+func terminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Number of mutating operations the REPL can undo
+const historySize = 20
+
+// Config of a REPL application
+type Config struct {
+	// Input and Output of the session, they default to stdin and stdout
+	Input  io.Reader
+	Output io.Writer
+	// Transcript records the session when not nil
+	Transcript *Transcript
+	// Batch runs the input without printing prompts
+	Batch bool
+	// ConfirmDelete asks before deleting unless --force is given
+	ConfirmDelete bool
+	// Prompt is a text/template which can use {{.count}}, {{.backend}},
+	// {{.unsaved}} and {{.notebook}}, the current notebook chosen by USE
+	Prompt string
+	// Backend is the name of the storage shown in the prompt
+	Backend string
+	// Clipboard defaults to the system clipboard
+	Clipboard Clipboard
+	// DryRun runs every command as if given --dry-run
+	DryRun bool
+	// Actor is who the changes are attributed to in the audit log
+	Actor string
+	// Presenter defaults to the presenter of Format
+	Presenter Presenter
+	// Format of the results, plain, json, yaml, xml or table, plain by
+	// default, a command chooses another with --format=yaml
+	Format string
+	// Formats are chosen beside the formats above, a format replaces the
+	// one of the same name
+	Formats []present.Format
+	// Reminders prints how many notes are due today before the prompt,
+	// when that number changes
+	Reminders bool
+	// Dashboard prints the notes pinned, those due today and those
+	// changed last when the interactive REPL starts
+	Dashboard bool
+	// Messages translates what the REPL prints, English by default
+	Messages i18n.Messages
+	// Commands run the usecases by name, see package command, the
+	// builtins such as UNDO are added to them
+	Commands map[string]Command
+}
+
+// Application is the REPL
+type Application struct {
+	commands  map[string]Command
+	usecase   usecase.Usecase
+	presenter Presenter
+	formats   present.Registry
+	history   *history
+	reader    *bufio.Reader
+	out       io.Writer
+	// console gets the prompt, which is left out of the transcript
+	console io.Writer
+	// transcript is nil unless the session is being recorded
+	transcript *Transcript
+	// interactive is false in batch mode, no prompt is printed
+	interactive bool
+	// confirmDelete asks before deleting unless --force is given
+	confirmDelete bool
+	prompt        *template.Template
+	backend       string
+	clipboard     Clipboard
+	dryRun        bool
+	actor         string
+	messages      i18n.Messages
+	dashboard     bool
+	// context of the command being run
+	context usecase.Context
+	// dueToday is the number of notes due today last printed, nil
+	// without reminders
+	dueToday *int
+	// notebook is the current notebook chosen by USE, the notes created
+	// without a notebook go there
+	notebook *string
+}
+
+// DefaultPrompt is printed when the prompt template fails
+const DefaultPrompt = "REPL > "
+
+// New builds a REPL on top of the usecases, it fails when the prompt
+// template doesn't parse
+func New(u usecase.Usecase, config Config) (Application, error) {
+	if config.Input == nil {
+		config.Input = os.Stdin
+	}
+	if config.Output == nil {
+		config.Output = os.Stdout
+	}
+	if config.Clipboard == nil {
+		config.Clipboard = SystemClipboard{}
+	}
+	// the plain results are printed by the REPL, transcripts are kept
+	// free of colors
+	text := textPresenter{color: terminal(config.Output) && config.Transcript == nil, messages: config.Messages}
+	formats := present.Default().With(present.Format{Name: "plain", MediaType: present.Plain.MediaType, Presenter: text}).With(config.Formats...)
+	if config.Presenter == nil {
+		format, ok := formats.Lookup(cmp.Or(config.Format, "plain"))
+		if !ok {
+			return Application{}, fmt.Errorf("unknown format %s, it is one of %s", config.Format, strings.Join(formats.Names(), ", "))
+		}
+		config.Presenter = format.Presenter
+	}
+	prompt, err := template.New("prompt").Parse(config.Prompt)
+	if err != nil {
+		return Application{}, err
+	}
+	app := Application{
+		usecase:    u,
+		presenter:  config.Presenter,
+		formats:    formats,
+		history:    newHistory(historySize),
+		reader:     bufio.NewReader(config.Input),
+		out:        io.MultiWriter(config.Output, config.Transcript),
+		console:    config.Output,
+		transcript: config.Transcript,
+
+		interactive:   !config.Batch,
+		confirmDelete: config.ConfirmDelete,
+		prompt:        prompt,
+		backend:       config.Backend,
+		clipboard:     config.Clipboard,
+		dryRun:        config.DryRun,
+		actor:         config.Actor,
+		messages:      config.Messages,
+		dashboard:     config.Dashboard,
+		notebook:      new(string),
+	}
+	if config.Reminders {
+		app.dueToday = new(int)
+		*app.dueToday = -1
+	}
+	app.commands = withBuiltins(config.Commands)
+	return app, nil
+}
+
+// Command registry
+// Command is a REPL command, Id tells its first argument is a note id,
+// which PICK gives and the shell completes, and Session tells it only
+// makes sense within a REPL session, the command line doesn't offer it
+type Command struct {
+	Run     Handler
+	Id      bool
+	Session bool
+}
+
+// builtins are the REPL commands doing more than running a usecase, the
+// others come from the registry of package command
+func builtins() map[string]Command {
+	return map[string]Command{
+		"CREATE": {Run: Application.handleCreate},
+		"UPDATE": {Run: Application.handleUpdate, Id: true},
+		"RENAME": {Run: Application.handleRename, Id: true},
+		"MOVE":   {Run: Application.handleMove, Id: true},
+		"DELETE": {Run: Application.handleDelete, Id: true},
+		"UNDO":   {Run: Application.handleUndo, Session: true},
+		"REDO":   {Run: Application.handleRedo, Session: true},
+		"COPY":   {Run: Application.handleCopy, Id: true},
+		"EXPORT": {Run: Application.handleExport},
+		"SAVE":   {Run: Application.handleSave},
+		"USE":    {Run: Application.handleUse, Session: true},
+		"AUDIT":  {Run: Application.handleAudit, Id: true},
+	}
+}
+
+// withBuiltins adds the builtins to the commands of the usecases
+func withBuiltins(commands map[string]Command) map[string]Command {
+	all := maps.Clone(commands)
+	if all == nil {
+		all = map[string]Command{}
+	}
+	maps.Copy(all, builtins())
+	return all
+}
+
+// CliCommands are the commands available from the command line, the
+// commands of the usecases and the builtins but those of a session, in
+// lower case
+func CliCommands(commands map[string]Command) []string {
+	return cliCommands(commands, func(c Command) bool { return !c.Session })
+}
+
+// CliIdCommands are the commands of the command line taking a note id
+func CliIdCommands(commands map[string]Command) []string {
+	return cliCommands(commands, func(c Command) bool { return !c.Session && c.Id })
+}
+
+func cliCommands(commands map[string]Command, keep func(Command) bool) []string {
+	names := []string{}
+	for name, c := range withBuiltins(commands) {
+		if keep(c) {
+			names = append(names, strings.ToLower(name))
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Handler runs a REPL command from its arguments, the first one being
+// the command name
+type Handler func(app Application, args []string)
+
+// Presented runs a usecase which only needs its arguments parsed and its
+// result presented
+func Presented[Message, Result any](p Parser[Message], c usecase.Command[Message, Result]) Handler {
+	return func(app Application, args []string) {
+		message, err := p.fromRepl(args)
+		if err != nil {
+			app.fail(err)
+			return
+		}
+		usecase.SetContext(&message, app.context)
+		result, err := c.Execute(message)
+		if err != nil {
+			app.fail(err)
+			return
+		}
+		app.presenter.Present(result, app.out)
+	}
+}
+
+// Recorded is Presented for usecases creating a note, created tells
+// which note so the creation can be undone, the zero note when none was
+func Recorded[Message, Result any](p Parser[Message], c usecase.Command[Message, Result], created func(Result) note.Note) Handler {
+	return func(app Application, args []string) {
+		message, err := p.fromRepl(args)
+		if err != nil {
+			app.fail(err)
+			return
+		}
+		usecase.SetContext(&message, app.context)
+		result, err := c.Execute(message)
+		if err != nil {
+			app.fail(err)
+			return
+		}
+		if n := created(result); n.Id != 0 {
+			app.record(change{after: n})
+		}
+		app.presenter.Present(result, app.out)
+	}
+}
+
+// fail reports an error without leaving the REPL
+func (app Application) fail(err error) {
+	app.messages.Fprintf(app.out, "Error: %s\n", app.messages.Error(err))
+}
+
+func (app Application) handleCreate(input []string) {
+	args, fromClipboard := withoutFlag(input, "--from-clipboard")
+	if fromClipboard {
+		content, err := app.clipboard.Read()
+		if err != nil {
+			app.fail(fmt.Errorf("clipboard: %w", err))
+			return
+		}
+		args = append(args[:min(2, len(args))], append([]string{content}, args[min(2, len(args)):]...)...)
+	}
+	message, err := CreateParser{}.fromRepl(args)
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	message.Context = app.context
+	if message.Notebook == "" {
+		message.Notebook = *app.notebook
+	}
+	// the content of the clipboard is kept as it is
+	message.Expand = !fromClipboard
+	result, err := app.usecase.Create.Execute(message)
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	app.record(change{after: result.Note})
+	app.presenter.Present(result, app.out)
+}
+
+func (app Application) handleUpdate(input []string) {
+	message, err := UpdateParser{}.fromRepl(input)
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	message.Context = app.context
+	before, err := app.usecase.Read.Execute(usecase.ReadMessage{Context: app.context, Id: message.Id, Quiet: true})
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	// the locks are advisory, the editor is only warned
+	lock, err := app.usecase.Locked.Execute(usecase.LockedMessage{Context: app.context, Id: message.Id})
+	if err == nil && lock.Locked && lock.Lock.Holder != app.actor {
+		app.messages.Fprintf(app.out, "Warning: note %d is being edited by %s until %s\n", lock.Lock.Id, lock.Lock.Holder, lock.Lock.Until.Format("15:04:05"))
+	}
+	result, err := app.usecase.Update.Execute(message)
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	app.record(change{before: before.Note, after: result.Note})
+	app.presenter.Present(result, app.out)
+}
+
+func (app Application) handleRename(input []string) {
+	message, err := RenameParser{}.fromRepl(input)
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	message.Context = app.context
+	before, err := app.usecase.Read.Execute(usecase.ReadMessage{Context: app.context, Id: message.Id, Quiet: true})
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	result, err := app.usecase.Rename.Execute(message)
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	app.record(change{before: before.Note, after: result.Note})
+	app.presenter.Present(result, app.out)
+}
+
+// handleMove puts a note in another notebook, as in MOVE;ID;NOTEBOOK, or
+// in none without a notebook
+func (app Application) handleMove(input []string) {
+	message, err := MoveParser{}.fromRepl(input)
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	message.Context = app.context
+	before, err := app.usecase.Read.Execute(usecase.ReadMessage{Context: app.context, Id: message.Id, Quiet: true})
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	result, err := app.usecase.Move.Execute(message)
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	app.record(change{before: before.Note, after: result.Note})
+	app.presenter.Present(result, app.out)
+}
+
+// record keeps a change for UNDO, changes made in a dry run are not
+// kept since they didn't happen
+func (app Application) record(c change) {
+	if app.context.DryRun {
+		return
+	}
+	app.history.record(c)
+}
+
+// flagValue removes a flag given a value from the REPL arguments, as in
+// --query=tag:work, and returns its value, empty when it is missing
+func flagValue(input []string, flag string) ([]string, string) {
+	args := []string{}
+	value := ""
+	for _, arg := range input {
+		if v, ok := strings.CutPrefix(arg, flag+"="); ok {
+			value = v
+			continue
+		}
+		args = append(args, arg)
+	}
+	return args, value
+}
+
+// withoutFlag removes a flag from the REPL arguments and reports
+// whether it was present
+func withoutFlag(input []string, flag string) ([]string, bool) {
+	args := []string{}
+	found := false
+	for _, arg := range input {
+		if arg == flag {
+			found = true
+			continue
+		}
+		args = append(args, arg)
+	}
+	return args, found
+}
+
+// readLine reads the next input line and records it in the transcript
+func (app Application) readLine() (string, error) {
+	input, err := app.reader.ReadString('\n')
+	if err != nil && (err != io.EOF || input == "") {
+		return "", err
+	}
+	app.transcript.command(input)
+	return input, nil
+}
+
+// ask prints a question and reads the answer, in batch mode comments
+// are skipped and the answer is echoed
+func (app Application) ask(question string) (string, bool) {
+	fmt.Fprint(app.out, question)
+	answer, err := app.readLine()
+	for err == nil && app.shouldSkip(answer) && !app.interactive {
+		answer, err = app.readLine()
+	}
+	if err == io.EOF {
+		return "", false
+	}
+	if err != nil {
+		panic(err)
+	}
+	answer = strings.TrimSpace(answer)
+	if !app.interactive {
+		fmt.Fprintln(app.out, answer)
+	}
+	return answer, true
+}
+
+func (app Application) confirm(question string) bool {
+	answer, _ := app.ask(question + app.messages.Sprintf(" [y/N] "))
+	answer = strings.ToLower(answer)
+	// the English answers are understood in every language
+	return slices.Contains([]string{"y", "yes", app.messages.Sprintf("y"), app.messages.Sprintf("yes")}, answer)
+}
+
+func (app Application) handleDelete(input []string) {
+	args, force := withoutFlag(input, "--force")
+	message, err := DeleteParser{}.fromRepl(args)
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	message.Context = app.context
+	read, err := app.usecase.Read.Execute(usecase.ReadMessage{Context: app.context, Id: message.Id, Quiet: true})
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	if app.confirmDelete && !force && !message.DryRun {
+		if !app.confirm(app.messages.Sprintf("Delete note %d %q?", read.Note.Id, read.Note.Name)) {
+			app.messages.Fprintf(app.out, "Aborted\n")
+			return
+		}
+	}
+	result, err := app.usecase.Delete.Execute(message)
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	if result.DryRun {
+		app.messages.Fprintf(app.out, "Would delete note %d %q\n", result.Note.Id, result.Note.Name)
+		return
+	}
+	app.record(change{before: result.Note})
+	app.messages.Fprintf(app.out, "Deleted note %d %q\n", result.Note.Id, result.Note.Name)
+}
+
+// apply moves storage from one side of a change to the other, it tells
+// whether it did, a dry run or a failure leaves the history as it is
+func (app Application) apply(from note.Note, to note.Note) bool {
+	if to.Id == 0 {
+		result, err := app.usecase.Delete.Execute(usecase.DeleteMessage{Context: app.context, Id: from.Id})
+		if err != nil {
+			app.fail(err)
+			return false
+		}
+		app.presenter.Present(result, app.out)
+		return !app.context.DryRun
+	}
+	result, err := app.usecase.Restore.Execute(usecase.RestoreMessage{Context: app.context, Note: to})
+	if err != nil {
+		app.fail(err)
+		return false
+	}
+	app.presenter.Present(result, app.out)
+	return !app.context.DryRun
+}
+
+func (app Application) handleCopy(input []string) {
+	message, err := ReadParser{}.fromRepl(input)
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	message.Context = app.context
+	result, err := app.usecase.Read.Execute(message)
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	err = app.clipboard.Write(result.Note.Content)
+	if err != nil {
+		app.fail(fmt.Errorf("clipboard: %w", err))
+		return
+	}
+	app.messages.Fprintf(app.out, "Copied note %d %q\n", result.Note.Id, result.Note.Name)
+}
+
+// handleExport renders every note to a PDF file, or the notes of a
+// notebook, or a note when its id is given, as in EXPORT;--pdf;FILE;ID
+// --query=tag:recipes only renders the notes the search query finds.
+func (app Application) handleExport(input []string) {
+	input, pdf := withoutFlag(input, "--pdf")
+	input, query := flagValue(input, "--query")
+	if !pdf {
+		app.fail(fmt.Errorf("%w format: EXPORT needs --pdf", note.ErrValidation))
+		return
+	}
+	path, err := arg(input, 1, "file")
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	title, notes := "Notes", []note.Note{}
+	if id, err := idArg(input, 2); err == nil {
+		result, err := app.usecase.Read.Execute(usecase.ReadMessage{Context: app.context, Id: id, Transclude: true})
+		if err != nil {
+			app.fail(err)
+			return
+		}
+		title, notes = result.Note.Name, []note.Note{result.Note}
+	} else {
+		message := usecase.ExportMessage{Context: app.context, Query: query, Transclude: true}
+		if len(input) > 2 {
+			title, message.Notebook = input[2], input[2]
+		}
+		result, err := app.usecase.Export.Execute(message)
+		if err != nil {
+			app.fail(err)
+			return
+		}
+		notes = slices.Collect(result.Notes)
+	}
+	if len(notes) == 0 {
+		app.messages.Fprintf(app.out, "No notes\n")
+		return
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	err = exchange.WritePDF(file, title, notes)
+	if closed := file.Close(); err == nil {
+		err = closed
+	}
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	app.messages.Fprintf(app.out, "Exported %d notes to %s\n", len(notes), path)
+}
+
+func (app Application) handleSave(input []string) {
+	result, err := app.usecase.Save.Execute(usecase.SaveMessage{Context: app.context})
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	if !result.Saved {
+		app.messages.Fprintf(app.out, "Nothing to save\n")
+		return
+	}
+	app.messages.Fprintf(app.out, "Saved\n")
+}
+
+// handleUse makes a notebook the current one, USE alone goes back to
+// none
+func (app Application) handleUse(input []string) {
+	*app.notebook = ""
+	if len(input) > 1 {
+		*app.notebook = strings.TrimSpace(input[1])
+	}
+	if *app.notebook == "" {
+		app.messages.Fprintf(app.out, "No current notebook\n")
+		return
+	}
+	app.messages.Fprintf(app.out, "Using notebook %s\n", *app.notebook)
+}
+
+// handleAudit lists the changes of every note, or of one note when an id
+// is given, oldest first
+func (app Application) handleAudit(input []string) {
+	message, err := AuditParser{}.fromRepl(input)
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	message.Context = app.context
+	result, err := app.usecase.Audit.Execute(message)
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	if len(result.Entries) == 0 {
+		app.messages.Fprintf(app.out, "No changes\n")
+		return
+	}
+	for _, e := range result.Entries {
+		fmt.Fprintf(app.out, "%s %s %s note %d: %v -> %v\n",
+			e.At.Format("2006-01-02 15:04:05"), e.Actor, e.Kind, e.NoteId, e.Before, e.After)
+	}
+}
+
+func (app Application) handleUndo(input []string) {
+	if !app.history.undo(func(c change) bool { return app.apply(c.after, c.before) }) {
+		app.messages.Fprintf(app.out, "Nothing to undo\n")
+	}
+}
+
+func (app Application) handleRedo(input []string) {
+	if !app.history.redo(func(c change) bool { return app.apply(c.before, c.after) }) {
+		app.messages.Fprintf(app.out, "Nothing to redo\n")
+	}
+}
+
+func (Application) shouldExit(input string) bool {
+	return strings.TrimSpace(input) == "exit"
+}
+
+// shouldSkip ignores blank lines and comments, which lets transcripts
+// be replayed as they are
+func (Application) shouldSkip(input string) bool {
+	input = strings.TrimSpace(input)
+	return input == "" || strings.HasPrefix(input, "#")
+}
+
+// printPrompt renders the prompt template with the session context
+func (app Application) printPrompt() {
+	status, err := app.usecase.Status.Execute(usecase.StatusMessage{Context: usecase.Context{Actor: app.actor}})
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	app.printDueToday()
+	unsaved := ""
+	if status.Unsaved {
+		unsaved = "*"
+	}
+	// the template is rendered aside, a failing one would print half a
+	// prompt
+	prompt := bytes.Buffer{}
+	err = app.prompt.Execute(&prompt, map[string]any{
+		"count":    status.Count,
+		"backend":  app.backend,
+		"unsaved":  unsaved,
+		"notebook": *app.notebook,
+	})
+	if err != nil {
+		app.fail(fmt.Errorf("prompt: %w", err))
+		fmt.Fprint(app.console, DefaultPrompt)
+		return
+	}
+	prompt.WriteTo(app.console)
+}
+
+// printDueToday tells how many notes are due today, once until that
+// number changes
+func (app Application) printDueToday() {
+	if app.dueToday == nil {
+		return
+	}
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	result, err := app.usecase.Due.Execute(usecase.DueMessage{
+		Context: usecase.Context{Actor: app.actor},
+		After:   start.Add(-time.Nanosecond),
+		Before:  start.AddDate(0, 0, 1).Add(-time.Nanosecond),
+	})
+	if err != nil {
+		app.fail(err)
+		return
+	}
+	count := len(result.Reminders)
+	if count == *app.dueToday {
+		return
+	}
+	*app.dueToday = count
+	switch count {
+	case 0:
+	case 1:
+		app.messages.Fprintf(app.console, "1 note due today\n")
+	default:
+		app.messages.Fprintf(app.console, "%d notes due today\n", count)
+	}
+}
+
+// save keeps the changes of the session when leaving
+func (app Application) save() {
+	_, err := app.usecase.Save.Execute(usecase.SaveMessage{Context: usecase.Context{Actor: app.actor}})
+	if err != nil {
+		app.fail(err)
+	}
+}
+
+// Run reads commands until exit or the end of the input, the changes
+// are saved when leaving
+func (app Application) Run() {
+	defer app.transcript.close()
+	defer app.save()
+	if app.interactive && app.dashboard {
+		app.dispatch([]string{"DASHBOARD"})
+	}
+	for {
+		if app.interactive {
+			app.printPrompt()
+		}
+		input, err := app.readLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			panic(err)
+		}
+		if app.shouldExit(input) {
+			break
+		}
+		if app.shouldSkip(input) {
+			continue
+		}
+		args := strings.Split(input, ";")
+		for i := range args {
+			args[i] = strings.TrimSpace(args[i])
+		}
+		app.dispatch(args)
+	}
+}
+
+func (app Application) dispatch(args []string) {
+	args, dryRun := withoutFlag(args, "--dry-run")
+	app.context = usecase.Context{DryRun: app.dryRun || dryRun, Actor: app.actor}
+	args, name := flagValue(args, "--format")
+	if name != "" {
+		format, ok := app.formats.Lookup(name)
+		if !ok {
+			app.fail(fmt.Errorf("%w %s", note.ErrValidation, app.messages.Sprintf("format: %s", name)))
+			return
+		}
+		app.presenter = format.Presenter
+	}
+	// a line of flags alone is as empty as a blank line
+	if len(args) == 0 {
+		return
+	}
+	args, ok := app.resolvePick(args)
+	if !ok {
+		app.messages.Fprintf(app.out, "Nothing picked\n")
+		return
+	}
+	c, ok := app.commands[args[0]]
+	if !ok {
+		app.fail(fmt.Errorf("%w %s", note.ErrValidation, app.messages.Sprintf("command: %s", args[0])))
+		return
+	}
+	c.Run(app, args)
+}
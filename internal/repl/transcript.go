@@ -0,0 +1,71 @@
+package repl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Transcript records a REPL session to a file
+// Commands are written as is and everything printed back is commented
+// out with "# " so a transcript can be replayed in batch mode
+// A nil transcript records nothing
+type Transcript struct {
+	file      *os.File
+	lineStart bool
+}
+
+// NewTranscript starts a transcript in a timestamped file of dir
+func NewTranscript(dir string) (*Transcript, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	name := filepath.Join(dir, "session-"+now.Format("20060102-150405")+".transcript")
+	file, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	t := &Transcript{file: file, lineStart: true}
+	fmt.Fprintf(t, "REPL session started %s\n", now.Format(time.RFC3339))
+	return t, nil
+}
+
+// Write comments out every line of the output
+func (t *Transcript) Write(p []byte) (int, error) {
+	if t == nil {
+		return len(p), nil
+	}
+	for _, line := range strings.SplitAfter(string(p), "\n") {
+		if line == "" {
+			continue
+		}
+		if t.lineStart {
+			t.file.WriteString("# ")
+		}
+		t.file.WriteString(line)
+		t.lineStart = strings.HasSuffix(line, "\n")
+	}
+	return len(p), nil
+}
+
+func (t *Transcript) command(input string) {
+	if t == nil {
+		return
+	}
+	if !t.lineStart {
+		t.file.WriteString("\n")
+	}
+	t.file.WriteString(strings.TrimRight(input, "\r\n") + "\n")
+	t.lineStart = true
+}
+
+func (t *Transcript) close() {
+	if t == nil {
+		return
+	}
+	t.file.Close()
+}
@@ -0,0 +1,94 @@
+// Package retention archives the stale notes. A policy such as
+// inbox:180d moves the notes of the inbox untouched for 180 days to the
+// archive notebook, the archiver applies the policies in the background.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"notes/internal/usecase"
+)
+
+// ParsePolicy reads a policy written NOTEBOOK:AGE or NOTEBOOK:AGE:ARCHIVE,
+// the age is a number of days as in 180d, of weeks as in 26w, or a Go
+// duration
+func ParsePolicy(s string) (usecase.Policy, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+		return usecase.Policy{}, fmt.Errorf("policy %q: not NOTEBOOK:AGE[:ARCHIVE]", s)
+	}
+	after, err := parseAge(parts[1])
+	if err != nil {
+		return usecase.Policy{}, fmt.Errorf("policy %q: %w", s, err)
+	}
+	p := usecase.Policy{Notebook: parts[0], After: after}
+	if len(parts) == 3 {
+		p.Archive = parts[2]
+	}
+	return p, nil
+}
+
+func parseAge(s string) (time.Duration, error) {
+	for unit, length := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, unit); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil {
+				return 0, fmt.Errorf("age %q: %w", s, err)
+			}
+			return time.Duration(count) * length, nil
+		}
+	}
+	return time.ParseDuration(s)
+}
+
+// Archiver applies the policies when it starts then every interval, to
+// the notes of every user
+// What it moves is recorded in the audit log, as changes of the
+// archiver actor.
+type Archiver struct {
+	usecase  usecase.Usecase
+	policies []usecase.Policy
+	interval time.Duration
+	errors   func(error)
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// NewArchiver applies the policies every hour when interval is 0, the
+// failures are given to errors
+func NewArchiver(u usecase.Usecase, policies []usecase.Policy, interval time.Duration, errors func(error)) Archiver {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return Archiver{usecase: u, policies: policies, interval: interval, errors: errors, ctx: ctx, cancel: cancel}
+}
+
+// Run applies the policies until Stop is called
+func (a Archiver) Run() {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		_, err := a.usecase.Archive.Execute(usecase.ArchiveMessage{
+			Context:  usecase.Context{Actor: "archiver"},
+			Policies: a.policies,
+		})
+		if err != nil && a.errors != nil {
+			a.errors(fmt.Errorf("archive: %w", err))
+		}
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop ends Run
+func (a Archiver) Stop() {
+	a.cancel()
+}
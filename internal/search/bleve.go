@@ -0,0 +1,285 @@
+//go:build bleve
+
+package search
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/mapping"
+	blevequery "github.com/blevesearch/bleve/v2/search/query"
+	bleveindex "github.com/blevesearch/bleve_index_api"
+
+	"notes/internal/crypt"
+	"notes/internal/note"
+	"notes/internal/query"
+)
+
+// Bleve searches the notes with a Bleve index, its queries have the
+// Bleve query string syntax: "exact phrases", +required and -excluded
+// words, name:word to search a field only and word^3 to boost a word
+// Words are stemmed as English, so "recipes" finds "recipe".
+type Bleve struct {
+	index  bleve.Index
+	path   string
+	errors func(error)
+}
+
+// document is what Bleve indexes of a note
+type document struct {
+	Name     string `json:"name"`
+	Content  string `json:"content"`
+	Notebook string `json:"notebook"`
+}
+
+func newMapping() *mapping.IndexMappingImpl {
+	m := bleve.NewIndexMapping()
+	m.DefaultAnalyzer = en.AnalyzerName
+	notebook := bleve.NewKeywordFieldMapping()
+	doc := bleve.NewDocumentMapping()
+	doc.AddFieldMappingsAt("notebook", notebook)
+	m.DefaultMapping = doc
+	return m
+}
+
+// OpenBleve opens the index kept in path, or keeps it in memory when
+// path is empty, failures to index the changes are given to errors
+// A new index, or one whose number of notes differs from notes, which
+// happens when the notes were changed without it, is built from notes.
+func OpenBleve(path string, notes note.List, errors func(error)) (*Bleve, error) {
+	b := &Bleve{path: path, errors: errors}
+	var err error
+	switch {
+	case path == "":
+		b.index, err = bleve.NewMemOnly(newMapping())
+	default:
+		b.index, err = bleve.Open(path)
+		if err == bleve.ErrorIndexPathDoesNotExist {
+			b.index, err = bleve.New(path, newMapping())
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("bleve %s: %w", path, err)
+	}
+	count, err := b.index.DocCount()
+	if err != nil || count != uint64(len(notes)) {
+		err = b.Rebuild(notes)
+	}
+	if err != nil {
+		b.index.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// Rebuild replaces the content of the index with notes, nothing else
+// may use the index meanwhile
+func (b *Bleve) Rebuild(notes note.List) error {
+	if b.path != "" {
+		err := b.index.Close()
+		if err != nil {
+			return err
+		}
+		err = os.RemoveAll(b.path)
+		if err != nil {
+			return err
+		}
+		b.index, err = bleve.New(b.path, newMapping())
+		if err != nil {
+			return fmt.Errorf("bleve %s: %w", b.path, err)
+		}
+	} else {
+		index, err := bleve.NewMemOnly(newMapping())
+		if err != nil {
+			return err
+		}
+		b.index.Close()
+		b.index = index
+	}
+	batch := b.index.NewBatch()
+	for _, n := range notes {
+		err := batch.Index(strconv.Itoa(n.Id), documentOf(n))
+		if err != nil {
+			return err
+		}
+	}
+	return b.index.Batch(batch)
+}
+
+// VerifyBleve compares the index kept in path with notes, which it was
+// built from, and describes every difference, the index is opened read
+// only so it is left as is
+func VerifyBleve(path string, notes note.List) ([]string, error) {
+	index, err := bleve.OpenUsing(path, map[string]interface{}{"read_only": true})
+	if err != nil {
+		return nil, fmt.Errorf("bleve %s: %w", path, err)
+	}
+	defer index.Close()
+	problems := []string{}
+	stored := map[string]bool{}
+	for _, n := range notes {
+		id := strconv.Itoa(n.Id)
+		stored[id] = true
+		doc, err := index.Document(id)
+		if err != nil {
+			return nil, err
+		}
+		if doc == nil {
+			problems = append(problems, fmt.Sprintf("note %d is not indexed", n.Id))
+			continue
+		}
+		indexed := map[string]string{}
+		doc.VisitFields(func(f bleveindex.Field) {
+			indexed[f.Name()] = string(f.Value())
+		})
+		d := documentOf(n)
+		if indexed["name"] != d.Name || indexed["content"] != d.Content || indexed["notebook"] != d.Notebook {
+			problems = append(problems, fmt.Sprintf("note %d changed since it was indexed", n.Id))
+		}
+	}
+	count, err := index.DocCount()
+	if err != nil {
+		return nil, err
+	}
+	result, err := index.Search(bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), int(count), 0, false))
+	if err != nil {
+		return nil, err
+	}
+	for _, hit := range result.Hits {
+		if !stored[hit.ID] {
+			problems = append(problems, fmt.Sprintf("note %s is indexed but not stored", hit.ID))
+		}
+	}
+	return problems, nil
+}
+
+// documentOf leaves out the ciphertext of the notes encrypted by their
+// clients
+func documentOf(n note.Note) document {
+	d := document{Name: n.Name, Content: n.Content, Notebook: n.Notebook}
+	if crypt.IsSealed(n.Content) {
+		d.Content = ""
+	}
+	return d
+}
+
+func (b *Bleve) Notify(e note.Event) {
+	id := strconv.Itoa(e.Note.Id)
+	var err error
+	if e.Kind == note.Deleted {
+		err = b.index.Delete(id)
+	} else {
+		err = b.index.Index(id, documentOf(e.Note))
+	}
+	if err != nil && b.errors != nil {
+		b.errors(fmt.Errorf("bleve: %w", err))
+	}
+}
+
+func (b *Bleve) Search(s string) ([]query.Hit, error) {
+	q := bleve.NewQueryStringQuery(s)
+	_, err := q.Parse()
+	if err != nil {
+		return nil, fmt.Errorf("%w query: %v", note.ErrValidation, err)
+	}
+	count, err := b.index.DocCount()
+	if err != nil {
+		return nil, err
+	}
+	result, err := b.index.Search(bleve.NewSearchRequestOptions(q, int(count), 0, false))
+	if err != nil {
+		return nil, err
+	}
+	hits := []query.Hit{}
+	for _, hit := range result.Hits {
+		id, err := strconv.Atoi(hit.ID)
+		if err == nil {
+			hits = append(hits, query.Hit{Id: id, Score: hit.Score})
+		}
+	}
+	return hits, nil
+}
+
+// maxSimilarTerms are the most frequent terms of a note searched to find
+// the notes similar to it
+const maxSimilarTerms = 25
+
+// Similar searches the most frequent terms of a note, the notes scoring
+// the most for them are the most similar
+func (b *Bleve) Similar(id note.Id, limit int) ([]query.Hit, error) {
+	doc, err := b.index.Document(strconv.Itoa(id))
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	analyzer := b.index.Mapping().AnalyzerNamed(en.AnalyzerName)
+	frequencies := map[string]int{}
+	doc.VisitFields(func(f bleveindex.Field) {
+		if f.Name() == "name" || f.Name() == "content" {
+			for _, t := range analyzer.Analyze(f.Value()) {
+				frequencies[string(t.Term)]++
+			}
+		}
+	})
+	terms := make([]string, 0, len(frequencies))
+	for t := range frequencies {
+		terms = append(terms, t)
+	}
+	slices.SortFunc(terms, func(a, b string) int {
+		return cmp.Or(frequencies[b]-frequencies[a], strings.Compare(a, b))
+	})
+	queries := []blevequery.Query{}
+	for _, t := range terms[:min(maxSimilarTerms, len(terms))] {
+		for _, field := range []string{"name", "content"} {
+			q := bleve.NewTermQuery(t)
+			q.SetField(field)
+			queries = append(queries, q)
+		}
+	}
+	if len(queries) == 0 {
+		return nil, nil
+	}
+	// one more, the note itself is found too
+	result, err := b.index.Search(bleve.NewSearchRequestOptions(bleve.NewDisjunctionQuery(queries...), limit+1, 0, false))
+	if err != nil {
+		return nil, err
+	}
+	hits := []query.Hit{}
+	for _, hit := range result.Hits {
+		other, err := strconv.Atoi(hit.ID)
+		if err == nil && other != id && len(hits) < limit {
+			hits = append(hits, query.Hit{Id: other, Score: hit.Score})
+		}
+	}
+	return hits, nil
+}
+
+// Highlight finds the words of a note matching those of the query, the
+// words are compared once stemmed as the index compares them
+func (b *Bleve) Highlight(q string, n note.Note) []query.Span {
+	analyzer := b.index.Mapping().AnalyzerNamed(en.AnalyzerName)
+	terms := map[string]bool{}
+	for _, t := range analyzer.Analyze([]byte(q)) {
+		terms[string(t.Term)] = true
+	}
+	spans := []query.Span{}
+	for field, text := range map[string]string{"name": n.Name, "content": n.Content} {
+		for _, t := range analyzer.Analyze([]byte(text)) {
+			if terms[string(t.Term)] {
+				spans = append(spans, query.Span{Field: field, Start: t.Start, End: t.End})
+			}
+		}
+	}
+	return spans
+}
+
+// Close writes the index, it must be closed for the last changes to be
+// kept
+func (b *Bleve) Close() error {
+	return b.index.Close()
+}
@@ -0,0 +1,278 @@
+// Package search keeps an inverted index of the words of the notes. The
+// index subscribes to the note events so it is updated on every change
+// instead of scanning every note on every search.
+package search
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"sync"
+	"unicode/utf8"
+
+	"notes/internal/crypt"
+	"notes/internal/note"
+	"notes/internal/query"
+)
+
+// nameWeight makes a word of the name count more than one of the
+// content
+const nameWeight = 3
+
+// k1 and b tune BM25: how quickly repeating a word stops raising the
+// score, and how much a long note is penalized for it
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Index maps each word to the notes holding it
+type Index struct {
+	// fuzziness is the number of typos tolerated in a word of a query
+	fuzziness int
+	mutex     sync.RWMutex
+	// postings maps a word to the weight it has in each note
+	postings map[string]map[note.Id]int
+	// words of each note, to remove them when the note changes
+	words map[note.Id][]string
+	// lengths of the notes, in weighted words, and their total
+	lengths map[note.Id]int
+	total   int
+}
+
+// NewIndex indexes notes, usually every note of the storage, a search
+// tolerates up to fuzziness typos per word, none when it is 0
+func NewIndex(notes note.List, fuzziness int) *Index {
+	i := &Index{fuzziness: fuzziness, postings: map[string]map[note.Id]int{}, words: map[note.Id][]string{}, lengths: map[note.Id]int{}}
+	for _, n := range notes {
+		i.add(n)
+	}
+	return i
+}
+
+func (i *Index) Notify(e note.Event) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.remove(e.Note.Id)
+	if e.Kind != note.Deleted {
+		i.add(e.Note)
+	}
+}
+
+func (i *Index) add(n note.Note) {
+	weights := map[string]int{}
+	for _, w := range query.Words(n.Name) {
+		weights[w] += nameWeight
+	}
+	// the ciphertext of the notes encrypted by their clients is no words
+	if !crypt.IsSealed(n.Content) {
+		for _, w := range query.Words(n.Content) {
+			weights[w]++
+		}
+	}
+	words := make([]string, 0, len(weights))
+	length := 0
+	for w, weight := range weights {
+		if i.postings[w] == nil {
+			i.postings[w] = map[note.Id]int{}
+		}
+		i.postings[w][n.Id] = weight
+		words = append(words, w)
+		length += weight
+	}
+	i.words[n.Id] = words
+	i.lengths[n.Id] = length
+	i.total += length
+}
+
+func (i *Index) remove(id note.Id) {
+	for _, w := range i.words[id] {
+		delete(i.postings[w], id)
+		if len(i.postings[w]) == 0 {
+			delete(i.postings, w)
+		}
+	}
+	delete(i.words, id)
+	i.total -= i.lengths[id]
+	delete(i.lengths, id)
+}
+
+// Search returns the notes holding every word of the query, the best
+// first as scored by BM25: the more often the words appear in a note,
+// in its name rather than its content, and the fewer notes hold them,
+// the better
+// A word with typos matches the words it is close to, but an exact
+// match scores more.
+func (i *Index) Search(q string) ([]query.Hit, error) {
+	words := query.Words(q)
+	if len(words) == 0 {
+		return nil, nil
+	}
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+	scores := i.matches(words[0])
+	for _, w := range words[1:] {
+		matches := i.matches(w)
+		for id := range scores {
+			score, ok := matches[id]
+			if !ok {
+				delete(scores, id)
+				continue
+			}
+			scores[id] += score
+		}
+	}
+	hits := make([]query.Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, query.Hit{Id: id, Score: score})
+	}
+	slices.SortFunc(hits, func(a, b query.Hit) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), a.Id-b.Id)
+	})
+	return hits, nil
+}
+
+// matches scores the notes holding a word or the words close to it, a
+// word with typos scores less the more typos it has
+func (i *Index) matches(word string) map[note.Id]float64 {
+	tolerated := i.tolerated(word)
+	scores := map[note.Id]float64{}
+	add := func(postings map[note.Id]int, distance int) {
+		closeness := float64(tolerated+1-distance) / float64(tolerated+1)
+		for id, score := range i.bm25(postings) {
+			scores[id] = max(scores[id], score*closeness)
+		}
+	}
+	add(i.postings[word], 0)
+	if tolerated == 0 {
+		return scores
+	}
+	length := utf8.RuneCountInString(word)
+	for w, postings := range i.postings {
+		if w == word || abs(utf8.RuneCountInString(w)-length) > tolerated {
+			continue
+		}
+		if d := distance(word, w); d <= tolerated {
+			add(postings, d)
+		}
+	}
+	return scores
+}
+
+// bm25 scores the notes of the postings of a word
+func (i *Index) bm25(postings map[note.Id]int) map[note.Id]float64 {
+	count := float64(len(i.lengths))
+	average := float64(i.total) / count
+	idf := math.Log(1 + (count-float64(len(postings))+0.5)/(float64(len(postings))+0.5))
+	scores := make(map[note.Id]float64, len(postings))
+	for id, weight := range postings {
+		tf := float64(weight)
+		scores[id] = idf * tf * (k1 + 1) / (tf + k1*(1-b+b*float64(i.lengths[id])/average))
+	}
+	return scores
+}
+
+// Similar returns the notes closest to a note, by the cosine of the
+// TF-IDF vectors of their words, at most limit of them
+func (i *Index) Similar(id note.Id, limit int) ([]query.Hit, error) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+	count := float64(len(i.lengths))
+	idf := func(w string) float64 {
+		return math.Log(1 + count/float64(len(i.postings[w])))
+	}
+	// norm is the length of the vector of a note
+	norm := func(id note.Id) float64 {
+		sum := 0.0
+		for _, w := range i.words[id] {
+			weight := float64(i.postings[w][id]) * idf(w)
+			sum += weight * weight
+		}
+		return math.Sqrt(sum)
+	}
+	dots := map[note.Id]float64{}
+	for _, w := range i.words[id] {
+		weight := float64(i.postings[w][id]) * idf(w)
+		for other, tf := range i.postings[w] {
+			if other != id {
+				dots[other] += weight * float64(tf) * idf(w)
+			}
+		}
+	}
+	hits := []query.Hit{}
+	n := norm(id)
+	for other, dot := range dots {
+		if dot > 0 {
+			hits = append(hits, query.Hit{Id: other, Score: dot / (n * norm(other))})
+		}
+	}
+	slices.SortFunc(hits, func(a, b query.Hit) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), a.Id-b.Id)
+	})
+	return hits[:min(limit, len(hits))], nil
+}
+
+// Highlight finds the words of a note matching those of the query, as
+// a search would with typos
+func (i *Index) Highlight(q string, n note.Note) []query.Span {
+	words := query.Words(q)
+	match := func(w string) bool {
+		for _, word := range words {
+			tolerated := i.tolerated(word)
+			if w == word || tolerated > 0 && abs(utf8.RuneCountInString(w)-utf8.RuneCountInString(word)) <= tolerated && distance(word, w) <= tolerated {
+				return true
+			}
+		}
+		return false
+	}
+	return append(query.Find("name", n.Name, match), query.Find("content", n.Content, match)...)
+}
+
+// tolerated is the number of typos tolerated in a word, short words
+// have to be exact or they would match too many others
+func (i *Index) tolerated(word string) int {
+	switch length := utf8.RuneCountInString(word); {
+	case length < 3:
+		return 0
+	case length < 6:
+		return min(i.fuzziness, 1)
+	default:
+		return min(i.fuzziness, 2)
+	}
+}
+
+// distance is the number of typos between two words, a typo being a
+// letter inserted, deleted, replaced or swapped with the next one
+func distance(a string, b string) int {
+	s, t := []rune(a), []rune(b)
+	// rows of the distances between the prefixes of s and t, d[i][j]
+	// being between s[:i] and t[:j]
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(s)][len(t)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
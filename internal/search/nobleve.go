@@ -0,0 +1,44 @@
+//go:build !bleve
+
+package search
+
+import (
+	"fmt"
+
+	"notes/internal/note"
+	"notes/internal/query"
+)
+
+// Bleve is only available when built with the bleve tag, go build
+// -tags bleve, it keeps the Bleve dependencies out of the default build
+type Bleve struct{}
+
+func OpenBleve(path string, notes note.List, errors func(error)) (*Bleve, error) {
+	return nil, fmt.Errorf("the bleve index needs notes built with -tags bleve")
+}
+
+func VerifyBleve(path string, notes note.List) ([]string, error) {
+	return nil, fmt.Errorf("the bleve index needs notes built with -tags bleve")
+}
+
+func (b *Bleve) Rebuild(notes note.List) error {
+	return nil
+}
+
+func (b *Bleve) Notify(e note.Event) {}
+
+func (b *Bleve) Search(s string) ([]query.Hit, error) {
+	return nil, nil
+}
+
+func (b *Bleve) Similar(id note.Id, limit int) ([]query.Hit, error) {
+	return nil, nil
+}
+
+func (b *Bleve) Highlight(q string, n note.Note) []query.Span {
+	return nil
+}
+
+func (b *Bleve) Close() error {
+	return nil
+}
@@ -0,0 +1,90 @@
+package search
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// Saved keeps the queries of the smart notebooks by name, in a json
+// file when it has a path and in memory otherwise
+type Saved struct {
+	path    string
+	mutex   *sync.Mutex
+	queries map[string]string
+}
+
+// NewSaved loads the queries saved in path, a missing file has none
+func NewSaved(path string) (Saved, error) {
+	s := Saved{path: path, mutex: &sync.Mutex{}, queries: map[string]string{}}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	err = json.Unmarshal(data, &s.queries)
+	return s, err
+}
+
+// Save replaces the query of name
+func (s Saved) Save(name string, query string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	previous, existed := s.queries[name]
+	s.queries[name] = query
+	err := s.write()
+	if err != nil && existed {
+		s.queries[name] = previous
+	} else if err != nil {
+		delete(s.queries, name)
+	}
+	return err
+}
+
+// Delete forgets the query of name, it tells whether there was one
+func (s Saved) Delete(name string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	query, ok := s.queries[name]
+	if !ok {
+		return false, nil
+	}
+	delete(s.queries, name)
+	err := s.write()
+	if err != nil {
+		s.queries[name] = query
+	}
+	return true, err
+}
+
+// All returns the queries by name
+func (s Saved) All() (map[string]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	queries := make(map[string]string, len(s.queries))
+	for name, query := range s.queries {
+		queries[name] = query
+	}
+	return queries, nil
+}
+
+func (s Saved) write() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.queries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	err = os.WriteFile(tmp, data, 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
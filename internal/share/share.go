@@ -0,0 +1,66 @@
+// Package share lets the owner of notes give other users access to
+// them: a share grants a user read or read/write access to one note or
+// to a whole notebook, and the store keeps the shares.
+package share
+
+import (
+	"time"
+
+	"notes/internal/note"
+)
+
+type Id = int
+
+// Access is what a user may do with a note, the zero access is none
+type Access string
+
+const (
+	Read  Access = "read"
+	Write Access = "write"
+)
+
+// Valid tells whether an access can be granted
+func (a Access) Valid() bool {
+	return a == Read || a == Write
+}
+
+// Allows tells whether a grants at least access b, writing allows
+// reading
+func (a Access) Allows(b Access) bool {
+	return a == Write || a == b && b != ""
+}
+
+// Share grants the access of a user to a note of its owner, or to every
+// note of one of the notebooks of its owner when Note is 0
+type Share struct {
+	Id       Id
+	Owner    note.UserId
+	Grantee  note.UserId
+	Note     note.Id
+	Notebook note.Notebook
+	Access   Access
+	Created  time.Time
+}
+
+// Covers tells whether the share is about a note
+func (s Share) Covers(n note.Note) bool {
+	if n.Owner != s.Owner {
+		return false
+	}
+	if s.Note != 0 {
+		return n.Id == s.Note
+	}
+	return s.Notebook != "" && n.Notebook == s.Notebook
+}
+
+// AccessTo is the widest access the shares grant to a note, none when
+// they don't cover it
+func AccessTo(shares []Share, n note.Note) Access {
+	access := Access("")
+	for _, s := range shares {
+		if s.Covers(n) && !access.Allows(s.Access) {
+			access = s.Access
+		}
+	}
+	return access
+}
@@ -0,0 +1,119 @@
+package share
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"notes/internal/note"
+)
+
+// Store keeps the shares, in a json file when it has a path and in
+// memory otherwise
+type Store struct {
+	path   string
+	mutex  *sync.RWMutex
+	shares *[]Share
+}
+
+// NewStore loads the shares kept in path, a missing file has none
+func NewStore(path string) (Store, error) {
+	s := Store{path: path, mutex: &sync.RWMutex{}, shares: &[]Share{}}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	err = json.Unmarshal(data, s.shares)
+	if err != nil {
+		return s, fmt.Errorf("shares %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Grant keeps a share under the next id, sharing the same note or
+// notebook with the same user again only changes the access
+func (s Store) Grant(granted Share) (Share, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	previous := slices.Clone(*s.shares)
+	last := Id(0)
+	for i, existing := range *s.shares {
+		if existing.Owner == granted.Owner && existing.Grantee == granted.Grantee && existing.Note == granted.Note && existing.Notebook == granted.Notebook {
+			(*s.shares)[i].Access = granted.Access
+			return (*s.shares)[i], s.restoreOnError(previous, s.write())
+		}
+		last = max(last, existing.Id)
+	}
+	granted.Id = last + 1
+	*s.shares = append(*s.shares, granted)
+	return granted, s.restoreOnError(previous, s.write())
+}
+
+// Revoke removes a share, a missing one fails with note.ErrNotFound
+func (s Store) Revoke(id Id) (Share, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	i := slices.IndexFunc(*s.shares, func(existing Share) bool { return existing.Id == id })
+	if i < 0 {
+		return Share{}, fmt.Errorf("share %d %w", id, note.ErrNotFound)
+	}
+	previous := slices.Clone(*s.shares)
+	revoked := (*s.shares)[i]
+	*s.shares = slices.Delete(*s.shares, i, i+1)
+	return revoked, s.restoreOnError(previous, s.write())
+}
+
+// Received returns the shares granted to a user
+func (s Store) Received(grantee note.UserId) []Share {
+	return s.filter(func(existing Share) bool { return existing.Grantee == grantee })
+}
+
+// Given returns the shares granted by a user
+func (s Store) Given(owner note.UserId) []Share {
+	return s.filter(func(existing Share) bool { return existing.Owner == owner })
+}
+
+func (s Store) filter(keep func(Share) bool) []Share {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	shares := []Share{}
+	for _, existing := range *s.shares {
+		if keep(existing) {
+			shares = append(shares, existing)
+		}
+	}
+	return shares
+}
+
+// restoreOnError puts the shares back as they were when writing them
+// failed
+func (s Store) restoreOnError(previous []Share, err error) error {
+	if err != nil {
+		*s.shares = previous
+	}
+	return err
+}
+
+func (s Store) write() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(*s.shares, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	err = os.WriteFile(tmp, data, 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,134 @@
+// Package slack answers the /note slash command of a Slack workspace.
+package slack
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"notes/internal/note"
+	"notes/internal/usecase"
+)
+
+// maxAge of a request, older ones may be replayed
+const maxAge = 5 * time.Minute
+
+// Command answers the slash command, "/note add NAME | CONTENT" creates
+// a note, "/note add TEXT" captures it to the inbox, "/note find TEXT"
+// and "/note get ID" read them
+// Requests are verified with the signing secret of the Slack app, the
+// changes are attributed to slack:<user name>.
+type Command struct {
+	usecase usecase.Usecase
+	secret  string
+	clock   usecase.Clock
+}
+
+func New(u usecase.Usecase, secret string, clock usecase.Clock) Command {
+	return Command{usecase: u, secret: secret, clock: clock}
+}
+
+// verify checks the signature of a request, see
+// https://api.slack.com/authentication/verifying-requests-from-slack
+func (c Command) verify(r *http.Request, body []byte) bool {
+	timestamp := r.Header.Get("X-Slack-Request-Timestamp")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	age := c.clock.Now().Sub(time.Unix(seconds, 0))
+	if age > maxAge || age < -maxAge {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(c.secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(r.Header.Get("X-Slack-Signature")))
+}
+
+type answer struct {
+	// ResponseType is in_channel to show the answer to everybody,
+	// ephemeral to show it to the user only
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+func (c Command) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !c.verify(r, body) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := usecase.Context{Actor: "slack:" + form.Get("user_name")}
+	a, err := c.run(ctx, form.Get("text"))
+	if err != nil {
+		a = answer{ResponseType: "ephemeral", Text: "Error: " + err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a)
+}
+
+func (c Command) run(ctx usecase.Context, text string) (answer, error) {
+	verb, arg, _ := strings.Cut(strings.TrimSpace(text), " ")
+	arg = strings.TrimSpace(arg)
+	switch strings.ToLower(verb) {
+	case "add":
+		name, content, ok := strings.Cut(arg, "|")
+		if !ok {
+			result, err := c.usecase.Quick.Execute(usecase.QuickMessage{Context: ctx, Content: arg})
+			if err != nil {
+				return answer{}, err
+			}
+			return answer{"in_channel", fmt.Sprintf("Captured note %d %q", result.Note.Id, result.Note.Name)}, nil
+		}
+		result, err := c.usecase.Create.Execute(usecase.CreateMessage{Context: ctx, Name: strings.TrimSpace(name), Content: strings.TrimSpace(content), Expand: true})
+		if err != nil {
+			return answer{}, err
+		}
+		return answer{"in_channel", fmt.Sprintf("Created note %d %q", result.Note.Id, result.Note.Name)}, nil
+	case "find":
+		result, err := c.usecase.Search.Execute(usecase.SearchMessage{Context: ctx, Query: arg})
+		if err != nil {
+			return answer{}, err
+		}
+		lines := bytes.Buffer{}
+		for _, n := range result.Notes {
+			fmt.Fprintf(&lines, "%d %s\n", n.Id, n.Name)
+		}
+		if lines.Len() == 0 {
+			return answer{"ephemeral", "No notes"}, nil
+		}
+		return answer{"ephemeral", strings.TrimSpace(lines.String())}, nil
+	case "get":
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return answer{}, fmt.Errorf("%w id: %q is not a number", note.ErrValidation, arg)
+		}
+		result, err := c.usecase.Read.Execute(usecase.ReadMessage{Context: ctx, Id: id})
+		if err != nil {
+			return answer{}, err
+		}
+		return answer{"ephemeral", "*" + result.Note.Name + "*\n" + result.Note.Content}, nil
+	default:
+		return answer{"ephemeral", "/note add NAME | CONTENT, /note add TEXT, /note find TEXT or /note get ID"}, nil
+	}
+}
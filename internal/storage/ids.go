@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"sync/atomic"
+
+	"notes/internal/note"
+)
+
+// IdGenerator gives the id of the notes being created, Seen tells it
+// about ids already in use, such as those loaded from a file, so they
+// are never given
+// Implementations must be safe for concurrent use
+type IdGenerator interface {
+	Next() note.Id
+	Seen(note.Id)
+}
+
+// Sequence counts up from the highest id given or seen
+type Sequence struct {
+	last *atomic.Int64
+}
+
+// NewSequence starts after last, the first id is last+1
+func NewSequence(last note.Id) Sequence {
+	s := Sequence{last: &atomic.Int64{}}
+	s.last.Store(int64(last))
+	return s
+}
+
+func (s Sequence) Next() note.Id {
+	return note.Id(s.last.Add(1))
+}
+
+func (s Sequence) Seen(id note.Id) {
+	for {
+		last := s.last.Load()
+		if int64(id) <= last || s.last.CompareAndSwap(last, int64(id)) {
+			return
+		}
+	}
+}
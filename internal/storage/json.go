@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync/atomic"
+
+	"notes/internal/note"
+)
+
+// Json works in memory and writes all the notes to a json file on
+// save, the file is loaded when the storage is created
+// The file keeps the last id given so ids of deleted notes are not
+// given again after a restart
+// The contents may be compressed in the file, see Compressing, they are
+// always kept in the clear in memory.
+type Json struct {
+	InMemory
+	path   string
+	dirty  *atomic.Bool
+	lastId *atomic.Int64
+	// compressAbove is the size of the contents compressed on save, none
+	// are when 0
+	compressAbove int
+}
+
+type jsonNote struct {
+	Id      note.Id      `json:"id"`
+	Name    note.Name    `json:"name"`
+	Content note.Content `json:"content"`
+	// Gzip is the content compressed with gzip in base64, Content is
+	// empty then
+	Gzip     string        `json:"gzip,omitempty"`
+	Notebook note.Notebook `json:"notebook,omitempty"`
+	Owner    note.UserId   `json:"owner,omitempty"`
+	Metadata note.Metadata `json:"metadata,omitempty"`
+}
+
+type jsonFile struct {
+	LastId note.Id    `json:"lastId"`
+	Notes  []jsonNote `json:"notes"`
+}
+
+// NewJson loads the notes of a json file, a missing file is an empty
+// storage which will be created on the first save
+// New notes take their ids from ids, which sees those of the file
+// Files holding only the list of notes, as first written, are read too
+func NewJson(path string, ids IdGenerator) (Json, error) {
+	s := Json{InMemory: NewInMemory(ids), path: path, dirty: &atomic.Bool{}, lastId: &atomic.Int64{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	file := jsonFile{}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		err = json.Unmarshal(data, &file.Notes)
+	} else {
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return s, err
+	}
+	for _, n := range file.Notes {
+		if n.Gzip != "" {
+			n.Content, err = decompress(n.Gzip)
+			if err != nil {
+				return s, fmt.Errorf("note %d: %w", n.Id, err)
+			}
+		}
+		s.InMemory.Restore(note.Note{Id: n.Id, Name: n.Name, Content: n.Content, Notebook: n.Notebook, Owner: n.Owner, Metadata: n.Metadata})
+		s.seen(n.Id)
+	}
+	s.seen(file.LastId)
+	return s, nil
+}
+
+// seen tells the id generator about an id and keeps it if it is the
+// last one
+func (s Json) seen(id note.Id) {
+	s.ids.Seen(id)
+	for {
+		last := s.lastId.Load()
+		if int64(id) <= last || s.lastId.CompareAndSwap(last, int64(id)) {
+			return
+		}
+	}
+}
+
+// Compressing is the storage saving the contents of more than above
+// bytes compressed, the files it writes can't be read by the versions
+// which don't know about compression
+// The contents saved before are compressed on the next save.
+func (s Json) Compressing(above int) Json {
+	s.compressAbove = above
+	return s
+}
+
+func (s Json) Create(name note.Name, content note.Content, notebook note.Notebook, owner note.UserId) note.Note {
+	s.dirty.Store(true)
+	n := s.InMemory.Create(name, content, notebook, owner)
+	s.seen(n.Id)
+	return n
+}
+
+func (s Json) Update(id note.Id, name note.Name, content note.Content) note.Note {
+	s.dirty.Store(true)
+	return s.InMemory.Update(id, name, content)
+}
+
+func (s Json) Rename(id note.Id, name note.Name) note.Note {
+	s.dirty.Store(true)
+	return s.InMemory.Rename(id, name)
+}
+
+func (s Json) Delete(id note.Id) note.Note {
+	s.dirty.Store(true)
+	return s.InMemory.Delete(id)
+}
+
+func (s Json) Restore(n note.Note) note.Note {
+	s.dirty.Store(true)
+	return s.InMemory.Restore(n)
+}
+
+func (s Json) Unsaved() bool {
+	return s.dirty.Load()
+}
+
+// Save writes to a temporary file first so a failed write can't
+// corrupt the previous save
+// The notes are unsaved again when they change while they are written,
+// the flag is cleared before they are read.
+func (s Json) Save() error {
+	s.dirty.Store(false)
+	err := s.write()
+	if err != nil {
+		s.dirty.Store(true)
+	}
+	return err
+}
+
+func (s Json) write() error {
+	file := jsonFile{LastId: note.Id(s.lastId.Load()), Notes: []jsonNote{}}
+	for _, n := range s.ReadAll() {
+		saved := jsonNote{Id: n.Id, Name: n.Name, Content: n.Content, Notebook: n.Notebook, Owner: n.Owner, Metadata: n.Metadata}
+		if s.compressAbove > 0 && len(n.Content) > s.compressAbove {
+			compressed, err := compress(n.Content)
+			if err != nil {
+				return err
+			}
+			saved.Content, saved.Gzip = "", compressed
+		}
+		file.Notes = append(file.Notes, saved)
+	}
+	return writeJson(s.path, file, 0o644)
+}
+
+// Dump writes the notes of any storage to a new file of the json
+// storage, which only its owner may read, to get them back with a json
+// storage
+func Dump(s Storage, path string) error {
+	file := jsonFile{Notes: []jsonNote{}}
+	for _, n := range s.ReadAll() {
+		file.Notes = append(file.Notes, jsonNote{Id: n.Id, Name: n.Name, Content: n.Content, Notebook: n.Notebook, Owner: n.Owner, Metadata: n.Metadata})
+		file.LastId = max(file.LastId, n.Id)
+	}
+	return writeJson(path, file, 0o600)
+}
+
+// writeJson writes to a temporary file first so a failed write can't
+// corrupt the previous one, the notes are sorted by id
+func writeJson(path string, file jsonFile, perm os.FileMode) error {
+	sort.Slice(file.Notes, func(i, j int) bool { return file.Notes[i].Id < file.Notes[j].Id })
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, data, perm)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func compress(content note.Content) (string, error) {
+	buffer := bytes.Buffer{}
+	writer := gzip.NewWriter(&buffer)
+	_, err := writer.Write([]byte(content))
+	if err != nil {
+		return "", err
+	}
+	err = writer.Close()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buffer.Bytes()), nil
+}
+
+func decompress(compressed string) (note.Content, error) {
+	data, err := base64.StdEncoding.DecodeString(compressed)
+	if err != nil {
+		return "", fmt.Errorf("gzip: %w", err)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("gzip: %w", err)
+	}
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("gzip: %w", err)
+	}
+	return string(content), nil
+}
@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"cmp"
+	"iter"
+	"slices"
+	"sync"
+
+	"notes/internal/crypt"
+	"notes/internal/note"
+)
+
+// InMemory saves data in memory during the programme execution
+// there is no persistance
+// The notes are spread over shards, each with its own lock, so the
+// requests on different notes don't wait for each other. Going through
+// all the notes locks one shard after the other, the notes changed
+// meanwhile may be seen in either state.
+type InMemory struct {
+	shards []shard
+	ids    IdGenerator
+}
+
+// shardCount is the number of shards, the notes are spread over them by
+// id and their ids follow each other, so a power of two is enough
+const shardCount = 32
+
+type shard struct {
+	mutex *sync.RWMutex
+	notes map[note.Id]note.Note
+}
+
+// NewInMemory takes the ids of new notes from ids
+func NewInMemory(ids IdGenerator) InMemory {
+	return newSharded(ids, shardCount)
+}
+
+// newSharded spreads the notes over count shards, a single one locks
+// every request out of the others as the storage first did
+func newSharded(ids IdGenerator, count int) InMemory {
+	shards := make([]shard, count)
+	for i := range shards {
+		shards[i] = shard{mutex: &sync.RWMutex{}, notes: map[note.Id]note.Note{}}
+	}
+	return InMemory{shards: shards, ids: ids}
+}
+
+// measured caches the length of the content of a note
+func measured(n note.Note) note.Note {
+	n.Length = note.Length{}
+	if !crypt.IsSealed(n.Content) {
+		n.Length = note.LengthOf(n.Content)
+	}
+	return n
+}
+
+// shardOf is the shard keeping the note of an id
+func (s InMemory) shardOf(id note.Id) shard {
+	return s.shards[uint(id)%uint(len(s.shards))]
+}
+
+func (s InMemory) Read(id note.Id) note.Note {
+	sh := s.shardOf(id)
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+	return sh.notes[id]
+}
+
+func (s InMemory) ReadAll() note.List {
+	return s.Find(Filter{})
+}
+
+func (s InMemory) Find(f Filter) note.List {
+	notes := note.List{}
+	for _, sh := range s.shards {
+		sh.mutex.RLock()
+		for _, n := range sh.notes {
+			if f.Matches(n) {
+				notes = append(notes, n)
+			}
+		}
+		sh.mutex.RUnlock()
+	}
+	// the shards hold the notes in no order
+	slices.SortFunc(notes, func(a, b note.Note) int { return cmp.Compare(a.Id, b.Id) })
+	return notes
+}
+
+// Each only holds the locks to take the ids, and to read each note in
+// turn, so the notes can be changed while going through them
+func (s InMemory) Each(f Filter) iter.Seq[note.Note] {
+	return func(yield func(note.Note) bool) {
+		ids := []note.Id{}
+		for _, sh := range s.shards {
+			sh.mutex.RLock()
+			for id := range sh.notes {
+				ids = append(ids, id)
+			}
+			sh.mutex.RUnlock()
+		}
+		slices.Sort(ids)
+		for _, id := range ids {
+			sh := s.shardOf(id)
+			sh.mutex.RLock()
+			n, ok := sh.notes[id]
+			sh.mutex.RUnlock()
+			if !ok || !f.Matches(n) {
+				continue
+			}
+			if !yield(n) {
+				return
+			}
+		}
+	}
+}
+
+func (s InMemory) Count(f Filter) int {
+	count := 0
+	for _, sh := range s.shards {
+		sh.mutex.RLock()
+		for _, n := range sh.notes {
+			if f.Matches(n) {
+				count++
+			}
+		}
+		sh.mutex.RUnlock()
+	}
+	return count
+}
+
+func (s InMemory) Exists(id note.Id) bool {
+	sh := s.shardOf(id)
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+	_, ok := sh.notes[id]
+	return ok
+}
+
+// Create takes the id first, the generator is safe for concurrent use,
+// then only locks the shard of the note
+func (s InMemory) Create(name note.Name, content note.Content, notebook note.Notebook, owner note.UserId) note.Note {
+	newId := s.ids.Next()
+	newNote := measured(note.Note{
+		Id:       newId,
+		Name:     name,
+		Content:  content,
+		Notebook: notebook,
+		Owner:    owner,
+	})
+	sh := s.shardOf(newId)
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+	sh.notes[newId] = newNote
+	return newNote
+}
+
+func (s InMemory) Update(id note.Id, name note.Name, content note.Content) note.Note {
+	sh := s.shardOf(id)
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+	n := sh.notes[id]
+	if name != "" {
+		n.Name = name
+	}
+	if content != "" {
+		n.Content = content
+		n = measured(n)
+	}
+	sh.notes[id] = n
+	return n
+}
+
+func (s InMemory) Rename(id note.Id, name note.Name) note.Note {
+	sh := s.shardOf(id)
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+	n := sh.notes[id]
+	n.Name = name
+	sh.notes[id] = n
+	return n
+}
+
+func (s InMemory) Delete(id note.Id) note.Note {
+	sh := s.shardOf(id)
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+	n := sh.notes[id]
+	delete(sh.notes, id)
+	return n
+}
+
+// Restore puts a note back under its original id
+func (s InMemory) Restore(n note.Note) note.Note {
+	n = measured(n)
+	sh := s.shardOf(n.Id)
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+	sh.notes[n.Id] = n
+	return n
+}
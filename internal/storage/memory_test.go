@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"notes/internal/note"
+)
+
+// benchmarkNotes are the notes in the storage before the reads and the
+// updates
+const benchmarkNotes = 1000
+
+// shardCounts compare the storage as it was, behind a single lock, with
+// the sharded one, go test -bench InMemory -cpu 1,4,8 shows how they
+// scale
+var shardCounts = []int{1, shardCount}
+
+// filled is a storage of count shards holding benchmarkNotes notes
+func filled(count int) InMemory {
+	s := newSharded(NewSequence(0), count)
+	for i := range benchmarkNotes {
+		s.Create(fmt.Sprintf("note %d", i), "some content to measure", "inbox", 0)
+	}
+	return s
+}
+
+func BenchmarkInMemoryCreate(b *testing.B) {
+	for _, count := range shardCounts {
+		b.Run(fmt.Sprintf("shards=%d", count), func(b *testing.B) {
+			s := newSharded(NewSequence(0), count)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					s.Create("name", "some content to measure", "inbox", 0)
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkInMemoryRead(b *testing.B) {
+	for _, count := range shardCounts {
+		b.Run(fmt.Sprintf("shards=%d", count), func(b *testing.B) {
+			s := filled(count)
+			next := atomic.Int64{}
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					s.Read(note.Id(next.Add(1)%benchmarkNotes + 1))
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkInMemoryUpdate(b *testing.B) {
+	for _, count := range shardCounts {
+		b.Run(fmt.Sprintf("shards=%d", count), func(b *testing.B) {
+			s := filled(count)
+			next := atomic.Int64{}
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					s.Update(note.Id(next.Add(1)%benchmarkNotes+1), "name", "some other content to measure")
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkInMemoryMixed reads nine notes for every note updated, as the
+// HTTP server does
+func BenchmarkInMemoryMixed(b *testing.B) {
+	for _, count := range shardCounts {
+		b.Run(fmt.Sprintf("shards=%d", count), func(b *testing.B) {
+			s := filled(count)
+			next := atomic.Int64{}
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					i := next.Add(1)
+					id := note.Id(i%benchmarkNotes + 1)
+					if i%10 == 0 {
+						s.Update(id, "name", "some other content to measure")
+					} else {
+						s.Read(id)
+					}
+				}
+			})
+		})
+	}
+}
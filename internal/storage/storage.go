@@ -0,0 +1,47 @@
+// Package storage defines where notes are kept. The usecases only know
+// the Storage interface, this package provides its implementations.
+package storage
+
+import (
+	"iter"
+
+	"notes/internal/note"
+)
+
+// Storage reads and writes notes. Reading a missing note returns the
+// zero note. ReadAll and Find list the notes in the order of their ids.
+// Each goes through the notes matching a filter one at a time, in the
+// order of their ids, rather than in a list of them all. The notes may
+// be changed meanwhile, a note deleted before its turn is skipped.
+type Storage interface {
+	ReadAll() note.List
+	Find(Filter) note.List
+	Each(Filter) iter.Seq[note.Note]
+	Read(note.Id) note.Note
+	Count(Filter) int
+	Exists(note.Id) bool
+	Create(note.Name, note.Content, note.Notebook, note.UserId) note.Note
+	Update(note.Id, note.Name, note.Content) note.Note
+	Rename(note.Id, note.Name) note.Note
+	Delete(note.Id) note.Note
+	Restore(note.Note) note.Note
+}
+
+// Filter selects notes, zero fields match every note
+type Filter struct {
+	Notebook note.Notebook
+	// Owner selects the notes of a user
+	Owner note.UserId
+}
+
+func (f Filter) Matches(n note.Note) bool {
+	return (f.Notebook == "" || n.Notebook == f.Notebook) && (f.Owner == 0 || n.Owner == f.Owner)
+}
+
+// Persistent is implemented by storages keeping the notes somewhere
+// else than in memory, changes stay unsaved until Save.
+type Persistent interface {
+	Storage
+	Save() error
+	Unsaved() bool
+}
@@ -0,0 +1,280 @@
+// Package telegram runs the usecases from the messages sent to a
+// Telegram bot.
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"notes/internal/note"
+	"notes/internal/usecase"
+)
+
+// maxLength of a Telegram message, in UTF-16 code units
+const maxLength = 4096
+
+// Config of the bot
+type Config struct {
+	// Token of the bot given by BotFather
+	Token string
+	// Users who may use the bot, by user name or id, nobody when empty
+	Users []string
+	// API defaults to https://api.telegram.org
+	API string
+}
+
+// Bot polls Telegram for the messages sent to it, its commands are
+// /new, /list, /find and /get
+// Changes are attributed to telegram:<user name>.
+type Bot struct {
+	usecase usecase.Usecase
+	config  Config
+	http    *http.Client
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func New(u usecase.Usecase, config Config) Bot {
+	if config.API == "" {
+		config.API = "https://api.telegram.org"
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return Bot{usecase: u, config: config, http: &http.Client{}, ctx: ctx, cancel: cancel}
+}
+
+type update struct {
+	UpdateId int64    `json:"update_id"`
+	Message  *message `json:"message"`
+}
+
+type message struct {
+	Text string `json:"text"`
+	Chat struct {
+		Id int64 `json:"id"`
+	} `json:"chat"`
+	From struct {
+		Id       int64  `json:"id"`
+		Username string `json:"username"`
+	} `json:"from"`
+}
+
+// call calls a method of the bot API, result gets the result of the
+// answer
+func (b Bot) call(method string, params any, result any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(b.ctx, http.MethodPost, b.config.API+"/bot"+b.config.Token+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := b.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	answer := struct {
+		Ok          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}{}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(data, &answer)
+	if err != nil {
+		return fmt.Errorf("telegram %s: %s", method, resp.Status)
+	}
+	if !answer.Ok {
+		return fmt.Errorf("telegram %s: %s", method, answer.Description)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(answer.Result, result)
+}
+
+// Run polls until Stop is called, failures are reported on stderr and
+// polling goes on after a while
+func (b Bot) Run() {
+	offset := int64(0)
+	for b.ctx.Err() == nil {
+		updates := []update{}
+		err := b.call("getUpdates", map[string]any{"offset": offset, "timeout": 30, "allowed_updates": []string{"message"}}, &updates)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return
+			}
+			fmt.Fprintln(os.Stderr, "notes:", err)
+			select {
+			case <-b.ctx.Done():
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+		for _, u := range updates {
+			offset = u.UpdateId + 1
+			if u.Message != nil {
+				b.handle(*u.Message)
+			}
+		}
+	}
+}
+
+// Stop interrupts the polling
+func (b Bot) Stop() {
+	b.cancel()
+}
+
+func (b Bot) allowed(m message) bool {
+	return slices.Contains(b.config.Users, m.From.Username) ||
+		slices.Contains(b.config.Users, strconv.FormatInt(m.From.Id, 10))
+}
+
+// handle answers a message, the command is its first word
+func (b Bot) handle(m message) {
+	if !b.allowed(m) {
+		b.reply(m, "Sorry, this bot is private")
+		return
+	}
+	ctx := usecase.Context{Actor: "telegram:" + m.From.Username}
+	if m.From.Username == "" {
+		ctx.Actor = "telegram:" + strconv.FormatInt(m.From.Id, 10)
+	}
+	first, rest, _ := strings.Cut(strings.TrimSpace(m.Text), "\n")
+	command, arg, _ := strings.Cut(first, " ")
+	// commands may be addressed to the bot, as /get@notes_bot in groups
+	command, _, _ = strings.Cut(command, "@")
+	arg = strings.TrimSpace(arg)
+	var text string
+	var err error
+	switch command {
+	case "/new":
+		text, err = b.create(ctx, arg, rest)
+	case "/list":
+		text, err = b.list(ctx)
+	case "/find":
+		text, err = b.find(ctx, arg)
+	case "/get":
+		text, err = b.get(ctx, arg)
+	default:
+		text = "/new NAME then the content on the next lines, or /new TEXT to capture it to the inbox\n/list\n/find TEXT\n/get ID"
+	}
+	if err != nil {
+		text = "Error: " + err.Error()
+	}
+	b.reply(m, text)
+}
+
+func (b Bot) create(ctx usecase.Context, name string, content string) (string, error) {
+	if strings.TrimSpace(content) == "" {
+		result, err := b.usecase.Quick.Execute(usecase.QuickMessage{Context: ctx, Content: name})
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Captured note %d %q", result.Note.Id, result.Note.Name), nil
+	}
+	result, err := b.usecase.Create.Execute(usecase.CreateMessage{Context: ctx, Name: name, Content: content, Expand: true})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Created note %d %q", result.Note.Id, result.Note.Name), nil
+}
+
+func (b Bot) list(ctx usecase.Context) (string, error) {
+	result, err := b.usecase.ReadAll.Execute(usecase.ReadAllMessage{Context: ctx})
+	if err != nil {
+		return "", err
+	}
+	notes := append(note.List{}, result.Notes...)
+	slices.SortFunc(notes, func(a, b note.Note) int { return a.Id - b.Id })
+	return titles(notes), nil
+}
+
+// find lists the notes found, the best match first
+func (b Bot) find(ctx usecase.Context, query string) (string, error) {
+	result, err := b.usecase.Search.Execute(usecase.SearchMessage{Context: ctx, Query: query})
+	if err != nil {
+		return "", err
+	}
+	return titles(result.Notes), nil
+}
+
+func titles(notes note.List) string {
+	if len(notes) == 0 {
+		return "No notes"
+	}
+	lines := []string{}
+	for _, n := range notes {
+		lines = append(lines, fmt.Sprintf("%d %s", n.Id, n.Name))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (b Bot) get(ctx usecase.Context, arg string) (string, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return "", fmt.Errorf("%w id: %q is not a number", note.ErrValidation, arg)
+	}
+	result, err := b.usecase.Read.Execute(usecase.ReadMessage{Context: ctx, Id: id})
+	if err != nil {
+		return "", err
+	}
+	return result.Note.Name + "\n\n" + result.Note.Content, nil
+}
+
+// reply sends the text in as many messages as it takes
+func (b Bot) reply(m message, text string) {
+	for _, part := range split(text, maxLength) {
+		err := b.call("sendMessage", map[string]any{"chat_id": m.Chat.Id, "text": part}, nil)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "notes:", err)
+			return
+		}
+	}
+}
+
+// split cuts a text in parts of at most limit UTF-16 code units, as
+// Telegram counts them, at the last line break of each part when there
+// is one
+func split(text string, limit int) []string {
+	parts := []string{}
+	for {
+		runes := []rune(text)
+		end, length := 0, 0
+		for end < len(runes) {
+			n := len(utf16.Encode(runes[end : end+1]))
+			if length+n > limit {
+				break
+			}
+			length += n
+			end++
+		}
+		if end == len(runes) {
+			if strings.TrimSpace(text) != "" || len(parts) == 0 {
+				parts = append(parts, text)
+			}
+			return parts
+		}
+		part := string(runes[:end])
+		if i := strings.LastIndex(part, "\n"); i > 0 {
+			part = part[:i]
+		}
+		parts = append(parts, part)
+		text = strings.TrimPrefix(text[len(part):], "\n")
+	}
+}
@@ -0,0 +1,103 @@
+package usecase
+
+import (
+	"fmt"
+	"time"
+
+	"notes/internal/audit"
+	"notes/internal/note"
+	"notes/internal/storage"
+)
+
+// Policy archives the notes of a notebook untouched for some time
+type Policy struct {
+	Notebook note.Notebook
+	// After is how long a note stays untouched before it is archived
+	After time.Duration
+	// Archive is the notebook the notes are moved to, "archive" when
+	// empty
+	Archive note.Notebook
+}
+
+func (p Policy) String() string {
+	return fmt.Sprintf("%s after %s to %s", p.Notebook, p.After, p.archive())
+}
+
+func (p Policy) archive() note.Notebook {
+	if p.Archive == "" {
+		return "archive"
+	}
+	return p.Archive
+}
+
+// Archived is a note moved by a policy
+type Archived struct {
+	Note note.Note
+	From note.Notebook
+	// Touched is when the note last changed
+	Touched time.Time
+}
+
+// Archive usecase
+// Moves the notes of the user untouched for longer than the policies
+// allow to their archive notebook, each move is a NoteMoved event
+// A note is touched when it changes, as recorded in the audit log, the
+// notes without any recorded change are never archived.
+type ArchiveCommand struct {
+	storage storage.Storage
+	events  *EventBus
+	log     audit.Store
+	clock   Clock
+}
+type ArchiveMessage struct {
+	Context
+	Policies []Policy
+}
+type ArchiveResult struct {
+	Archived []Archived
+	DryRun   bool
+}
+
+func (i ArchiveMessage) validate() error {
+	for _, p := range i.Policies {
+		if p.Notebook == "" {
+			return fmt.Errorf("%w policy: no notebook", note.ErrValidation)
+		}
+		if p.After <= 0 {
+			return fmt.Errorf("%w policy %s: the notes must stay untouched for a while", note.ErrValidation, p.Notebook)
+		}
+		if p.archive() == p.Notebook {
+			return fmt.Errorf("%w policy %s: the notes are archived where they are", note.ErrValidation, p.Notebook)
+		}
+	}
+	return nil
+}
+
+func (u ArchiveCommand) Execute(i ArchiveMessage) (ArchiveResult, error) {
+	entries, err := u.log.Query(audit.Query{Owner: i.User})
+	if err != nil {
+		return ArchiveResult{}, err
+	}
+	touched := map[note.Id]time.Time{}
+	for _, e := range entries {
+		touched[e.NoteId] = e.At
+	}
+	now := u.clock.Now()
+	archived := []Archived{}
+	for _, p := range i.Policies {
+		for n := range u.storage.Each(storage.Filter{Notebook: p.Notebook, Owner: i.User}) {
+			t, ok := touched[n.Id]
+			if !ok || now.Sub(t) < p.After {
+				continue
+			}
+			moved := n
+			moved.Notebook = p.archive()
+			if !i.DryRun {
+				moved = u.storage.Restore(moved)
+				u.events.publish(i.Context, note.Moved, moved, n)
+			}
+			archived = append(archived, Archived{Note: moved, From: n.Notebook, Touched: t})
+		}
+	}
+	return ArchiveResult{Archived: archived, DryRun: i.DryRun}, nil
+}
@@ -0,0 +1,240 @@
+package usecase
+
+import (
+	"fmt"
+	"iter"
+	"slices"
+
+	"notes/internal/note"
+	"notes/internal/share"
+	"notes/internal/storage"
+)
+
+// Authorizer decides whether a subject may run a command on a note, the
+// action is the name of the command, or lintReport for a lint writing
+// its report, and the note is zero for commands which don't target one,
+// such as readAll
+// A refusal should wrap note.ErrForbidden
+type Authorizer interface {
+	Authorize(subject string, action string, n note.Note) error
+}
+
+// AuthorizerFunc lets a plain function be an authorizer
+type AuthorizerFunc func(subject string, action string, n note.Note) error
+
+func (f AuthorizerFunc) Authorize(subject string, action string, n note.Note) error {
+	return f(subject, action, n)
+}
+
+// Authorizing asks the authorizer before every command, the subject is
+// the actor of the message context
+func Authorizing(a Authorizer, s storage.Storage) Decorator {
+	return func(name string, next Execute) Execute {
+		return func(message any) (any, error) {
+			subject := ""
+			if m, ok := message.(interface{ context() Context }); ok {
+				subject = m.context().Actor
+			}
+			n := note.Note{}
+			if m, ok := message.(interface {
+				target(storage.Storage) note.Note
+			}); ok {
+				n = m.target(s)
+			}
+			err := a.Authorize(subject, actionOf(name, message), n)
+			if err != nil {
+				return nil, err
+			}
+			return next(message)
+		}
+	}
+}
+
+// ReadOnly lets its subjects run only the commands which don't change
+// notes, everybody else may run every command
+// Saving is allowed, it only keeps the changes of others
+type ReadOnly []string
+
+var readCommands = []string{"read", "readAll", "list", "count", "exists", "locked", "audit", "activity", "recent", "dashboard", "status", "save", "search", "similar", "notebooks", "login", "authenticate", "shares", "sharedWithMe", "apiTokens", "usage", "export", "due", "dedupe", "lint", "diff", "suggestTitle"}
+
+func (r ReadOnly) Authorize(subject string, action string, n note.Note) error {
+	if !slices.Contains(r, subject) || slices.Contains(readCommands, action) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s is read only", note.ErrForbidden, subject)
+}
+
+// Scoping refuses the commands changing anything to a read-only
+// context, whatever the authorizer
+func Scoping(name string, next Execute) Execute {
+	return func(message any) (any, error) {
+		m, ok := message.(interface{ context() Context })
+		action := actionOf(name, message)
+		if ok && m.context().ReadOnly && !slices.Contains(readCommands, action) {
+			return nil, fmt.Errorf("%w: %s needs a read/write token", note.ErrForbidden, action)
+		}
+		return next(message)
+	}
+}
+
+// actionOf is what a message does, the name of its command unless the
+// message tells otherwise
+func actionOf(name string, message any) string {
+	if m, ok := message.(interface{ action() string }); ok {
+		return m.action()
+	}
+	return name
+}
+
+// action of a lint is a write when it keeps its report in a note
+func (i LintMessage) action() string {
+	if i.Report {
+		return "lintReport"
+	}
+	return "lint"
+}
+
+// Targets of the commands, the note a command is about to read or change
+func (i ReadMessage) target(s storage.Storage) note.Note {
+	return s.Read(i.Id)
+}
+
+func (i ExistsMessage) target(s storage.Storage) note.Note {
+	return s.Read(i.Id)
+}
+
+func (i SimilarMessage) target(s storage.Storage) note.Note {
+	return s.Read(i.Id)
+}
+
+func (i CreateMessage) target(s storage.Storage) note.Note {
+	return note.Note{Name: i.Name, Content: i.Content, Notebook: i.Notebook, Owner: i.User}
+}
+
+func (i QuickMessage) target(s storage.Storage) note.Note {
+	return note.Note{Content: i.Content, Owner: i.User}
+}
+
+func (i UpdateMessage) target(s storage.Storage) note.Note {
+	return s.Read(i.Id)
+}
+
+func (i RenameMessage) target(s storage.Storage) note.Note {
+	return s.Read(i.Id)
+}
+
+func (i MoveMessage) target(s storage.Storage) note.Note {
+	return s.Read(i.Id)
+}
+
+func (i ColorMessage) target(s storage.Storage) note.Note {
+	return s.Read(i.Id)
+}
+
+func (i PinMessage) target(s storage.Storage) note.Note {
+	return s.Read(i.Id)
+}
+
+func (i LockMessage) target(s storage.Storage) note.Note {
+	return s.Read(i.Id)
+}
+
+func (i UnlockMessage) target(s storage.Storage) note.Note {
+	return s.Read(i.Id)
+}
+
+func (i LockedMessage) target(s storage.Storage) note.Note {
+	return s.Read(i.Id)
+}
+
+func (i DeleteMessage) target(s storage.Storage) note.Note {
+	return s.Read(i.Id)
+}
+
+func (i MergeMessage) target(s storage.Storage) note.Note {
+	return s.Read(i.Id)
+}
+
+func (i SummarizeMessage) target(s storage.Storage) note.Note {
+	return s.Read(i.Id)
+}
+
+func (i DiffMessage) target(s storage.Storage) note.Note {
+	return s.Read(i.Id)
+}
+
+func (i RestoreMessage) target(s storage.Storage) note.Note {
+	return i.Note
+}
+
+func (i AuditMessage) target(s storage.Storage) note.Note {
+	return s.Read(i.NoteId)
+}
+
+func (i ActivityMessage) target(s storage.Storage) note.Note {
+	return s.Read(i.Id)
+}
+
+func (i EmailMessage) target(s storage.Storage) note.Note {
+	return s.Read(i.Id)
+}
+
+func (i PublishMessage) target(s storage.Storage) note.Note {
+	return s.Read(i.Id)
+}
+
+// Permissions of the users on the notes of others
+// access tells what the user of a context may do with a note: anything
+// without an account, from the REPL or a server hosting a single person,
+// or as its owner, and otherwise what the shares they received grant
+func (c Context) access(shares Shares, n note.Note) share.Access {
+	if c.User == 0 || n.Owner == c.User {
+		return share.Write
+	}
+	if shares == nil {
+		return ""
+	}
+	return share.AccessTo(shares.Received(c.User), n)
+}
+
+// readShared reads a note the user of the context may read along with
+// their access to it, the other notes read as missing so their ids
+// don't tell they exist
+func readShared(s storage.Storage, shares Shares, c Context, id note.Id) (note.Note, share.Access) {
+	n := s.Read(id)
+	access := c.access(shares, n)
+	if n.Id == 0 || !access.Allows(share.Read) {
+		return note.Note{}, ""
+	}
+	return n, access
+}
+
+// eachShared goes through the notes matching a filter which the user of
+// the context may read
+func eachShared(s storage.Storage, shares Shares, c Context, f storage.Filter) iter.Seq[note.Note] {
+	if c.User == 0 {
+		return s.Each(f)
+	}
+	received := []share.Share{}
+	if shares != nil {
+		received = shares.Received(c.User)
+	}
+	return func(yield func(note.Note) bool) {
+		for n := range s.Each(f) {
+			if n.Owner != c.User && share.AccessTo(received, n) == "" {
+				continue
+			}
+			if !yield(n) {
+				return
+			}
+		}
+	}
+}
+
+// writable refuses to change a note shared read only
+func writable(n note.Note, access share.Access) error {
+	if !access.Allows(share.Write) {
+		return fmt.Errorf("note %d %w: it is shared read only", n.Id, note.ErrForbidden)
+	}
+	return nil
+}
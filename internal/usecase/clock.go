@@ -0,0 +1,23 @@
+package usecase
+
+import "time"
+
+// Clock tells the time to the commands and the event bus, a fixed clock
+// makes note names and event times predictable
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the wall clock
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// ClockFunc lets a plain function be a clock
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
@@ -0,0 +1,126 @@
+package usecase
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"notes/internal/note"
+	"notes/internal/storage"
+)
+
+// ColorKey is the key of the color of a note in its metadata
+const ColorKey = "color"
+
+// NotebookColors keep the colors of the notebooks of every user, a
+// color.Store does
+type NotebookColors interface {
+	// Set colors a notebook, the empty color removes its color
+	Set(user note.UserId, notebook note.Notebook, color string) error
+	// Of returns the colors of the notebooks of a user by name
+	Of(user note.UserId) map[note.Notebook]string
+}
+
+// notebookColors are the colors of the notebooks of a user by name, none
+// without NotebookColors
+func notebookColors(colors NotebookColors, user note.UserId) map[note.Notebook]string {
+	if colors == nil {
+		return map[note.Notebook]string{}
+	}
+	return colors.Of(user)
+}
+
+// colorOf is the color of a note, or the one of its notebook when it has
+// none
+func colorOf(n note.Note, notebooks map[note.Notebook]string) string {
+	if color, ok := n.Metadata[ColorKey]; ok {
+		return color
+	}
+	return notebooks[n.Notebook]
+}
+
+func validColor(color string) error {
+	if color != "" && !slices.Contains(note.Colors, color) {
+		return fmt.Errorf("%w color: %q is not one of %v", note.ErrValidation, color, note.Colors)
+	}
+	return nil
+}
+
+// Color usecase
+// Labels a note, or a notebook when no note is given, with a color of
+// the palette, an empty color removes the label
+type ColorCommand struct {
+	storage storage.Storage
+	shares  Shares
+	events  *EventBus
+	colors  NotebookColors
+}
+type ColorMessage struct {
+	Context
+	Id       note.Id
+	Notebook note.Notebook
+	Color    string
+}
+type ColorResult struct {
+	// Note is the note colored, the zero note for a notebook
+	Note     note.Note
+	Notebook note.Notebook
+	Color    string
+	DryRun   bool
+}
+
+func (i ColorMessage) validate() error {
+	if i.Id == 0 && strings.TrimSpace(i.Notebook) == "" {
+		return fmt.Errorf("%w color: no note nor notebook to color", note.ErrValidation)
+	}
+	return validColor(i.Color)
+}
+
+func (u ColorCommand) Execute(i ColorMessage) (ColorResult, error) {
+	if i.Id == 0 {
+		return u.colorNotebook(i)
+	}
+	n, access := readShared(u.storage, u.shares, i.Context, i.Id)
+	if n.Id == 0 {
+		return ColorResult{}, fmt.Errorf("note %d %w", i.Id, note.ErrNotFound)
+	}
+	err := writable(n, access)
+	if err != nil {
+		return ColorResult{}, err
+	}
+	colored := n
+	colored.Metadata = n.Metadata.With(ColorKey, i.Color)
+	if i.Color == "" {
+		colored.Metadata = n.Metadata.Without(ColorKey)
+	}
+	if i.DryRun {
+		return ColorResult{Note: colored, Notebook: n.Notebook, Color: i.Color, DryRun: true}, nil
+	}
+	colored = u.storage.Restore(colored)
+	u.events.publish(i.Context, note.Updated, colored, n)
+	return ColorResult{
+		Note:     colored,
+		Notebook: colored.Notebook,
+		Color:    i.Color,
+	}, nil
+}
+
+// colorNotebook labels a notebook of the user, it needs a note in it
+func (u ColorCommand) colorNotebook(i ColorMessage) (ColorResult, error) {
+	notebook := strings.TrimSpace(i.Notebook)
+	if u.storage.Count(storage.Filter{Notebook: notebook, Owner: i.User}) == 0 {
+		return ColorResult{}, fmt.Errorf("notebook %s %w", notebook, note.ErrNotFound)
+	}
+	if u.colors == nil {
+		return ColorResult{}, fmt.Errorf("notebook colors: none configured")
+	}
+	result := ColorResult{Notebook: notebook, Color: i.Color, DryRun: i.DryRun}
+	if i.DryRun {
+		return result, nil
+	}
+	err := u.colors.Set(i.User, notebook, i.Color)
+	if err != nil {
+		return ColorResult{}, fmt.Errorf("notebook colors: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,1903 @@
+package usecase
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"math"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"notes/internal/audit"
+	"notes/internal/crypt"
+	"notes/internal/expand"
+	"notes/internal/note"
+	"notes/internal/query"
+	"notes/internal/share"
+	"notes/internal/storage"
+	"notes/internal/user"
+)
+
+// Context carries what a message needs besides its own fields, it is
+// embedded in every message
+type Context struct {
+	// DryRun reports what the command would do without touching the
+	// storage, it only matters to the commands changing notes
+	DryRun bool
+	// Actor is who runs the command, as recorded in the audit log and
+	// checked by the authorizer
+	Actor string
+	// User is the account running the command once authenticated, 0
+	// without accounts
+	User note.UserId
+	// ReadOnly only lets the commands which don't change anything run,
+	// as for a read-only API token
+	ReadOnly bool
+}
+
+func (c *Context) setContext(ctx Context) {
+	*c = ctx
+}
+
+func (c Context) context() Context {
+	return c
+}
+
+// SetContext sets the context of a message given by pointer, messages
+// without a context are left untouched
+func SetContext(message any, ctx Context) {
+	if m, ok := message.(interface{ setContext(Context) }); ok {
+		m.setContext(ctx)
+	}
+}
+
+// ReadAll usecase
+type ReadAllMessage struct {
+	Context
+	// Sort orders the notes by length, see Sorts, by id when empty
+	Sort string
+	// Color only lists the notes of a color when not empty
+	Color string
+}
+
+type ReadAllResult struct {
+	Notes note.List
+}
+
+type ReadAllCommand struct {
+	storage storage.Storage
+	colors  NotebookColors
+}
+
+func (i ReadAllMessage) validate() error {
+	return cmp.Or(validSort(i.Sort), validColor(i.Color))
+}
+
+func (u ReadAllCommand) Execute(i ReadAllMessage) (ReadAllResult, error) {
+	notes := u.storage.Find(storage.Filter{Owner: i.User})
+	if i.Color != "" {
+		colors := notebookColors(u.colors, i.User)
+		notes = slices.DeleteFunc(notes, func(n note.Note) bool { return colorOf(n, colors) != i.Color })
+	}
+	sortByLength(notes, i.Sort, func(n note.Note) note.Length { return n.Length })
+	return ReadAllResult{
+		Notes: notes,
+	}, nil
+}
+
+// List usecase
+// Lists the notes of the user with a preview of their content, Read
+// gives the whole content of a note
+type ListMessage struct {
+	Context
+	// Sort orders the notes by length, see Sorts, by id when empty
+	Sort string
+	// Color only lists the notes of a color when not empty
+	Color string
+}
+
+type ListResult struct {
+	Notes []note.Summary
+}
+
+type ListCommand struct {
+	storage storage.Storage
+	colors  NotebookColors
+}
+
+// previewLength is the number of characters of the previews
+const previewLength = 200
+
+func (i ListMessage) validate() error {
+	return cmp.Or(validSort(i.Sort), validColor(i.Color))
+}
+
+func (u ListCommand) Execute(i ListMessage) (ListResult, error) {
+	colors := notebookColors(u.colors, i.User)
+	summaries := []note.Summary{}
+	for n := range u.storage.Each(storage.Filter{Owner: i.User}) {
+		summary := summaryOf(n)
+		summary.Color = colorOf(n, colors)
+		if i.Color == "" || summary.Color == i.Color {
+			summaries = append(summaries, summary)
+		}
+	}
+	sortByLength(summaries, i.Sort, func(n note.Summary) note.Length { return n.Length })
+	return ListResult{
+		Notes: summaries,
+	}, nil
+}
+
+// summaryOf cuts the content of a note to its preview, a sealed content
+// has none as the start of the ciphertext tells nothing
+func summaryOf(n note.Note) note.Summary {
+	preview := n.Content
+	if crypt.IsSealed(preview) {
+		preview = ""
+	}
+	if utf8.RuneCountInString(preview) > previewLength {
+		runes := []rune(preview)
+		preview = string(runes[:previewLength])
+	}
+	return note.Summary{Id: n.Id, Name: n.Name, Notebook: n.Notebook, Owner: n.Owner, Preview: preview, Size: len(n.Content), Length: n.Length}
+}
+
+// Read usecase
+// Reading a note records who viewed it in the audit log, a read which
+// can't be recorded fails
+// A note may be read as it was at a time, from its changes in the audit
+// log, even after it was deleted.
+type ReadCommand struct {
+	storage storage.Storage
+	shares  Shares
+	log     audit.Store
+	clock   Clock
+}
+type ReadMessage struct {
+	Context
+	Id note.Id
+	// At reads the note as it was then, zero reads it now
+	At time.Time
+	// Transclude replaces the ![[embeds]] of the content by the notes
+	// they name, to render the note, it is not to be saved back
+	Transclude bool
+	// Quiet doesn't log the view, the note is only loaded to be changed
+	Quiet bool
+}
+type ReadResult struct {
+	Note note.Note
+}
+
+func (u ReadCommand) Execute(i ReadMessage) (ReadResult, error) {
+	n, _ := readShared(u.storage, u.shares, i.Context, i.Id)
+	if !i.At.IsZero() {
+		var err error
+		n, err = readVersion(u.storage, u.shares, u.log, i.Context, i.Id, i.At)
+		if err != nil {
+			return ReadResult{}, err
+		}
+	}
+	if n.Id == 0 {
+		return ReadResult{}, fmt.Errorf("note %d %w", i.Id, note.ErrNotFound)
+	}
+	if u.log != nil && !i.Quiet {
+		viewed := n
+		viewed.Content = ""
+		err := u.log.Append(audit.Entry{At: u.clock.Now(), Actor: i.Actor, Kind: note.Viewed, NoteId: n.Id, After: viewed})
+		if err != nil {
+			return ReadResult{}, fmt.Errorf("read: audit: %w", err)
+		}
+	}
+	if i.Transclude {
+		n = transcluded(u.storage, u.shares, i.Context, n)
+	}
+	return ReadResult{
+		Note: n,
+	}, nil
+}
+
+// Count usecase
+type CountCommand struct {
+	storage storage.Storage
+}
+type CountMessage struct {
+	Context
+	// Notebook only counts its notes when not empty
+	Notebook note.Notebook
+}
+type CountResult struct {
+	Count int
+}
+
+func (u CountCommand) Execute(i CountMessage) (CountResult, error) {
+	return CountResult{
+		Count: u.storage.Count(storage.Filter{Notebook: i.Notebook, Owner: i.User}),
+	}, nil
+}
+
+// Exists usecase
+type ExistsCommand struct {
+	storage storage.Storage
+	shares  Shares
+}
+type ExistsMessage struct {
+	Context
+	Id note.Id
+}
+type ExistsResult struct {
+	Exists bool
+}
+
+func (u ExistsCommand) Execute(i ExistsMessage) (ExistsResult, error) {
+	n, _ := readShared(u.storage, u.shares, i.Context, i.Id)
+	return ExistsResult{
+		Exists: n.Id != 0,
+	}, nil
+}
+
+// Create usecase
+type CreateCommand struct {
+	storage  storage.Storage
+	events   *EventBus
+	expander expand.Expander
+}
+type CreateMessage struct {
+	Context
+	Name     note.Name
+	Content  note.Content
+	Notebook note.Notebook
+	// Expand replaces the placeholders of the name and the content, such
+	// as {{date}}, the notes imported or synced are kept as they are
+	Expand bool
+}
+type CreateResult struct {
+	Note   note.Note
+	DryRun bool
+}
+
+func (i CreateMessage) validate() error {
+	if strings.TrimSpace(i.Name) == "" {
+		return fmt.Errorf("%w name: a note needs a name", note.ErrValidation)
+	}
+	return nil
+}
+
+func (u CreateCommand) Execute(i CreateMessage) (CreateResult, error) {
+	if i.Expand {
+		var err error
+		i.Name, i.Content, err = expanded(u.expander, i.Name, i.Content)
+		if err != nil {
+			return CreateResult{}, err
+		}
+	}
+	if i.DryRun {
+		n := note.Note{Name: i.Name, Content: i.Content, Notebook: i.Notebook, Owner: i.User}
+		return CreateResult{Note: n, DryRun: true}, nil
+	}
+	n := u.storage.Create(i.Name, i.Content, i.Notebook, i.User)
+	u.events.publish(i.Context, note.Created, n, note.Note{})
+	return CreateResult{
+		Note: n,
+	}, nil
+}
+
+// Quick usecase
+// Captures content straight into the inbox notebook, the note is named
+// after the time of the capture and its placeholders are expanded
+type QuickCommand struct {
+	storage  storage.Storage
+	events   *EventBus
+	inbox    note.Notebook
+	clock    Clock
+	expander expand.Expander
+}
+type QuickMessage struct {
+	Context
+	Content note.Content
+}
+type QuickResult struct {
+	Note   note.Note
+	DryRun bool
+}
+
+func (i QuickMessage) validate() error {
+	if strings.TrimSpace(i.Content) == "" {
+		return fmt.Errorf("%w content: nothing to capture", note.ErrValidation)
+	}
+	return nil
+}
+
+func (u QuickCommand) Execute(i QuickMessage) (QuickResult, error) {
+	name := u.name(i.User)
+	_, content, err := expanded(u.expander, "", i.Content)
+	if err != nil {
+		return QuickResult{}, err
+	}
+	i.Content = content
+	if i.DryRun {
+		n := note.Note{Name: name, Content: i.Content, Notebook: u.inbox, Owner: i.User}
+		return QuickResult{Note: n, DryRun: true}, nil
+	}
+	n := u.storage.Create(name, i.Content, u.inbox, i.User)
+	u.events.publish(i.Context, note.Created, n, note.Note{})
+	return QuickResult{
+		Note: n,
+	}, nil
+}
+
+// name is the time of a capture, a second capture of the same second
+// is numbered as names are unique
+func (u QuickCommand) name(owner note.UserId) note.Name {
+	stamp := u.clock.Now().Format("2006-01-02 15:04:05")
+	taken := map[note.Name]bool{}
+	for n := range u.storage.Each(storage.Filter{Owner: owner}) {
+		if strings.HasPrefix(n.Name, stamp) {
+			taken[n.Name] = true
+		}
+	}
+	name := stamp
+	for i := 2; taken[name]; i++ {
+		name = fmt.Sprintf("%s %d", stamp, i)
+	}
+	return name
+}
+
+// expanded replaces the placeholders of a name and a content
+func expanded(e expand.Expander, name note.Name, content note.Content) (note.Name, note.Content, error) {
+	name, err := e.Expand(name)
+	if err != nil {
+		return "", "", fmt.Errorf("%w name: %v", note.ErrValidation, err)
+	}
+	if crypt.IsSealed(content) {
+		return name, content, nil
+	}
+	content, err = e.Expand(content)
+	if err != nil {
+		return "", "", fmt.Errorf("%w content: %v", note.ErrValidation, err)
+	}
+	return name, content, nil
+}
+
+// Update usecase
+type UpdateCommand struct {
+	storage storage.Storage
+	shares  Shares
+	events  *EventBus
+}
+type UpdateMessage struct {
+	Context
+	Id      note.Id
+	Name    note.Name
+	Content note.Content
+}
+type UpdateResult struct {
+	Note   note.Note
+	DryRun bool
+}
+
+func (u UpdateCommand) Execute(i UpdateMessage) (UpdateResult, error) {
+	previous, access := readShared(u.storage, u.shares, i.Context, i.Id)
+	if previous.Id == 0 {
+		return UpdateResult{}, fmt.Errorf("note %d %w", i.Id, note.ErrNotFound)
+	}
+	err := writable(previous, access)
+	if err != nil {
+		return UpdateResult{}, err
+	}
+	if i.DryRun {
+		n := previous
+		if i.Name != "" {
+			n.Name = i.Name
+		}
+		if i.Content != "" {
+			n.Content = i.Content
+		}
+		return UpdateResult{Note: n, DryRun: true}, nil
+	}
+	n := u.storage.Update(i.Id, i.Name, i.Content)
+	u.events.publish(i.Context, note.Updated, n, previous)
+	return UpdateResult{
+		Note: n,
+	}, nil
+}
+
+// Rename usecase
+// Names are unique, renaming a note to the name of another one is a
+// conflict
+type RenameCommand struct {
+	storage storage.Storage
+	shares  Shares
+	events  *EventBus
+}
+type RenameMessage struct {
+	Context
+	Id   note.Id
+	Name note.Name
+}
+type RenameResult struct {
+	Note   note.Note
+	DryRun bool
+}
+
+func (i RenameMessage) validate() error {
+	if strings.TrimSpace(i.Name) == "" {
+		return fmt.Errorf("%w name: empty", note.ErrValidation)
+	}
+	return nil
+}
+
+func (u RenameCommand) Execute(i RenameMessage) (RenameResult, error) {
+	previous, access := readShared(u.storage, u.shares, i.Context, i.Id)
+	if previous.Id == 0 {
+		return RenameResult{}, fmt.Errorf("note %d %w", i.Id, note.ErrNotFound)
+	}
+	err := writable(previous, access)
+	if err != nil {
+		return RenameResult{}, err
+	}
+	for n := range u.storage.Each(storage.Filter{Owner: previous.Owner}) {
+		if n.Name == i.Name && n.Id != i.Id {
+			return RenameResult{}, fmt.Errorf("name %q: %w with note %d", i.Name, note.ErrConflict, n.Id)
+		}
+	}
+	if i.DryRun {
+		n := previous
+		n.Name = i.Name
+		return RenameResult{Note: n, DryRun: true}, nil
+	}
+	n := u.storage.Rename(i.Id, i.Name)
+	u.events.publish(i.Context, note.Renamed, n, previous)
+	return RenameResult{
+		Note: n,
+	}, nil
+}
+
+// Move usecase
+// Puts a note in another notebook, or in none with an empty notebook,
+// each move is a NoteMoved event so the vault moves the file too
+type MoveCommand struct {
+	storage storage.Storage
+	shares  Shares
+	events  *EventBus
+}
+type MoveMessage struct {
+	Context
+	Id       note.Id
+	Notebook note.Notebook
+}
+type MoveResult struct {
+	Note   note.Note
+	DryRun bool
+}
+
+func (u MoveCommand) Execute(i MoveMessage) (MoveResult, error) {
+	previous, access := readShared(u.storage, u.shares, i.Context, i.Id)
+	if previous.Id == 0 {
+		return MoveResult{}, fmt.Errorf("note %d %w", i.Id, note.ErrNotFound)
+	}
+	err := writable(previous, access)
+	if err != nil {
+		return MoveResult{}, err
+	}
+	moved := previous
+	moved.Notebook = strings.TrimSpace(i.Notebook)
+	if i.DryRun || moved.Notebook == previous.Notebook {
+		return MoveResult{Note: moved, DryRun: i.DryRun}, nil
+	}
+	moved = u.storage.Restore(moved)
+	u.events.publish(i.Context, note.Moved, moved, previous)
+	return MoveResult{
+		Note: moved,
+	}, nil
+}
+
+// Delete Command
+type DeleteCommand struct {
+	storage storage.Storage
+	shares  Shares
+	events  *EventBus
+}
+type DeleteMessage struct {
+	Context
+	Id note.Id
+}
+type DeleteResult struct {
+	Note   note.Note
+	DryRun bool
+}
+
+func (u DeleteCommand) Execute(i DeleteMessage) (DeleteResult, error) {
+	previous, access := readShared(u.storage, u.shares, i.Context, i.Id)
+	if previous.Id == 0 {
+		return DeleteResult{}, fmt.Errorf("note %d %w", i.Id, note.ErrNotFound)
+	}
+	err := writable(previous, access)
+	if err != nil {
+		return DeleteResult{}, err
+	}
+	if i.DryRun {
+		return DeleteResult{Note: previous, DryRun: true}, nil
+	}
+	n := u.storage.Delete(i.Id)
+	u.events.publish(i.Context, note.Deleted, n, n)
+	return DeleteResult{
+		Note: n,
+	}, nil
+}
+
+// Restore usecase
+type RestoreCommand struct {
+	storage storage.Storage
+	shares  Shares
+	events  *EventBus
+}
+type RestoreMessage struct {
+	Context
+	Note note.Note
+}
+type RestoreResult struct {
+	Note   note.Note
+	DryRun bool
+}
+
+func (i RestoreMessage) validate() error {
+	if i.Note.Id == 0 {
+		return fmt.Errorf("%w id: only a note with an id can be restored", note.ErrValidation)
+	}
+	return nil
+}
+
+func (u RestoreCommand) Execute(i RestoreMessage) (RestoreResult, error) {
+	err := writable(i.Note, i.access(u.shares, i.Note))
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	previous := u.storage.Read(i.Note.Id)
+	if previous.Id != 0 && previous.Owner != i.Note.Owner {
+		return RestoreResult{}, fmt.Errorf("note %d %w: the id is taken", i.Note.Id, note.ErrConflict)
+	}
+	if i.DryRun {
+		return RestoreResult{Note: i.Note, DryRun: true}, nil
+	}
+	n := u.storage.Restore(i.Note)
+	u.events.publish(i.Context, note.Restored, n, previous)
+	return RestoreResult{
+		Note: n,
+	}, nil
+}
+
+// Audit usecase
+type AuditCommand struct {
+	storage storage.Storage
+	shares  Shares
+	log     audit.Store
+}
+type AuditMessage struct {
+	Context
+	// NoteId limits the entries to one note when not zero
+	NoteId note.Id
+}
+type AuditResult struct {
+	Entries []audit.Entry
+}
+
+func (u AuditCommand) Execute(i AuditMessage) (AuditResult, error) {
+	q := audit.Query{NoteId: i.NoteId, Owner: i.User}
+	// the history of a note shared with the user is theirs to see too
+	if n, _ := readShared(u.storage, u.shares, i.Context, i.NoteId); n.Id != 0 {
+		q.Owner = 0
+	}
+	entries, err := u.log.Query(q)
+	if err != nil {
+		return AuditResult{}, err
+	}
+	return AuditResult{
+		Entries: entries,
+	}, nil
+}
+
+// Activity usecase
+// Tells who viewed and changed a note and when, only its owner may know
+type ActivityCommand struct {
+	storage storage.Storage
+	shares  Shares
+	log     audit.Store
+}
+type ActivityMessage struct {
+	Context
+	Id note.Id
+}
+type ActivityResult struct {
+	Note     note.Note
+	Activity []Activity
+}
+
+// Activity is a view or a change of a note, the oldest first
+type Activity struct {
+	At    time.Time
+	Actor string
+	Kind  note.EventKind
+}
+
+func (u ActivityCommand) Execute(i ActivityMessage) (ActivityResult, error) {
+	n, _ := readShared(u.storage, u.shares, i.Context, i.Id)
+	if n.Id == 0 {
+		return ActivityResult{}, fmt.Errorf("note %d %w", i.Id, note.ErrNotFound)
+	}
+	if i.User != 0 && n.Owner != i.User {
+		return ActivityResult{}, fmt.Errorf("note %d %w: only its owner sees its activity", i.Id, note.ErrForbidden)
+	}
+	activity := []Activity{}
+	if u.log == nil {
+		return ActivityResult{Note: n, Activity: activity}, nil
+	}
+	entries, err := u.log.Query(audit.Query{NoteId: i.Id, Views: true})
+	if err != nil {
+		return ActivityResult{}, fmt.Errorf("activity: %w", err)
+	}
+	for _, e := range entries {
+		activity = append(activity, Activity{At: e.At, Actor: e.Actor, Kind: e.Kind})
+	}
+	return ActivityResult{
+		Note:     n,
+		Activity: activity,
+	}, nil
+}
+
+// Email usecase
+// Sends a note to an address through the mailer, which renders it
+type EmailCommand struct {
+	storage storage.Storage
+	shares  Shares
+	mailer  Mailer
+}
+type EmailMessage struct {
+	Context
+	Id note.Id
+	To string
+}
+type EmailResult struct {
+	Note   note.Note
+	To     string
+	DryRun bool
+}
+
+func (i EmailMessage) validate() error {
+	_, err := mail.ParseAddress(i.To)
+	if err != nil {
+		return fmt.Errorf("%w to: %q is not an address", note.ErrValidation, i.To)
+	}
+	return nil
+}
+
+func (u EmailCommand) Execute(i EmailMessage) (EmailResult, error) {
+	n, _ := readShared(u.storage, u.shares, i.Context, i.Id)
+	if n.Id == 0 {
+		return EmailResult{}, fmt.Errorf("note %d %w", i.Id, note.ErrNotFound)
+	}
+	if u.mailer == nil {
+		return EmailResult{}, fmt.Errorf("email: no mail server configured")
+	}
+	if crypt.IsSealed(n.Content) {
+		return EmailResult{}, fmt.Errorf("%w: note %d is encrypted, only its clients can read it", note.ErrValidation, i.Id)
+	}
+	if i.DryRun {
+		return EmailResult{Note: n, To: i.To, DryRun: true}, nil
+	}
+	err := u.mailer.Send(i.To, n)
+	if err != nil {
+		return EmailResult{}, fmt.Errorf("email: %w", err)
+	}
+	return EmailResult{
+		Note: n,
+		To:   i.To,
+	}, nil
+}
+
+// Publish usecase
+// Publishes a note through the publisher, a note published before is
+// updated
+type PublishCommand struct {
+	storage   storage.Storage
+	shares    Shares
+	publisher Publisher
+}
+type PublishMessage struct {
+	Context
+	Id note.Id
+	// Public lets anybody find a newly published note
+	Public bool
+}
+type PublishResult struct {
+	Note   note.Note
+	URL    string
+	DryRun bool
+}
+
+func (u PublishCommand) Execute(i PublishMessage) (PublishResult, error) {
+	n, access := readShared(u.storage, u.shares, i.Context, i.Id)
+	if n.Id == 0 {
+		return PublishResult{}, fmt.Errorf("note %d %w", i.Id, note.ErrNotFound)
+	}
+	// making a note public is up to those who may change it
+	err := writable(n, access)
+	if err != nil {
+		return PublishResult{}, err
+	}
+	if u.publisher == nil {
+		return PublishResult{}, fmt.Errorf("publish: no publisher configured")
+	}
+	if crypt.IsSealed(n.Content) {
+		return PublishResult{}, fmt.Errorf("%w: note %d is encrypted, only its clients can read it", note.ErrValidation, i.Id)
+	}
+	if i.DryRun {
+		return PublishResult{Note: n, DryRun: true}, nil
+	}
+	url, err := u.publisher.Publish(n, i.Public)
+	if err != nil {
+		return PublishResult{}, fmt.Errorf("publish: %w", err)
+	}
+	return PublishResult{
+		Note: n,
+		URL:  url,
+	}, nil
+}
+
+// Search usecase
+// Finds the notes through the searcher, which keeps an index of them
+// A query with fields, phrases or operators, see package query, is
+// matched against every note, its bare words are still searched in the
+// index and the times of the notes come from the audit log.
+// The notes are ranked by the scores of the searcher, a note changed
+// recently scoring more.
+// A semantic search gives the query as is to the semantic searcher,
+// which finds the notes by meaning.
+type SearchCommand struct {
+	storage  storage.Storage
+	shares   Shares
+	clock    Clock
+	searcher Searcher
+	semantic Searcher
+	times    *noteTimes
+}
+type SearchMessage struct {
+	Context
+	Query    string
+	Semantic bool
+}
+type SearchResult struct {
+	Notes note.List
+	// Snippets show why each note matched, in the order of the notes
+	Snippets []query.Snippet
+}
+
+// recencyBoost is how much more a note changed just now scores than an
+// old one, the boost halves every recencyHalfLife
+const (
+	recencyBoost    = 0.5
+	recencyHalfLife = 30 * 24 * time.Hour
+	// pinnedBoost is how much more a pinned note scores
+	pinnedBoost = 0.5
+)
+
+func (i SearchMessage) validate() error {
+	if i.Semantic && strings.TrimSpace(i.Query) == "" {
+		return fmt.Errorf("%w query: nothing to search", note.ErrValidation)
+	}
+	if i.Semantic {
+		return nil
+	}
+	_, err := query.Parse(i.Query)
+	return err
+}
+
+func (u SearchCommand) Execute(i SearchMessage) (SearchResult, error) {
+	if i.Semantic {
+		return u.searchSemantic(i)
+	}
+	if u.searcher == nil {
+		return SearchResult{}, fmt.Errorf("search: no index configured")
+	}
+	q, err := query.Parse(i.Query)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	notes, err := u.find(i.Context, q, i.Query)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	snippets := []query.Snippet{}
+	for _, n := range notes {
+		spans := q.Spans(query.Document{Note: n}, func(word string) []query.Span {
+			return u.highlight(word, n)
+		})
+		snippets = append(snippets, query.Snippets(n, spans)...)
+	}
+	return SearchResult{
+		Notes:    notes,
+		Snippets: snippets,
+	}, nil
+}
+
+// searchSemantic finds the notes closest in meaning to the query, the
+// snippets show the words of the query the notes hold, if any
+func (u SearchCommand) searchSemantic(i SearchMessage) (SearchResult, error) {
+	if u.semantic == nil {
+		return SearchResult{}, fmt.Errorf("semantic search: no embeddings provider configured")
+	}
+	hits, err := u.semantic.Search(i.Query)
+	if err != nil {
+		return SearchResult{}, fmt.Errorf("semantic search: %w", err)
+	}
+	_, updated, err := u.times.read()
+	if err != nil {
+		return SearchResult{}, fmt.Errorf("semantic search: %w", err)
+	}
+	notes := note.List{}
+	snippets := []query.Snippet{}
+	for _, h := range u.rank(hits, updated) {
+		n, _ := readShared(u.storage, u.shares, i.Context, h.Id)
+		if n.Id == 0 {
+			continue
+		}
+		notes = append(notes, n)
+		snippets = append(snippets, query.Snippets(n, u.highlight(i.Query, n))...)
+	}
+	return SearchResult{
+		Notes:    notes,
+		Snippets: snippets,
+	}, nil
+}
+
+// find returns the notes the user of the context may read matching the
+// query, the most relevant first
+func (u SearchCommand) find(c Context, q query.Query, raw string) (note.List, error) {
+	created, updated, err := u.times.read()
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	var hits []query.Hit
+	if q.Plain() {
+		hits, err = u.searcher.Search(raw)
+		if err != nil {
+			return nil, fmt.Errorf("search: %w", err)
+		}
+	} else {
+		hits, err = u.filter(c, q, created, updated)
+		if err != nil {
+			return nil, err
+		}
+	}
+	notes := note.List{}
+	for _, h := range u.rank(hits, updated) {
+		// the index may lag behind a note deleted meanwhile
+		if n, _ := readShared(u.storage, u.shares, c, h.Id); n.Id != 0 {
+			notes = append(notes, n)
+		}
+	}
+	return notes, nil
+}
+
+// rank boosts the notes pinned and those changed recently and sorts the
+// hits by score, then by id
+func (u SearchCommand) rank(hits []query.Hit, updated map[note.Id]time.Time) []query.Hit {
+	ranked := slices.Clone(hits)
+	for i, h := range ranked {
+		if u.storage.Read(h.Id).Metadata[PinKey] != "" {
+			ranked[i].Score *= 1 + pinnedBoost
+		}
+	}
+	if u.clock != nil {
+		now := u.clock.Now()
+		for i, h := range ranked {
+			at, ok := updated[h.Id]
+			if !ok {
+				continue
+			}
+			age := max(now.Sub(at), 0)
+			ranked[i].Score *= 1 + recencyBoost*math.Pow(0.5, float64(age)/float64(recencyHalfLife))
+		}
+	}
+	slices.SortStableFunc(ranked, func(a, b query.Hit) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), a.Id-b.Id)
+	})
+	return ranked
+}
+
+// highlight tells where a bare word matched a note
+func (u SearchCommand) highlight(word string, n note.Note) []query.Span {
+	if h, ok := u.searcher.(Highlighter); ok {
+		return h.Highlight(word, n)
+	}
+	words := query.Words(word)
+	match := func(w string) bool { return slices.Contains(words, w) }
+	return append(query.Find("name", n.Name, match), query.Find("content", n.Content, match)...)
+}
+
+// filter matches every note against the query, a match scores the sum
+// of the scores of its bare words, or 1 without any
+func (u SearchCommand) filter(c Context, q query.Query, created, updated map[note.Id]time.Time) ([]query.Hit, error) {
+	found := map[string]map[note.Id]float64{}
+	for _, w := range q.Words() {
+		hits, err := u.searcher.Search(w)
+		if err != nil {
+			return nil, fmt.Errorf("search: %w", err)
+		}
+		found[w] = map[note.Id]float64{}
+		for _, h := range hits {
+			found[w][h.Id] = h.Score
+		}
+	}
+	hits := []query.Hit{}
+	for n := range eachShared(u.storage, u.shares, c, storage.Filter{}) {
+		d := query.Document{Note: n, Created: created[n.Id], Updated: updated[n.Id]}
+		score := 0.0
+		matched := q.Match(d, func(word string, id note.Id) bool {
+			s, ok := found[word][id]
+			score += s
+			return ok
+		})
+		if matched {
+			hits = append(hits, query.Hit{Id: n.Id, Score: cmp.Or(score, 1)})
+		}
+	}
+	return hits, nil
+}
+
+// Similar usecase
+// Finds the notes sharing the most words with a note, to spot duplicates
+// and related notes
+type SimilarCommand struct {
+	storage  storage.Storage
+	shares   Shares
+	searcher Searcher
+}
+type SimilarMessage struct {
+	Context
+	Id note.Id
+	// Limit is the number of notes found, 5 when it is 0
+	Limit int
+}
+type SimilarResult struct {
+	Note    note.Note
+	Similar note.List
+}
+
+func (i SimilarMessage) validate() error {
+	if i.Limit < 0 {
+		return fmt.Errorf("%w limit: %d is negative", note.ErrValidation, i.Limit)
+	}
+	return nil
+}
+
+func (u SimilarCommand) Execute(i SimilarMessage) (SimilarResult, error) {
+	n, _ := readShared(u.storage, u.shares, i.Context, i.Id)
+	if n.Id == 0 {
+		return SimilarResult{}, fmt.Errorf("note %d %w", i.Id, note.ErrNotFound)
+	}
+	finder, ok := u.searcher.(SimilarFinder)
+	if !ok {
+		return SimilarResult{}, fmt.Errorf("similar: the index can't compare notes")
+	}
+	limit := cmp.Or(i.Limit, 5)
+	// the notes the user can't read are left out after the index found
+	// them
+	candidates := limit
+	if i.User != 0 {
+		candidates = u.storage.Count(storage.Filter{})
+	}
+	hits, err := finder.Similar(i.Id, candidates)
+	if err != nil {
+		return SimilarResult{}, fmt.Errorf("similar: %w", err)
+	}
+	similar := note.List{}
+	for _, h := range hits {
+		if s, _ := readShared(u.storage, u.shares, i.Context, h.Id); s.Id != 0 && s.Id != n.Id && len(similar) < limit {
+			similar = append(similar, s)
+		}
+	}
+	return SimilarResult{
+		Note:    n,
+		Similar: similar,
+	}, nil
+}
+
+// Notebooks usecase
+// Lists the notebooks of the notes and the smart notebooks, whose notes
+// are those currently matching their saved search
+type NotebooksCommand struct {
+	storage storage.Storage
+	search  SearchCommand
+	saved   SavedSearches
+	colors  NotebookColors
+}
+type NotebooksMessage struct {
+	Context
+	// Color only lists the notebooks of a color when not empty
+	Color string
+}
+type NotebooksResult struct {
+	Notebooks []NotebookSummary
+}
+
+// NotebookSummary is a notebook and its number of notes, the query of
+// a smart notebook is not empty
+type NotebookSummary struct {
+	Name  note.Notebook
+	Count int
+	Query string
+	Color string `json:",omitempty"`
+}
+
+func (i NotebooksMessage) validate() error {
+	return validColor(i.Color)
+}
+
+func (u NotebooksCommand) Execute(i NotebooksMessage) (NotebooksResult, error) {
+	counts := map[note.Notebook]int{}
+	for n := range u.storage.Each(storage.Filter{Owner: i.User}) {
+		if n.Notebook != "" {
+			counts[n.Notebook]++
+		}
+	}
+	colors := notebookColors(u.colors, i.User)
+	notebooks := []NotebookSummary{}
+	for name, count := range counts {
+		if i.Color == "" || colors[name] == i.Color {
+			notebooks = append(notebooks, NotebookSummary{Name: name, Count: count, Color: colors[name]})
+		}
+	}
+	queries := map[string]string{}
+	// the smart notebooks have no color
+	if u.saved != nil && i.Color == "" {
+		var err error
+		queries, err = u.saved.All()
+		if err != nil {
+			return NotebooksResult{}, fmt.Errorf("notebooks: %w", err)
+		}
+	}
+	for name, search := range queries {
+		q, err := query.Parse(search)
+		if err != nil {
+			return NotebooksResult{}, fmt.Errorf("notebook %s: %w", name, err)
+		}
+		notes, err := u.search.find(i.Context, q, search)
+		if err != nil {
+			return NotebooksResult{}, fmt.Errorf("notebook %s: %w", name, err)
+		}
+		notebooks = append(notebooks, NotebookSummary{Name: name, Count: len(notes), Query: search})
+	}
+	slices.SortFunc(notebooks, func(a, b NotebookSummary) int { return strings.Compare(a.Name, b.Name) })
+	return NotebooksResult{
+		Notebooks: notebooks,
+	}, nil
+}
+
+// SaveSearch usecase
+// Saves a search as a smart notebook, saving it again replaces its
+// query
+type SaveSearchCommand struct {
+	storage storage.Storage
+	saved   SavedSearches
+}
+type SaveSearchMessage struct {
+	Context
+	Name  note.Notebook
+	Query string
+}
+type SaveSearchResult struct {
+	Name   note.Notebook
+	Query  string
+	DryRun bool
+}
+
+func (i SaveSearchMessage) validate() error {
+	if strings.TrimSpace(i.Name) == "" {
+		return fmt.Errorf("%w name: a smart notebook needs a name", note.ErrValidation)
+	}
+	_, err := query.Parse(i.Query)
+	return err
+}
+
+func (u SaveSearchCommand) Execute(i SaveSearchMessage) (SaveSearchResult, error) {
+	if u.saved == nil {
+		return SaveSearchResult{}, fmt.Errorf("save search: no saved searches configured")
+	}
+	if u.storage.Count(storage.Filter{Notebook: i.Name, Owner: i.User}) > 0 {
+		return SaveSearchResult{}, fmt.Errorf("notebook %s %w: it holds notes", i.Name, note.ErrConflict)
+	}
+	if i.DryRun {
+		return SaveSearchResult{Name: i.Name, Query: i.Query, DryRun: true}, nil
+	}
+	err := u.saved.Save(i.Name, i.Query)
+	if err != nil {
+		return SaveSearchResult{}, fmt.Errorf("save search: %w", err)
+	}
+	return SaveSearchResult{
+		Name:  i.Name,
+		Query: i.Query,
+	}, nil
+}
+
+// DeleteSearch usecase
+// Deletes a smart notebook, its notes are left alone
+type DeleteSearchCommand struct {
+	saved SavedSearches
+}
+type DeleteSearchMessage struct {
+	Context
+	Name note.Notebook
+}
+type DeleteSearchResult struct {
+	Name   note.Notebook
+	DryRun bool
+}
+
+func (u DeleteSearchCommand) Execute(i DeleteSearchMessage) (DeleteSearchResult, error) {
+	queries := map[string]string{}
+	if u.saved != nil {
+		var err error
+		queries, err = u.saved.All()
+		if err != nil {
+			return DeleteSearchResult{}, fmt.Errorf("delete search: %w", err)
+		}
+	}
+	if _, ok := queries[i.Name]; !ok {
+		return DeleteSearchResult{}, fmt.Errorf("smart notebook %s %w", i.Name, note.ErrNotFound)
+	}
+	if i.DryRun {
+		return DeleteSearchResult{Name: i.Name, DryRun: true}, nil
+	}
+	_, err := u.saved.Delete(i.Name)
+	if err != nil {
+		return DeleteSearchResult{}, fmt.Errorf("delete search: %w", err)
+	}
+	return DeleteSearchResult{
+		Name: i.Name,
+	}, nil
+}
+
+// Save usecase
+type SaveCommand struct {
+	storage storage.Storage
+}
+type SaveMessage struct {
+	Context
+}
+type SaveResult struct {
+	Saved bool
+}
+
+func (u SaveCommand) Execute(i SaveMessage) (SaveResult, error) {
+	persistent, ok := u.storage.(storage.Persistent)
+	if !ok || !persistent.Unsaved() {
+		return SaveResult{Saved: false}, nil
+	}
+	err := persistent.Save()
+	if err != nil {
+		return SaveResult{}, err
+	}
+	return SaveResult{Saved: true}, nil
+}
+
+// Status usecase
+type StatusCommand struct {
+	storage storage.Storage
+}
+type StatusMessage struct {
+	Context
+}
+type StatusResult struct {
+	Count   int
+	Unsaved bool
+}
+
+func (u StatusCommand) Execute(i StatusMessage) (StatusResult, error) {
+	unsaved := false
+	if persistent, ok := u.storage.(storage.Persistent); ok {
+		unsaved = persistent.Unsaved()
+	}
+	return StatusResult{
+		Count:   u.storage.Count(storage.Filter{Owner: i.User}),
+		Unsaved: unsaved,
+	}, nil
+}
+
+// Register usecase
+// Creates the account of a user, only the hash of the password is kept
+type RegisterCommand struct {
+	users Users
+	clock Clock
+}
+type RegisterMessage struct {
+	Context
+	Name     string
+	Password user.Secret
+}
+type RegisterResult struct {
+	User   user.User
+	DryRun bool
+}
+
+// minPassword is the shortest password accepted
+const minPassword = 8
+
+func (i RegisterMessage) validate() error {
+	switch {
+	case strings.TrimSpace(i.Name) == "":
+		return fmt.Errorf("%w name: a user needs a name", note.ErrValidation)
+	case strings.ContainsAny(i.Name, ": \t\r\n"):
+		return fmt.Errorf("%w name: %q has spaces or a colon", note.ErrValidation, i.Name)
+	case utf8.RuneCountInString(string(i.Password)) < minPassword:
+		return fmt.Errorf("%w password: at least %d characters", note.ErrValidation, minPassword)
+	}
+	return nil
+}
+
+func (u RegisterCommand) Execute(i RegisterMessage) (RegisterResult, error) {
+	if u.users == nil {
+		return RegisterResult{}, fmt.Errorf("register: no accounts configured")
+	}
+	if u.users.ByName(i.Name).Id != 0 {
+		return RegisterResult{}, fmt.Errorf("user %s %w: the name is taken", i.Name, note.ErrConflict)
+	}
+	if i.DryRun {
+		return RegisterResult{User: user.User{Name: i.Name, Created: u.clock.Now()}, DryRun: true}, nil
+	}
+	hash, err := user.HashPassword(string(i.Password))
+	if err != nil {
+		return RegisterResult{}, fmt.Errorf("register: %w", err)
+	}
+	created, err := u.users.Create(i.Name, hash, u.clock.Now())
+	if err != nil {
+		return RegisterResult{}, fmt.Errorf("register: %w", err)
+	}
+	return RegisterResult{
+		User: created,
+	}, nil
+}
+
+// Login usecase
+// Checks the password of a user and issues a token proving who the user
+// is to the next commands
+// With two-factor authentication the user sends a code of their app or
+// a recovery code too
+type LoginCommand struct {
+	users  Users
+	tokens Tokens
+	clock  Clock
+}
+type LoginMessage struct {
+	Context
+	Name     string
+	Password user.Secret
+	Code     user.Secret
+}
+type LoginResult struct {
+	User  user.User
+	Token string
+}
+
+func (u LoginCommand) Execute(i LoginMessage) (LoginResult, error) {
+	if u.users == nil || u.tokens == nil {
+		return LoginResult{}, fmt.Errorf("login: no accounts configured")
+	}
+	found := u.users.ByName(i.Name)
+	if found.Id == 0 || !user.CheckPassword(found.PasswordHash, string(i.Password)) {
+		return LoginResult{}, fmt.Errorf("login %w: wrong name or password", note.ErrUnauthorized)
+	}
+	if found.Totp.Enabled {
+		if i.Code == "" {
+			return LoginResult{}, fmt.Errorf("login %w: two-factor authentication is on, a code is needed", note.ErrUnauthorized)
+		}
+		totp, ok := found.Totp.Check(string(i.Code), u.clock.Now())
+		if !ok {
+			return LoginResult{}, fmt.Errorf("login %w: wrong or used code", note.ErrUnauthorized)
+		}
+		found.Totp = totp
+		err := u.users.Update(found)
+		if err != nil {
+			return LoginResult{}, fmt.Errorf("login: %w", err)
+		}
+	}
+	token, err := u.tokens.Issue(found)
+	if err != nil {
+		return LoginResult{}, fmt.Errorf("login: %w", err)
+	}
+	return LoginResult{
+		User:  found,
+		Token: token,
+	}, nil
+}
+
+// Authenticate usecase
+// Tells which user a token was issued to, applications authenticate
+// their requests with it before running the other commands
+type AuthenticateCommand struct {
+	users     Users
+	tokens    Tokens
+	apiTokens ApiTokens
+}
+type AuthenticateMessage struct {
+	Context
+	Token user.Secret
+}
+type AuthenticateResult struct {
+	User user.User
+	// Scope is read-only for read-only API tokens
+	Scope user.Scope
+}
+
+func (u AuthenticateCommand) Execute(i AuthenticateMessage) (AuthenticateResult, error) {
+	if u.users == nil || u.tokens == nil {
+		return AuthenticateResult{}, fmt.Errorf("authenticate %w: no accounts configured", note.ErrUnauthorized)
+	}
+	id, scope := user.Id(0), user.ReadWrite
+	if user.IsApiToken(string(i.Token)) {
+		if u.apiTokens == nil {
+			return AuthenticateResult{}, fmt.Errorf("authenticate %w: no api tokens configured", note.ErrUnauthorized)
+		}
+		token := u.apiTokens.Verify(string(i.Token))
+		if token.Id == 0 {
+			return AuthenticateResult{}, fmt.Errorf("authenticate %w: unknown or revoked token", note.ErrUnauthorized)
+		}
+		id, scope = token.User, token.Scope
+	} else {
+		var err error
+		id, err = u.tokens.Verify(string(i.Token))
+		if err != nil {
+			return AuthenticateResult{}, err
+		}
+	}
+	found := u.users.Read(id)
+	if found.Id == 0 {
+		return AuthenticateResult{}, fmt.Errorf("authenticate %w: unknown user %d", note.ErrUnauthorized, id)
+	}
+	return AuthenticateResult{
+		User:  found,
+		Scope: scope,
+	}, nil
+}
+
+// Share usecase
+// Grants another user read or read/write access to a note or a notebook
+// of the user, sharing it with them again changes their access
+type ShareCommand struct {
+	storage storage.Storage
+	shares  Shares
+	users   Users
+	clock   Clock
+}
+type ShareMessage struct {
+	Context
+	// Id is the note shared, or 0 to share Notebook
+	Id       note.Id
+	Notebook note.Notebook
+	// With is the name of the user given access
+	With   string
+	Access share.Access
+}
+type ShareResult struct {
+	Share  share.Share
+	DryRun bool
+}
+
+func (i ShareMessage) validate() error {
+	switch {
+	case (i.Id == 0) == (i.Notebook == ""):
+		return fmt.Errorf("%w: share either a note or a notebook", note.ErrValidation)
+	case strings.TrimSpace(i.With) == "":
+		return fmt.Errorf("%w with: share with whom", note.ErrValidation)
+	case !i.Access.Valid():
+		return fmt.Errorf("%w access: %q is neither %s nor %s", note.ErrValidation, i.Access, share.Read, share.Write)
+	}
+	return nil
+}
+
+func (u ShareCommand) Execute(i ShareMessage) (ShareResult, error) {
+	if u.shares == nil || u.users == nil {
+		return ShareResult{}, fmt.Errorf("share: no accounts configured")
+	}
+	if i.User == 0 {
+		return ShareResult{}, fmt.Errorf("share %w: sharing needs an account", note.ErrUnauthorized)
+	}
+	if i.Id != 0 {
+		n, _ := readShared(u.storage, u.shares, i.Context, i.Id)
+		if n.Id == 0 {
+			return ShareResult{}, fmt.Errorf("note %d %w", i.Id, note.ErrNotFound)
+		}
+		if n.Owner != i.User {
+			return ShareResult{}, fmt.Errorf("note %d %w: only its owner shares it", i.Id, note.ErrForbidden)
+		}
+	} else if u.storage.Count(storage.Filter{Notebook: i.Notebook, Owner: i.User}) == 0 {
+		return ShareResult{}, fmt.Errorf("notebook %s %w", i.Notebook, note.ErrNotFound)
+	}
+	grantee := u.users.ByName(i.With)
+	if grantee.Id == 0 {
+		return ShareResult{}, fmt.Errorf("user %s %w", i.With, note.ErrNotFound)
+	}
+	if grantee.Id == i.User {
+		return ShareResult{}, fmt.Errorf("%w with: the notes are yours already", note.ErrValidation)
+	}
+	s := share.Share{Owner: i.User, Grantee: grantee.Id, Note: i.Id, Notebook: i.Notebook, Access: i.Access, Created: u.clock.Now()}
+	if i.DryRun {
+		return ShareResult{Share: s, DryRun: true}, nil
+	}
+	s, err := u.shares.Grant(s)
+	if err != nil {
+		return ShareResult{}, fmt.Errorf("share: %w", err)
+	}
+	return ShareResult{
+		Share: s,
+	}, nil
+}
+
+// Unshare usecase
+// Revokes a share the user granted
+type UnshareCommand struct {
+	shares Shares
+}
+type UnshareMessage struct {
+	Context
+	Id share.Id
+}
+type UnshareResult struct {
+	Share  share.Share
+	DryRun bool
+}
+
+func (u UnshareCommand) Execute(i UnshareMessage) (UnshareResult, error) {
+	if u.shares == nil {
+		return UnshareResult{}, fmt.Errorf("unshare: no accounts configured")
+	}
+	given := u.shares.Given(i.User)
+	found := slices.IndexFunc(given, func(s share.Share) bool { return s.Id == i.Id })
+	// the shares of others are missing to the user
+	if found < 0 {
+		return UnshareResult{}, fmt.Errorf("share %d %w", i.Id, note.ErrNotFound)
+	}
+	if i.DryRun {
+		return UnshareResult{Share: given[found], DryRun: true}, nil
+	}
+	revoked, err := u.shares.Revoke(i.Id)
+	if err != nil {
+		return UnshareResult{}, fmt.Errorf("unshare: %w", err)
+	}
+	return UnshareResult{
+		Share: revoked,
+	}, nil
+}
+
+// Shares usecase
+// Lists the shares the user granted
+type SharesCommand struct {
+	shares Shares
+}
+type SharesMessage struct {
+	Context
+}
+type SharesResult struct {
+	Shares []share.Share
+}
+
+func (u SharesCommand) Execute(i SharesMessage) (SharesResult, error) {
+	if u.shares == nil {
+		return SharesResult{Shares: []share.Share{}}, nil
+	}
+	return SharesResult{
+		Shares: u.shares.Given(i.User),
+	}, nil
+}
+
+// SharedWithMe usecase
+// Lists the notes others shared with the user and the access granted to
+// each
+type SharedWithMeCommand struct {
+	storage storage.Storage
+	shares  Shares
+	users   Users
+}
+type SharedWithMeMessage struct {
+	Context
+}
+type SharedWithMeResult struct {
+	Notes []SharedNote
+}
+
+// SharedNote is a note shared with the user, with the name of its owner
+type SharedNote struct {
+	Note   note.Note
+	Owner  string
+	Access share.Access
+}
+
+func (u SharedWithMeCommand) Execute(i SharedWithMeMessage) (SharedWithMeResult, error) {
+	notes := []SharedNote{}
+	if u.shares == nil || i.User == 0 {
+		return SharedWithMeResult{Notes: notes}, nil
+	}
+	received := u.shares.Received(i.User)
+	for n := range u.storage.Each(storage.Filter{}) {
+		if n.Owner == i.User {
+			continue
+		}
+		if access := share.AccessTo(received, n); access != "" {
+			owner := ""
+			if u.users != nil {
+				owner = u.users.Read(n.Owner).Name
+			}
+			notes = append(notes, SharedNote{Note: n, Owner: owner, Access: access})
+		}
+	}
+	return SharedWithMeResult{
+		Notes: notes,
+	}, nil
+}
+
+// CreateApiToken usecase
+// Creates a personal token of the user for their scripts, the token is
+// only ever shown in the result
+type CreateApiTokenCommand struct {
+	apiTokens ApiTokens
+	clock     Clock
+}
+type CreateApiTokenMessage struct {
+	Context
+	Name  string
+	Scope user.Scope
+}
+type CreateApiTokenResult struct {
+	Token    string
+	ApiToken user.ApiToken
+	DryRun   bool
+}
+
+func (i CreateApiTokenMessage) validate() error {
+	if strings.TrimSpace(i.Name) == "" {
+		return fmt.Errorf("%w name: a token needs a name", note.ErrValidation)
+	}
+	if !i.Scope.Valid() {
+		return fmt.Errorf("%w scope: %q is neither %s nor %s", note.ErrValidation, i.Scope, user.ReadOnly, user.ReadWrite)
+	}
+	return nil
+}
+
+func (u CreateApiTokenCommand) Execute(i CreateApiTokenMessage) (CreateApiTokenResult, error) {
+	if u.apiTokens == nil {
+		return CreateApiTokenResult{}, fmt.Errorf("create token: no api tokens configured")
+	}
+	if i.User == 0 {
+		return CreateApiTokenResult{}, fmt.Errorf("create token %w: tokens need an account", note.ErrUnauthorized)
+	}
+	if i.DryRun {
+		return CreateApiTokenResult{ApiToken: user.ApiToken{User: i.User, Name: i.Name, Scope: i.Scope, Created: u.clock.Now()}, DryRun: true}, nil
+	}
+	secret, token, err := u.apiTokens.Create(i.User, i.Name, i.Scope, u.clock.Now())
+	if err != nil {
+		return CreateApiTokenResult{}, fmt.Errorf("create token: %w", err)
+	}
+	return CreateApiTokenResult{
+		Token:    secret,
+		ApiToken: token,
+	}, nil
+}
+
+// ApiTokens usecase
+// Lists the tokens of the user, without the tokens themselves
+type ApiTokensCommand struct {
+	apiTokens ApiTokens
+}
+type ApiTokensMessage struct {
+	Context
+}
+type ApiTokensResult struct {
+	ApiTokens []user.ApiToken
+}
+
+func (u ApiTokensCommand) Execute(i ApiTokensMessage) (ApiTokensResult, error) {
+	if u.apiTokens == nil || i.User == 0 {
+		return ApiTokensResult{ApiTokens: []user.ApiToken{}}, nil
+	}
+	return ApiTokensResult{
+		ApiTokens: u.apiTokens.List(i.User),
+	}, nil
+}
+
+// RevokeApiToken usecase
+// Revokes a token of the user, the scripts using it are refused from
+// then on
+type RevokeApiTokenCommand struct {
+	apiTokens ApiTokens
+}
+type RevokeApiTokenMessage struct {
+	Context
+	Id int
+}
+type RevokeApiTokenResult struct {
+	ApiToken user.ApiToken
+	DryRun   bool
+}
+
+func (u RevokeApiTokenCommand) Execute(i RevokeApiTokenMessage) (RevokeApiTokenResult, error) {
+	if u.apiTokens == nil {
+		return RevokeApiTokenResult{}, fmt.Errorf("revoke token: no api tokens configured")
+	}
+	if i.DryRun {
+		tokens := u.apiTokens.List(i.User)
+		found := slices.IndexFunc(tokens, func(t user.ApiToken) bool { return t.Id == i.Id })
+		if found < 0 {
+			return RevokeApiTokenResult{}, fmt.Errorf("token %d %w", i.Id, note.ErrNotFound)
+		}
+		return RevokeApiTokenResult{ApiToken: tokens[found], DryRun: true}, nil
+	}
+	revoked, err := u.apiTokens.Revoke(i.User, i.Id)
+	if err != nil {
+		return RevokeApiTokenResult{}, err
+	}
+	return RevokeApiTokenResult{
+		ApiToken: revoked,
+	}, nil
+}
+
+// EnrollTotp usecase
+// Starts the two-factor authentication of the user, the result is the
+// secret to give their authenticator app, as a QR code of its uri
+// Logging in doesn't need a code until the user confirms the app gives
+// the right ones.
+type EnrollTotpCommand struct {
+	users Users
+}
+type EnrollTotpMessage struct {
+	Context
+}
+type EnrollTotpResult struct {
+	Secret string
+	Uri    string
+	DryRun bool
+}
+
+// totpIssuer names the server in the authenticator apps
+const totpIssuer = "notes"
+
+func (u EnrollTotpCommand) Execute(i EnrollTotpMessage) (EnrollTotpResult, error) {
+	if u.users == nil {
+		return EnrollTotpResult{}, fmt.Errorf("enroll totp: no accounts configured")
+	}
+	found := u.users.Read(i.User)
+	if found.Id == 0 {
+		return EnrollTotpResult{}, fmt.Errorf("enroll totp %w: two-factor authentication needs an account", note.ErrUnauthorized)
+	}
+	if found.Totp.Enabled {
+		return EnrollTotpResult{}, fmt.Errorf("enroll totp %w: two-factor authentication is on already, disable it first", note.ErrConflict)
+	}
+	totp, err := user.NewTotp()
+	if err != nil {
+		return EnrollTotpResult{}, fmt.Errorf("enroll totp: %w", err)
+	}
+	if i.DryRun {
+		return EnrollTotpResult{DryRun: true}, nil
+	}
+	found.Totp = totp
+	err = u.users.Update(found)
+	if err != nil {
+		return EnrollTotpResult{}, fmt.Errorf("enroll totp: %w", err)
+	}
+	return EnrollTotpResult{
+		Secret: totp.Secret,
+		Uri:    totp.Uri(totpIssuer, found.Name),
+	}, nil
+}
+
+// ConfirmTotp usecase
+// Turns the two-factor authentication on with a first code of the app,
+// the result has the recovery codes, which are only ever shown there
+type ConfirmTotpCommand struct {
+	users Users
+	clock Clock
+}
+type ConfirmTotpMessage struct {
+	Context
+	Code user.Secret
+}
+type ConfirmTotpResult struct {
+	RecoveryCodes []string
+	DryRun        bool
+}
+
+func (i ConfirmTotpMessage) validate() error {
+	if strings.TrimSpace(string(i.Code)) == "" {
+		return fmt.Errorf("%w code: a code of the app is needed", note.ErrValidation)
+	}
+	return nil
+}
+
+func (u ConfirmTotpCommand) Execute(i ConfirmTotpMessage) (ConfirmTotpResult, error) {
+	if u.users == nil {
+		return ConfirmTotpResult{}, fmt.Errorf("confirm totp: no accounts configured")
+	}
+	found := u.users.Read(i.User)
+	if found.Id == 0 {
+		return ConfirmTotpResult{}, fmt.Errorf("confirm totp %w: two-factor authentication needs an account", note.ErrUnauthorized)
+	}
+	if found.Totp.Enabled {
+		return ConfirmTotpResult{}, fmt.Errorf("confirm totp %w: two-factor authentication is on already", note.ErrConflict)
+	}
+	if found.Totp.Secret == "" {
+		return ConfirmTotpResult{}, fmt.Errorf("confirm totp %w: enroll first", note.ErrConflict)
+	}
+	totp, ok := found.Totp.Check(string(i.Code), u.clock.Now())
+	if !ok {
+		return ConfirmTotpResult{}, fmt.Errorf("%w code: wrong code, check the clock of the device", note.ErrValidation)
+	}
+	if i.DryRun {
+		return ConfirmTotpResult{RecoveryCodes: []string{}, DryRun: true}, nil
+	}
+	codes, hashes, err := user.NewRecoveryCodes()
+	if err != nil {
+		return ConfirmTotpResult{}, fmt.Errorf("confirm totp: %w", err)
+	}
+	totp.Enabled, totp.Recovery = true, hashes
+	found.Totp = totp
+	err = u.users.Update(found)
+	if err != nil {
+		return ConfirmTotpResult{}, fmt.Errorf("confirm totp: %w", err)
+	}
+	return ConfirmTotpResult{
+		RecoveryCodes: codes,
+	}, nil
+}
+
+// DisableTotp usecase
+// Turns the two-factor authentication off, with a code of the app or a
+// recovery code so a stolen session can't
+type DisableTotpCommand struct {
+	users Users
+	clock Clock
+}
+type DisableTotpMessage struct {
+	Context
+	Code user.Secret
+}
+type DisableTotpResult struct {
+	DryRun bool
+}
+
+func (u DisableTotpCommand) Execute(i DisableTotpMessage) (DisableTotpResult, error) {
+	if u.users == nil {
+		return DisableTotpResult{}, fmt.Errorf("disable totp: no accounts configured")
+	}
+	found := u.users.Read(i.User)
+	if found.Id == 0 {
+		return DisableTotpResult{}, fmt.Errorf("disable totp %w: two-factor authentication needs an account", note.ErrUnauthorized)
+	}
+	if !found.Totp.Enabled {
+		if i.DryRun {
+			return DisableTotpResult{DryRun: true}, nil
+		}
+		// an enrollment never confirmed is dropped
+		found.Totp = user.Totp{}
+		return DisableTotpResult{}, u.users.Update(found)
+	}
+	_, ok := found.Totp.Check(string(i.Code), u.clock.Now())
+	if !ok {
+		return DisableTotpResult{}, fmt.Errorf("disable totp %w: wrong or used code", note.ErrForbidden)
+	}
+	if i.DryRun {
+		return DisableTotpResult{DryRun: true}, nil
+	}
+	found.Totp = user.Totp{}
+	err := u.users.Update(found)
+	if err != nil {
+		return DisableTotpResult{}, fmt.Errorf("disable totp: %w", err)
+	}
+	return DisableTotpResult{}, nil
+}
+
+// Usage usecase
+// Tells what the notes of the user take up and their quota
+type UsageCommand struct {
+	storage storage.Storage
+	quota   Quota
+}
+type UsageMessage struct {
+	Context
+}
+type UsageResult struct {
+	Usage Usage
+	Quota Quota
+}
+
+func (u UsageCommand) Execute(i UsageMessage) (UsageResult, error) {
+	return UsageResult{
+		Usage: usageOf(u.storage, i.User),
+		Quota: u.quota,
+	}, nil
+}
+
+// Export usecase
+// Goes through the notes of the user one at a time, so they are written
+// out without a list of them all in memory
+// A query exports the notes the search finds, as tag:recipes, which are
+// looked up before the export starts.
+type ExportCommand struct {
+	storage storage.Storage
+	shares  Shares
+	search  SearchCommand
+}
+type ExportMessage struct {
+	Context
+	// Notebook only exports the notes of a notebook when not empty
+	Notebook note.Notebook
+	// Query only exports the notes matching it when not empty, in the
+	// syntax of the search
+	Query string
+	// Transclude replaces the ![[embeds]] of the contents by the notes
+	// they name, to render the notes rather than to back them up
+	Transclude bool
+}
+type ExportResult struct {
+	// Notes are read as they are iterated, in the order of their ids
+	Notes iter.Seq[note.Note]
+}
+
+func (i ExportMessage) validate() error {
+	if strings.TrimSpace(i.Query) == "" {
+		return nil
+	}
+	_, err := query.Parse(i.Query)
+	return err
+}
+
+func (u ExportCommand) Execute(i ExportMessage) (ExportResult, error) {
+	notes := u.storage.Each(storage.Filter{Notebook: i.Notebook, Owner: i.User})
+	if strings.TrimSpace(i.Query) != "" {
+		matched, err := u.matching(i.Context, i.Query)
+		if err != nil {
+			return ExportResult{}, err
+		}
+		all := notes
+		notes = func(yield func(note.Note) bool) {
+			for n := range all {
+				if matched[n.Id] && !yield(n) {
+					return
+				}
+			}
+		}
+	}
+	if !i.Transclude {
+		return ExportResult{Notes: notes}, nil
+	}
+	return ExportResult{
+		Notes: func(yield func(note.Note) bool) {
+			for n := range notes {
+				if !yield(transcluded(u.storage, u.shares, i.Context, n)) {
+					return
+				}
+			}
+		},
+	}, nil
+}
+
+// matching finds the ids of the notes matching a query, an index is only
+// needed for the bare words
+func (u ExportCommand) matching(c Context, raw string) (map[note.Id]bool, error) {
+	q, err := query.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if u.search.searcher == nil && len(q.Words()) > 0 {
+		return nil, fmt.Errorf("search: no index configured")
+	}
+	found, err := u.search.find(c, q, raw)
+	if err != nil {
+		return nil, err
+	}
+	matched := map[note.Id]bool{}
+	for _, n := range found {
+		matched[n.Id] = true
+	}
+	return matched, nil
+}
@@ -0,0 +1,97 @@
+package usecase
+
+import (
+	"fmt"
+	"time"
+
+	"notes/internal/note"
+	"notes/internal/storage"
+)
+
+// PinKey is the key of the pinned notes in their metadata
+const PinKey = "pinned"
+
+// Pin usecase
+// Pins a note to the dashboard, or unpins it
+type PinCommand struct {
+	storage storage.Storage
+	shares  Shares
+	events  *EventBus
+}
+type PinMessage struct {
+	Context
+	Id    note.Id
+	Unpin bool
+}
+type PinResult struct {
+	Note   note.Note
+	Pinned bool
+	DryRun bool
+}
+
+func (u PinCommand) Execute(i PinMessage) (PinResult, error) {
+	n, access := readShared(u.storage, u.shares, i.Context, i.Id)
+	if n.Id == 0 {
+		return PinResult{}, fmt.Errorf("note %d %w", i.Id, note.ErrNotFound)
+	}
+	err := writable(n, access)
+	if err != nil {
+		return PinResult{}, err
+	}
+	pinned := n
+	pinned.Metadata = n.Metadata.With(PinKey, "true")
+	if i.Unpin {
+		pinned.Metadata = n.Metadata.Without(PinKey)
+	}
+	if i.DryRun {
+		return PinResult{Note: pinned, Pinned: !i.Unpin, DryRun: true}, nil
+	}
+	pinned = u.storage.Restore(pinned)
+	u.events.publish(i.Context, note.Updated, pinned, n)
+	return PinResult{Note: pinned, Pinned: !i.Unpin}, nil
+}
+
+// dashboardRecent is the number of recent notes of the dashboard
+const dashboardRecent = 5
+
+// Dashboard usecase
+// Gathers what the user starts a session with: the notes pinned, those
+// due today and those changed last
+type DashboardCommand struct {
+	storage storage.Storage
+	clock   Clock
+	due     DueCommand
+	recent  RecentCommand
+}
+type DashboardMessage struct {
+	Context
+}
+type DashboardResult struct {
+	Pinned note.List
+	Due    []Reminder
+	Recent []RecentNote
+}
+
+func (u DashboardCommand) Execute(i DashboardMessage) (DashboardResult, error) {
+	pinned := note.List{}
+	for n := range u.storage.Each(storage.Filter{Owner: i.User}) {
+		if n.Metadata[PinKey] != "" {
+			pinned = append(pinned, n)
+		}
+	}
+	now := u.clock.Now()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	due, err := u.due.Execute(DueMessage{
+		Context: i.Context,
+		After:   start.Add(-time.Nanosecond),
+		Before:  start.AddDate(0, 0, 1).Add(-time.Nanosecond),
+	})
+	if err != nil {
+		return DashboardResult{}, err
+	}
+	recent, err := u.recent.Execute(RecentMessage{Context: i.Context, Limit: dashboardRecent})
+	if err != nil {
+		return DashboardResult{}, err
+	}
+	return DashboardResult{Pinned: pinned, Due: due.Reminders, Recent: recent.Notes}, nil
+}
@@ -0,0 +1,167 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"notes/internal/crypt"
+	"notes/internal/note"
+)
+
+// Command decorators
+// A decorator wraps the execution of every command with a cross-cutting
+// concern, it sees messages and results as any so one decorator fits
+// all the commands
+type Execute func(message any) (any, error)
+type Decorator func(name string, next Execute) Execute
+
+type commandFunc[Message, Result any] func(Message) (Result, error)
+
+func (f commandFunc[Message, Result]) Execute(m Message) (Result, error) {
+	return f(m)
+}
+
+// decorate wraps a command with decorators, the first one being the
+// outermost
+func decorate[Message, Result any](name string, command Command[Message, Result], decorators []Decorator) Command[Message, Result] {
+	next := func(message any) (any, error) {
+		return command.Execute(message.(Message))
+	}
+	for i := len(decorators) - 1; i >= 0; i-- {
+		next = decorators[i](name, next)
+	}
+	return commandFunc[Message, Result](func(m Message) (Result, error) {
+		result, err := next(m)
+		r, _ := result.(Result)
+		return r, err
+	})
+}
+
+// Validating rejects messages whose validate method fails before they
+// reach the command
+func Validating(name string, next Execute) Execute {
+	return func(message any) (any, error) {
+		if v, ok := message.(interface{ validate() error }); ok {
+			err := v.validate()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return next(message)
+	}
+}
+
+// Sealing refuses the contents the clients didn't seal, see package
+// crypt, so a server in the end-to-end encrypted mode only keeps
+// ciphertext
+func Sealing(name string, next Execute) Execute {
+	return func(message any) (any, error) {
+		content := note.Content("")
+		switch m := message.(type) {
+		case CreateMessage:
+			content = m.Content
+		case QuickMessage:
+			content = m.Content
+		case UpdateMessage:
+			content = m.Content
+		case TodayMessage:
+			m.Blank = true
+			message = m
+		}
+		if content != "" && !crypt.IsSealed(content) {
+			return nil, fmt.Errorf("%w content: the server only keeps the contents encrypted by the clients", note.ErrValidation)
+		}
+		return next(message)
+	}
+}
+
+// Logging logs every command with its duration and error
+func Logging(logger *log.Logger) Decorator {
+	return func(name string, next Execute) Execute {
+		return func(message any) (any, error) {
+			start := time.Now()
+			result, err := next(message)
+			if err != nil {
+				logger.Printf("%s %+v failed after %s: %v", name, message, time.Since(start), err)
+			} else {
+				logger.Printf("%s %+v done in %s", name, message, time.Since(start))
+			}
+			return result, err
+		}
+	}
+}
+
+// Metrics counts the executions, failures and time spent per command
+type Metrics struct {
+	mutex    sync.Mutex
+	calls    map[string]int
+	failures map[string]int
+	duration map[string]time.Duration
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		calls:    map[string]int{},
+		failures: map[string]int{},
+		duration: map[string]time.Duration{},
+	}
+}
+
+// Decorator records the metrics of the commands it wraps
+func (m *Metrics) Decorator(name string, next Execute) Execute {
+	return func(message any) (any, error) {
+		start := time.Now()
+		result, err := next(message)
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		m.calls[name]++
+		m.duration[name] += time.Since(start)
+		if err != nil {
+			m.failures[name]++
+		}
+		return result, err
+	}
+}
+
+// Write prints the metrics in the Prometheus text format
+func (m *Metrics) Write(w io.Writer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	names := []string{}
+	for name := range m.calls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "notes_command_calls_total{command=%q} %d\n", name, m.calls[name])
+		fmt.Fprintf(w, "notes_command_failures_total{command=%q} %d\n", name, m.failures[name])
+		fmt.Fprintf(w, "notes_command_seconds_total{command=%q} %f\n", name, m.duration[name].Seconds())
+	}
+}
+
+// Retrying runs a command again when it fails with a temporary error,
+// waiting twice as long before each new attempt
+func Retrying(attempts int, wait time.Duration) Decorator {
+	return func(name string, next Execute) Execute {
+		return func(message any) (any, error) {
+			result, err := next(message)
+			delay := wait
+			for i := 1; i < attempts && isTemporary(err); i++ {
+				time.Sleep(delay)
+				delay *= 2
+				result, err = next(message)
+			}
+			return result, err
+		}
+	}
+}
+
+func isTemporary(err error) bool {
+	var t interface{ Temporary() bool }
+	return errors.As(err, &t) && t.Temporary()
+}
@@ -0,0 +1,246 @@
+package usecase
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"fmt"
+	"slices"
+	"strings"
+
+	"notes/internal/crypt"
+	"notes/internal/note"
+	"notes/internal/query"
+	"notes/internal/storage"
+)
+
+// Duplicates are notes with the same content, or nearly
+type Duplicates struct {
+	// Notes in the order of their ids, the first one is kept by a merge
+	Notes note.List
+	// Exact tells the contents are the same but for the case and the
+	// spacing
+	Exact bool
+	// Similarity is the lowest share of words two of the notes have in
+	// common, from 0 to 1
+	Similarity float64
+}
+
+// Dedupe usecase
+// Finds the notes of the user which duplicate each other, Merge or
+// Delete removes the duplicates
+// The contents are compared by their words, two notes are near
+// duplicates when the words they have in common are at least the
+// threshold of all their words. The sealed contents are left out.
+type DedupeCommand struct {
+	storage storage.Storage
+}
+type DedupeMessage struct {
+	Context
+	// Threshold is the similarity of the near duplicates, 0.8 when 0,
+	// only the exact duplicates are found with 1
+	Threshold float64
+}
+type DedupeResult struct {
+	Duplicates []Duplicates
+}
+
+func (i DedupeMessage) validate() error {
+	if i.Threshold < 0 || i.Threshold > 1 {
+		return fmt.Errorf("%w threshold: %g is not between 0 and 1", note.ErrValidation, i.Threshold)
+	}
+	return nil
+}
+
+// normalized is a content without its case and spacing
+func normalized(content note.Content) string {
+	return strings.Join(strings.Fields(strings.ToLower(content)), " ")
+}
+
+func (u DedupeCommand) Execute(i DedupeMessage) (DedupeResult, error) {
+	threshold := cmp.Or(i.Threshold, 0.8)
+	// the exact duplicates share the hash of their normalized content
+	exact := map[[sha256.Size]byte]note.List{}
+	hashes := [][sha256.Size]byte{}
+	for n := range u.storage.Each(storage.Filter{Owner: i.User}) {
+		content := normalized(n.Content)
+		if content == "" || crypt.IsSealed(n.Content) {
+			continue
+		}
+		hash := sha256.Sum256([]byte(content))
+		if _, ok := exact[hash]; !ok {
+			hashes = append(hashes, hash)
+		}
+		exact[hash] = append(exact[hash], n)
+	}
+	// the near duplicates are found among one note of each exact group,
+	// the groups linked by a pair of similar notes are joined
+	type candidate struct {
+		notes note.List
+		words map[string]bool
+	}
+	candidates := []candidate{}
+	for _, hash := range hashes {
+		c := candidate{notes: exact[hash], words: map[string]bool{}}
+		for _, w := range query.Words(exact[hash][0].Content) {
+			c.words[w] = true
+		}
+		candidates = append(candidates, c)
+	}
+	groups := make([]int, len(candidates))
+	similarity := make([]float64, len(candidates))
+	for k := range groups {
+		groups[k] = k
+		similarity[k] = 1
+	}
+	var root func(int) int
+	root = func(k int) int {
+		if groups[k] != k {
+			groups[k] = root(groups[k])
+		}
+		return groups[k]
+	}
+	if threshold < 1 {
+		// the notes are compared by increasing number of words, a note
+		// with too few words for the next ones can't be similar to them
+		order := make([]int, len(candidates))
+		for k := range order {
+			order[k] = k
+		}
+		slices.SortFunc(order, func(a, b int) int {
+			return cmp.Compare(len(candidates[a].words), len(candidates[b].words))
+		})
+		for x, a := range order {
+			for _, b := range order[x+1:] {
+				if float64(len(candidates[a].words)) < threshold*float64(len(candidates[b].words)) {
+					break
+				}
+				s := jaccard(candidates[a].words, candidates[b].words)
+				if s < threshold {
+					continue
+				}
+				ra, rb := root(a), root(b)
+				if ra != rb {
+					groups[rb] = ra
+				}
+				similarity[ra] = min(similarity[ra], similarity[rb], s)
+			}
+		}
+	}
+	joined := map[int]*Duplicates{}
+	roots := []int{}
+	for k, c := range candidates {
+		r := root(k)
+		d, ok := joined[r]
+		if !ok {
+			d = &Duplicates{Exact: true}
+			joined[r] = d
+			roots = append(roots, r)
+		}
+		if len(d.Notes) > 0 {
+			d.Exact = false
+		}
+		d.Notes = append(d.Notes, c.notes...)
+	}
+	duplicates := []Duplicates{}
+	for _, r := range roots {
+		d := joined[r]
+		if len(d.Notes) < 2 {
+			continue
+		}
+		d.Similarity = similarity[r]
+		slices.SortFunc(d.Notes, func(a, b note.Note) int { return cmp.Compare(a.Id, b.Id) })
+		duplicates = append(duplicates, *d)
+	}
+	slices.SortFunc(duplicates, func(a, b Duplicates) int { return cmp.Compare(a.Notes[0].Id, b.Notes[0].Id) })
+	return DedupeResult{Duplicates: duplicates}, nil
+}
+
+// jaccard is the share of the words of two sets in both
+func jaccard(a map[string]bool, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	common := 0
+	for w := range a {
+		if b[w] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// Merge usecase
+// Keeps a note and deletes its duplicates, the contents of the
+// duplicates it doesn't hold yet are appended to it
+type MergeCommand struct {
+	storage storage.Storage
+	shares  Shares
+	events  *EventBus
+}
+type MergeMessage struct {
+	Context
+	// Id of the note kept
+	Id note.Id
+	// Duplicates deleted
+	Duplicates []note.Id
+}
+type MergeResult struct {
+	Note    note.Note
+	Deleted note.List
+	DryRun  bool
+}
+
+func (i MergeMessage) validate() error {
+	if len(i.Duplicates) == 0 {
+		return fmt.Errorf("%w duplicates: none to merge", note.ErrValidation)
+	}
+	if slices.Contains(i.Duplicates, i.Id) {
+		return fmt.Errorf("%w duplicates: note %d is the one kept", note.ErrValidation, i.Id)
+	}
+	return nil
+}
+
+func (u MergeCommand) Execute(i MergeMessage) (MergeResult, error) {
+	kept, access := readShared(u.storage, u.shares, i.Context, i.Id)
+	if kept.Id == 0 {
+		return MergeResult{}, fmt.Errorf("note %d %w", i.Id, note.ErrNotFound)
+	}
+	err := writable(kept, access)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	duplicates := note.List{}
+	content := kept.Content
+	for _, id := range i.Duplicates {
+		d, access := readShared(u.storage, u.shares, i.Context, id)
+		if d.Id == 0 {
+			return MergeResult{}, fmt.Errorf("note %d %w", id, note.ErrNotFound)
+		}
+		err := writable(d, access)
+		if err != nil {
+			return MergeResult{}, err
+		}
+		duplicates = append(duplicates, d)
+		if strings.Contains(normalized(content), normalized(d.Content)) {
+			continue
+		}
+		if crypt.IsSealed(content) || crypt.IsSealed(d.Content) {
+			return MergeResult{}, fmt.Errorf("%w note %d: the sealed contents can't be merged", note.ErrValidation, id)
+		}
+		content = strings.TrimRight(content, "\n") + "\n\n" + d.Content
+	}
+	merged := kept
+	merged.Content = content
+	if i.DryRun {
+		return MergeResult{Note: merged, Deleted: duplicates, DryRun: true}, nil
+	}
+	if content != kept.Content {
+		merged = u.storage.Update(kept.Id, "", content)
+		u.events.publish(i.Context, note.Updated, merged, kept)
+	}
+	for _, d := range duplicates {
+		n := u.storage.Delete(d.Id)
+		u.events.publish(i.Context, note.Deleted, n, n)
+	}
+	return MergeResult{Note: merged, Deleted: duplicates}, nil
+}
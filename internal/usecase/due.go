@@ -0,0 +1,49 @@
+package usecase
+
+import (
+	"slices"
+	"time"
+
+	"notes/internal/note"
+	"notes/internal/query"
+	"notes/internal/storage"
+)
+
+// Reminder is a note with a due date
+type Reminder struct {
+	Note note.Note
+	Due  time.Time
+}
+
+// Due usecase
+// Lists the notes of the user due in a period, the earliest first
+// A note is due at the date of a due: word of its content, see query.Due,
+// the sealed contents have none
+type DueCommand struct {
+	storage storage.Storage
+}
+type DueMessage struct {
+	Context
+	// After excludes the notes due at or before it, none when zero
+	After time.Time
+	// Before excludes the notes due after it, none when zero
+	Before time.Time
+}
+type DueResult struct {
+	Reminders []Reminder
+}
+
+func (u DueCommand) Execute(i DueMessage) (DueResult, error) {
+	reminders := []Reminder{}
+	for n := range u.storage.Each(storage.Filter{Owner: i.User}) {
+		due, ok := query.Due(n.Content)
+		if !ok || (!i.After.IsZero() && !due.After(i.After)) || (!i.Before.IsZero() && due.After(i.Before)) {
+			continue
+		}
+		reminders = append(reminders, Reminder{Note: n, Due: due})
+	}
+	slices.SortStableFunc(reminders, func(a, b Reminder) int {
+		return a.Due.Compare(b.Due)
+	})
+	return DueResult{Reminders: reminders}, nil
+}
@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"sync"
+
+	"notes/internal/note"
+)
+
+// Subscriber receives the events published by the commands
+type Subscriber interface {
+	Notify(note.Event)
+}
+
+// SubscriberFunc lets a plain function subscribe to events
+type SubscriberFunc func(note.Event)
+
+func (f SubscriberFunc) Notify(e note.Event) {
+	f(e)
+}
+
+// EventBus delivers the events published by the commands to every
+// subscriber, synchronously and in order of subscription
+// Subscribers doing slow work should hand it off to a goroutine
+type EventBus struct {
+	mutex       sync.RWMutex
+	subscribers []Subscriber
+	clock       Clock
+}
+
+// NewEventBus stamps the events with the time of clock
+func NewEventBus(clock Clock) *EventBus {
+	return &EventBus{clock: clock}
+}
+
+func (b *EventBus) Subscribe(s Subscriber) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.subscribers = append(b.subscribers, s)
+}
+
+func (b *EventBus) publish(ctx Context, kind note.EventKind, n note.Note, previous note.Note) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	e := note.Event{Kind: kind, Note: n, Previous: previous, Actor: ctx.Actor, At: b.clock.Now()}
+	for _, s := range b.subscribers {
+		s.Notify(e)
+	}
+}
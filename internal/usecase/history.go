@@ -0,0 +1,318 @@
+package usecase
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"notes/internal/audit"
+	"notes/internal/note"
+	"notes/internal/share"
+	"notes/internal/storage"
+)
+
+// timeLayouts are the layouts of ParseTime, the ones without a zone are
+// in local time
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// ParseTime reads the time of a version of a note, as
+// 2024-06-01T00:00:00Z, 2024-06-01 09:30 or 2024-06-01
+func ParseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w time: %q is not a time such as 2024-06-01T00:00:00Z", note.ErrValidation, s)
+}
+
+// ParseVersion reads a version of a note by its time, see ParseTime,
+// or by its number, as v3
+func ParseVersion(s string) (time.Time, int, error) {
+	s = strings.TrimSpace(s)
+	if digits, ok := strings.CutPrefix(s, "v"); ok {
+		number, err := strconv.Atoi(digits)
+		if err != nil || number < 1 {
+			return time.Time{}, 0, fmt.Errorf("%w version: %q is not a version such as v3", note.ErrValidation, s)
+		}
+		return time.Time{}, number, nil
+	}
+	t, err := ParseTime(s)
+	return t, 0, err
+}
+
+// versionAt is a note as it was at a time, told by its changes in the
+// audit log, the zero note when it didn't exist then
+// The note is as the last change before the time left it, or as the
+// first change after the time found it. A note without any change
+// recorded has always been as it is now.
+func versionAt(log audit.Store, id note.Id, now note.Note, at time.Time) (note.Note, error) {
+	if log == nil {
+		return note.Note{}, fmt.Errorf("note %d %w: there is no history without an audit log", id, note.ErrNotFound)
+	}
+	entries, err := log.Query(audit.Query{NoteId: id})
+	if err != nil {
+		return note.Note{}, fmt.Errorf("history: %w", err)
+	}
+	version, found := now, false
+	for _, e := range entries {
+		if e.At.After(at) {
+			if !found {
+				version = e.Before
+			}
+			break
+		}
+		version, found = e.After, true
+	}
+	return version, nil
+}
+
+// versionNumbered is a note as its changes in the audit log left it,
+// version 1 is the note as created, the views are not versions
+func versionNumbered(log audit.Store, id note.Id, number int) (note.Note, error) {
+	if log == nil {
+		return note.Note{}, fmt.Errorf("note %d %w: there is no history without an audit log", id, note.ErrNotFound)
+	}
+	entries, err := log.Query(audit.Query{NoteId: id})
+	if err != nil {
+		return note.Note{}, fmt.Errorf("history: %w", err)
+	}
+	count := 0
+	for _, e := range entries {
+		if e.Kind == note.Viewed {
+			continue
+		}
+		count++
+		if count == number {
+			return e.After, nil
+		}
+	}
+	return note.Note{}, nil
+}
+
+// readVersion reads a note as it was at a time, if the user of the
+// context may read it now, or could then when it is deleted
+func readVersion(s storage.Storage, shares Shares, log audit.Store, c Context, id note.Id, at time.Time) (note.Note, error) {
+	return readHistory(s, shares, c, id, at.Format(time.RFC3339), func(now note.Note) (note.Note, error) {
+		return versionAt(log, id, now, at)
+	})
+}
+
+// readVersionNumbered reads a version of a note by its number, see
+// versionNumbered, as readVersion does
+func readVersionNumbered(s storage.Storage, shares Shares, log audit.Store, c Context, id note.Id, number int) (note.Note, error) {
+	return readHistory(s, shares, c, id, fmt.Sprintf("version %d", number), func(note.Note) (note.Note, error) {
+		return versionNumbered(log, id, number)
+	})
+}
+
+// readHistory checks the user of the context may read the version of a
+// note found by version, when describes the version in the errors
+func readHistory(s storage.Storage, shares Shares, c Context, id note.Id, when string, version func(now note.Note) (note.Note, error)) (note.Note, error) {
+	now, _ := readShared(s, shares, c, id)
+	if now.Id == 0 && s.Read(id).Id != 0 {
+		return note.Note{}, fmt.Errorf("note %d %w", id, note.ErrNotFound)
+	}
+	found, err := version(now)
+	if err != nil {
+		return note.Note{}, err
+	}
+	if found.Id == 0 || (now.Id == 0 && !c.access(shares, found).Allows(share.Read)) {
+		return note.Note{}, fmt.Errorf("note %d %w at %s", id, note.ErrNotFound, when)
+	}
+	return found, nil
+}
+
+// DiffLine is a line of a diff, Kind tells whether it is in both
+// versions " ", removed "-" or added "+"
+type DiffLine struct {
+	Kind string
+	Text string
+}
+
+// Diff usecase
+// Compares two versions of a note line by line, the versions are the
+// note as it was at two times, see Read, or as its changes numbered from
+// 1 left it
+type DiffCommand struct {
+	storage storage.Storage
+	shares  Shares
+	log     audit.Store
+	clock   Clock
+}
+type DiffMessage struct {
+	Context
+	Id   note.Id
+	From time.Time
+	// To is now when zero
+	To time.Time
+	// FromVersion and ToVersion are the numbers of the versions, they are
+	// read rather than the times when not 0
+	FromVersion int
+	ToVersion   int
+}
+type DiffResult struct {
+	From  note.Note
+	To    note.Note
+	Lines []DiffLine
+	// Unified is the diff of the contents in the unified format, the
+	// changed lines with three lines of context
+	Unified string
+}
+
+func (i DiffMessage) validate() error {
+	if i.From.IsZero() && i.FromVersion == 0 {
+		return fmt.Errorf("%w from: the diff needs the time or the version of the first version", note.ErrValidation)
+	}
+	if i.FromVersion < 0 || i.ToVersion < 0 {
+		return fmt.Errorf("%w version: the versions are numbered from 1", note.ErrValidation)
+	}
+	return nil
+}
+
+func (u DiffCommand) Execute(i DiffMessage) (DiffResult, error) {
+	read := func(at time.Time, number int) (note.Note, error) {
+		if number != 0 {
+			return readVersionNumbered(u.storage, u.shares, u.log, i.Context, i.Id, number)
+		}
+		if at.IsZero() {
+			at = u.clock.Now()
+		}
+		return readVersion(u.storage, u.shares, u.log, i.Context, i.Id, at)
+	}
+	from, err := read(i.From, i.FromVersion)
+	if err != nil {
+		return DiffResult{}, err
+	}
+	later, err := read(i.To, i.ToVersion)
+	if err != nil {
+		return DiffResult{}, err
+	}
+	lines := diffLines(strings.Split(from.Content, "\n"), strings.Split(later.Content, "\n"))
+	return DiffResult{
+		From:    from,
+		To:      later,
+		Lines:   lines,
+		Unified: unified(from, later, lines),
+	}, nil
+}
+
+// diffContext is the number of unchanged lines around the changes of a
+// unified diff
+const diffContext = 3
+
+// unified writes the lines of a diff as hunks of changes, each with the
+// line numbers of its first line in both versions and its lengths
+// An unchanged content has no hunks.
+func unified(from note.Note, to note.Note, lines []DiffLine) string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", from.Name, to.Name)
+	// at is the number of each line in both versions, from 1
+	type numbered struct{ from, to int }
+	at := make([]numbered, len(lines)+1)
+	fromLine, toLine := 1, 1
+	for k, l := range lines {
+		at[k] = numbered{fromLine, toLine}
+		if l.Kind != "+" {
+			fromLine++
+		}
+		if l.Kind != "-" {
+			toLine++
+		}
+	}
+	at[len(lines)] = numbered{fromLine, toLine}
+	for k := 0; k < len(lines); {
+		if lines[k].Kind == " " {
+			k++
+			continue
+		}
+		start := max(k-diffContext, 0)
+		end := k
+		// the hunk goes on while the changes are closer than twice the
+		// context
+		for end < len(lines) {
+			next := end
+			for next < len(lines) && lines[next].Kind == " " {
+				next++
+			}
+			if next == len(lines) || next-end > 2*diffContext {
+				end = min(end+diffContext, len(lines))
+				break
+			}
+			for next < len(lines) && lines[next].Kind != " " {
+				next++
+			}
+			end = next
+		}
+		removed, added := 0, 0
+		for _, l := range lines[start:end] {
+			if l.Kind != "+" {
+				removed++
+			}
+			if l.Kind != "-" {
+				added++
+			}
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(at[start].from, removed), hunkRange(at[start].to, added))
+		for _, l := range lines[start:end] {
+			b.WriteString(l.Kind + l.Text + "\n")
+		}
+		k = end
+	}
+	return b.String()
+}
+
+// hunkRange is the first line and the length of a hunk, an empty hunk
+// starts at the line before it
+func hunkRange(first int, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", first-1)
+	}
+	if length == 1 {
+		return fmt.Sprint(first)
+	}
+	return fmt.Sprintf("%d,%d", first, length)
+}
+
+// DiffContents compares two contents line by line, as Diff does
+func DiffContents(from note.Content, to note.Content) []DiffLine {
+	return diffLines(strings.Split(from, "\n"), strings.Split(to, "\n"))
+}
+
+// diffLines finds the longest common subsequence of the lines, the
+// other lines are removed from a or added from b
+func diffLines(a []string, b []string) []DiffLine {
+	// common[i][j] is the length of the subsequence of a[i:] and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+	lines := []DiffLine{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, DiffLine{" ", a[i]})
+			i, j = i+1, j+1
+		case j == len(b) || (i < len(a) && common[i+1][j] >= common[i][j+1]):
+			lines = append(lines, DiffLine{"-", a[i]})
+			i++
+		default:
+			lines = append(lines, DiffLine{"+", b[j]})
+			j++
+		}
+	}
+	return lines
+}
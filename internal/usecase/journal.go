@@ -0,0 +1,107 @@
+package usecase
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"notes/internal/expand"
+	"notes/internal/note"
+	"notes/internal/storage"
+)
+
+// Journal keeps a daily note for each user, named after its date
+type Journal struct {
+	// Notebook of the daily notes, "journal" when empty
+	Notebook note.Notebook
+	// Template is a text/template of the content of a new daily note,
+	// given its .Date, DefaultJournalTemplate when nil
+	// The variables of the expander are its functions, such as {{uuid}},
+	// it must be parsed with them, see expand.Expander.Funcs.
+	Template *template.Template
+}
+
+// DefaultJournalTemplate titles the daily notes with their date
+var DefaultJournalTemplate = template.Must(template.New("journal").Parse("# {{.Date.Format \"Monday 2 January 2006\"}}\n\n"))
+
+// journalDate names the daily notes
+const journalDate = "2006-01-02"
+
+// Today usecase
+// Opens the daily note of the user, created from the template of the
+// journal when there is none yet
+type TodayCommand struct {
+	storage  storage.Storage
+	events   *EventBus
+	journal  Journal
+	clock    Clock
+	quota    Quota
+	expander expand.Expander
+}
+type TodayMessage struct {
+	Context
+	// Blank creates the daily note without the template, the end-to-end
+	// encrypted servers can't write contents
+	Blank bool
+}
+type TodayResult struct {
+	Note note.Note
+	// Created tells the daily note didn't exist
+	Created bool
+	DryRun  bool
+}
+
+func (u TodayCommand) Execute(i TodayMessage) (TodayResult, error) {
+	now := u.clock.Now()
+	name := now.Format(journalDate)
+	notebook := u.journal.Notebook
+	if notebook == "" {
+		notebook = "journal"
+	}
+	for n := range u.storage.Each(storage.Filter{Notebook: notebook, Owner: i.User}) {
+		if n.Name == name {
+			return TodayResult{Note: n}, nil
+		}
+	}
+	content := ""
+	if !i.Blank {
+		var err error
+		content, err = u.content(now)
+		if err != nil {
+			return TodayResult{}, err
+		}
+	}
+	err := u.quota.check(usageOf(u.storage, i.User), 1, len(content))
+	if err != nil {
+		return TodayResult{}, err
+	}
+	if i.DryRun {
+		n := note.Note{Name: name, Content: content, Notebook: notebook, Owner: i.User}
+		return TodayResult{Note: n, Created: true, DryRun: true}, nil
+	}
+	n := u.storage.Create(name, content, notebook, i.User)
+	u.events.publish(i.Context, note.Created, n, note.Note{})
+	return TodayResult{
+		Note:    n,
+		Created: true,
+	}, nil
+}
+
+func (u TodayCommand) content(date time.Time) (note.Content, error) {
+	t := u.journal.Template
+	if t == nil {
+		t = DefaultJournalTemplate
+	}
+	t, err := t.Clone()
+	if err != nil {
+		return "", fmt.Errorf("journal template: %w", err)
+	}
+	t.Funcs(u.expander.Funcs())
+	content := strings.Builder{}
+	err = t.Execute(&content, map[string]any{"Date": date})
+	if err != nil {
+		return "", fmt.Errorf("journal template: %w", err)
+	}
+	return content.String(), nil
+}
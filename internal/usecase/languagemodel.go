@@ -0,0 +1,7 @@
+package usecase
+
+// LanguageModel writes the answer to a prompt, following the
+// instructions given to it, as a large language model does
+type LanguageModel interface {
+	Complete(instructions string, prompt string) (string, error)
+}
@@ -0,0 +1,36 @@
+package usecase
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"notes/internal/note"
+)
+
+// Sorts of the notes listed, the shortest first by length and the
+// longest first by -length, the notes of the same length stay in their
+// order
+var Sorts = []string{"length", "-length"}
+
+func validSort(sort string) error {
+	if sort != "" && !slices.Contains(Sorts, sort) {
+		return fmt.Errorf("%w sort: %q is not one of %v", note.ErrValidation, sort, Sorts)
+	}
+	return nil
+}
+
+// sortByLength orders the notes by the number of words of their
+// content, they are left in the order of their ids without a sort
+func sortByLength[T any](notes []T, sort string, length func(T) note.Length) {
+	if sort == "" {
+		return
+	}
+	slices.SortStableFunc(notes, func(a, b T) int {
+		order := cmp.Compare(length(a).Words, length(b).Words)
+		if sort == "-length" {
+			return -order
+		}
+		return order
+	})
+}
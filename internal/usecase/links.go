@@ -0,0 +1,6 @@
+package usecase
+
+// LinkChecker follows a web link, the error tells why it is broken
+type LinkChecker interface {
+	Check(url string) error
+}
@@ -0,0 +1,186 @@
+package usecase
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"notes/internal/crypt"
+	"notes/internal/note"
+	"notes/internal/storage"
+)
+
+// LintProblem is a broken link of a note
+type LintProblem struct {
+	Id   note.Id
+	Name note.Name
+	// Line is the number of the line of the link, from 1, and Context
+	// its text
+	Line    int
+	Context string
+	Link    string
+	Reason  string
+}
+
+// Lint usecase
+// Finds the broken links of the notes of the user: the [[wiki links]]
+// naming no note, and the web links the checker can't follow, they are
+// not checked without a checker
+// The report is kept in the note LintReport when asked.
+type LintCommand struct {
+	storage storage.Storage
+	events  *EventBus
+	links   LinkChecker
+}
+type LintMessage struct {
+	Context
+	// Report writes the problems to the report note
+	Report bool
+}
+type LintResult struct {
+	Problems []LintProblem
+	// Report is the report note when one was written
+	Report note.Note
+	DryRun bool
+}
+
+// LintReport is the name of the report note
+const LintReport = "Lint report"
+
+var (
+	wikiLink = regexp.MustCompile(`\[\[([^\[\]|#]+)(#[^\[\]|]*)?(\|[^\[\]]*)?\]\]`)
+	webLink  = regexp.MustCompile(`https?://[^\s<>()\[\]"'` + "`" + `]+`)
+)
+
+// linkCheckers is the number of web links checked at the same time
+const linkCheckers = 8
+
+// contextLength is the number of characters of a line kept in a problem
+const contextLength = 120
+
+func (u LintCommand) Execute(i LintMessage) (LintResult, error) {
+	names := map[string]bool{}
+	for n := range u.storage.Each(storage.Filter{Owner: i.User}) {
+		names[strings.ToLower(strings.TrimSpace(n.Name))] = true
+	}
+	problems := []LintProblem{}
+	// the web links are checked once, after the notes were read
+	found := map[string][]LintProblem{}
+	links := []string{}
+	for n := range u.storage.Each(storage.Filter{Owner: i.User}) {
+		if crypt.IsSealed(n.Content) || n.Name == LintReport {
+			continue
+		}
+		for number, line := range strings.Split(n.Content, "\n") {
+			problem := LintProblem{Id: n.Id, Name: n.Name, Line: number + 1, Context: lineContext(line)}
+			for _, m := range wikiLink.FindAllStringSubmatch(line, -1) {
+				if !names[strings.ToLower(strings.TrimSpace(m[1]))] {
+					problem.Link, problem.Reason = m[0], "no such note"
+					problems = append(problems, problem)
+				}
+			}
+			if u.links == nil {
+				continue
+			}
+			for _, link := range webLink.FindAllString(line, -1) {
+				link = strings.TrimRight(link, ".,;:!?")
+				problem.Link = link
+				if _, ok := found[link]; !ok {
+					links = append(links, link)
+				}
+				found[link] = append(found[link], problem)
+			}
+		}
+	}
+	broken := u.check(links)
+	for _, link := range links {
+		if err, ok := broken[link]; ok {
+			for _, p := range found[link] {
+				p.Reason = err.Error()
+				problems = append(problems, p)
+			}
+		}
+	}
+	slices.SortStableFunc(problems, func(a, b LintProblem) int {
+		if a.Id != b.Id {
+			return a.Id - b.Id
+		}
+		return a.Line - b.Line
+	})
+	result := LintResult{Problems: problems, DryRun: i.DryRun}
+	if !i.Report {
+		return result, nil
+	}
+	result.Report = note.Note{Name: LintReport, Content: lintReport(problems), Owner: i.User}
+	if i.DryRun {
+		return result, nil
+	}
+	for n := range u.storage.Each(storage.Filter{Owner: i.User}) {
+		if n.Name == LintReport {
+			result.Report = u.storage.Update(n.Id, "", result.Report.Content)
+			u.events.publish(i.Context, note.Updated, result.Report, n)
+			return result, nil
+		}
+	}
+	result.Report = u.storage.Create(LintReport, result.Report.Content, "", i.User)
+	u.events.publish(i.Context, note.Created, result.Report, note.Note{})
+	return result, nil
+}
+
+// check follows the web links a few at a time, the broken ones are
+// returned with their error
+func (u LintCommand) check(found []string) map[string]error {
+	broken := map[string]error{}
+	mutex := sync.Mutex{}
+	links := make(chan string)
+	wait := sync.WaitGroup{}
+	for range min(linkCheckers, len(found)) {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for link := range links {
+				if err := u.links.Check(link); err != nil {
+					mutex.Lock()
+					broken[link] = err
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+	for _, link := range found {
+		links <- link
+	}
+	close(links)
+	wait.Wait()
+	return broken
+}
+
+// lineContext trims a line to its start
+func lineContext(line string) string {
+	line = strings.TrimSpace(line)
+	if utf8.RuneCountInString(line) <= contextLength {
+		return line
+	}
+	return string([]rune(line)[:contextLength]) + "…"
+}
+
+// lintReport lists the problems in Markdown, by note
+func lintReport(problems []LintProblem) string {
+	if len(problems) == 0 {
+		return "No broken links\n"
+	}
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "%d broken links\n", len(problems))
+	last := note.Id(0)
+	for _, p := range problems {
+		if p.Id != last {
+			fmt.Fprintf(&b, "\n## [[%s]]\n\n", p.Name)
+			last = p.Id
+		}
+		fmt.Fprintf(&b, "- line %d: %s, %s\n  > %s\n", p.Line, p.Link, p.Reason, p.Context)
+	}
+	return b.String()
+}
@@ -0,0 +1,192 @@
+package usecase
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"notes/internal/note"
+	"notes/internal/storage"
+)
+
+// LockDuration is how long a note stays locked unless its lock is renewed
+const LockDuration = 5 * time.Minute
+
+// NoteLock tells a note is being edited by its holder, the actor of the
+// lock, until it expires
+type NoteLock struct {
+	Id     note.Id
+	Holder string
+	Until  time.Time
+}
+
+// locks of the notes being edited, kept in memory as they don't outlive
+// their editors
+type locks struct {
+	mutex *sync.Mutex
+	held  map[note.Id]NoteLock
+}
+
+func newLocks() locks {
+	return locks{mutex: &sync.Mutex{}, held: map[note.Id]NoteLock{}}
+}
+
+// get is the lock of a note unless it expired
+func (l locks) get(id note.Id, now time.Time) (NoteLock, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.unexpired(id, now)
+}
+
+func (l locks) unexpired(id note.Id, now time.Time) (NoteLock, bool) {
+	lock, ok := l.held[id]
+	if ok && !now.Before(lock.Until) {
+		delete(l.held, id)
+		return NoteLock{}, false
+	}
+	return lock, ok
+}
+
+// acquire takes the lock of a note for a holder, or renews it, unless
+// another holder has it
+func (l locks) acquire(lock NoteLock, now time.Time) (NoteLock, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	held, ok := l.unexpired(lock.Id, now)
+	if ok && held.Holder != lock.Holder {
+		return held, locked(held)
+	}
+	l.held[lock.Id] = lock
+	return lock, nil
+}
+
+// release gives the lock of a note back, only its holder may
+func (l locks) release(id note.Id, holder string, now time.Time) (NoteLock, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	held, ok := l.unexpired(id, now)
+	if !ok {
+		return NoteLock{}, fmt.Errorf("lock of note %d %w", id, note.ErrNotFound)
+	}
+	if held.Holder != holder {
+		return held, locked(held)
+	}
+	delete(l.held, id)
+	return held, nil
+}
+
+func locked(lock NoteLock) error {
+	return fmt.Errorf("note %d %w: it is locked by %s until %s", lock.Id, note.ErrConflict, lock.Holder, lock.Until.Format("15:04:05"))
+}
+
+// Lock usecase
+// Tells the other editors of a note it is being edited, they are warned
+// by Locked before they edit it too
+// The lock is advisory, the note may still be changed, it expires after
+// LockDuration unless its holder locks the note again.
+type LockCommand struct {
+	storage storage.Storage
+	shares  Shares
+	locks   locks
+	clock   Clock
+}
+type LockMessage struct {
+	Context
+	Id note.Id
+}
+type LockResult struct {
+	Lock   NoteLock
+	DryRun bool
+}
+
+func (u LockCommand) Execute(i LockMessage) (LockResult, error) {
+	n, access := readShared(u.storage, u.shares, i.Context, i.Id)
+	if n.Id == 0 {
+		return LockResult{}, fmt.Errorf("note %d %w", i.Id, note.ErrNotFound)
+	}
+	err := writable(n, access)
+	if err != nil {
+		return LockResult{}, err
+	}
+	now := u.clock.Now()
+	lock := NoteLock{Id: n.Id, Holder: i.Actor, Until: now.Add(LockDuration)}
+	if i.DryRun {
+		if held, ok := u.locks.get(n.Id, now); ok && held.Holder != i.Actor {
+			return LockResult{}, locked(held)
+		}
+		return LockResult{Lock: lock, DryRun: true}, nil
+	}
+	lock, err = u.locks.acquire(lock, now)
+	if err != nil {
+		return LockResult{}, err
+	}
+	return LockResult{Lock: lock}, nil
+}
+
+// Unlock usecase
+// Releases the lock of a note before it expires, only its holder may
+type UnlockCommand struct {
+	storage storage.Storage
+	shares  Shares
+	locks   locks
+	clock   Clock
+}
+type UnlockMessage struct {
+	Context
+	Id note.Id
+}
+type UnlockResult struct {
+	Lock   NoteLock
+	DryRun bool
+}
+
+func (u UnlockCommand) Execute(i UnlockMessage) (UnlockResult, error) {
+	n, _ := readShared(u.storage, u.shares, i.Context, i.Id)
+	if n.Id == 0 {
+		return UnlockResult{}, fmt.Errorf("note %d %w", i.Id, note.ErrNotFound)
+	}
+	now := u.clock.Now()
+	if i.DryRun {
+		held, ok := u.locks.get(n.Id, now)
+		if !ok {
+			return UnlockResult{}, fmt.Errorf("lock of note %d %w", n.Id, note.ErrNotFound)
+		}
+		if held.Holder != i.Actor {
+			return UnlockResult{}, locked(held)
+		}
+		return UnlockResult{Lock: held, DryRun: true}, nil
+	}
+	held, err := u.locks.release(n.Id, i.Actor, now)
+	if err != nil {
+		return UnlockResult{}, err
+	}
+	return UnlockResult{Lock: held}, nil
+}
+
+// Locked usecase
+// Tells whether a note is being edited, and by whom, to whoever may read
+// it
+type LockedCommand struct {
+	storage storage.Storage
+	shares  Shares
+	locks   locks
+	clock   Clock
+}
+type LockedMessage struct {
+	Context
+	Id note.Id
+}
+type LockedResult struct {
+	Locked bool
+	// Lock is the zero lock when the note is not locked
+	Lock NoteLock
+}
+
+func (u LockedCommand) Execute(i LockedMessage) (LockedResult, error) {
+	n, _ := readShared(u.storage, u.shares, i.Context, i.Id)
+	if n.Id == 0 {
+		return LockedResult{}, fmt.Errorf("note %d %w", i.Id, note.ErrNotFound)
+	}
+	lock, ok := u.locks.get(n.Id, u.clock.Now())
+	return LockedResult{Locked: ok, Lock: lock}, nil
+}
@@ -0,0 +1,15 @@
+package usecase
+
+import "notes/internal/note"
+
+// Mailer sends a note by email, how the note is rendered is up to it
+type Mailer interface {
+	Send(to string, n note.Note) error
+}
+
+// MailerFunc lets a plain function be a mailer
+type MailerFunc func(to string, n note.Note) error
+
+func (f MailerFunc) Send(to string, n note.Note) error {
+	return f(to, n)
+}
@@ -0,0 +1,11 @@
+package usecase
+
+import "notes/internal/note"
+
+// Publisher publishes a note outside the application, publishing it
+// again updates it
+// Public tells whether anybody may find the note, or only those who
+// are given its url.
+type Publisher interface {
+	Publish(n note.Note, public bool) (url string, err error)
+}
@@ -0,0 +1,98 @@
+package usecase
+
+import (
+	"fmt"
+	"slices"
+	"sync/atomic"
+
+	"notes/internal/note"
+)
+
+// WriteQueue runs the commands changing the notes of a file one at a
+// time, in the order they come, so they wait in a bounded queue rather
+// than piling up on the lock of the file
+// A command coming while the queue is full is refused with
+// note.ErrBusy, its client may send it again later.
+type WriteQueue struct {
+	jobs    chan writeJob
+	refused *atomic.Int64
+}
+
+type writeJob struct {
+	run  func() (any, error)
+	done chan writeDone
+}
+
+// writeDone is the outcome of a command, a panic of the command is
+// raised again by its caller rather than by the queue
+type writeDone struct {
+	result any
+	err    error
+	panic  any
+}
+
+// WriteQueueStats tells how full the queue is and how many commands it
+// refused
+type WriteQueueStats struct {
+	Queued  int
+	Size    int
+	Refused int64
+}
+
+// NewWriteQueue keeps up to size commands waiting, the queue runs as
+// long as the process
+func NewWriteQueue(size int) WriteQueue {
+	q := WriteQueue{jobs: make(chan writeJob, size), refused: &atomic.Int64{}}
+	go func() {
+		for job := range q.jobs {
+			job.done <- job.execute()
+		}
+	}()
+	return q
+}
+
+func (j writeJob) execute() (done writeDone) {
+	defer func() {
+		done.panic = recover()
+	}()
+	done.result, done.err = j.run()
+	return done
+}
+
+func (q WriteQueue) Stats() WriteQueueStats {
+	return WriteQueueStats{Queued: len(q.jobs), Size: cap(q.jobs), Refused: q.refused.Load()}
+}
+
+// queued tells whether a command goes through the queue, the ones
+// changing notes and saving them do
+func queued(name string) bool {
+	return name == "save" || !slices.Contains(readCommands, name)
+}
+
+// Queueing runs the commands which change the notes or write them to a
+// file through a queue, the reads and the dry runs go straight to the
+// storage, where they may run concurrently
+func Queueing(q WriteQueue) Decorator {
+	return func(name string, next Execute) Execute {
+		return func(message any) (any, error) {
+			if !queued(actionOf(name, message)) {
+				return next(message)
+			}
+			if m, ok := message.(interface{ context() Context }); ok && m.context().DryRun {
+				return next(message)
+			}
+			job := writeJob{run: func() (any, error) { return next(message) }, done: make(chan writeDone, 1)}
+			select {
+			case q.jobs <- job:
+			default:
+				q.refused.Add(1)
+				return nil, fmt.Errorf("%s %w: %d writes are waiting, try again later", name, note.ErrBusy, cap(q.jobs))
+			}
+			done := <-job.done
+			if done.panic != nil {
+				panic(done.panic)
+			}
+			return done.result, done.err
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package usecase
+
+import (
+	"fmt"
+
+	"notes/internal/note"
+	"notes/internal/storage"
+)
+
+// Quota limits the notes of each user, a zero limit is no limit
+type Quota struct {
+	// Notes is how many notes a user may keep
+	Notes int
+	// Bytes is the total size of the contents of the notes of a user
+	Bytes int
+}
+
+// Usage is what the notes of a user take up
+type Usage struct {
+	Notes int
+	Bytes int
+}
+
+func usageOf(s storage.Storage, owner note.UserId) Usage {
+	u := Usage{}
+	for n := range s.Each(storage.Filter{Owner: owner}) {
+		u.Notes++
+		u.Bytes += len(n.Content)
+	}
+	return u
+}
+
+// check refuses to add notes and bytes to a usage beyond the quota, a
+// change freeing space always passes
+func (q Quota) check(u Usage, notes int, bytes int) error {
+	if q.Notes > 0 && notes > 0 && u.Notes+notes > q.Notes {
+		return fmt.Errorf("%w: the quota of %d notes is reached", note.ErrForbidden, q.Notes)
+	}
+	if q.Bytes > 0 && bytes > 0 && u.Bytes+bytes > q.Bytes {
+		return fmt.Errorf("%w: %d more bytes would exceed the quota of %d bytes, %d are used", note.ErrTooLarge, bytes, q.Bytes, u.Bytes)
+	}
+	return nil
+}
+
+// Limiting refuses the commands adding notes or content beyond the
+// quota of the owner of the notes, who is charged for the changes of
+// those they share their notes with
+func Limiting(q Quota, s storage.Storage) Decorator {
+	return func(name string, next Execute) Execute {
+		if q == (Quota{}) {
+			return next
+		}
+		return func(message any) (any, error) {
+			owner, notes, bytes := note.UserId(0), 0, 0
+			switch m := message.(type) {
+			case CreateMessage:
+				owner, notes, bytes = m.User, 1, len(m.Content)
+			case QuickMessage:
+				owner, notes, bytes = m.User, 1, len(m.Content)
+			case UpdateMessage:
+				n := s.Read(m.Id)
+				if n.Id == 0 || m.Content == "" {
+					return next(message)
+				}
+				owner, bytes = n.Owner, len(m.Content)-len(n.Content)
+			case RestoreMessage:
+				previous := s.Read(m.Note.Id)
+				if previous.Id == 0 {
+					notes = 1
+				}
+				owner, bytes = m.Note.Owner, len(m.Note.Content)-len(previous.Content)
+			default:
+				return next(message)
+			}
+			err := q.check(usageOf(s, owner), notes, bytes)
+			if err != nil {
+				return nil, err
+			}
+			return next(message)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package usecase
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	"notes/internal/audit"
+	"notes/internal/note"
+	"notes/internal/storage"
+)
+
+// Kinds of the recent notes, those read and those changed
+const (
+	RecentViewed = "viewed"
+	RecentEdited = "edited"
+)
+
+// recentLimit is the number of recent notes listed by default
+const recentLimit = 10
+
+// RecentNote is a note and when it was last viewed or changed
+type RecentNote struct {
+	Note note.Note
+	At   time.Time
+}
+
+// Recent usecase
+// Lists the notes last viewed or changed, the latest first, from the
+// audit log, there are none without one
+// The notes are those touched by the account of the user, or by anybody
+// without accounts. The notes deleted since are left out.
+type RecentCommand struct {
+	storage storage.Storage
+	shares  Shares
+	log     audit.Store
+}
+type RecentMessage struct {
+	Context
+	// Kind is RecentViewed or RecentEdited, the default
+	Kind string
+	// Limit is the number of notes, 10 when 0
+	Limit int
+}
+type RecentResult struct {
+	Notes []RecentNote
+}
+
+func (i RecentMessage) validate() error {
+	if i.Kind != "" && i.Kind != RecentViewed && i.Kind != RecentEdited {
+		return fmt.Errorf("%w kind: %q is neither %s nor %s", note.ErrValidation, i.Kind, RecentViewed, RecentEdited)
+	}
+	if i.Limit < 0 {
+		return fmt.Errorf("%w limit: %d is negative", note.ErrValidation, i.Limit)
+	}
+	return nil
+}
+
+func (u RecentCommand) Execute(i RecentMessage) (RecentResult, error) {
+	recent := []RecentNote{}
+	if u.log == nil {
+		return RecentResult{Notes: recent}, nil
+	}
+	viewed := i.Kind == RecentViewed
+	q := audit.Query{Views: viewed}
+	if i.User != 0 {
+		q.Actor = i.Actor
+	}
+	entries, err := u.log.Query(q)
+	if err != nil {
+		return RecentResult{}, fmt.Errorf("recent: %w", err)
+	}
+	limit := cmp.Or(i.Limit, recentLimit)
+	seen := map[note.Id]bool{}
+	// the entries are the oldest first
+	for _, e := range slices.Backward(entries) {
+		if len(recent) == limit {
+			break
+		}
+		if seen[e.NoteId] || (e.Kind == note.Viewed) != viewed {
+			continue
+		}
+		seen[e.NoteId] = true
+		n, _ := readShared(u.storage, u.shares, i.Context, e.NoteId)
+		if n.Id != 0 {
+			recent = append(recent, RecentNote{Note: n, At: e.At})
+		}
+	}
+	return RecentResult{Notes: recent}, nil
+}
@@ -0,0 +1,34 @@
+package usecase
+
+import (
+	"notes/internal/note"
+	"notes/internal/query"
+)
+
+// Searcher finds the notes matching a query, the best match first, and
+// scores them by relevance
+// A query the searcher can't parse should fail with note.ErrValidation.
+type Searcher interface {
+	Search(query string) ([]query.Hit, error)
+}
+
+// Highlighter is a searcher telling where the words of a query match a
+// note, as its index matches them, with typos or stems
+// Without it only the words as typed are highlighted.
+type Highlighter interface {
+	Highlight(query string, n note.Note) []query.Span
+}
+
+// SimilarFinder is a searcher finding the notes which share the most
+// words with a note, the most similar first
+type SimilarFinder interface {
+	Similar(id note.Id, limit int) ([]query.Hit, error)
+}
+
+// SavedSearches keeps the queries of the smart notebooks by name
+type SavedSearches interface {
+	Save(name string, query string) error
+	// Delete tells whether there was a query to delete
+	Delete(name string) (bool, error)
+	All() (map[string]string, error)
+}
@@ -0,0 +1,16 @@
+package usecase
+
+import (
+	"notes/internal/note"
+	"notes/internal/share"
+)
+
+// Shares keeps the accesses the users grant to each other on their
+// notes, see share.Store
+// Revoking a missing share should fail with note.ErrNotFound.
+type Shares interface {
+	Grant(s share.Share) (share.Share, error)
+	Revoke(id share.Id) (share.Share, error)
+	Received(grantee note.UserId) []share.Share
+	Given(owner note.UserId) []share.Share
+}
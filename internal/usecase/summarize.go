@@ -0,0 +1,112 @@
+package usecase
+
+import (
+	"fmt"
+	"strings"
+
+	"notes/internal/crypt"
+	"notes/internal/note"
+	"notes/internal/storage"
+)
+
+// SummaryKey is the key of the summary in the metadata of a note
+const SummaryKey = "summary"
+
+// the instructions of the language model
+const (
+	summaryInstructions = "Summarize the note the user gives in two or three sentences, in the language of the note. Answer with the summary only."
+	titleInstructions   = "Suggest a short title for the note the user gives, in the language of the note. Answer with the title only, without quotes."
+)
+
+// Summarize usecase
+// Asks the language model for a summary of a note, kept in its metadata
+type SummarizeCommand struct {
+	storage storage.Storage
+	shares  Shares
+	events  *EventBus
+	model   LanguageModel
+}
+type SummarizeMessage struct {
+	Context
+	Id note.Id
+}
+type SummarizeResult struct {
+	Note    note.Note
+	Summary string
+	DryRun  bool
+}
+
+func (u SummarizeCommand) Execute(i SummarizeMessage) (SummarizeResult, error) {
+	n, access := readShared(u.storage, u.shares, i.Context, i.Id)
+	if n.Id == 0 {
+		return SummarizeResult{}, fmt.Errorf("note %d %w", i.Id, note.ErrNotFound)
+	}
+	err := writable(n, access)
+	if err != nil {
+		return SummarizeResult{}, err
+	}
+	if u.model == nil {
+		return SummarizeResult{}, fmt.Errorf("summarize: no language model configured")
+	}
+	if crypt.IsSealed(n.Content) {
+		return SummarizeResult{}, fmt.Errorf("%w: note %d is encrypted, only its clients can read it", note.ErrValidation, i.Id)
+	}
+	if strings.TrimSpace(n.Content) == "" {
+		return SummarizeResult{}, fmt.Errorf("%w: note %d has no content to summarize", note.ErrValidation, i.Id)
+	}
+	summary, err := u.model.Complete(summaryInstructions, n.Name+"\n\n"+n.Content)
+	if err != nil {
+		return SummarizeResult{}, fmt.Errorf("summarize: %w", err)
+	}
+	summary = strings.TrimSpace(summary)
+	summarized := n
+	summarized.Metadata = n.Metadata.With(SummaryKey, summary)
+	if i.DryRun {
+		return SummarizeResult{Note: summarized, Summary: summary, DryRun: true}, nil
+	}
+	summarized = u.storage.Restore(summarized)
+	u.events.publish(i.Context, note.Updated, summarized, n)
+	return SummarizeResult{
+		Note:    summarized,
+		Summary: summary,
+	}, nil
+}
+
+// SuggestTitle usecase
+// Asks the language model for a title of a content, such as the one of
+// a note being written
+type SuggestTitleCommand struct {
+	model LanguageModel
+}
+type SuggestTitleMessage struct {
+	Context
+	Content note.Content
+}
+type SuggestTitleResult struct {
+	Title note.Name
+}
+
+func (i SuggestTitleMessage) validate() error {
+	if strings.TrimSpace(i.Content) == "" {
+		return fmt.Errorf("%w content: nothing to title", note.ErrValidation)
+	}
+	if crypt.IsSealed(i.Content) {
+		return fmt.Errorf("%w content: it is encrypted", note.ErrValidation)
+	}
+	return nil
+}
+
+func (u SuggestTitleCommand) Execute(i SuggestTitleMessage) (SuggestTitleResult, error) {
+	if u.model == nil {
+		return SuggestTitleResult{}, fmt.Errorf("suggest title: no language model configured")
+	}
+	title, err := u.model.Complete(titleInstructions, i.Content)
+	if err != nil {
+		return SuggestTitleResult{}, fmt.Errorf("suggest title: %w", err)
+	}
+	// the first line, without the quotes or the markup the model may add
+	title, _, _ = strings.Cut(strings.TrimSpace(title), "\n")
+	return SuggestTitleResult{
+		Title: strings.Trim(strings.TrimSpace(title), "\"'*#` "),
+	}, nil
+}
@@ -0,0 +1,66 @@
+package usecase
+
+import (
+	"maps"
+	"sync"
+	"time"
+
+	"notes/internal/audit"
+	"notes/internal/note"
+)
+
+// noteTimes keeps when the notes were created and last changed for the
+// searches, the audit log is read once, on the first search, the events
+// keep the times up to date afterwards
+type noteTimes struct {
+	log     audit.Store
+	mutex   sync.Mutex
+	loaded  bool
+	created map[note.Id]time.Time
+	updated map[note.Id]time.Time
+}
+
+func newNoteTimes(log audit.Store) *noteTimes {
+	return &noteTimes{log: log, created: map[note.Id]time.Time{}, updated: map[note.Id]time.Time{}}
+}
+
+// Notify records the time of a change, the changes made before the log
+// is read are found in the log
+func (t *noteTimes) Notify(e note.Event) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.loaded {
+		t.record(e.Note.Id, e.Kind, e.At)
+	}
+}
+
+func (t *noteTimes) record(id note.Id, kind note.EventKind, at time.Time) {
+	if kind == note.Viewed {
+		return
+	}
+	if _, ok := t.created[id]; !ok || kind == note.Created {
+		t.created[id] = at
+	}
+	t.updated[id] = at
+}
+
+// read copies when the notes were created and last changed, they are
+// unknown without an audit log
+func (t *noteTimes) read() (map[note.Id]time.Time, map[note.Id]time.Time, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.log == nil {
+		return map[note.Id]time.Time{}, map[note.Id]time.Time{}, nil
+	}
+	if !t.loaded {
+		entries, err := t.log.Query(audit.Query{})
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, e := range entries {
+			t.record(e.NoteId, e.Kind, e.At)
+		}
+		t.loaded = true
+	}
+	return maps.Clone(t.created), maps.Clone(t.updated), nil
+}
@@ -0,0 +1,67 @@
+package usecase
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"notes/internal/crypt"
+	"notes/internal/note"
+	"notes/internal/storage"
+)
+
+// embedDepth is how deep the embeds are followed, an embed deeper is
+// left as it is
+const embedDepth = 5
+
+// embed is a ![[Name]] of a content, which may have a heading or an
+// alias as its wiki link
+var embed = regexp.MustCompile(`!\[\[([^\[\]|#]+)(#[^\[\]|]*)?(\|[^\[\]]*)?\]\]`)
+
+// transcluded replaces the ![[embeds]] of the content of a note by the
+// contents of the notes they name, which the user of the context may
+// read, for the note to be rendered
+// The notes of the owner of the note come first when several have the
+// name. An embed of a note embedding it, of a note missing or sealed, or
+// deeper than embedDepth is left as it is.
+func transcluded(s storage.Storage, shares Shares, c Context, n note.Note) note.Note {
+	if crypt.IsSealed(n.Content) || !embed.MatchString(n.Content) {
+		return n
+	}
+	var byName map[string]note.Note
+	find := func(name string) (note.Note, bool) {
+		if byName == nil {
+			byName = map[string]note.Note{}
+			for m := range eachShared(s, shares, c, storage.Filter{}) {
+				key := strings.ToLower(strings.TrimSpace(m.Name))
+				if found, ok := byName[key]; ok && found.Owner == n.Owner {
+					continue
+				}
+				byName[key] = m
+			}
+		}
+		m, ok := byName[strings.ToLower(strings.TrimSpace(name))]
+		return m, ok
+	}
+	var expand func(content note.Content, embedding []note.Id) note.Content
+	expand = func(content note.Content, embedding []note.Id) note.Content {
+		lines := strings.Split(content, "\n")
+		for k, line := range lines {
+			lines[k] = embed.ReplaceAllStringFunc(line, func(link string) string {
+				m, ok := find(embed.FindStringSubmatch(link)[1])
+				if !ok || crypt.IsSealed(m.Content) || len(embedding) > embedDepth || slices.Contains(embedding, m.Id) {
+					return link
+				}
+				embedded := strings.Trim(expand(m.Content, append(embedding[:len(embedding):len(embedding)], m.Id)), "\n")
+				// an embed on its own line is a block of its own
+				if strings.TrimSpace(line) == link {
+					return "\n" + embedded + "\n"
+				}
+				return embedded
+			})
+		}
+		return strings.Join(lines, "\n")
+	}
+	n.Content = expand(n.Content, []note.Id{n.Id})
+	return n
+}
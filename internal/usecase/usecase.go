@@ -0,0 +1,205 @@
+// Package usecase holds what the application can do with notes. Each
+// usecase is a Command taking a message and returning a result, the
+// applications parse their input into messages and present the results.
+package usecase
+
+import (
+	"notes/internal/audit"
+	"notes/internal/expand"
+	"notes/internal/note"
+	"notes/internal/storage"
+)
+
+// Command executes one usecase
+type Command[Message, Result any] interface {
+	Execute(Message) (Result, error)
+}
+
+// Usecase gathers every command of the application
+type Usecase struct {
+	Read     Command[ReadMessage, ReadResult]
+	ReadAll  Command[ReadAllMessage, ReadAllResult]
+	List     Command[ListMessage, ListResult]
+	Count    Command[CountMessage, CountResult]
+	Exists   Command[ExistsMessage, ExistsResult]
+	Create   Command[CreateMessage, CreateResult]
+	Quick    Command[QuickMessage, QuickResult]
+	Today    Command[TodayMessage, TodayResult]
+	Update   Command[UpdateMessage, UpdateResult]
+	Rename   Command[RenameMessage, RenameResult]
+	Move     Command[MoveMessage, MoveResult]
+	Color    Command[ColorMessage, ColorResult]
+	Pin      Command[PinMessage, PinResult]
+	Lock     Command[LockMessage, LockResult]
+	Unlock   Command[UnlockMessage, UnlockResult]
+	Locked   Command[LockedMessage, LockedResult]
+	Delete   Command[DeleteMessage, DeleteResult]
+	Restore  Command[RestoreMessage, RestoreResult]
+	Save     Command[SaveMessage, SaveResult]
+	Status   Command[StatusMessage, StatusResult]
+	Audit    Command[AuditMessage, AuditResult]
+	Activity Command[ActivityMessage, ActivityResult]
+	Recent   Command[RecentMessage, RecentResult]
+	Email    Command[EmailMessage, EmailResult]
+	Publish  Command[PublishMessage, PublishResult]
+	Search   Command[SearchMessage, SearchResult]
+	Similar  Command[SimilarMessage, SimilarResult]
+
+	Notebooks    Command[NotebooksMessage, NotebooksResult]
+	SaveSearch   Command[SaveSearchMessage, SaveSearchResult]
+	DeleteSearch Command[DeleteSearchMessage, DeleteSearchResult]
+
+	Register     Command[RegisterMessage, RegisterResult]
+	Login        Command[LoginMessage, LoginResult]
+	Authenticate Command[AuthenticateMessage, AuthenticateResult]
+
+	Share        Command[ShareMessage, ShareResult]
+	Unshare      Command[UnshareMessage, UnshareResult]
+	Shares       Command[SharesMessage, SharesResult]
+	SharedWithMe Command[SharedWithMeMessage, SharedWithMeResult]
+
+	CreateApiToken Command[CreateApiTokenMessage, CreateApiTokenResult]
+	ApiTokens      Command[ApiTokensMessage, ApiTokensResult]
+	RevokeApiToken Command[RevokeApiTokenMessage, RevokeApiTokenResult]
+
+	EnrollTotp  Command[EnrollTotpMessage, EnrollTotpResult]
+	ConfirmTotp Command[ConfirmTotpMessage, ConfirmTotpResult]
+	DisableTotp Command[DisableTotpMessage, DisableTotpResult]
+
+	Usage     Command[UsageMessage, UsageResult]
+	Export    Command[ExportMessage, ExportResult]
+	Due       Command[DueMessage, DueResult]
+	Dashboard Command[DashboardMessage, DashboardResult]
+
+	Archive Command[ArchiveMessage, ArchiveResult]
+	Dedupe  Command[DedupeMessage, DedupeResult]
+	Merge   Command[MergeMessage, MergeResult]
+	Lint    Command[LintMessage, LintResult]
+	Diff    Command[DiffMessage, DiffResult]
+
+	Summarize    Command[SummarizeMessage, SummarizeResult]
+	SuggestTitle Command[SuggestTitleMessage, SuggestTitleResult]
+}
+
+// Deps are what the usecases are built on, only the storage is needed
+// Usecase only know the storage interface which could have
+// many implementations
+type Deps struct {
+	Storage storage.Storage
+	// Clock tells the time to the commands, it should be the clock of
+	// the event bus, the system clock when nil
+	Clock Clock
+	// Inbox is the notebook of the quick notes
+	Inbox note.Notebook
+	// Events is the bus the commands changing notes publish to, a new
+	// one when nil, the audit Log is where the changes recorded from
+	// these events are queried
+	Events *EventBus
+	Log    audit.Store
+	// Mailer sends notes by email, it may be nil when no mail server is
+	// configured, and so may the publisher, the searcher, the semantic
+	// searcher finding the notes by meaning, the saved searches of the
+	// smart notebooks, and the users, their tokens, the shares of their
+	// notes and their API tokens when the server hosts several people,
+	// the link checker following the web links of the notes, the
+	// language model summarizing them and the colors of the notebooks
+	Mailer    Mailer
+	Publisher Publisher
+	Searcher  Searcher
+	Semantic  Searcher
+	Saved     SavedSearches
+	Users     Users
+	Tokens    Tokens
+	Shares    Shares
+	ApiTokens ApiTokens
+	Links     LinkChecker
+	Model     LanguageModel
+	Colors    NotebookColors
+	// Quota limits the notes of each user, the zero quota doesn't, and
+	// the journal tells where the daily notes go and what they start
+	// with
+	Quota   Quota
+	Journal Journal
+	// Expander replaces the placeholders, such as {{date}}, of the notes
+	// created and of the journal template
+	Expander expand.Expander
+}
+
+// New builds the usecases on top of a storage
+// Inversion of control happens here
+// The locks of the notes being edited are kept in memory
+// Every command goes through the decorators, then the scope of the
+// context is checked, the message validated and the quota checked last
+func New(d Deps, decorators ...Decorator) Usecase {
+	if d.Clock == nil {
+		d.Clock = SystemClock{}
+	}
+	if d.Events == nil {
+		d.Events = NewEventBus(d.Clock)
+	}
+	s, clock, inbox, events, log := d.Storage, d.Clock, d.Inbox, d.Events, d.Log
+	mailer, publisher, searcher, semantic, saved := d.Mailer, d.Publisher, d.Searcher, d.Semantic, d.Saved
+	users, tokens, shares, apiTokens := d.Users, d.Tokens, d.Shares, d.ApiTokens
+	links, model, colors := d.Links, d.Model, d.Colors
+	quota, journal, expander := d.Quota, d.Journal, d.Expander
+	decorators = append(decorators[:len(decorators):len(decorators)], Scoping, Validating, Limiting(quota, s))
+	locks := newLocks()
+	times := newNoteTimes(log)
+	events.Subscribe(times)
+	return Usecase{
+		decorate("read", Command[ReadMessage, ReadResult](ReadCommand{s, shares, log, clock}), decorators),
+		decorate("readAll", Command[ReadAllMessage, ReadAllResult](ReadAllCommand{s, colors}), decorators),
+		decorate("list", Command[ListMessage, ListResult](ListCommand{s, colors}), decorators),
+		decorate("count", Command[CountMessage, CountResult](CountCommand{s}), decorators),
+		decorate("exists", Command[ExistsMessage, ExistsResult](ExistsCommand{s, shares}), decorators),
+		decorate("create", Command[CreateMessage, CreateResult](CreateCommand{s, events, expander}), decorators),
+		decorate("quick", Command[QuickMessage, QuickResult](QuickCommand{s, events, inbox, clock, expander}), decorators),
+		decorate("today", Command[TodayMessage, TodayResult](TodayCommand{s, events, journal, clock, quota, expander}), decorators),
+		decorate("update", Command[UpdateMessage, UpdateResult](UpdateCommand{s, shares, events}), decorators),
+		decorate("rename", Command[RenameMessage, RenameResult](RenameCommand{s, shares, events}), decorators),
+		decorate("move", Command[MoveMessage, MoveResult](MoveCommand{s, shares, events}), decorators),
+		decorate("color", Command[ColorMessage, ColorResult](ColorCommand{s, shares, events, colors}), decorators),
+		decorate("pin", Command[PinMessage, PinResult](PinCommand{s, shares, events}), decorators),
+		decorate("lock", Command[LockMessage, LockResult](LockCommand{s, shares, locks, clock}), decorators),
+		decorate("unlock", Command[UnlockMessage, UnlockResult](UnlockCommand{s, shares, locks, clock}), decorators),
+		decorate("locked", Command[LockedMessage, LockedResult](LockedCommand{s, shares, locks, clock}), decorators),
+		decorate("delete", Command[DeleteMessage, DeleteResult](DeleteCommand{s, shares, events}), decorators),
+		decorate("restore", Command[RestoreMessage, RestoreResult](RestoreCommand{s, shares, events}), decorators),
+		decorate("save", Command[SaveMessage, SaveResult](SaveCommand{s}), decorators),
+		decorate("status", Command[StatusMessage, StatusResult](StatusCommand{s}), decorators),
+		decorate("audit", Command[AuditMessage, AuditResult](AuditCommand{s, shares, log}), decorators),
+		decorate("activity", Command[ActivityMessage, ActivityResult](ActivityCommand{s, shares, log}), decorators),
+		decorate("recent", Command[RecentMessage, RecentResult](RecentCommand{s, shares, log}), decorators),
+		decorate("email", Command[EmailMessage, EmailResult](EmailCommand{s, shares, mailer}), decorators),
+		decorate("publish", Command[PublishMessage, PublishResult](PublishCommand{s, shares, publisher}), decorators),
+		decorate("search", Command[SearchMessage, SearchResult](SearchCommand{s, shares, clock, searcher, semantic, times}), decorators),
+		decorate("similar", Command[SimilarMessage, SimilarResult](SimilarCommand{s, shares, searcher}), decorators),
+		decorate("notebooks", Command[NotebooksMessage, NotebooksResult](NotebooksCommand{s, SearchCommand{s, shares, clock, searcher, semantic, times}, saved, colors}), decorators),
+		decorate("saveSearch", Command[SaveSearchMessage, SaveSearchResult](SaveSearchCommand{s, saved}), decorators),
+		decorate("deleteSearch", Command[DeleteSearchMessage, DeleteSearchResult](DeleteSearchCommand{saved}), decorators),
+		decorate("register", Command[RegisterMessage, RegisterResult](RegisterCommand{users, clock}), decorators),
+		decorate("login", Command[LoginMessage, LoginResult](LoginCommand{users, tokens, clock}), decorators),
+		decorate("authenticate", Command[AuthenticateMessage, AuthenticateResult](AuthenticateCommand{users, tokens, apiTokens}), decorators),
+		decorate("share", Command[ShareMessage, ShareResult](ShareCommand{s, shares, users, clock}), decorators),
+		decorate("unshare", Command[UnshareMessage, UnshareResult](UnshareCommand{shares}), decorators),
+		decorate("shares", Command[SharesMessage, SharesResult](SharesCommand{shares}), decorators),
+		decorate("sharedWithMe", Command[SharedWithMeMessage, SharedWithMeResult](SharedWithMeCommand{s, shares, users}), decorators),
+		decorate("createApiToken", Command[CreateApiTokenMessage, CreateApiTokenResult](CreateApiTokenCommand{apiTokens, clock}), decorators),
+		decorate("apiTokens", Command[ApiTokensMessage, ApiTokensResult](ApiTokensCommand{apiTokens}), decorators),
+		decorate("revokeApiToken", Command[RevokeApiTokenMessage, RevokeApiTokenResult](RevokeApiTokenCommand{apiTokens}), decorators),
+		decorate("enrollTotp", Command[EnrollTotpMessage, EnrollTotpResult](EnrollTotpCommand{users}), decorators),
+		decorate("confirmTotp", Command[ConfirmTotpMessage, ConfirmTotpResult](ConfirmTotpCommand{users, clock}), decorators),
+		decorate("disableTotp", Command[DisableTotpMessage, DisableTotpResult](DisableTotpCommand{users, clock}), decorators),
+		decorate("usage", Command[UsageMessage, UsageResult](UsageCommand{s, quota}), decorators),
+		decorate("export", Command[ExportMessage, ExportResult](ExportCommand{s, shares, SearchCommand{s, shares, clock, searcher, semantic, times}}), decorators),
+		decorate("due", Command[DueMessage, DueResult](DueCommand{s}), decorators),
+		decorate("dashboard", Command[DashboardMessage, DashboardResult](DashboardCommand{s, clock, DueCommand{s}, RecentCommand{s, shares, log}}), decorators),
+		decorate("archive", Command[ArchiveMessage, ArchiveResult](ArchiveCommand{s, events, log, clock}), decorators),
+		decorate("dedupe", Command[DedupeMessage, DedupeResult](DedupeCommand{s}), decorators),
+		decorate("merge", Command[MergeMessage, MergeResult](MergeCommand{s, shares, events}), decorators),
+		decorate("lint", Command[LintMessage, LintResult](LintCommand{s, events, links}), decorators),
+		decorate("diff", Command[DiffMessage, DiffResult](DiffCommand{s, shares, log, clock}), decorators),
+		decorate("summarize", Command[SummarizeMessage, SummarizeResult](SummarizeCommand{s, shares, events, model}), decorators),
+		decorate("suggestTitle", Command[SuggestTitleMessage, SuggestTitleResult](SuggestTitleCommand{model}), decorators),
+	}
+}
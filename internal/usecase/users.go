@@ -0,0 +1,39 @@
+package usecase
+
+import (
+	"time"
+
+	"notes/internal/user"
+)
+
+// Users keeps the accounts of the people sharing the server, see
+// user.Store
+// Creating a user whose name is taken should fail with
+// note.ErrConflict, reading a missing one returns the zero user and
+// updating it should fail with note.ErrNotFound.
+type Users interface {
+	Create(name string, passwordHash string, created time.Time) (user.User, error)
+	Read(id user.Id) user.User
+	ByName(name string) user.User
+	Update(u user.User) error
+}
+
+// Tokens issues the tokens proving who sends a request and tells whose
+// they are, see user.Tokens
+// A token which can't be verified should fail with
+// note.ErrUnauthorized.
+type Tokens interface {
+	Issue(u user.User) (string, error)
+	Verify(token string) (user.Id, error)
+}
+
+// ApiTokens keeps the personal tokens the users give to their scripts,
+// see user.ApiTokens
+// Verifying an unknown token returns the zero token, revoking a token
+// of another user should fail with note.ErrNotFound.
+type ApiTokens interface {
+	Create(owner user.Id, name string, scope user.Scope, created time.Time) (string, user.ApiToken, error)
+	Verify(secret string) user.ApiToken
+	List(owner user.Id) []user.ApiToken
+	Revoke(owner user.Id, id int) (user.ApiToken, error)
+}
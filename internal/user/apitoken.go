@@ -0,0 +1,193 @@
+package user
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"notes/internal/note"
+)
+
+// Scope is what an API token lets a script do
+type Scope string
+
+const (
+	// ReadOnly tokens only run the commands which don't change notes
+	ReadOnly  Scope = "read"
+	ReadWrite Scope = "write"
+)
+
+func (s Scope) Valid() bool {
+	return s == ReadOnly || s == ReadWrite
+}
+
+// apiTokenPrefix tells the API tokens from the tokens of /login
+const apiTokenPrefix = "notes_"
+
+// IsApiToken tells whether a token is an API token
+func IsApiToken(token string) bool {
+	return strings.HasPrefix(token, apiTokenPrefix)
+}
+
+// ApiToken is a personal token a user gives to their scripts, it stays
+// valid until revoked
+// Only the hash of the token is kept, the token itself is shown once
+// when created.
+type ApiToken struct {
+	Id      int
+	User    Id
+	Name    string
+	Scope   Scope
+	Hash    string `json:"-"`
+	Created time.Time
+}
+
+// jsonApiToken keeps the hash, which ApiToken leaves out of json
+type jsonApiToken struct {
+	Id      int       `json:"id"`
+	User    Id        `json:"user"`
+	Name    string    `json:"name"`
+	Scope   Scope     `json:"scope"`
+	Hash    string    `json:"hash"`
+	Created time.Time `json:"created"`
+}
+
+// ApiTokens keeps the API tokens, in a json file when it has a path and
+// in memory otherwise
+type ApiTokens struct {
+	path   string
+	mutex  *sync.RWMutex
+	tokens *[]ApiToken
+}
+
+// NewApiTokens loads the API tokens kept in path, a missing file has
+// none
+func NewApiTokens(path string) (ApiTokens, error) {
+	t := ApiTokens{path: path, mutex: &sync.RWMutex{}, tokens: &[]ApiToken{}}
+	if path == "" {
+		return t, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return t, err
+	}
+	tokens := []jsonApiToken{}
+	err = json.Unmarshal(data, &tokens)
+	if err != nil {
+		return t, fmt.Errorf("api tokens %s: %w", path, err)
+	}
+	for _, token := range tokens {
+		*t.tokens = append(*t.tokens, ApiToken(token))
+	}
+	return t, nil
+}
+
+// Create makes a new token of a user and returns it along with what is
+// kept of it, the names of the tokens of a user are unique
+func (t ApiTokens) Create(user Id, name string, scope Scope, created time.Time) (string, ApiToken, error) {
+	random, err := RandomToken()
+	if err != nil {
+		return "", ApiToken{}, err
+	}
+	secret := apiTokenPrefix + random
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	last := 0
+	for _, existing := range *t.tokens {
+		if existing.User == user && existing.Name == name {
+			return "", ApiToken{}, fmt.Errorf("token %s %w: the name is taken", name, note.ErrConflict)
+		}
+		last = max(last, existing.Id)
+	}
+	token := ApiToken{Id: last + 1, User: user, Name: name, Scope: scope, Hash: hashToken(secret), Created: created}
+	*t.tokens = append(*t.tokens, token)
+	err = t.write()
+	if err != nil {
+		*t.tokens = (*t.tokens)[:len(*t.tokens)-1]
+		return "", ApiToken{}, err
+	}
+	return secret, token, nil
+}
+
+// Verify returns the token kept for secret, the zero token when it was
+// never created or was revoked
+func (t ApiTokens) Verify(secret string) ApiToken {
+	hash := hashToken(secret)
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	for _, token := range *t.tokens {
+		if token.Hash == hash {
+			return token
+		}
+	}
+	return ApiToken{}
+}
+
+// List returns the tokens of a user
+func (t ApiTokens) List(user Id) []ApiToken {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	tokens := []ApiToken{}
+	for _, token := range *t.tokens {
+		if token.User == user {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// Revoke deletes a token of a user, the tokens of others are missing
+// and fail with note.ErrNotFound
+func (t ApiTokens) Revoke(user Id, id int) (ApiToken, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	i := slices.IndexFunc(*t.tokens, func(token ApiToken) bool { return token.User == user && token.Id == id })
+	if i < 0 {
+		return ApiToken{}, fmt.Errorf("token %d %w", id, note.ErrNotFound)
+	}
+	previous := slices.Clone(*t.tokens)
+	revoked := (*t.tokens)[i]
+	*t.tokens = slices.Delete(*t.tokens, i, i+1)
+	err := t.write()
+	if err != nil {
+		*t.tokens = previous
+		return ApiToken{}, err
+	}
+	return revoked, nil
+}
+
+func (t ApiTokens) write() error {
+	if t.path == "" {
+		return nil
+	}
+	tokens := []jsonApiToken{}
+	for _, token := range *t.tokens {
+		tokens = append(tokens, jsonApiToken(token))
+	}
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	err = os.WriteFile(tmp, data, 0o600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.path)
+}
+
+// hashToken is a plain sha256, the tokens are random and long enough
+// not to need a slow hash like the passwords
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,63 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"notes/internal/crypt"
+)
+
+// iterations of PBKDF2, as recommended by OWASP for HMAC-SHA256, the
+// hashes keep their number of iterations so it may be raised later
+const iterations = 600_000
+
+// HashPassword hashes a password with PBKDF2-HMAC-SHA256 and a random
+// salt, as pbkdf2-sha256$iterations$salt$key
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+	key := crypt.Pbkdf2([]byte(password), salt, iterations)
+	encoding := base64.RawStdEncoding
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", iterations, encoding.EncodeToString(salt), encoding.EncodeToString(key)), nil
+}
+
+// CheckPassword tells whether password is the one hash was made of
+func CheckPassword(hash string, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	n, err := strconv.Atoi(parts[1])
+	if err != nil || n <= 0 {
+		return false
+	}
+	encoding := base64.RawStdEncoding
+	salt, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	key, err := encoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(crypt.Pbkdf2([]byte(password), salt, n), key) == 1
+}
+
+// Secret is a password or a token, it is masked when printed so logging
+// the messages carrying it doesn't leak it
+type Secret string
+
+func (s Secret) String() string {
+	return "***"
+}
+
+func (s Secret) GoString() string {
+	return `"***"`
+}
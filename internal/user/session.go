@@ -0,0 +1,128 @@
+package user
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Session of a browser, the browser only knows its id, in a cookie
+type Session struct {
+	User Id
+	Name string
+	// CSRF is the token the forms of the session post back, proving the
+	// request comes from a page of the server
+	CSRF    string
+	Expires time.Time
+}
+
+// Sessions keeps the sessions of the browsers, in a json file when it
+// has a path and in memory otherwise
+// Only the hashes of the session ids are kept, so reading the file
+// doesn't give away the sessions.
+type Sessions struct {
+	path     string
+	ttl      time.Duration
+	mutex    *sync.Mutex
+	sessions map[string]Session
+	now      func() time.Time
+}
+
+// NewSessions loads the sessions kept in path, they expire ttl after
+// they started
+func NewSessions(path string, ttl time.Duration) (Sessions, error) {
+	s := Sessions{path: path, ttl: ttl, mutex: &sync.Mutex{}, sessions: map[string]Session{}, now: time.Now}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	err = json.Unmarshal(data, &s.sessions)
+	if err != nil {
+		return s, fmt.Errorf("sessions %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Create starts the session of a user and returns its id, the expired
+// sessions are dropped meanwhile
+func (s Sessions) Create(u User) (string, Session, error) {
+	id, err := RandomToken()
+	if err != nil {
+		return "", Session{}, err
+	}
+	csrf, err := RandomToken()
+	if err != nil {
+		return "", Session{}, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.now()
+	for key, session := range s.sessions {
+		if !now.Before(session.Expires) {
+			delete(s.sessions, key)
+		}
+	}
+	session := Session{User: u.Id, Name: u.Name, CSRF: csrf, Expires: now.Add(s.ttl)}
+	s.sessions[hashToken(id)] = session
+	return id, session, s.write()
+}
+
+// Read returns the session of an id, the zero session when there is
+// none or it expired
+func (s Sessions) Read(id string) Session {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	session := s.sessions[hashToken(id)]
+	if !s.now().Before(session.Expires) {
+		return Session{}
+	}
+	return session
+}
+
+// Delete ends a session, ending a missing one does nothing
+func (s Sessions) Delete(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := hashToken(id)
+	if _, ok := s.sessions[key]; !ok {
+		return nil
+	}
+	delete(s.sessions, key)
+	return s.write()
+}
+
+func (s Sessions) write() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(s.sessions)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	err = os.WriteFile(tmp, data, 0o600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// RandomToken returns 32 random bytes encoded for urls and cookies
+func RandomToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,138 @@
+package user
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"notes/internal/note"
+)
+
+// Store keeps the users, in a json file when it has a path and in memory
+// otherwise
+type Store struct {
+	path  string
+	mutex *sync.RWMutex
+	users map[Id]User
+}
+
+// jsonUser keeps the hash of the password and the second factor, which
+// User leaves out of json
+type jsonUser struct {
+	Id           Id        `json:"id"`
+	Name         string    `json:"name"`
+	PasswordHash string    `json:"passwordHash"`
+	Created      time.Time `json:"created"`
+	Totp         Totp      `json:"totp"`
+}
+
+// NewStore loads the users kept in path, a missing file has none
+func NewStore(path string) (Store, error) {
+	s := Store{path: path, mutex: &sync.RWMutex{}, users: map[Id]User{}}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	users := []jsonUser{}
+	err = json.Unmarshal(data, &users)
+	if err != nil {
+		return s, fmt.Errorf("users %s: %w", path, err)
+	}
+	for _, u := range users {
+		s.users[u.Id] = User(u)
+	}
+	return s, nil
+}
+
+// Create adds a user under the next id, names are unique regardless of
+// case
+func (s Store) Create(name string, passwordHash string, created time.Time) (User, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	last := Id(0)
+	for id, u := range s.users {
+		if strings.EqualFold(u.Name, name) {
+			return User{}, fmt.Errorf("user %s %w: the name is taken", name, note.ErrConflict)
+		}
+		last = max(last, id)
+	}
+	u := User{Id: last + 1, Name: name, PasswordHash: passwordHash, Created: created}
+	s.users[u.Id] = u
+	err := s.write()
+	if err != nil {
+		delete(s.users, u.Id)
+		return User{}, err
+	}
+	return u, nil
+}
+
+// Update replaces a user, its name stays the same
+func (s Store) Update(u User) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	previous, ok := s.users[u.Id]
+	if !ok {
+		return fmt.Errorf("user %d %w", u.Id, note.ErrNotFound)
+	}
+	u.Name = previous.Name
+	s.users[u.Id] = u
+	err := s.write()
+	if err != nil {
+		s.users[u.Id] = previous
+		return err
+	}
+	return nil
+}
+
+// Read returns the user of an id, the zero user when there is none
+func (s Store) Read(id Id) User {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.users[id]
+}
+
+// ByName returns the user of a name regardless of case, the zero user
+// when there is none
+func (s Store) ByName(name string) User {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, u := range s.users {
+		if strings.EqualFold(u.Name, name) {
+			return u
+		}
+	}
+	return User{}
+}
+
+func (s Store) write() error {
+	if s.path == "" {
+		return nil
+	}
+	users := []jsonUser{}
+	for id := Id(1); len(users) < len(s.users); id++ {
+		if u, ok := s.users[id]; ok {
+			users = append(users, jsonUser(u))
+		}
+	}
+	data, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	// the file holds the password hashes and the totp secrets, only its
+	// owner may read it
+	err = os.WriteFile(tmp, data, 0o600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,84 @@
+package user
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"notes/internal/note"
+)
+
+// Tokens issues and verifies the tokens of the users, JSON Web Tokens
+// signed with HMAC-SHA256, whose subject is the id of the user
+type Tokens struct {
+	secret []byte
+	// ttl is how long a token is valid
+	ttl time.Duration
+	now func() time.Time
+}
+
+// NewTokens signs the tokens with secret, they expire after ttl
+func NewTokens(secret []byte, ttl time.Duration) Tokens {
+	return Tokens{secret: secret, ttl: ttl, now: time.Now}
+}
+
+type claims struct {
+	Subject string `json:"sub"`
+	Name    string `json:"name"`
+	Issued  int64  `json:"iat"`
+	Expires int64  `json:"exp"`
+}
+
+// header of every token, only HS256 is accepted
+var header = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Issue returns a token of a user
+func (t Tokens) Issue(u User) (string, error) {
+	now := t.now()
+	payload, err := json.Marshal(claims{Subject: strconv.Itoa(u.Id), Name: u.Name, Issued: now.Unix(), Expires: now.Add(t.ttl).Unix()})
+	if err != nil {
+		return "", err
+	}
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + t.sign(signed), nil
+}
+
+// Verify returns the id of the user of a token, a token which is
+// malformed, not signed by t or expired fails with note.ErrUnauthorized
+func (t Tokens) Verify(token string) (Id, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != header {
+		return 0, fmt.Errorf("%w: malformed token", note.ErrUnauthorized)
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(t.sign(parts[0]+"."+parts[1]))) {
+		return 0, fmt.Errorf("%w: invalid token", note.ErrUnauthorized)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("%w: malformed token", note.ErrUnauthorized)
+	}
+	c := claims{}
+	err = json.Unmarshal(payload, &c)
+	if err != nil {
+		return 0, fmt.Errorf("%w: malformed token", note.ErrUnauthorized)
+	}
+	if t.now().Unix() >= c.Expires {
+		return 0, fmt.Errorf("%w: expired token", note.ErrUnauthorized)
+	}
+	id, err := strconv.Atoi(c.Subject)
+	if err != nil {
+		return 0, fmt.Errorf("%w: malformed token", note.ErrUnauthorized)
+	}
+	return id, nil
+}
+
+func (t Tokens) sign(s string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(s))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
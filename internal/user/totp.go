@@ -0,0 +1,126 @@
+package user
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// totpPeriod is how long a code lasts, the codes of the periods
+	// right before and after are accepted too, for clocks drifting apart
+	totpPeriod = 30
+	totpDigits = 6
+	// recoveryCodes is how many recovery codes an account gets
+	recoveryCodes = 10
+)
+
+// Totp is the second factor of an account: the codes of an
+// authenticator app, RFC 6238, and recovery codes for when the app is
+// lost
+type Totp struct {
+	// Secret is shared with the app, base32 encoded, it is set from the
+	// enrollment
+	Secret string `json:"secret,omitempty"`
+	// Enabled once the user proved the app gives the right codes, logging
+	// in needs a code from then on
+	Enabled bool `json:"enabled,omitempty"`
+	// Step is the period of the last code accepted, a code is accepted
+	// once only
+	Step int64 `json:"step,omitempty"`
+	// Recovery are the hashes of the recovery codes not used yet
+	Recovery []string `json:"recovery,omitempty"`
+}
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTotp returns a second factor with a random secret, which isn't
+// enabled yet
+func NewTotp() (Totp, error) {
+	secret := make([]byte, 20)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return Totp{}, err
+	}
+	return Totp{Secret: base32NoPadding.EncodeToString(secret)}, nil
+}
+
+// Uri is the provisioning uri of the secret, authenticator apps read it
+// from a QR code
+func (t Totp) Uri(issuer string, account string) string {
+	query := url.Values{}
+	query.Set("secret", t.Secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+	return "otpauth://totp/" + url.PathEscape(issuer+":"+account) + "?" + query.Encode()
+}
+
+// Check tells whether a code of the app or a recovery code is right at
+// a time, it returns the second factor with the code used up
+func (t Totp) Check(code string, at time.Time) (Totp, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) == totpDigits {
+		key, err := base32NoPadding.DecodeString(t.Secret)
+		if err != nil {
+			return t, false
+		}
+		step := at.Unix() / totpPeriod
+		for s := step - 1; s <= step+1; s++ {
+			if s > t.Step && subtle.ConstantTimeCompare([]byte(hotp(key, s)), []byte(code)) == 1 {
+				t.Step = s
+				return t, true
+			}
+		}
+		return t, false
+	}
+	hash := hashToken(normalizeRecovery(code))
+	used := slices.Index(t.Recovery, hash)
+	if used < 0 {
+		return t, false
+	}
+	t.Recovery = slices.Delete(slices.Clone(t.Recovery), used, used+1)
+	return t, true
+}
+
+// hotp is the code of a counter, RFC 4226
+func hotp(key []byte, counter int64) string {
+	mac := hmac.New(sha1.New, key)
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(counter)))
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1_000_000)
+}
+
+// NewRecoveryCodes returns the recovery codes to give the user and the
+// hashes to keep, each code logs in once in place of the app
+func NewRecoveryCodes() ([]string, []string, error) {
+	codes, hashes := []string{}, []string{}
+	for range recoveryCodes {
+		b := make([]byte, 10)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(base32NoPadding.EncodeToString(b))
+		codes = append(codes, code[:4]+"-"+code[4:8]+"-"+code[8:12]+"-"+code[12:])
+		hashes = append(hashes, hashToken(code))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecovery lets the users type the recovery codes without
+// their dashes or in capitals
+func normalizeRecovery(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
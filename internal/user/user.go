@@ -0,0 +1,24 @@
+// Package user holds the accounts of the people sharing a server: the
+// user entity, how passwords are hashed, where users are kept and the
+// tokens proving who sends a request.
+package user
+
+import (
+	"time"
+
+	"notes/internal/note"
+)
+
+type Id = note.UserId
+
+// User is an account, the zero user, with an Id of 0, stands for no
+// account
+// Only the hash of the password is kept, see HashPassword.
+type User struct {
+	Id           Id
+	Name         string
+	PasswordHash string `json:"-"`
+	Created      time.Time
+	// Totp is the second factor of the user, when they enrolled one
+	Totp Totp `json:"-"`
+}
@@ -0,0 +1,312 @@
+// Package vault mirrors a folder of Markdown files into the notes, so an
+// editor such as Obsidian and the server can share a vault. The file
+// notebook/name.md is the note name of the notebook, and its content is
+// the whole file.
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"notes/internal/note"
+	"notes/internal/usecase"
+)
+
+// Config of the vault
+type Config struct {
+	// Dir is the folder of the vault
+	Dir string
+	// Interval between two scans of the folder, 2s by default
+	Interval time.Duration
+	// Write mirrors the changes the other applications make to the notes
+	// back to the files, the notes without a file are written out when
+	// the watcher starts
+	Write bool
+}
+
+// actor is who the changes coming from the files are attributed to
+const actor = "vault"
+
+// file is what the watcher knows of a file of the vault, a file which
+// is not as known has changed
+type file struct {
+	id       note.Id
+	modified time.Time
+	size     int64
+}
+
+// Watcher scans the vault for the files added, changed or deleted since
+// the last scan, and creates, updates or deletes their notes
+// When it starts, the notes are matched to the files by notebook and
+// name, and the files replace the contents of their notes. A note whose
+// file disappears while the watcher runs is deleted, an emptied file
+// leaves its note as it was. The hidden files and folders, such as
+// .obsidian, are left out.
+type Watcher struct {
+	usecase usecase.Usecase
+	config  Config
+	errors  func(error)
+	mutex   *sync.Mutex
+	// files by their path in the vault
+	files  map[string]file
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// New watches the vault of config, the failures are given to errors
+// The watcher must be subscribed to the note events for the changes to
+// be written back to the files.
+func New(u usecase.Usecase, config Config, errors func(error)) Watcher {
+	if config.Interval <= 0 {
+		config.Interval = 2 * time.Second
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return Watcher{
+		usecase: u,
+		config:  config,
+		errors:  errors,
+		mutex:   &sync.Mutex{},
+		files:   map[string]file{},
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Run scans the vault until Stop is called
+func (w Watcher) Run() {
+	w.scan()
+	if w.config.Write {
+		w.writeOut()
+	}
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		w.scan()
+	}
+}
+
+// Stop ends Run
+func (w Watcher) Stop() {
+	w.cancel()
+}
+
+func (w Watcher) context() usecase.Context {
+	return usecase.Context{Actor: actor}
+}
+
+func (w Watcher) fail(err error) {
+	if w.errors != nil {
+		w.errors(fmt.Errorf("vault: %w", err))
+	}
+}
+
+// hidden tells whether a file or folder is hidden, as the settings and
+// the trash of Obsidian
+func hidden(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
+
+// scan mirrors the files changed since the last scan into the notes
+func (w Watcher) scan() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	seen := map[string]bool{}
+	err := filepath.WalkDir(w.config.Dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == w.config.Dir {
+			return nil
+		}
+		if entry.IsDir() {
+			if hidden(entry.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if hidden(entry.Name()) || filepath.Ext(entry.Name()) != ".md" {
+			return nil
+		}
+		rel, err := filepath.Rel(w.config.Dir, path)
+		if err != nil {
+			return err
+		}
+		seen[rel] = true
+		info, err := entry.Info()
+		if err != nil {
+			w.fail(err)
+			return nil
+		}
+		known, ok := w.files[rel]
+		if ok && known.modified.Equal(info.ModTime()) && known.size == info.Size() {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			w.fail(err)
+			return nil
+		}
+		id, err := w.mirror(rel, known.id, string(content))
+		if err != nil {
+			w.fail(fmt.Errorf("%s: %w", rel, err))
+			return nil
+		}
+		w.files[rel] = file{id: id, modified: info.ModTime(), size: info.Size()}
+		return nil
+	})
+	// a vault which can't be read is not a vault without files
+	if err != nil {
+		w.fail(err)
+		return
+	}
+	for rel, f := range w.files {
+		if seen[rel] {
+			continue
+		}
+		delete(w.files, rel)
+		_, err := w.usecase.Delete.Execute(usecase.DeleteMessage{Context: w.context(), Id: f.id})
+		if err != nil {
+			w.fail(fmt.Errorf("%s: %w", rel, err))
+		}
+	}
+}
+
+// mirror updates the note of a file, or creates it, the note is found by
+// its notebook and name when the file is not known yet
+func (w Watcher) mirror(rel string, id note.Id, content string) (note.Id, error) {
+	notebook, name := notebookOf(rel), nameOf(rel)
+	if id == 0 {
+		result, err := w.usecase.Export.Execute(usecase.ExportMessage{Context: w.context(), Notebook: notebook})
+		if err != nil {
+			return 0, err
+		}
+		for n := range result.Notes {
+			if n.Notebook == notebook && fileName(n.Name) == name {
+				id = n.Id
+				if n.Content == content {
+					return id, nil
+				}
+				break
+			}
+		}
+	}
+	if id != 0 {
+		_, err := w.usecase.Update.Execute(usecase.UpdateMessage{Context: w.context(), Id: id, Content: content})
+		if err == nil || !errors.Is(err, note.ErrNotFound) {
+			return id, err
+		}
+	}
+	result, err := w.usecase.Create.Execute(usecase.CreateMessage{Context: w.context(), Name: name, Content: content, Notebook: notebook})
+	if err != nil {
+		return 0, err
+	}
+	return result.Note.Id, nil
+}
+
+// writeOut writes the notes without a file to the vault
+func (w Watcher) writeOut() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	mirrored := map[note.Id]bool{}
+	for _, f := range w.files {
+		mirrored[f.id] = true
+	}
+	result, err := w.usecase.Export.Execute(usecase.ExportMessage{Context: w.context()})
+	if err != nil {
+		w.fail(err)
+		return
+	}
+	for n := range result.Notes {
+		if n.Owner == 0 && !mirrored[n.Id] {
+			w.write(n)
+		}
+	}
+}
+
+// Notify writes the changes of the notes back to their files, but those
+// coming from the files
+// Only the notes without an owner are mirrored, the vault has no account.
+func (w Watcher) Notify(e note.Event) {
+	if !w.config.Write || e.Actor == actor || e.Note.Owner != 0 {
+		return
+	}
+	switch e.Kind {
+	case note.Created, note.Updated, note.Renamed, note.Moved, note.Restored, note.Deleted:
+	default:
+		return
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for rel, f := range w.files {
+		if f.id != e.Note.Id || (e.Kind != note.Deleted && rel == pathOf(e.Note)) {
+			continue
+		}
+		delete(w.files, rel)
+		err := os.Remove(filepath.Join(w.config.Dir, rel))
+		if err != nil && !os.IsNotExist(err) {
+			w.fail(err)
+		}
+	}
+	if e.Kind != note.Deleted {
+		w.write(e.Note)
+	}
+}
+
+// write writes a note to its file and remembers it, so the next scan
+// doesn't take it for a change
+func (w Watcher) write(n note.Note) {
+	rel := pathOf(n)
+	path := filepath.Join(w.config.Dir, rel)
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		w.fail(err)
+		return
+	}
+	err = os.WriteFile(path, []byte(n.Content), 0o644)
+	if err != nil {
+		w.fail(err)
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		w.fail(err)
+		return
+	}
+	w.files[rel] = file{id: n.Id, modified: info.ModTime(), size: info.Size()}
+}
+
+// pathOf is the file of a note in the vault
+func pathOf(n note.Note) string {
+	return filepath.Join(filepath.FromSlash(n.Notebook), fileName(n.Name)+".md")
+}
+
+// fileName keeps the separators of the paths out of a name
+func fileName(name note.Name) string {
+	return strings.NewReplacer("/", "-", "\\", "-").Replace(name)
+}
+
+func nameOf(rel string) note.Name {
+	return strings.TrimSuffix(filepath.Base(rel), ".md")
+}
+
+// notebookOf is the folder of a file, the files at the root of the vault
+// are in no notebook
+func notebookOf(rel string) note.Notebook {
+	dir := filepath.ToSlash(filepath.Dir(rel))
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
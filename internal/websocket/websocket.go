@@ -0,0 +1,223 @@
+// Package websocket serves the WebSocket protocol, RFC 6455, as much of
+// it as the applications need: text messages, pings and closing.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxMessage is the size of the largest message read, in bytes
+const MaxMessage = 1 << 20
+
+// writeTimeout is how long a peer has to take a message, a stalled one
+// fails the write rather than blocking the writer
+const writeTimeout = 10 * time.Second
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// guid the accept key of the handshake is derived with
+const guid = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Conn is a WebSocket connection, one goroutine may read while others
+// write
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mutex  *sync.Mutex
+}
+
+// Upgrade switches a request to the WebSocket protocol, it answers the
+// request itself when it fails
+// Browsers send the cookies of the server whichever page opens the
+// connection, so only the pages of the server itself may open one.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	fail := func(status int, err error) (*Conn, error) {
+		http.Error(w, err.Error(), status)
+		return nil, err
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || !headerHas(r, "Connection", "upgrade") || !headerHas(r, "Upgrade", "websocket") || key == "" {
+		return fail(http.StatusBadRequest, errors.New("websocket: not a websocket handshake"))
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return fail(http.StatusUpgradeRequired, errors.New("websocket: unsupported version"))
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || !strings.EqualFold(u.Host, r.Host) {
+			return fail(http.StatusForbidden, fmt.Errorf("websocket: origin %s not allowed", origin))
+		}
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return fail(http.StatusInternalServerError, errors.New("websocket: the connection can't be taken over"))
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return fail(http.StatusInternalServerError, fmt.Errorf("websocket: %w", err))
+	}
+	sum := sha1.Sum([]byte(key + guid))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(sum[:]))
+	err = rw.Flush()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: %w", err)
+	}
+	// the http server's deadlines don't apply to a long lived connection
+	conn.SetDeadline(time.Time{})
+	return &Conn{conn: conn, reader: rw.Reader, mutex: &sync.Mutex{}}, nil
+}
+
+// headerHas tells whether a comma separated header holds a token,
+// regardless of case
+func headerHas(r *http.Request, name string, token string) bool {
+	for _, value := range r.Header.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Read returns the next message, answering the pings meanwhile, it
+// fails with io.EOF once the other side closed the connection
+func (c *Conn) Read() ([]byte, error) {
+	message := []byte{}
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case opPing:
+			err = c.write(opPong, payload)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			c.write(opClose, payload)
+			c.conn.Close()
+			return nil, io.EOF
+		}
+		message = append(message, payload...)
+		if len(message) > MaxMessage {
+			c.closeWith(1009, "message too big")
+			return nil, errors.New("websocket: message too big")
+		}
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+func (c *Conn) readFrame() (bool, byte, []byte, error) {
+	header := make([]byte, 2)
+	_, err := io.ReadFull(c.reader, header)
+	if err != nil {
+		return false, 0, nil, err
+	}
+	fin, opcode := header[0]&0x80 != 0, header[0]&0x0f
+	masked, length := header[1]&0x80 != 0, uint64(header[1]&0x7f)
+	switch opcode {
+	case opContinuation, opText, opBinary, opClose, opPing, opPong:
+	default:
+		c.closeWith(1002, "unknown opcode")
+		return false, 0, nil, fmt.Errorf("websocket: unknown opcode %d", opcode)
+	}
+	// clients must mask their frames
+	if !masked {
+		c.closeWith(1002, "unmasked frame")
+		return false, 0, nil, errors.New("websocket: unmasked frame")
+	}
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		_, err = io.ReadFull(c.reader, extended)
+		length = uint64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		_, err = io.ReadFull(c.reader, extended)
+		length = binary.BigEndian.Uint64(extended)
+	}
+	if err != nil {
+		return false, 0, nil, err
+	}
+	if length > MaxMessage {
+		c.closeWith(1009, "message too big")
+		return false, 0, nil, errors.New("websocket: message too big")
+	}
+	mask := make([]byte, 4)
+	_, err = io.ReadFull(c.reader, mask)
+	if err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, length)
+	_, err = io.ReadFull(c.reader, payload)
+	if err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// Write sends a text message
+func (c *Conn) Write(message []byte) error {
+	return c.write(opText, message)
+}
+
+func (c *Conn) write(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	frame = append(frame, payload...)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// Close closes the connection normally
+func (c *Conn) Close() error {
+	return c.closeWith(1000, "")
+}
+
+func (c *Conn) closeWith(code uint16, reason string) error {
+	c.write(opClose, append(binary.BigEndian.AppendUint16(nil, code), reason...))
+	return c.conn.Close()
+}
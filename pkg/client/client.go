@@ -0,0 +1,190 @@
+// Package client calls the HTTP API of a notes server from other Go
+// programs: the notes, their search, their export and their changes as
+// they happen.
+// The requests which may be sent twice, the reads, the updates and the
+// deletions, are tried again when the server can't be reached or is
+// unavailable.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client calls the server at its base url, it is safe for concurrent use
+type Client struct {
+	base     string
+	http     *http.Client
+	token    string
+	name     string
+	password string
+	retries  int
+	backoff  time.Duration
+}
+
+// Option configures a client
+type Option func(*Client)
+
+// WithToken authenticates the requests by a bearer token, one from
+// Login or an API token
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithBasicAuth authenticates the requests by the name and password of a
+// user
+func WithBasicAuth(name string, password string) Option {
+	return func(c *Client) { c.name, c.password = name, password }
+}
+
+// WithHTTPClient sends the requests with another http.Client, the
+// default one times out after 30 seconds
+func WithHTTPClient(h *http.Client) Option {
+	return func(c *Client) { c.http = h }
+}
+
+// WithRetries tries a request again up to retries times, waiting backoff
+// before the first try and twice as long before each next one
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) { c.retries, c.backoff = retries, backoff }
+}
+
+// New calls the server at base, such as http://example.org:8080, a
+// request is tried again twice by default
+func New(base string, options ...Option) Client {
+	c := Client{
+		base:    strings.TrimSuffix(base, "/"),
+		http:    &http.Client{Timeout: 30 * time.Second},
+		retries: 2,
+		backoff: 500 * time.Millisecond,
+	}
+	for _, o := range options {
+		o(&c)
+	}
+	return c
+}
+
+// Error is an answer of the server with an error status, the message is
+// the body of the answer
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%s %s: %s: %s", e.Method, e.Path, e.Status, e.Message)
+}
+
+// retried are the statuses of a server which may answer later
+var retried = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// idempotent requests are tried again, the others might be applied
+// twice
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// send sends a request with a body unless it is nil, a form for
+// url.Values and json otherwise, and returns the answer when its status
+// is a success, error statuses are errors
+func (c Client) send(method string, path string, body any, accept string) (*http.Response, error) {
+	var data []byte
+	contentType := "application/json"
+	switch body := body.(type) {
+	case nil:
+	case url.Values:
+		data = []byte(body.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	default:
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	wait := c.backoff
+	for try := 0; ; try++ {
+		resp, err := c.try(method, path, data, contentType, accept)
+		last := try >= c.retries || !idempotent(method)
+		switch {
+		case err != nil && !last:
+		case err != nil:
+			return nil, err
+		case retried[resp.StatusCode] && !last:
+			resp.Body.Close()
+		case resp.StatusCode >= 300:
+			defer resp.Body.Close()
+			message, _ := io.ReadAll(resp.Body)
+			return nil, Error{Method: method, Path: path, StatusCode: resp.StatusCode, Status: resp.Status, Message: strings.TrimSpace(string(message))}
+		default:
+			return resp, nil
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+func (c Client) try(method string, path string, data []byte, contentType string, accept string) (*http.Response, error) {
+	req, err := c.request(context.Background(), method, path, data, contentType, accept)
+	if err != nil {
+		return nil, err
+	}
+	return c.http.Do(req)
+}
+
+// request is authenticated by the token or else the name and password
+// of the client
+func (c Client) request(ctx context.Context, method string, path string, data []byte, contentType string, accept string) (*http.Request, error) {
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", accept)
+	switch {
+	case c.token != "":
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	case c.name != "":
+		req.SetBasicAuth(c.name, c.password)
+	}
+	return req, nil
+}
+
+// do sends a request and decodes the json answer into result unless it
+// is nil
+func (c Client) do(method string, path string, body any, result any) error {
+	resp, err := c.send(method, path, body, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,157 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Note as the API sends it
+type Note struct {
+	Id       int
+	Name     string
+	Content  string
+	Notebook string
+	Owner    int
+	Metadata map[string]string `json:",omitempty"`
+	Length   Length
+}
+
+// Length of the content of a note
+type Length struct {
+	Words          int
+	Characters     int
+	ReadingMinutes int
+}
+
+// Summary is a note of a listing, with a preview of its content
+type Summary struct {
+	Id       int
+	Name     string
+	Notebook string
+	Owner    int
+	Preview  string
+	Size     int
+	Length   Length
+	Color    string `json:",omitempty"`
+}
+
+// Snippet shows why a note matched a search, Matches are the byte ranges
+// of the matches in Text
+type Snippet struct {
+	NoteId  int
+	Field   string
+	Text    string
+	Matches [][2]int
+}
+
+// Change of a note in the audit log of the server
+type Change struct {
+	At     time.Time `json:"at"`
+	Actor  string    `json:"actor"`
+	Kind   string    `json:"kind"`
+	NoteId int       `json:"noteId"`
+	Before Note      `json:"before"`
+	After  Note      `json:"after"`
+}
+
+// noteRequest is the body of the requests writing a note
+type noteRequest struct {
+	Name     string `json:"name,omitempty"`
+	Content  string `json:"content,omitempty"`
+	Notebook string `json:"notebook,omitempty"`
+}
+
+func notePath(id int) string {
+	return "/notes/" + strconv.Itoa(id)
+}
+
+// Login is the client authenticated by the token the server gives a
+// user, code is the one time code of the users with two factors
+func (c Client) Login(name string, password string, code string) (Client, error) {
+	result := struct{ Token string }{}
+	err := c.do(http.MethodPost, "/login", url.Values{"name": {name}, "password": {password}, "code": {code}}, &result)
+	if err != nil {
+		return c, err
+	}
+	c.token = result.Token
+	return c, nil
+}
+
+func (c Client) Read(id int) (Note, error) {
+	result := struct{ Note Note }{}
+	err := c.do(http.MethodGet, notePath(id), nil, &result)
+	return result.Note, err
+}
+
+// ReadAll reads every note of the user with its content
+func (c Client) ReadAll() ([]Note, error) {
+	result := struct{ Notes []Note }{}
+	err := c.do(http.MethodGet, "/notes/?include=content", nil, &result)
+	return result.Notes, err
+}
+
+// List lists the notes of the user with a preview of their content
+func (c Client) List() ([]Summary, error) {
+	result := struct{ Notes []Summary }{}
+	err := c.do(http.MethodGet, "/notes/", nil, &result)
+	return result.Notes, err
+}
+
+// Create creates the note of the name, content and notebook of n
+func (c Client) Create(n Note) (Note, error) {
+	result := struct{ Note Note }{}
+	err := c.do(http.MethodPost, "/notes/", noteRequest{Name: n.Name, Content: n.Content, Notebook: n.Notebook}, &result)
+	return result.Note, err
+}
+
+// Update changes the name and the content of a note
+func (c Client) Update(id int, n Note) (Note, error) {
+	result := struct{ Note Note }{}
+	err := c.do(http.MethodPut, notePath(id), noteRequest{Name: n.Name, Content: n.Content}, &result)
+	return result.Note, err
+}
+
+func (c Client) Rename(id int, name string) (Note, error) {
+	result := struct{ Note Note }{}
+	err := c.do(http.MethodPost, notePath(id)+"/rename", noteRequest{Name: name}, &result)
+	return result.Note, err
+}
+
+func (c Client) Move(id int, notebook string) (Note, error) {
+	result := struct{ Note Note }{}
+	err := c.do(http.MethodPost, notePath(id)+"/move", noteRequest{Notebook: notebook}, &result)
+	return result.Note, err
+}
+
+func (c Client) Delete(id int) error {
+	return c.do(http.MethodDelete, notePath(id), nil, nil)
+}
+
+// Search finds the notes matching a query, in the syntax of the search
+// of the server, semantic searches them by meaning
+func (c Client) Search(query string, semantic bool) ([]Note, []Snippet, error) {
+	result := struct {
+		Notes    []Note
+		Snippets []Snippet
+	}{}
+	values := url.Values{"q": {query}}
+	if semantic {
+		values.Set("semantic", "true")
+	}
+	err := c.do(http.MethodGet, "/notes/search?"+values.Encode(), nil, &result)
+	return result.Notes, result.Snippets, err
+}
+
+// Changes lists the changes of a note in the audit log, the oldest
+// first, those of every note of the user for id 0
+func (c Client) Changes(id int) ([]Change, error) {
+	result := struct{ Entries []Change }{}
+	path := "/audit"
+	if id != 0 {
+		path += "?noteId=" + strconv.Itoa(id)
+	}
+	err := c.do(http.MethodGet, path, nil, &result)
+	return result.Entries, err
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Event is a change of a note sent by the server as it happens, Previous
+// is the note before the change, nil for a creation
+type Event struct {
+	Kind     string
+	Note     Note
+	Previous *Note
+	Actor    string
+	At       time.Time
+}
+
+// Export reads the notes of the user one at a time as the server sends
+// them, those matching query only unless it is empty
+// A failure ends the notes with its error.
+func (c Client) Export(query string) iter.Seq2[Note, error] {
+	return func(yield func(Note, error) bool) {
+		path := "/export"
+		if query != "" {
+			path += "?" + url.Values{"q": {query}}.Encode()
+		}
+		resp, err := c.send(http.MethodGet, path, nil, "application/x-ndjson")
+		if err != nil {
+			yield(Note{}, err)
+			return
+		}
+		defer resp.Body.Close()
+		decoder := json.NewDecoder(resp.Body)
+		for decoder.More() {
+			n := Note{}
+			err := decoder.Decode(&n)
+			if err != nil {
+				yield(Note{}, err)
+				return
+			}
+			if !yield(n, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Events reads the changes of the notes of the user as they happen,
+// until ctx is done or the server closes the stream, which ends the
+// events with an error
+// The server only sends them when it runs the HTTP mode, the events
+// published while the client isn't connected are missed.
+func (c Client) Events(ctx context.Context) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		req, err := c.request(ctx, http.MethodGet, "/events", nil, "", "text/event-stream")
+		if err != nil {
+			yield(Event{}, err)
+			return
+		}
+		// the stream outlives the timeout of the requests
+		h := *c.http
+		h.Timeout = 0
+		resp, err := h.Do(req)
+		if err != nil {
+			if ctx.Err() == nil {
+				yield(Event{}, err)
+			}
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			yield(Event{}, Error{Method: http.MethodGet, Path: "/events", StatusCode: resp.StatusCode, Status: resp.Status})
+			return
+		}
+		data := ""
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(nil, 16<<20)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if value, ok := strings.CutPrefix(line, "data:"); ok {
+				data += strings.TrimPrefix(value, " ")
+				continue
+			}
+			// the other fields and the comments are ignored, an empty line
+			// ends an event
+			if line != "" || data == "" {
+				continue
+			}
+			e := Event{}
+			err := json.Unmarshal([]byte(data), &e)
+			data = ""
+			if err != nil {
+				err = fmt.Errorf("event: %w", err)
+			}
+			if !yield(e, err) || err != nil {
+				return
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		err = scanner.Err()
+		if err == nil {
+			err = fmt.Errorf("GET /events: the server closed the stream")
+		}
+		yield(Event{}, err)
+	}
+}